@@ -24,6 +24,7 @@ func provideClientChain(in ClientChainIn) xhttpclient.Chain {
 	)
 
 	return xhttpclient.NewChain(
+		xhttpclient.TracePropagation{}.Then,
 		xmetricshttp.RoundTripperCounter{
 			Metric:   xmetrics.LabelledCounterVec{CounterVec: in.RequestCount},
 			Labeller: labeller,
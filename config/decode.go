@@ -0,0 +1,144 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+var headerType = reflect.TypeOf(http.Header{})
+
+// HeaderDecodeHook is a mapstructure decode hook that produces an http.Header from configuration.  Each
+// header may be expressed either as a single value or as a list of values, which makes the common case of
+// one value per header much more readable in configuration files:
+//
+//    header:
+//      X-Single: value
+//      X-Multiple: ["value1", "value2"]
+//
+// Keys are canonicalized via http.CanonicalHeaderKey.  This is important, as viper lowercases all keys.
+func HeaderDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != headerType || from.Kind() != reflect.Map {
+		return data, nil
+	}
+
+	var (
+		source = reflect.ValueOf(data)
+		header = make(http.Header, source.Len())
+	)
+
+	for _, key := range source.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		value := source.MapIndex(key)
+		if value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < value.Len(); i++ {
+				header.Add(name, fmt.Sprint(value.Index(i).Interface()))
+			}
+
+		case reflect.Invalid:
+			// a null value in configuration produces no header
+
+		default:
+			header.Add(name, fmt.Sprint(value.Interface()))
+		}
+	}
+
+	return header, nil
+}
+
+// TlsVersion is a TLS protocol version, such as tls.VersionTLS12.  Configuration fields of this type may be
+// written in a readable form that TlsVersionDecodeHook understands.
+type TlsVersion uint16
+
+var tlsVersionType = reflect.TypeOf(TlsVersion(0))
+
+// tlsVersions maps the human-readable TLS version numbers onto crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func unknownTlsVersion(value interface{}) error {
+	return fmt.Errorf("Unknown TLS version [%v].  Expected one of 1.0, 1.1, 1.2, or 1.3", value)
+}
+
+// TlsVersionDecodeHook is a mapstructure decode hook that produces TlsVersion values from readable strings.
+// Versions may be written as 1.2, TLS1.2, TLSv1.2, or TLS 1.2, in any case.  Numeric strings, e.g. 0x0303, are
+// also accepted.  Since YAML parses an unquoted 1.2 as a number, floating point values are accepted as well.
+// Any other string or floating point value results in an error.  Integers, e.g. 0x0303, are left to the decoder.
+//
+// This hook applies only to fields of type TlsVersion, so other uint16 fields are unaffected.
+func TlsVersionDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != tlsVersionType {
+		return data, nil
+	}
+
+	switch from.Kind() {
+	case reflect.Float32, reflect.Float64:
+		// an unquoted 1.0 is parsed as the number 1
+		version := strconv.FormatFloat(reflect.ValueOf(data).Float(), 'f', -1, from.Bits())
+		if !strings.Contains(version, ".") {
+			version += ".0"
+		}
+
+		if v, ok := tlsVersions[version]; ok {
+			return TlsVersion(v), nil
+		}
+
+		return nil, unknownTlsVersion(data)
+
+	case reflect.String:
+		// handled below
+
+	default:
+		return data, nil
+	}
+
+	value := strings.TrimSpace(reflect.ValueOf(data).String())
+	if len(value) == 0 {
+		return TlsVersion(0), nil
+	}
+
+	version := strings.ToLower(value)
+	version = strings.TrimPrefix(version, "tls")
+	version = strings.TrimPrefix(version, "v")
+	version = strings.TrimSpace(version)
+	if v, ok := tlsVersions[version]; ok {
+		return TlsVersion(v), nil
+	}
+
+	if v, err := strconv.ParseUint(value, 0, 16); err == nil {
+		return TlsVersion(v), nil
+	}
+
+	return nil, unknownTlsVersion(value)
+}
+
+// DefaultDecoderOptions returns the decoder options this package uses for every ViperUnmarshaller
+// created by ProvideViper.  In addition to spf13/viper's default hooks for durations and slices,
+// HeaderDecodeHook and TlsVersionDecodeHook are installed.
+func DefaultDecoderOptions() []viper.DecoderConfigOption {
+	return []viper.DecoderConfigOption{
+		viper.DecodeHook(
+			mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				HeaderDecodeHook,
+				TlsVersionDecodeHook,
+			),
+		),
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTlsVersionDecodeHook(t *testing.T) {
+	type settings struct {
+		Version TlsVersion
+		Other   uint16
+	}
+
+	t.Run("OtherUint16", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			out, err = ProvideViper(Yaml(`
+version: "1.2"
+other: "771"
+`))(ViperIn{})
+		)
+
+		require.NoError(err)
+
+		var s settings
+		require.NoError(out.Unmarshaller.Unmarshal(&s))
+		assert.Equal(TlsVersion(tls.VersionTLS12), s.Version)
+		assert.Equal(uint16(771), s.Other)
+	})
+
+	t.Run("OtherUint16NotAVersion", func(t *testing.T) {
+		var (
+			require = require.New(t)
+
+			out, err = ProvideViper(Yaml(`
+other: "1.2"
+`))(ViperIn{})
+		)
+
+		require.NoError(err)
+
+		// 1.2 is only meaningful for TLS versions, so a plain uint16 field must not be decoded as one
+		var s settings
+		require.Error(out.Unmarshaller.Unmarshal(&s))
+	})
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// ReloadListener is invoked each time the configuration is reloaded.  The viper instance has already re-read
+// its configuration, so listeners can simply re-read the keys they care about.
+type ReloadListener func(*viper.Viper)
+
+// Reloads is an event source for configuration reloads.  Components register listeners with Listen, and those
+// listeners are invoked in registration order whenever Reload is called.  When created via ProvideReloads, Reload
+// is called each time viper detects a change to its configuration file.
+//
+// Listeners are invoked synchronously on the goroutine that detected the change.  Listeners that do significant
+// work should hand off to another goroutine.
+//
+// Viper is not safe for concurrent use, so the file is re-read while holding a lock.  Where and Sources take that
+// same lock, which makes them safe to use while the configuration file is watched.
+type Reloads struct {
+	viper     *viper.Viper
+	viperLock sync.RWMutex
+	logger    log.Logger
+
+	lock      sync.Mutex
+	nextID    int
+	listeners map[int]ReloadListener
+	order     []int
+}
+
+// NewReloads creates a Reloads event source for the given viper instance.  The returned instance does not itself
+// watch for changes.  Use ProvideReloads or invoke Reload from some other mechanism, e.g. a signal handler.
+func NewReloads(v *viper.Viper) *Reloads {
+	return &Reloads{
+		viper:     v,
+		logger:    log.NewNopLogger(),
+		listeners: make(map[int]ReloadListener),
+	}
+}
+
+// Listen registers a listener for subsequent reloads.  The returned closure removes the listener and is idempotent.
+func (r *Reloads) Listen(l ReloadListener) func() {
+	r.lock.Lock()
+	id := r.nextID
+	r.nextID++
+	r.listeners[id] = l
+	r.order = append(r.order, id)
+	r.lock.Unlock()
+
+	return func() {
+		r.lock.Lock()
+		delete(r.listeners, id)
+		for i, v := range r.order {
+			if v == id {
+				r.order = append(r.order[:i], r.order[i+1:]...)
+				break
+			}
+		}
+
+		r.lock.Unlock()
+	}
+}
+
+// Reload notifies each registered listener that the configuration has changed
+func (r *Reloads) Reload() {
+	r.lock.Lock()
+	listeners := make([]ReloadListener, 0, len(r.order))
+	for _, id := range r.order {
+		listeners = append(listeners, r.listeners[id])
+	}
+
+	r.lock.Unlock()
+
+	for _, l := range listeners {
+		l(r.viper)
+	}
+}
+
+// readInConfig re-reads viper's configuration file, then notifies listeners.  If the file cannot be read, e.g.
+// because it was only partially written, the error is logged and listeners are not notified, since viper still
+// holds the previous configuration.
+func (r *Reloads) readInConfig() {
+	r.viperLock.Lock()
+	err := r.viper.ReadInConfig()
+	r.viperLock.Unlock()
+
+	if err != nil {
+		// these keys match xlog's, which this package cannot import
+		r.logger.Log(
+			level.Key(), level.ErrorValue(),
+			"msg", "unable to reload configuration",
+			"file", r.viper.ConfigFileUsed(),
+			"error", err,
+		)
+
+		return
+	}
+
+	r.Reload()
+}
+
+// Where is the same as the package-level Where, except that it never runs concurrently with a re-read
+// of the configuration file
+func (r *Reloads) Where(key string) Source {
+	r.viperLock.RLock()
+	defer r.viperLock.RUnlock()
+	return Where(r.viper, key)
+}
+
+// Sources is the same as the package-level Sources, except that it never runs concurrently with a re-read
+// of the configuration file
+func (r *Reloads) Sources() []KeySource {
+	r.viperLock.RLock()
+	defer r.viperLock.RUnlock()
+	return Sources(r.viper)
+}
+
+// watch re-reads the configuration file each time it changes, until the watcher is closed or stop is closed.
+// Like viper's own WatchConfig, the file's directory is watched, so that files which are replaced, e.g. by
+// updating the symlinks of a Kubernetes ConfigMap, are detected.
+func (r *Reloads) watch(watcher *fsnotify.Watcher, file string, stop <-chan struct{}) {
+	var (
+		configFile  = filepath.Clean(file)
+		realFile, _ = filepath.EvalSymlinks(file)
+	)
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			currentFile, _ := filepath.EvalSymlinks(file)
+			changed := filepath.Clean(event.Name) == configFile && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+			if changed || (len(currentFile) > 0 && currentFile != realFile) {
+				realFile = currentFile
+				select {
+				case <-stop:
+					return
+
+				default:
+					r.readInConfig()
+				}
+			}
+
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// ReloadsIn describes the dependencies for ProvideReloads
+type ReloadsIn struct {
+	fx.In
+
+	Viper     *viper.Viper
+	Lifecycle fx.Lifecycle
+
+	// Logger is the optional logger for configuration files that fail to reload.  If unset, such failures are
+	// not logged, although listeners are still not notified.
+	Logger log.Logger `optional:"true"`
+}
+
+// ProvideReloads is an uber/fx provider for a Reloads event source.  Once the application starts, its configuration
+// file is watched and each change triggers a reload.  Configuration that was not read from a file, e.g. via Json or
+// Yaml, cannot be watched, in which case Reload is only ever invoked by application code.
+//
+// Viper's own WatchConfig is not used, since it re-reads the file without any synchronization and cannot be stopped.
+// Instead, watching stops when the application stops, and no reloads are delivered afterward.
+func ProvideReloads(in ReloadsIn) *Reloads {
+	var (
+		r       = NewReloads(in.Viper)
+		watcher *fsnotify.Watcher
+		stop    = make(chan struct{})
+		done    = make(chan struct{})
+	)
+
+	if in.Logger != nil {
+		r.logger = in.Logger
+	}
+
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			file := in.Viper.ConfigFileUsed()
+			if len(file) == 0 {
+				close(done)
+				return nil
+			}
+
+			var err error
+			watcher, err = fsnotify.NewWatcher()
+			if err == nil {
+				err = watcher.Add(filepath.Dir(filepath.Clean(file)))
+			}
+
+			if err != nil {
+				if watcher != nil {
+					watcher.Close()
+				}
+
+				return err
+			}
+
+			go func() {
+				defer close(done)
+				r.watch(watcher, file, stop)
+			}()
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(stop)
+			if watcher != nil {
+				watcher.Close()
+			}
+
+			select {
+			case <-done:
+				return nil
+
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+
+	return r
+}
@@ -0,0 +1,336 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func testReloadsListen(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		v     = viper.New()
+		r     = NewReloads(v)
+		calls []string
+	)
+
+	listener := func(name string) ReloadListener {
+		return func(actual *viper.Viper) {
+			assert.Equal(v, actual)
+			calls = append(calls, name)
+		}
+	}
+
+	r.Reload()
+	assert.Empty(calls)
+
+	r.Listen(listener("first"))
+	cancelSecond := r.Listen(listener("second"))
+	r.Listen(listener("third"))
+
+	r.Reload()
+	assert.Equal([]string{"first", "second", "third"}, calls)
+
+	calls = nil
+	cancelSecond()
+	cancelSecond()
+	r.Reload()
+	assert.Equal([]string{"first", "third"}, calls)
+
+	calls = nil
+	r.Listen(listener("fourth"))
+	r.Reload()
+	assert.Equal([]string{"first", "third", "fourth"}, calls)
+}
+
+func testReloadsUnsubscribeDuringReload(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		r     = NewReloads(viper.New())
+		calls []string
+
+		cancelSecond func()
+	)
+
+	r.Listen(func(*viper.Viper) {
+		calls = append(calls, "first")
+		cancelSecond()
+	})
+
+	cancelSecond = r.Listen(func(*viper.Viper) {
+		calls = append(calls, "second")
+	})
+
+	// listeners are snapshotted before any are invoked
+	r.Reload()
+	assert.Equal([]string{"first", "second"}, calls)
+
+	calls = nil
+	r.Reload()
+	assert.Equal([]string{"first"}, calls)
+}
+
+func TestReloads(t *testing.T) {
+	t.Run("Listen", testReloadsListen)
+	t.Run("UnsubscribeDuringReload", testReloadsUnsubscribeDuringReload)
+}
+
+func testProvideReloadsNoFile(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		r   *Reloads
+		app = fxtest.New(t,
+			fx.Provide(
+				ProvideViper(Json(`{"key": "value"}`)),
+				ProvideReloads,
+			),
+			fx.Populate(&r),
+		)
+	)
+
+	require.NoError(app.Err())
+	app.RequireStart()
+
+	var value string
+	r.Listen(func(v *viper.Viper) {
+		value = v.GetString("key")
+	})
+
+	r.Reload()
+	assert.Equal("value", value)
+	app.RequireStop()
+}
+
+func testProvideReloadsWatch(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "config.json")
+	)
+
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": "first"}`), 0600))
+
+	var (
+		lock   sync.Mutex
+		values []string
+
+		r   *Reloads
+		app = fxtest.New(t,
+			fx.Provide(
+				func() (*viper.Viper, error) {
+					v := viper.New()
+					v.SetConfigFile(file)
+					return v, v.ReadInConfig()
+				},
+				ProvideReloads,
+			),
+			fx.Populate(&r),
+		)
+
+		reloaded = func() []string {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]string{}, values...)
+		}
+	)
+
+	require.NoError(app.Err())
+	r.Listen(func(v *viper.Viper) {
+		lock.Lock()
+		values = append(values, v.GetString("key"))
+		lock.Unlock()
+	})
+
+	app.RequireStart()
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": "second"}`), 0600))
+	assert.Eventually(
+		func() bool {
+			v := reloaded()
+			return len(v) > 0 && v[len(v)-1] == "second"
+		},
+		5*time.Second,
+		10*time.Millisecond,
+	)
+
+	app.RequireStop()
+	before := reloaded()
+
+	// watching stops along with the application, so changes no longer reach listeners
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": "third"}`), 0600))
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(before, reloaded())
+}
+
+func testProvideReloadsSources(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "config.json")
+	)
+
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": "first"}`), 0600))
+
+	var (
+		r   *Reloads
+		app = fxtest.New(t,
+			fx.Provide(
+				func() (*viper.Viper, error) {
+					v := viper.New()
+					v.SetConfigFile(file)
+					return v, v.ReadInConfig()
+				},
+				ProvideReloads,
+			),
+			fx.Populate(&r),
+		)
+
+		reloaded = make(chan struct{}, 1)
+	)
+
+	require.NoError(app.Err())
+	r.Listen(func(*viper.Viper) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+
+	app.RequireStart()
+	defer app.RequireStop()
+
+	// run with -race: reading sources while the file is re-read must not race
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+
+			default:
+				ioutil.WriteFile(file, []byte(fmt.Sprintf(`{"key": "value%d"}`, i)), 0600)
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}()
+
+	defer close(stop)
+	for i := 0; i < 20; i++ {
+		assert.Equal(SourceConfig, r.Where("key"))
+		assert.Equal([]KeySource{{Key: "key", Source: SourceConfig}}, r.Sources())
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		assert.Fail("no reload occurred")
+	}
+}
+
+func testProvideReloadsInvalidFile(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "config.json")
+	)
+
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": "first"}`), 0600))
+
+	var (
+		lock   sync.Mutex
+		output bytes.Buffer
+		values []string
+
+		r   *Reloads
+		app = fxtest.New(t,
+			fx.Provide(
+				func() (*viper.Viper, error) {
+					v := viper.New()
+					v.SetConfigFile(file)
+					return v, v.ReadInConfig()
+				},
+				func() log.Logger {
+					logger := log.NewJSONLogger(&output)
+					return log.LoggerFunc(func(keyvals ...interface{}) error {
+						lock.Lock()
+						defer lock.Unlock()
+						return logger.Log(keyvals...)
+					})
+				},
+				ProvideReloads,
+			),
+			fx.Populate(&r),
+		)
+
+		logged = func() string {
+			lock.Lock()
+			defer lock.Unlock()
+			return output.String()
+		}
+
+		reloaded = func() []string {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]string{}, values...)
+		}
+	)
+
+	require.NoError(app.Err())
+	r.Listen(func(v *viper.Viper) {
+		lock.Lock()
+		values = append(values, v.GetString("key"))
+		lock.Unlock()
+	})
+
+	app.RequireStart()
+	defer app.RequireStop()
+
+	// a file that cannot be parsed is logged, and listeners never see the stale configuration
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": `), 0600))
+	require.Eventually(
+		func() bool { return strings.Contains(logged(), "unable to reload configuration") },
+		5*time.Second,
+		10*time.Millisecond,
+	)
+
+	assert.Empty(reloaded())
+
+	require.NoError(ioutil.WriteFile(file, []byte(`{"key": "second"}`), 0600))
+	assert.Eventually(
+		func() bool {
+			v := reloaded()
+			return len(v) > 0 && v[len(v)-1] == "second"
+		},
+		5*time.Second,
+		10*time.Millisecond,
+	)
+}
+
+func TestProvideReloads(t *testing.T) {
+	t.Run("NoFile", testProvideReloadsNoFile)
+	t.Run("Watch", testProvideReloadsWatch)
+	t.Run("InvalidFile", testProvideReloadsInvalidFile)
+	t.Run("Sources", testProvideReloadsSources)
+}
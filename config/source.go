@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/spf13/viper"
+)
+
+// Source identifies the viper layer that supplied the effective value of a configuration key
+type Source string
+
+const (
+	// SourceNone indicates that the key has no value in any layer
+	SourceNone Source = ""
+
+	// SourceOverride indicates a value supplied via viper.Set
+	SourceOverride Source = "override"
+
+	// SourceFlag indicates a value supplied by a bound command-line flag that was explicitly set
+	SourceFlag Source = "flag"
+
+	// SourceEnv indicates a value supplied by an environment variable
+	SourceEnv Source = "env"
+
+	// SourceConfig indicates a value supplied by a configuration file or reader
+	SourceConfig Source = "config"
+
+	// SourceKeyValue indicates a value supplied by a remote key/value store
+	SourceKeyValue Source = "kvstore"
+
+	// SourceDefault indicates a value supplied via viper.SetDefault
+	SourceDefault Source = "default"
+
+	// SourceFlagDefault indicates the default value of a bound command-line flag that was not explicitly set
+	SourceFlagDefault Source = "flagDefault"
+)
+
+// KeySource describes where a single configuration key obtained its effective value
+type KeySource struct {
+	Key    string
+	Source Source
+}
+
+// viperField returns the value of an unexported field of a Viper instance.  Viper does not expose
+// its individual layers, so this is the only way to determine where a value came from.  If the field
+// does not exist, as could happen with a different version of viper, this function returns nil.
+// TestViperLayout pins the fields this package reads, so that a viper upgrade which changes them
+// fails that test rather than silently misreporting sources.  Nothing fails at compile time.
+//
+// Like any other read of a Viper instance, this must not run concurrently with a re-read of its
+// configuration.  See Reloads.Where.
+func viperField(v *viper.Viper, name string) interface{} {
+	f := reflect.ValueOf(v).Elem().FieldByName(name)
+	if !f.IsValid() {
+		return nil
+	}
+
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Interface()
+}
+
+// searchLayer checks if the given path has a value in one of viper's nested maps.  Like viper, keys
+// which themselves contain the delimiter are considered, with longer prefixes taking precedence.
+func searchLayer(m map[string]interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for i := len(path); i > 0; i-- {
+		next, ok := m[strings.Join(path[0:i], ".")]
+		if !ok {
+			continue
+		}
+
+		if i == len(path) {
+			return next != nil
+		}
+
+		switch nested := next.(type) {
+		case map[string]interface{}:
+			if searchLayer(nested, path[i:]) {
+				return true
+			}
+
+		case map[interface{}]interface{}:
+			converted := make(map[string]interface{}, len(nested))
+			for k, v := range nested {
+				if s, ok := k.(string); ok {
+					converted[strings.ToLower(s)] = v
+				}
+			}
+
+			if searchLayer(converted, path[i:]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lookupEnv mimics viper's own environment lookup, including any key replacer and the empty value policy
+func lookupEnv(v *viper.Viper, name string) bool {
+	if r, ok := viperField(v, "envKeyReplacer").(*strings.Replacer); ok && r != nil {
+		name = r.Replace(name)
+	}
+
+	value, ok := os.LookupEnv(name)
+	allowEmpty, _ := viperField(v, "allowEmptyEnv").(bool)
+	return ok && (allowEmpty || len(value) > 0)
+}
+
+// Where reports which layer supplied the effective value of a key, following viper's precedence:
+// overrides, changed flags, environment variables, configuration, key/value stores, defaults, and
+// finally unchanged flags.  This function is intended for troubleshooting only, e.g. determining why
+// a configuration value is not what was expected.
+//
+// Viper is not safe for concurrent use, so this function must not be called while viper re-reads its
+// configuration, e.g. via viper.WatchConfig.  When the configuration file is watched via ProvideReloads,
+// use Reloads.Where instead.
+func Where(v *viper.Viper, key string) Source {
+	if !v.IsSet(key) {
+		return SourceNone
+	}
+
+	key = strings.ToLower(key)
+	aliases, _ := viperField(v, "aliases").(map[string]string)
+	for {
+		realKey, ok := aliases[key]
+		if !ok {
+			break
+		}
+
+		key = realKey
+	}
+
+	path := strings.Split(key, ".")
+	if m, ok := viperField(v, "override").(map[string]interface{}); ok && searchLayer(m, path) {
+		return SourceOverride
+	}
+
+	pflags, _ := viperField(v, "pflags").(map[string]viper.FlagValue)
+	if flag, ok := pflags[key]; ok && flag.HasChanged() {
+		return SourceFlag
+	}
+
+	if automatic, _ := viperField(v, "automaticEnvApplied").(bool); automatic {
+		name := strings.ToUpper(key)
+		if prefix, _ := viperField(v, "envPrefix").(string); len(prefix) > 0 {
+			name = strings.ToUpper(prefix + "_" + key)
+		}
+
+		if lookupEnv(v, name) {
+			return SourceEnv
+		}
+	}
+
+	if env, ok := viperField(v, "env").(map[string]string); ok {
+		if name, ok := env[key]; ok && lookupEnv(v, name) {
+			return SourceEnv
+		}
+	}
+
+	if m, ok := viperField(v, "config").(map[string]interface{}); ok && searchLayer(m, path) {
+		return SourceConfig
+	}
+
+	if m, ok := viperField(v, "kvstore").(map[string]interface{}); ok && searchLayer(m, path) {
+		return SourceKeyValue
+	}
+
+	if m, ok := viperField(v, "defaults").(map[string]interface{}); ok && searchLayer(m, path) {
+		return SourceDefault
+	}
+
+	if _, ok := pflags[key]; ok {
+		return SourceFlagDefault
+	}
+
+	return SourceNone
+}
+
+// Sources reports the Source of every key known to viper, sorted by key.  Only keys and sources are
+// returned, so that the result can be safely logged or exposed without leaking secrets.  Like Where, this
+// function must not be called while viper re-reads its configuration.  See Reloads.Sources.
+func Sources(v *viper.Viper) []KeySource {
+	keys := v.AllKeys()
+	sort.Strings(keys)
+
+	sources := make([]KeySource, 0, len(keys))
+	for _, k := range keys {
+		sources = append(sources, KeySource{Key: k, Source: Where(v, k)})
+	}
+
+	return sources
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestViperLayout verifies the unexported viper fields that Where depends on.  If this test fails
+// after upgrading viper, Where and Sources must be updated before they can be trusted.
+func TestViperLayout(t *testing.T) {
+	expected := map[string]reflect.Type{
+		"override":            reflect.TypeOf(map[string]interface{}{}),
+		"config":              reflect.TypeOf(map[string]interface{}{}),
+		"kvstore":             reflect.TypeOf(map[string]interface{}{}),
+		"defaults":            reflect.TypeOf(map[string]interface{}{}),
+		"pflags":              reflect.TypeOf(map[string]viper.FlagValue{}),
+		"env":                 reflect.TypeOf(map[string]string{}),
+		"aliases":             reflect.TypeOf(map[string]string{}),
+		"envPrefix":           reflect.TypeOf(""),
+		"envKeyReplacer":      reflect.TypeOf((*strings.Replacer)(nil)),
+		"allowEmptyEnv":       reflect.TypeOf(false),
+		"automaticEnvApplied": reflect.TypeOf(false),
+	}
+
+	viperType := reflect.TypeOf(viper.Viper{})
+	for name, fieldType := range expected {
+		t.Run(name, func(t *testing.T) {
+			field, ok := viperType.FieldByName(name)
+			if assert.True(t, ok, "viper no longer has a field named %s", name) {
+				assert.Equal(t, fieldType, field.Type)
+			}
+		})
+	}
+}
+
+func testWhereNone(t *testing.T) {
+	v := viper.New()
+	assert.Equal(t, SourceNone, Where(v, "missing"))
+}
+
+func testWhereOverride(t *testing.T) {
+	v := viper.New()
+	v.SetDefault("key", "default")
+	v.Set("key", "override")
+	assert.Equal(t, SourceOverride, Where(v, "key"))
+}
+
+func testWhereFlag(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		v  = viper.New()
+		fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	)
+
+	fs.String("key", "flagDefault", "")
+	require.NoError(v.BindPFlag("key", fs.Lookup("key")))
+	assert.Equal(t, SourceFlagDefault, Where(v, "key"))
+
+	require.NoError(fs.Parse([]string{"--key=flag"}))
+	assert.Equal(t, SourceFlag, Where(v, "key"))
+
+	// a changed flag takes precedence over defaults
+	v.SetDefault("key", "default")
+	assert.Equal(t, SourceFlag, Where(v, "key"))
+}
+
+func testWhereFlagDefault(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		v  = viper.New()
+		fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	)
+
+	fs.String("key", "flagDefault", "")
+	require.NoError(v.BindPFlag("key", fs.Lookup("key")))
+	v.SetDefault("key", "default")
+	assert.Equal(t, SourceDefault, Where(v, "key"))
+}
+
+func testWhereEnv(t *testing.T) {
+	t.Run("Bound", func(t *testing.T) {
+		t.Setenv("THEMIS_SOURCE_TEST_BOUND", "env")
+
+		v := viper.New()
+		require.NoError(t, v.BindEnv("key", "THEMIS_SOURCE_TEST_BOUND"))
+		v.SetDefault("key", "default")
+		assert.Equal(t, SourceEnv, Where(v, "key"))
+	})
+
+	t.Run("Automatic", func(t *testing.T) {
+		t.Setenv("THEMIS_SOURCE_TEST_NESTED_KEY", "env")
+
+		v := viper.New()
+		v.SetEnvPrefix("themis_source_test")
+		v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+		v.AutomaticEnv()
+		v.SetDefault("nested.key", "default")
+		assert.Equal(t, SourceEnv, Where(v, "nested.key"))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Setenv("THEMIS_SOURCE_TEST_EMPTY", "")
+
+		v := viper.New()
+		require.NoError(t, v.BindEnv("key", "THEMIS_SOURCE_TEST_EMPTY"))
+		v.SetDefault("key", "default")
+		assert.Equal(t, SourceDefault, Where(v, "key"))
+
+		v.AllowEmptyEnv(true)
+		assert.Equal(t, SourceEnv, Where(v, "key"))
+	})
+}
+
+func testWhereConfig(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		v = viper.New()
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(strings.NewReader(`{"nested": {"key": "config"}, "Mixed": "config"}`)))
+	v.SetDefault("nested.key", "default")
+	v.SetDefault("nested.other", "default")
+
+	assert.Equal(t, SourceConfig, Where(v, "nested.key"))
+	assert.Equal(t, SourceConfig, Where(v, "NESTED.KEY"))
+	assert.Equal(t, SourceConfig, Where(v, "mixed"))
+	assert.Equal(t, SourceDefault, Where(v, "nested.other"))
+}
+
+func testWhereKeyValue(t *testing.T) {
+	v := viper.New()
+	v.SetDefault("key", "default")
+
+	// populating a remote store requires a provider, so the layer is written directly
+	kvstore, ok := viperField(v, "kvstore").(map[string]interface{})
+	require.True(t, ok)
+	kvstore["key"] = "kvstore"
+
+	assert.Equal(t, SourceKeyValue, Where(v, "key"))
+}
+
+func testWhereDefault(t *testing.T) {
+	v := viper.New()
+	v.SetDefault("nested.key", "default")
+	assert.Equal(t, SourceDefault, Where(v, "nested.key"))
+}
+
+func testWhereAlias(t *testing.T) {
+	v := viper.New()
+	v.RegisterAlias("alias", "key")
+	v.Set("key", "override")
+	assert.Equal(t, SourceOverride, Where(v, "alias"))
+}
+
+func TestWhere(t *testing.T) {
+	t.Run("None", testWhereNone)
+	t.Run("Override", testWhereOverride)
+	t.Run("Flag", testWhereFlag)
+	t.Run("FlagDefault", testWhereFlagDefault)
+	t.Run("Env", testWhereEnv)
+	t.Run("Config", testWhereConfig)
+	t.Run("KeyValue", testWhereKeyValue)
+	t.Run("Default", testWhereDefault)
+	t.Run("Alias", testWhereAlias)
+}
+
+func TestSources(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v = viper.New()
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(strings.NewReader(`{"b": "config"}`)))
+	v.SetDefault("c", "default")
+	v.Set("a", "override")
+
+	assert.Equal(
+		[]KeySource{
+			{Key: "a", Source: SourceOverride},
+			{Key: "b", Source: SourceConfig},
+			{Key: "c", Source: SourceDefault},
+		},
+		Sources(v),
+	)
+}
@@ -69,3 +69,29 @@ func (mke missingKeyError) Error() string {
 func NewMissingKeyError(k string) MissingKeyError {
 	return missingKeyError{k: k}
 }
+
+// UnmarshalRequired unmarshals the given configuration key into an object, normally a pointer to struct.
+// Unlike UnmarshalKey, if the key is not present in the configuration, a MissingKeyError is returned
+// rather than silently unmarshalling a zero value.
+func UnmarshalRequired(u KeyUnmarshaller, k string, v interface{}) error {
+	if !u.IsSet(k) {
+		return NewMissingKeyError(k)
+	}
+
+	return u.UnmarshalKey(k, v)
+}
+
+// Sub safely extracts a configuration subtree from a Viper instance.  Viper.Sub returns nil when the key
+// does not exist, which is easy to miss and typically results in zero-valued configuration.  This function
+// returns a MissingKeyError in that case instead.
+//
+// Note that the returned Viper instance does not share decoder options with its parent.  Prefer
+// UnmarshalRequired when the goal is simply to unmarshal the subtree.
+func Sub(v *viper.Viper, k string) (*viper.Viper, error) {
+	sub := v.Sub(k)
+	if sub == nil {
+		return nil, NewMissingKeyError(k)
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testServerConfig struct {
+	Address string
+}
+
+func newTestViper(t *testing.T) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("json")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`{"servers": {"main": {"address": ":8080"}}}`)))
+	return v
+}
+
+func testUnmarshalRequiredPresent(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		target testServerConfig
+	)
+
+	require.NoError(UnmarshalRequired(ViperUnmarshaller{Viper: newTestViper(t)}, "servers.main", &target))
+	assert.Equal(":8080", target.Address)
+}
+
+func testUnmarshalRequiredMissing(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		target = testServerConfig{Address: "unchanged"}
+	)
+
+	err := UnmarshalRequired(ViperUnmarshaller{Viper: newTestViper(t)}, "servers.missing", &target)
+	require.Error(err)
+
+	mke, ok := err.(MissingKeyError)
+	require.True(ok)
+	assert.Equal("servers.missing", mke.Key())
+	assert.Contains(err.Error(), "servers.missing")
+	assert.Equal("unchanged", target.Address)
+}
+
+func TestUnmarshalRequired(t *testing.T) {
+	t.Run("Present", testUnmarshalRequiredPresent)
+	t.Run("Missing", testUnmarshalRequiredMissing)
+}
+
+func testSubPresent(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		target testServerConfig
+	)
+
+	sub, err := Sub(newTestViper(t), "servers.main")
+	require.NoError(err)
+	require.NotNil(sub)
+	require.NoError(sub.Unmarshal(&target))
+	assert.Equal(":8080", target.Address)
+}
+
+func testSubMissing(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	sub, err := Sub(newTestViper(t), "servers.missing")
+	assert.Nil(sub)
+	require.Error(err)
+
+	mke, ok := err.(MissingKeyError)
+	require.True(ok)
+	assert.Equal("servers.missing", mke.Key())
+}
+
+func TestSub(t *testing.T) {
+	t.Run("Present", testSubPresent)
+	t.Run("Missing", testSubMissing)
+}
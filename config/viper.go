@@ -29,6 +29,8 @@ type ViperIn struct {
 	// If supplied, this slice is used to create the Unmarshaller component.
 	//
 	// Note that spf13/viper provides a default set of options.  See https://godoc.org/github.com/spf13/viper#DecoderConfigOption
+	// This package appends these options to DefaultDecoderOptions, so any option that replaces the decode hook
+	// will override the hooks installed by this package.
 	DecoderOptions []viper.DecoderConfigOption `optional:"true"`
 }
 
@@ -62,7 +64,7 @@ func ProvideViper(builders ...ViperBuilder) func(ViperIn) (ViperOut, error) {
 
 		return ViperOut{
 			Viper:        viper,
-			Unmarshaller: ViperUnmarshaller{Viper: viper, Options: in.DecoderOptions},
+			Unmarshaller: ViperUnmarshaller{Viper: viper, Options: append(DefaultDecoderOptions(), in.DecoderOptions...)},
 		}, nil
 	}
 }
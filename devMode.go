@@ -38,6 +38,7 @@ servers:
   health:
     address: :8084
     disableHTTPKeepAlives: true
+    serverInfo: true
     header:
       X-Midt-Server:
         - issuer
@@ -22,6 +22,8 @@ import (
 	"time"
 
 	"github.com/InVisionApp/go-health"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/xmidt-org/themis/config"
 	"github.com/xmidt-org/themis/key"
@@ -92,6 +94,14 @@ func setupViper(in config.ViperIn, v *viper.Viper) (err error) {
 	return nil
 }
 
+// logConfigSources emits, at debug level, the source of each configuration key's effective value.
+// Values are deliberately omitted, as they may contain secrets.
+func logConfigSources(l log.Logger, v *viper.Viper) {
+	for _, ks := range config.Sources(v) {
+		level.Debug(l).Log(xlog.MessageKey(), "configuration source", "key", ks.Key, "source", ks.Source)
+	}
+}
+
 func main() {
 	app := fx.New(
 		xlog.Logger(),
@@ -108,6 +118,13 @@ func main() {
 			xmetricshttp.Unmarshal("prometheus", promhttp.HandlerOpts{}),
 			provideClientChain,
 			provideServerChainFactory,
+			provideServerConnStateFactory,
+			provideServerConcurrencyMetricsFactory,
+			provideServerHandshakeWaitFactory,
+			xhttpserver.ProvideShutdownSequence,
+			xhttpserver.ProvideGate,
+			xhttpserver.ProvideTlsPolicies,
+			xhttpserver.ProvideShutdownSignal,
 			xhttpclient.Unmarshal{Key: "client"}.Provide,
 			xhttpserver.Unmarshal{Key: "servers.key", Optional: true}.Annotated(),
 			xhttpserver.Unmarshal{Key: "servers.issuer", Optional: true}.Annotated(),
@@ -116,6 +133,7 @@ func main() {
 			xhttpserver.Unmarshal{Key: "servers.health", Optional: true}.Annotated(),
 		),
 		fx.Invoke(
+			logConfigSources,
 			xhealth.ApplyChecks(
 				&health.Config{
 					Name:     applicationName,
@@ -132,7 +150,17 @@ func main() {
 			BuildClaimsRoutes,
 			BuildMetricsRoutes,
 			BuildHealthRoutes,
+			BuildInfoRoutes,
 			CheckServerRequirements,
+
+			// These hooks must follow every server's hooks, and must stay in this order.  OnStart hooks run
+			// in order:  the startup gate opens once every server is listening, then readiness is reported.
+			// OnStop hooks run in reverse:  readiness is withdrawn, then in-flight requests are signaled to
+			// stop, and only then do the servers drain, in the order given by their shutdownOrder.
+			xhttpserver.StopInSequence,
+			xhttpserver.OpenOnStart,
+			xhttpserver.CancelOnStop,
+			xhealth.ReadyOnStart,
 		),
 	)
 
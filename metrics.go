@@ -39,6 +39,44 @@ func provideMetrics() fx.Option {
 			},
 			ServerLabel,
 		),
+		xmetrics.ProvideGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "server_limiter_concurrent_requests",
+				Help: "tracks the current number of requests executing within a server's concurrency limit",
+			},
+			ServerLabel,
+		),
+		xmetrics.ProvideGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "server_limiter_queued_requests",
+				Help: "tracks the current number of requests waiting for a server's concurrency limit",
+			},
+			ServerLabel,
+		),
+		xmetrics.ProvideHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "server_connection_lifetime_ms",
+				Help: "tracks how long incoming connections remain open in ms",
+			},
+			xmetricshttp.DefaultTransportLabel,
+			ServerLabel,
+		),
+		xmetrics.ProvideHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "server_connection_requests",
+				Help:    "tracks the number of requests served over each incoming connection",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+			},
+			xmetricshttp.DefaultTransportLabel,
+			ServerLabel,
+		),
+		xmetrics.ProvideHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "server_tls_handshake_wait_ms",
+				Help: "tracks how long new TLS connections wait for a handshake slot in ms",
+			},
+			ServerLabel,
+		),
 		xmetrics.ProvideCounterVec(
 			prometheus.CounterOpts{
 				Name: "client_request_count",
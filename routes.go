@@ -2,6 +2,8 @@ package main
 
 import (
 	"errors"
+	"net"
+	"net/http"
 
 	"github.com/xmidt-org/themis/key"
 	"github.com/xmidt-org/themis/token"
@@ -68,6 +70,86 @@ func provideServerChainFactory(in ServerChainIn) xhttpserver.ChainFactory {
 	})
 }
 
+type ServerConnStateIn struct {
+	fx.In
+
+	ConnectionLifetime *prometheus.HistogramVec `name:"server_connection_lifetime_ms"`
+	ConnectionRequests *prometheus.HistogramVec `name:"server_connection_requests"`
+}
+
+func provideServerConnStateFactory(in ServerConnStateIn) xhttpserver.ConnStateFactory {
+	return xhttpserver.ConnStateFactoryFunc(func(name string, o xhttpserver.Options) (func(net.Conn, http.ConnState), error) {
+		curryLabel := prometheus.Labels{
+			ServerLabel: name,
+		}
+
+		connectionLifetime, err := in.ConnectionLifetime.CurryWith(curryLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		connectionRequests, err := in.ConnectionRequests.CurryWith(curryLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		return xmetricshttp.ConnectionMetrics{
+			Lifetime: xmetrics.LabelledObserverVec{ObserverVec: connectionLifetime},
+			Requests: xmetrics.LabelledObserverVec{ObserverVec: connectionRequests},
+		}.NewConnState(), nil
+	})
+}
+
+type ServerConcurrencyMetricsIn struct {
+	fx.In
+
+	Concurrent *prometheus.GaugeVec `name:"server_limiter_concurrent_requests"`
+	Queued     *prometheus.GaugeVec `name:"server_limiter_queued_requests"`
+}
+
+func provideServerConcurrencyMetricsFactory(in ServerConcurrencyMetricsIn) xhttpserver.ConcurrencyMetricsFactory {
+	return xhttpserver.ConcurrencyMetricsFactoryFunc(func(name string, o xhttpserver.Options) (xhttpserver.ConcurrencyMetrics, error) {
+		curryLabel := prometheus.Labels{
+			ServerLabel: name,
+		}
+
+		concurrent, err := in.Concurrent.CurryWith(curryLabel)
+		if err != nil {
+			return xhttpserver.ConcurrencyMetrics{}, err
+		}
+
+		queued, err := in.Queued.CurryWith(curryLabel)
+		if err != nil {
+			return xhttpserver.ConcurrencyMetrics{}, err
+		}
+
+		return xhttpserver.ConcurrencyMetrics{
+			Concurrent: xmetrics.LabelledGaugeVec{GaugeVec: concurrent},
+			Queued:     xmetrics.LabelledGaugeVec{GaugeVec: queued},
+		}, nil
+	})
+}
+
+type ServerHandshakeWaitIn struct {
+	fx.In
+
+	HandshakeWait *prometheus.HistogramVec `name:"server_tls_handshake_wait_ms"`
+}
+
+func provideServerHandshakeWaitFactory(in ServerHandshakeWaitIn) xhttpserver.HandshakeWaitFactory {
+	return xhttpserver.HandshakeWaitFactoryFunc(func(name string, o xhttpserver.Options) (xmetrics.Observer, error) {
+		handshakeWait, err := in.HandshakeWait.CurryWith(prometheus.Labels{
+			ServerLabel: name,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return xmetrics.LabelledObserverVec{ObserverVec: handshakeWait}, nil
+	})
+}
+
 type KeyRoutesIn struct {
 	fx.In
 	Router  *mux.Router `name:"servers.key"`
@@ -151,12 +233,52 @@ func BuildMetricsRoutes(in MetricsRoutesIn) {
 
 type HealthRoutesIn struct {
 	fx.In
-	Router  *mux.Router `name:"servers.health"`
-	Handler xhealth.Handler
+	Router           *mux.Router `name:"servers.health"`
+	Handler          xhealth.Handler
+	LivenessHandler  xhealth.LivenessHandler
+	ReadinessHandler xhealth.ReadinessHandler
+	Routes           xhealth.Routes
 }
 
 func BuildHealthRoutes(in HealthRoutesIn) {
 	if in.Router != nil && in.Handler != nil {
-		in.Router.Handle("/health", in.Handler).Methods("GET")
+		in.Router.Handle(in.Routes.Path, in.Handler).Methods(in.Routes.Methods...)
 	}
+
+	if in.Router != nil && in.LivenessHandler != nil && len(in.Routes.LivenessPath) > 0 {
+		in.Router.Handle(in.Routes.LivenessPath, in.LivenessHandler).Methods(in.Routes.Methods...)
+	}
+
+	if in.Router != nil && in.ReadinessHandler != nil && len(in.Routes.ReadinessPath) > 0 {
+		in.Router.Handle(in.Routes.ReadinessPath, in.ReadinessHandler).Methods(in.Routes.Methods...)
+	}
+}
+
+type InfoRoutesIn struct {
+	fx.In
+	Router *mux.Router `name:"servers.health"`
+}
+
+// BuildInfoRoutes mounts the build information endpoint on the health server, if one is configured
+func BuildInfoRoutes(in InfoRoutesIn) {
+	if in.Router != nil {
+		in.Router.Handle(
+			xhttpserver.DefaultInfoPath,
+			xhttpserver.NewInfoHandler(xhttpserver.BuildInfo{
+				Version:   definedOrEmpty(Version),
+				GitCommit: definedOrEmpty(GitCommit),
+				BuildTime: definedOrEmpty(BuildTime),
+			}),
+		).Methods("GET")
+	}
+}
+
+// definedOrEmpty returns the empty string for build values that were not set via -ldflags, so that
+// they can be filled in from the binary's embedded build information
+func definedOrEmpty(v string) string {
+	if v == "undefined" {
+		return ""
+	}
+
+	return v
 }
@@ -9,6 +9,22 @@ import (
 
 type Handler http.Handler
 
+// LivenessHandler reports the health status of the application regardless of readiness.  This handler
+// stays healthy while the application drains during shutdown, so that liveness probes do not kill it mid-drain.
+type LivenessHandler http.Handler
+
+// ReadinessHandler reports whether the application should receive traffic.  This handler responds with
+// http.StatusServiceUnavailable whenever the application's Readiness is not ready, which includes the time
+// between the start of shutdown and the end of the drain, or a fatal check has failed.  Otherwise, it reports
+// the health status.  See NewReadinessHandler.
+type ReadinessHandler http.Handler
+
 func NewHandler(h health.IHealth, custom map[string]interface{}) Handler {
 	return handlers.NewJSONHandlerFunc(h, custom)
 }
+
+// NewBriefHandler creates a Handler whose body is a plain "ok" or "failed", without the results of
+// individual checks
+func NewBriefHandler(h health.IHealth) Handler {
+	return handlers.NewBasicHandlerFunc(h)
+}
@@ -2,6 +2,7 @@ package xhealth
 
 import (
 	"context"
+	"time"
 
 	health "github.com/InVisionApp/go-health"
 	"github.com/go-kit/kit/log"
@@ -17,6 +18,35 @@ type Options struct {
 
 	// Custom is an optional map passed to NewHandler that is included in all responses to health checks
 	Custom map[string]interface{}
+
+	// Brief replaces the detailed JSON body, which includes the result of each check, with a plain "ok" or
+	// "failed".  Some probers cannot cope with large bodies.  The status code is the same either way, and
+	// Custom is ignored.
+	Brief bool
+
+	// HideReadinessDetails omits the status of each check from the body of the 503 returned when the application
+	// is not ready or a check has failed, which avoids exposing details of dependencies in production.  Brief
+	// implies this option.
+	HideReadinessDetails bool
+
+	// WarmupDuration holds readiness, but not liveness, for this long after the application starts, e.g. while
+	// caches are primed.  WarmupRequireSignal additionally holds readiness until application code calls
+	// SignalWarmupComplete.  Without it, that signal ends the warm-up early.  See Warmup.
+	WarmupDuration      time.Duration
+	WarmupRequireSignal bool
+
+	// Path, LivenessPath, and ReadinessPath are the URI paths at which the Handler, LivenessHandler, and
+	// ReadinessHandler are mounted.  If unset, DefaultPath, DefaultLivenessPath, and DefaultReadinessPath
+	// are used.  DisableProbes mounts only the Handler, for orchestrators that do not distinguish liveness
+	// from readiness.  See Routes.
+	Path          string
+	LivenessPath  string
+	ReadinessPath string
+	DisableProbes bool
+
+	// Methods are the HTTP methods accepted by the health handlers, e.g. GET and HEAD for probers that
+	// use HEAD.  If unset, DefaultMethods is used.
+	Methods []string
 }
 
 // New constructs an IHealth instance for the given environment.  If either the DisableLogging option field
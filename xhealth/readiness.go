@@ -0,0 +1,168 @@
+package xhealth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	health "github.com/InVisionApp/go-health"
+	"go.uber.org/fx"
+)
+
+const (
+	// NotReadyStatus is the status reported when the application's Readiness is not ready, e.g. during startup
+	// or shutdown
+	NotReadyStatus = "not ready"
+
+	// WarmingUpStatus is the status reported while the application's Warmup holds it not ready
+	WarmingUpStatus = "warming up"
+
+	// FailedStatus is the status reported when the application is ready but one or more fatal checks failed
+	FailedStatus = "failed"
+)
+
+// Readiness tracks whether an application has finished starting.  The health Handler responds with
+// http.StatusServiceUnavailable while the application is not ready.
+//
+// A Readiness starts out ready, so that applications which do not use ReadyOnStart are unaffected.
+type Readiness struct {
+	notReady int32
+
+	// Warmup optionally holds this Readiness not ready until the warm-up is complete.  It must be set
+	// before this Readiness is used.
+	Warmup *Warmup
+}
+
+// Ready tests if the application is ready to serve traffic
+func (r *Readiness) Ready() bool {
+	return atomic.LoadInt32(&r.notReady) == 0 && !r.warmingUp()
+}
+
+// warmingUp tests if this Readiness is held by an incomplete Warmup
+func (r *Readiness) warmingUp() bool {
+	return r.Warmup != nil && !r.Warmup.Complete()
+}
+
+// SetReady updates the ready state
+func (r *Readiness) SetReady(ready bool) {
+	if ready {
+		atomic.StoreInt32(&r.notReady, 0)
+	} else {
+		atomic.StoreInt32(&r.notReady, 1)
+	}
+}
+
+// Then is an Alice-style decorator that returns http.StatusServiceUnavailable until this Readiness is ready
+func (r *Readiness) Then(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !r.Ready() {
+			response.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+// CheckStatus is the outcome of a single health check, as reported in the body of an unavailable response
+type CheckStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Unavailable is the JSON body of the http.StatusServiceUnavailable responses produced by NewReadinessHandler
+type Unavailable struct {
+	// Status is one of NotReadyStatus, WarmingUpStatus, or FailedStatus
+	Status string `json:"status"`
+
+	// Checks are the latest results of every check, ordered by name.  This field is omitted when details
+	// are hidden.
+	Checks []CheckStatus `json:"checks,omitempty"`
+}
+
+// checkStatuses returns the latest results of the health checks, ordered by name
+func checkStatuses(h health.IHealth) []CheckStatus {
+	states, _, err := h.State()
+	if err != nil {
+		return nil
+	}
+
+	statuses := make([]CheckStatus, 0, len(states))
+	for name, state := range states {
+		statuses = append(statuses, CheckStatus{
+			Name:   name,
+			Status: state.Status,
+			Error:  state.Err,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+// NewReadinessHandler creates a ReadinessHandler that delegates to next while the application is ready and no fatal
+// check has failed.  Otherwise, it responds with http.StatusServiceUnavailable and an Unavailable body that lists
+// each check's status, so that it is obvious from a single request which dependency is unhealthy.  Since check
+// errors can reveal details of the infrastructure, hideDetails omits the checks from that body.
+func NewReadinessHandler(h health.IHealth, r *Readiness, next http.Handler, hideDetails bool) ReadinessHandler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var body Unavailable
+		switch {
+		case atomic.LoadInt32(&r.notReady) != 0:
+			body.Status = NotReadyStatus
+
+		case r.warmingUp():
+			body.Status = WarmingUpStatus
+
+		case h.Failed():
+			body.Status = FailedStatus
+
+		default:
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		if !hideDetails {
+			body.Checks = checkStatuses(h)
+		}
+
+		response.Header().Set("Content-Type", "application/json")
+		response.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(response).Encode(body)
+	})
+}
+
+// ReadyOnStartIn defines the dependencies for ReadyOnStart
+type ReadyOnStartIn struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Readiness *Readiness
+}
+
+// ReadyOnStart is an uber/fx Invoke function that marks the application as not ready until every OnStart
+// hook has completed.  Since uber/fx runs OnStart hooks in the order they were appended, this function
+// must be the last Invoke function for an application.
+//
+// Because uber/fx runs OnStop hooks in reverse order, the application is marked as not ready as soon as
+// shutdown begins, before any server starts to drain.  This gives load balancers, e.g. Kubernetes, a chance
+// to stop routing traffic before connections are closed.  A LivenessHandler is unaffected.
+func ReadyOnStart(in ReadyOnStartIn) {
+	in.Readiness.SetReady(false)
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			in.Readiness.SetReady(true)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			in.Readiness.SetReady(false)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,194 @@
+package xhealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	health "github.com/InVisionApp/go-health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHealth is a health.IHealth with canned check results
+type testHealth struct {
+	health.IHealth
+
+	states map[string]health.State
+	failed bool
+}
+
+func (th testHealth) State() (map[string]health.State, bool, error) {
+	return th.states, th.failed, nil
+}
+
+func (th testHealth) Failed() bool {
+	return th.failed
+}
+
+func newFailedHealth() testHealth {
+	return testHealth{
+		states: map[string]health.State{
+			"redis":    {Name: "redis", Status: "ok"},
+			"database": {Name: "database", Status: "failed", Err: "connection refused"},
+		},
+		failed: true,
+	}
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.Write([]byte("ok"))
+	})
+}
+
+func serveReadiness(t *testing.T, h http.Handler) (*httptest.ResponseRecorder, Unavailable) {
+	var (
+		response = httptest.NewRecorder()
+		body     Unavailable
+	)
+
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	if response.Code == http.StatusServiceUnavailable {
+		assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+	}
+
+	return response, body
+}
+
+func testReadinessThen(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		r = new(Readiness)
+		h = r.Then(okHandler())
+	)
+
+	response := httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusOK, response.Code)
+
+	r.SetReady(false)
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+}
+
+func testNewReadinessHandlerReady(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		h           = NewReadinessHandler(testHealth{}, new(Readiness), okHandler(), false)
+		response, _ = serveReadiness(t, h)
+	)
+
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("ok", response.Body.String())
+}
+
+func testNewReadinessHandlerNotReady(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		r = new(Readiness)
+		h = NewReadinessHandler(newFailedHealth(), r, okHandler(), false)
+	)
+
+	r.SetReady(false)
+	response, body := serveReadiness(t, h)
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal(NotReadyStatus, body.Status)
+	assert.Len(body.Checks, 2)
+}
+
+func testNewReadinessHandlerWarmingUp(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		w = NewWarmup(time.Hour, false)
+		h = NewReadinessHandler(testHealth{}, &Readiness{Warmup: w}, okHandler(), false)
+	)
+
+	w.OnStart()(context.Background())
+	response, body := serveReadiness(t, h)
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal(WarmingUpStatus, body.Status)
+
+	w.SignalWarmupComplete()
+	response, _ = serveReadiness(t, h)
+	assert.Equal(http.StatusOK, response.Code)
+}
+
+func testNewReadinessHandlerFailed(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		h              = NewReadinessHandler(newFailedHealth(), new(Readiness), okHandler(), false)
+		response, body = serveReadiness(t, h)
+	)
+
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal(
+		Unavailable{
+			Status: FailedStatus,
+			Checks: []CheckStatus{
+				{Name: "database", Status: "failed", Error: "connection refused"},
+				{Name: "redis", Status: "ok"},
+			},
+		},
+		body,
+	)
+}
+
+func testNewReadinessHandlerHideDetails(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		h              = NewReadinessHandler(newFailedHealth(), new(Readiness), okHandler(), true)
+		response, body = serveReadiness(t, h)
+	)
+
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal(Unavailable{Status: FailedStatus}, body)
+	assert.NotContains(response.Body.String(), "checks")
+	assert.NotContains(response.Body.String(), "connection refused")
+}
+
+// errorHealth is a health.IHealth whose state cannot be read
+type errorHealth struct {
+	testHealth
+}
+
+func (eh errorHealth) State() (map[string]health.State, bool, error) {
+	return nil, false, errors.New("expected")
+}
+
+func testNewReadinessHandlerStateError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		h              = NewReadinessHandler(errorHealth{testHealth{failed: true}}, new(Readiness), okHandler(), false)
+		response, body = serveReadiness(t, h)
+	)
+
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal(Unavailable{Status: FailedStatus}, body)
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("Then", testReadinessThen)
+}
+
+func TestNewReadinessHandler(t *testing.T) {
+	t.Run("Ready", testNewReadinessHandlerReady)
+	t.Run("NotReady", testNewReadinessHandlerNotReady)
+	t.Run("WarmingUp", testNewReadinessHandlerWarmingUp)
+	t.Run("Failed", testNewReadinessHandlerFailed)
+	t.Run("HideDetails", testNewReadinessHandlerHideDetails)
+	t.Run("StateError", testNewReadinessHandlerStateError)
+}
@@ -0,0 +1,74 @@
+package xhealth
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultPath is the URI path of the health Handler when none is configured
+	DefaultPath = "/health"
+
+	// DefaultLivenessPath is the URI path of the LivenessHandler when none is configured
+	DefaultLivenessPath = "/health/live"
+
+	// DefaultReadinessPath is the URI path of the ReadinessHandler when none is configured
+	DefaultReadinessPath = "/health/ready"
+)
+
+// DefaultMethods returns the HTTP methods accepted by the health handlers when none are configured
+func DefaultMethods() []string {
+	return []string{http.MethodGet}
+}
+
+// Routes describes where an application should mount the health handlers.  Unmarshal produces this
+// from Options, with defaults applied.
+type Routes struct {
+	// Path is the URI path of the Handler
+	Path string
+
+	// LivenessPath is the URI path of the LivenessHandler.  If empty, that handler should not be mounted.
+	LivenessPath string
+
+	// ReadinessPath is the URI path of the ReadinessHandler.  If empty, that handler should not be mounted.
+	ReadinessPath string
+
+	// Methods are the HTTP methods that each handler accepts
+	Methods []string
+}
+
+// NewRoutes applies defaults to the routing configuration in the given Options
+func NewRoutes(o Options) Routes {
+	r := Routes{
+		Path:          o.Path,
+		LivenessPath:  o.LivenessPath,
+		ReadinessPath: o.ReadinessPath,
+	}
+
+	if len(r.Path) == 0 {
+		r.Path = DefaultPath
+	}
+
+	if o.DisableProbes {
+		r.LivenessPath = ""
+		r.ReadinessPath = ""
+	} else {
+		if len(r.LivenessPath) == 0 {
+			r.LivenessPath = DefaultLivenessPath
+		}
+
+		if len(r.ReadinessPath) == 0 {
+			r.ReadinessPath = DefaultReadinessPath
+		}
+	}
+
+	for _, m := range o.Methods {
+		r.Methods = append(r.Methods, strings.ToUpper(m))
+	}
+
+	if len(r.Methods) == 0 {
+		r.Methods = DefaultMethods()
+	}
+
+	return r
+}
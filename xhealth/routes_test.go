@@ -0,0 +1,58 @@
+package xhealth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRoutes(t *testing.T) {
+	testData := []struct {
+		name     string
+		options  Options
+		expected Routes
+	}{
+		{
+			name: "Defaults",
+			expected: Routes{
+				Path:          DefaultPath,
+				LivenessPath:  DefaultLivenessPath,
+				ReadinessPath: DefaultReadinessPath,
+				Methods:       []string{http.MethodGet},
+			},
+		},
+		{
+			name: "Custom",
+			options: Options{
+				Path:          "/healthz",
+				LivenessPath:  "/livez",
+				ReadinessPath: "/readyz",
+				Methods:       []string{"get", "Head"},
+			},
+			expected: Routes{
+				Path:          "/healthz",
+				LivenessPath:  "/livez",
+				ReadinessPath: "/readyz",
+				Methods:       []string{http.MethodGet, http.MethodHead},
+			},
+		},
+		{
+			name: "DisableProbes",
+			options: Options{
+				LivenessPath:  "/livez",
+				DisableProbes: true,
+			},
+			expected: Routes{
+				Path:    DefaultPath,
+				Methods: []string{http.MethodGet},
+			},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			assert.Equal(t, record.expected, NewRoutes(record.options))
+		})
+	}
+}
@@ -33,14 +33,44 @@ type HealthIn struct {
 	// Configs is an optional slice of checks.  If both this field and Config are set, both
 	// fields are added.
 	Configs []*health.Config `optional:"true"`
+
+	// Checks are the checks contributed by other components via the healthchecks value group.
+	// This allows any component, e.g. a database client, to register its own check.  See CheckOut.
+	Checks []*health.Config `group:"healthchecks"`
+}
+
+// CheckOut is a convenient way for components to contribute a check to the health service
+// that is created by Unmarshal.  Constructors can embed or return this struct.
+type CheckOut struct {
+	fx.Out
+
+	Check *health.Config `group:"healthchecks"`
 }
 
 // HealthOut defines the components emitted by this package
 type HealthOut struct {
 	fx.Out
 
-	Health  health.IHealth
+	Health health.IHealth
+
+	// Handler reports the health status, gated by Readiness.  It behaves the same as ReadinessHandler.
 	Handler Handler
+
+	// LivenessHandler reports the health status, and is unaffected by Readiness
+	LivenessHandler LivenessHandler
+
+	// ReadinessHandler reports the health status, gated by Readiness
+	ReadinessHandler ReadinessHandler
+
+	// Readiness controls whether Handler and ReadinessHandler report the application as ready.  See ReadyOnStart.
+	Readiness *Readiness
+
+	// Warmup allows application code to signal that its warm-up is complete.  It only holds readiness when
+	// a WarmupDuration or WarmupRequireSignal is configured.
+	Warmup *Warmup
+
+	// Routes describes where the handlers should be mounted, as configured
+	Routes Routes
 }
 
 // Unmarshal returns an uber/fx provider that reads configuration from a Viper
@@ -69,14 +99,44 @@ func Unmarshal(configKey string) func(HealthIn) (HealthOut, error) {
 			}
 		}
 
+		if len(in.Checks) > 0 {
+			if err := h.AddChecks(in.Checks); err != nil {
+				return HealthOut{}, err
+			}
+		}
+
 		in.Lifecycle.Append(fx.Hook{
 			OnStart: OnStart(in.Logger, h),
 			OnStop:  OnStop(in.Logger, h),
 		})
 
+		var (
+			warmup    = NewWarmup(o.WarmupDuration, o.WarmupRequireSignal)
+			readiness = new(Readiness)
+		)
+
+		if o.WarmupDuration > 0 || o.WarmupRequireSignal {
+			readiness.Warmup = warmup
+			in.Lifecycle.Append(fx.Hook{
+				OnStart: warmup.OnStart(),
+			})
+		}
+
+		handler := NewHandler(h, o.Custom)
+		if o.Brief {
+			handler = NewBriefHandler(h)
+		}
+
+		ready := NewReadinessHandler(h, readiness, handler, o.HideReadinessDetails || o.Brief)
+
 		return HealthOut{
-			Health:  h,
-			Handler: NewHandler(h, o.Custom),
+			Health:           h,
+			Handler:          ready,
+			LivenessHandler:  handler,
+			ReadinessHandler: ready,
+			Readiness:        readiness,
+			Warmup:           warmup,
+			Routes:           NewRoutes(o),
 		}, nil
 	}
 }
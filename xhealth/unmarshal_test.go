@@ -0,0 +1,297 @@
+package xhealth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/config"
+	"github.com/xmidt-org/themis/xlog"
+
+	health "github.com/InVisionApp/go-health"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func testUnmarshalDetailed(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		routes   Routes
+		liveness LivenessHandler
+
+		app = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				func() *health.Config {
+					return &health.Config{
+						Name:     "nop",
+						Checker:  NopCheckable{},
+						Interval: time.Hour,
+					}
+				},
+				config.ProvideViper(
+					config.Json(`
+						{
+							"health": {
+								"custom": {"version": "1.0"},
+								"readinessPath": "/readyz",
+								"methods": ["GET", "HEAD"]
+							}
+						}`,
+					),
+				),
+				Unmarshal("health"),
+			),
+			fx.Populate(&routes, &liveness),
+		)
+	)
+
+	require.NoError(app.Err())
+	app.RequireStart()
+	defer app.RequireStop()
+
+	assert.Equal(
+		Routes{
+			Path:          DefaultPath,
+			LivenessPath:  DefaultLivenessPath,
+			ReadinessPath: "/readyz",
+			Methods:       []string{http.MethodGet, http.MethodHead},
+		},
+		routes,
+	)
+
+	// the detailed body includes Custom once the first check has run
+	assert.Eventually(
+		func() bool {
+			response := httptest.NewRecorder()
+			liveness.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+			return response.Code == http.StatusOK && strings.Contains(response.Body.String(), `"version":"1.0"`)
+		},
+		5*time.Second,
+		10*time.Millisecond,
+	)
+}
+
+func testUnmarshalBrief(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		liveness LivenessHandler
+
+		app = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"health": {
+								"custom": {"version": "1.0"},
+								"brief": true
+							}
+						}`,
+					),
+				),
+				Unmarshal("health"),
+			),
+			fx.Populate(&liveness),
+		)
+	)
+
+	require.NoError(app.Err())
+
+	response := httptest.NewRecorder()
+	liveness.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("ok", response.Body.String())
+}
+
+func testUnmarshalHealthChecks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		h health.IHealth
+
+		app = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`{"health": {}}`),
+				),
+				func() CheckOut {
+					return CheckOut{
+						Check: &health.Config{
+							Name:     "first",
+							Checker:  NopCheckable{},
+							Interval: time.Hour,
+						},
+					}
+				},
+				func() CheckOut {
+					return CheckOut{
+						Check: &health.Config{
+							Name:     "second",
+							Checker:  NopCheckable{},
+							Interval: time.Hour,
+						},
+					}
+				},
+				Unmarshal("health"),
+			),
+			fx.Populate(&h),
+		)
+	)
+
+	require.NoError(app.Err())
+	app.RequireStart()
+	defer app.RequireStop()
+
+	// checks run asynchronously once the health service starts
+	assert.Eventually(
+		func() bool {
+			states, _, err := h.State()
+			if err != nil {
+				return false
+			}
+
+			_, first := states["first"]
+			_, second := states["second"]
+			return first && second
+		},
+		5*time.Second,
+		10*time.Millisecond,
+	)
+}
+
+func testUnmarshalReadyOnStart(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		readiness *Readiness
+		readyDuringStart,
+		readyDuringStop bool
+
+		app = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`{"health": {}}`),
+				),
+				Unmarshal("health"),
+			),
+			fx.Invoke(
+				// this hook is appended ahead of ReadyOnStart's, as any server's hooks would be
+				func(l fx.Lifecycle, r *Readiness) {
+					l.Append(fx.Hook{
+						OnStart: func(context.Context) error {
+							readyDuringStart = r.Ready()
+							return nil
+						},
+						OnStop: func(context.Context) error {
+							readyDuringStop = r.Ready()
+							return nil
+						},
+					})
+				},
+				ReadyOnStart,
+			),
+			fx.Populate(&readiness),
+		)
+	)
+
+	require.NoError(app.Err())
+	require.NotNil(readiness)
+	assert.False(readiness.Ready())
+
+	app.RequireStart()
+	assert.False(readyDuringStart)
+	assert.True(readiness.Ready())
+
+	app.RequireStop()
+	assert.False(readyDuringStop)
+	assert.False(readiness.Ready())
+}
+
+func testUnmarshalDrain(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		serve = func(h http.Handler) int {
+			response := httptest.NewRecorder()
+			h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+			return response.Code
+		}
+
+		livenessDuringStart,
+		readinessDuringStart,
+		livenessDuringStop,
+		readinessDuringStop,
+		handlerDuringStop int
+
+		liveness  LivenessHandler
+		readiness ReadinessHandler
+		handler   Handler
+
+		app = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`{"health": {"brief": true}}`),
+				),
+				Unmarshal("health"),
+			),
+			fx.Invoke(
+				// this hook is appended ahead of ReadyOnStart's, so it observes the handlers as a draining server would
+				func(l fx.Lifecycle, lh LivenessHandler, rh ReadinessHandler, h Handler) {
+					l.Append(fx.Hook{
+						OnStart: func(context.Context) error {
+							livenessDuringStart = serve(lh)
+							readinessDuringStart = serve(rh)
+							return nil
+						},
+						OnStop: func(context.Context) error {
+							livenessDuringStop = serve(lh)
+							readinessDuringStop = serve(rh)
+							handlerDuringStop = serve(h)
+							return nil
+						},
+					})
+				},
+				ReadyOnStart,
+			),
+			fx.Populate(&liveness, &readiness, &handler),
+		)
+	)
+
+	require.NoError(app.Err())
+	app.RequireStart()
+	assert.Equal(http.StatusOK, livenessDuringStart)
+	assert.Equal(http.StatusServiceUnavailable, readinessDuringStart)
+	assert.Equal(http.StatusOK, serve(liveness))
+	assert.Equal(http.StatusOK, serve(readiness))
+	assert.Equal(http.StatusOK, serve(handler))
+
+	app.RequireStop()
+	assert.Equal(http.StatusOK, livenessDuringStop)
+	assert.Equal(http.StatusServiceUnavailable, readinessDuringStop)
+	assert.Equal(http.StatusServiceUnavailable, handlerDuringStop)
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("Detailed", testUnmarshalDetailed)
+	t.Run("Brief", testUnmarshalBrief)
+	t.Run("HealthChecks", testUnmarshalHealthChecks)
+	t.Run("ReadyOnStart", testUnmarshalReadyOnStart)
+	t.Run("Drain", testUnmarshalDrain)
+}
@@ -0,0 +1,77 @@
+package xhealth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Warmup holds an application's readiness after startup, e.g. while caches are primed, without affecting liveness.
+// The warm-up begins when the application starts and is complete once its duration has elapsed or
+// SignalWarmupComplete is called, whichever comes first.  If the signal is required, both must happen instead.
+//
+// A Warmup that is never started is never complete.
+type Warmup struct {
+	duration      time.Duration
+	requireSignal bool
+	now           func() time.Time
+
+	lock      sync.Mutex
+	started   bool
+	startedAt time.Time
+	signaled  bool
+}
+
+// NewWarmup creates a Warmup with the given duration.  If requireSignal is true, the warm-up also lasts until
+// SignalWarmupComplete is called.
+func NewWarmup(duration time.Duration, requireSignal bool) *Warmup {
+	return &Warmup{
+		duration:      duration,
+		requireSignal: requireSignal,
+		now:           time.Now,
+	}
+}
+
+// Start begins the warm-up.  Only the first call has any effect.
+func (w *Warmup) Start() {
+	w.lock.Lock()
+	if !w.started {
+		w.started = true
+		w.startedAt = w.now()
+	}
+
+	w.lock.Unlock()
+}
+
+// OnStart returns an uber/fx Lifecycle hook that starts this Warmup
+func (w *Warmup) OnStart() func(context.Context) error {
+	return func(context.Context) error {
+		w.Start()
+		return nil
+	}
+}
+
+// SignalWarmupComplete is called by application code when its warm-up work is done.  Unless the signal is
+// required, this ends the warm-up early.
+func (w *Warmup) SignalWarmupComplete() {
+	w.lock.Lock()
+	w.signaled = true
+	w.lock.Unlock()
+}
+
+// Complete tests if the warm-up is over
+func (w *Warmup) Complete() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.started {
+		return false
+	}
+
+	elapsed := w.now().Sub(w.startedAt) >= w.duration
+	if w.requireSignal {
+		return elapsed && w.signaled
+	}
+
+	return elapsed || w.signaled
+}
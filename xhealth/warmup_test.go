@@ -0,0 +1,134 @@
+package xhealth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestWarmup creates a Warmup whose clock is advanced via the returned function
+func newTestWarmup(duration time.Duration, requireSignal bool) (*Warmup, func(time.Duration)) {
+	var (
+		current = time.Now()
+		w       = NewWarmup(duration, requireSignal)
+	)
+
+	w.now = func() time.Time { return current }
+	return w, func(d time.Duration) { current = current.Add(d) }
+}
+
+func testWarmupNotStarted(t *testing.T) {
+	w, _ := newTestWarmup(0, false)
+	w.SignalWarmupComplete()
+	assert.False(t, w.Complete())
+}
+
+func testWarmupDuration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		w, advance = newTestWarmup(time.Minute, false)
+	)
+
+	assert.NoError(w.OnStart()(context.Background()))
+	assert.False(w.Complete())
+
+	advance(30 * time.Second)
+	assert.False(w.Complete())
+
+	// subsequent starts do not restart the warm-up
+	w.Start()
+	advance(30 * time.Second)
+	assert.True(w.Complete())
+}
+
+func testWarmupSignal(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		w, advance = newTestWarmup(time.Minute, false)
+	)
+
+	w.Start()
+	advance(time.Second)
+	assert.False(w.Complete())
+
+	w.SignalWarmupComplete()
+	assert.True(w.Complete())
+}
+
+func testWarmupRequireSignal(t *testing.T) {
+	t.Run("SignalFirst", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			w, advance = newTestWarmup(time.Minute, true)
+		)
+
+		w.Start()
+		w.SignalWarmupComplete()
+		assert.False(w.Complete())
+
+		advance(time.Minute)
+		assert.True(w.Complete())
+	})
+
+	t.Run("DurationFirst", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			w, advance = newTestWarmup(time.Minute, true)
+		)
+
+		w.Start()
+		advance(time.Hour)
+		assert.False(w.Complete())
+
+		w.SignalWarmupComplete()
+		assert.True(w.Complete())
+	})
+
+	t.Run("NoDuration", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			w, _ = newTestWarmup(0, true)
+		)
+
+		w.Start()
+		assert.False(w.Complete())
+
+		w.SignalWarmupComplete()
+		assert.True(w.Complete())
+	})
+}
+
+func testWarmupReadiness(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		w, advance = newTestWarmup(time.Minute, false)
+		r          = &Readiness{Warmup: w}
+	)
+
+	assert.False(r.Ready())
+
+	w.Start()
+	assert.False(r.Ready())
+
+	advance(time.Minute)
+	assert.True(r.Ready())
+
+	r.SetReady(false)
+	assert.False(r.Ready())
+}
+
+func TestWarmup(t *testing.T) {
+	t.Run("NotStarted", testWarmupNotStarted)
+	t.Run("Duration", testWarmupDuration)
+	t.Run("Signal", testWarmupSignal)
+	t.Run("RequireSignal", testWarmupRequireSignal)
+	t.Run("Readiness", testWarmupReadiness)
+}
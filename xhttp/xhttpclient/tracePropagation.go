@@ -0,0 +1,31 @@
+package xhttpclient
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TracePropagation provides a RoundTripper constructor that injects the span context of each request,
+// if any, into that request's headers.  This allows downstream services to continue a trace started by
+// an incoming request, e.g. by xhttpserver.Trace.
+type TracePropagation struct {
+	// Propagator is used to inject the span context.  If unset, the W3C trace context propagator is used.
+	Propagator propagation.TextMapPropagator
+}
+
+func (tp TracePropagation) Then(next http.RoundTripper) http.RoundTripper {
+	propagator := tp.Propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+
+	return RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		propagator.Inject(request.Context(), propagation.HeaderCarrier(request.Header))
+		return next.RoundTrip(request)
+	})
+}
+
+func (tp TracePropagation) ThenFunc(next RoundTripperFunc) http.RoundTripper {
+	return tp.Then(next)
+}
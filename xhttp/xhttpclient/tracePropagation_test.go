@@ -0,0 +1,80 @@
+package xhttpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func testTracePropagationNoSpan(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		request          = httptest.NewRequest("GET", "/", nil)
+		expectedResponse = new(http.Response)
+		expectedErr      = errors.New("expected")
+
+		roundTripper = new(mockRoundTripper)
+	)
+
+	decorated := TracePropagation{}.Then(roundTripper)
+	require.NotNil(decorated)
+
+	roundTripper.ExpectRoundTrip(request).Once().Return(expectedResponse, expectedErr)
+	actualResponse, actualErr := decorated.RoundTrip(request)
+	assert.Equal(expectedResponse, actualResponse)
+	assert.Equal(expectedErr, actualErr)
+	assert.Empty(request.Header.Get("traceparent"))
+
+	roundTripper.AssertExpectations(t)
+}
+
+func testTracePropagationSpan(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		traceID, _ = trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _  = trace.SpanIDFromHex("00f067aa0ba902b7")
+
+		ctx = trace.ContextWithSpanContext(
+			context.Background(),
+			trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    traceID,
+				SpanID:     spanID,
+				TraceFlags: trace.FlagsSampled,
+			}),
+		)
+
+		request          = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		expectedResponse = new(http.Response)
+
+		roundTripper = new(mockRoundTripper)
+	)
+
+	decorated := TracePropagation{}.ThenFunc(roundTripper.RoundTrip)
+	require.NotNil(decorated)
+
+	roundTripper.ExpectRoundTrip(request).Once().Return(expectedResponse, nil)
+	actualResponse, actualErr := decorated.RoundTrip(request)
+	assert.Equal(expectedResponse, actualResponse)
+	assert.NoError(actualErr)
+	assert.Equal(
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		request.Header.Get("traceparent"),
+	)
+
+	roundTripper.AssertExpectations(t)
+}
+
+func TestTracePropagation(t *testing.T) {
+	t.Run("NoSpan", testTracePropagationNoSpan)
+	t.Run("Span", testTracePropagationSpan)
+}
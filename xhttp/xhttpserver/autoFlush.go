@@ -0,0 +1,120 @@
+package xhttpserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultAutoFlushTypes returns the media types that AutoFlush treats as streaming when none are configured.  A
+// distinct slice is returned with each call.
+func DefaultAutoFlushTypes() []string {
+	return []string{"text/event-stream"}
+}
+
+// autoFlushWriter is a decorated http.ResponseWriter that flushes after each write of a streaming response
+type autoFlushWriter struct {
+	next       http.ResponseWriter
+	mediaTypes []string
+	decided    bool
+	streaming  bool
+}
+
+// isStreaming determines, from the Content-Type at the time of the first write, whether the response is streaming
+func (afw *autoFlushWriter) isStreaming() bool {
+	if afw.decided {
+		return afw.streaming
+	}
+
+	afw.decided = true
+	contentType := afw.next.Header().Get("Content-Type")
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	for _, mt := range afw.mediaTypes {
+		if mediaType == mt {
+			afw.streaming = true
+			break
+		}
+	}
+
+	return afw.streaming
+}
+
+// Unwrap returns the decorated http.ResponseWriter
+func (afw *autoFlushWriter) Unwrap() http.ResponseWriter {
+	return afw.next
+}
+
+func (afw *autoFlushWriter) Header() http.Header {
+	return afw.next.Header()
+}
+
+func (afw *autoFlushWriter) Write(b []byte) (int, error) {
+	streaming := afw.isStreaming()
+	n, err := afw.next.Write(b)
+	if err == nil && streaming {
+		afw.Flush()
+	}
+
+	return n, err
+}
+
+func (afw *autoFlushWriter) WriteHeader(statusCode int) {
+	afw.isStreaming()
+	afw.next.WriteHeader(statusCode)
+}
+
+func (afw *autoFlushWriter) Flush() {
+	if f, ok := afw.next.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (afw *autoFlushWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := afw.next.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, ErrHijackerNotSupported
+}
+
+func (afw *autoFlushWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := afw.next.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// AutoFlush is an Alice-style decorator that flushes streaming responses, e.g. server-sent events, after each
+// write.  This way, handlers that forget to flush do not leave events sitting in net/http's buffers.  Whether
+// a response is streaming is decided by its Content-Type when the header or first body bytes are written, so
+// handlers must set the Content-Type before writing.  Other responses are buffered as usual.
+type AutoFlush struct {
+	// Types are the case-insensitive media types, without parameters, that are considered streaming.  If unset,
+	// DefaultAutoFlushTypes is used.
+	Types []string
+}
+
+func (af AutoFlush) Then(next http.Handler) http.Handler {
+	types := af.Types
+	if len(types) == 0 {
+		types = DefaultAutoFlushTypes()
+	}
+
+	mediaTypes := make([]string, len(types))
+	for i, t := range types {
+		mediaTypes[i] = strings.ToLower(t)
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		next.ServeHTTP(
+			&autoFlushWriter{next: response, mediaTypes: mediaTypes},
+			request,
+		)
+	})
+}
+
+func (af AutoFlush) ThenFunc(next http.HandlerFunc) http.Handler {
+	return af.Then(next)
+}
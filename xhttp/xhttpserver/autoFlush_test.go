@@ -0,0 +1,107 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultAutoFlushTypes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"text/event-stream"}, DefaultAutoFlushTypes())
+
+	// each call must return a distinct slice, so that callers cannot alter the defaults
+	DefaultAutoFlushTypes()[0] = "application/json"
+	assert.Equal("text/event-stream", DefaultAutoFlushTypes()[0])
+}
+
+// flushCountingRecorder is an httptest.ResponseRecorder that counts calls to Flush
+type flushCountingRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (fcr *flushCountingRecorder) Flush() {
+	fcr.flushes++
+	fcr.ResponseRecorder.Flush()
+}
+
+func testAutoFlush(t *testing.T, af AutoFlush, contentType string, writeHeader bool, expectedFlushes int) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = af.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Header().Set("Content-Type", contentType)
+			if writeHeader {
+				response.WriteHeader(299)
+			}
+
+			response.Write([]byte("data: 1\n\n"))
+			response.Write([]byte("data: 2\n\n"))
+		})
+
+		response = &flushCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal("data: 1\n\ndata: 2\n\n", response.Body.String())
+	assert.Equal(expectedFlushes, response.flushes)
+}
+
+func TestAutoFlush(t *testing.T) {
+	testData := []struct {
+		name            string
+		autoFlush       AutoFlush
+		contentType     string
+		expectedFlushes int
+	}{
+		{"EventStream", AutoFlush{}, "text/event-stream", 2},
+		{"CaseInsensitive", AutoFlush{}, "Text/Event-Stream", 2},
+		{"Parameters", AutoFlush{}, "text/event-stream; charset=utf-8", 2},
+		{"NotStreaming", AutoFlush{}, "application/json", 0},
+		{"Custom", AutoFlush{Types: []string{"application/x-ndjson"}}, "application/x-ndjson", 2},
+		{"CustomExcludes", AutoFlush{Types: []string{"application/x-ndjson"}}, "text/event-stream", 0},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			t.Run("WriteHeader", func(t *testing.T) {
+				testAutoFlush(t, record.autoFlush, record.contentType, true, record.expectedFlushes)
+			})
+
+			t.Run("Write", func(t *testing.T) {
+				testAutoFlush(t, record.autoFlush, record.contentType, false, record.expectedFlushes)
+			})
+		})
+	}
+
+	t.Run("Interfaces", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			decorated = AutoFlush{}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+				assert.Implements((*http.Flusher)(nil), response)
+				assert.Implements((*http.Hijacker)(nil), response)
+				assert.Implements((*http.Pusher)(nil), response)
+
+				_, _, err := response.(http.Hijacker).Hijack()
+				assert.Equal(ErrHijackerNotSupported, err)
+				assert.Equal(http.ErrNotSupported, response.(http.Pusher).Push("/", nil))
+
+				response.(http.Flusher).Flush()
+			})
+
+			response = httptest.NewRecorder()
+		)
+
+		require.NotNil(decorated)
+		decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.True(response.Flushed)
+	})
+}
@@ -0,0 +1,68 @@
+package xhttpserver
+
+import (
+	"context"
+	"net"
+)
+
+// ServerInfo describes the server that accepted a request
+type ServerInfo struct {
+	// Name is the name under which the server was configured, e.g. by Unmarshal.  This may be empty.
+	Name string
+
+	// Address is the network address on which the server is listening, including any port chosen by the OS
+	Address string
+}
+
+type serverInfoContextKey struct{}
+
+// WithServerInfo returns a new context with the given ServerInfo
+func WithServerInfo(ctx context.Context, si ServerInfo) context.Context {
+	return context.WithValue(ctx, serverInfoContextKey{}, si)
+}
+
+// ServerInfoFromContext returns the ServerInfo seeded into a server's base context.  The returned boolean
+// is false if the server was not configured with Options.ServerInfo.
+func ServerInfoFromContext(ctx context.Context) (ServerInfo, bool) {
+	si, ok := ctx.Value(serverInfoContextKey{}).(ServerInfo)
+	return si, ok
+}
+
+// newBaseContext produces the http.Server.BaseContext for a set of options.  If the options configure no
+// base context, this function returns nil, which leaves net/http's default in place.
+func newBaseContext(o Options) func(net.Listener) context.Context {
+	if o.BaseContext == nil && !o.ServerInfo {
+		return nil
+	}
+
+	return func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if o.BaseContext != nil {
+			ctx = o.BaseContext()
+		}
+
+		if o.ServerInfo {
+			// preserve any name seeded by the configured base context
+			si, _ := ServerInfoFromContext(ctx)
+			si.Address = l.Addr().String()
+			ctx = WithServerInfo(ctx, si)
+		}
+
+		return ctx
+	}
+}
+
+// withServerName decorates a base context function so that the returned context carries a ServerInfo with
+// the given name
+func withServerName(base func() context.Context, name string) func() context.Context {
+	return func() context.Context {
+		ctx := context.Background()
+		if base != nil {
+			ctx = base()
+		}
+
+		si, _ := ServerInfoFromContext(ctx)
+		si.Name = name
+		return WithServerInfo(ctx, si)
+	}
+}
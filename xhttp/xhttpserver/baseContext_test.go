@@ -0,0 +1,117 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type regionContextKey struct{}
+
+func testNewBaseContextNone(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(newBaseContext(Options{}))
+	assert.Nil(New(Options{}, log.NewNopLogger(), http.NotFoundHandler()).(*http.Server).BaseContext)
+}
+
+func testNewBaseContextCustom(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		baseContext = newBaseContext(Options{
+			BaseContext: func() context.Context {
+				return context.WithValue(context.Background(), regionContextKey{}, "us-east")
+			},
+		})
+	)
+
+	require.NotNil(baseContext)
+	ctx := baseContext(newPipeListener())
+	assert.Equal("us-east", ctx.Value(regionContextKey{}))
+
+	_, ok := ServerInfoFromContext(ctx)
+	assert.False(ok)
+}
+
+func testNewBaseContextServerInfo(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		baseContext = newBaseContext(Options{
+			ServerInfo:  true,
+			BaseContext: withServerName(nil, "main"),
+		})
+	)
+
+	require.NotNil(baseContext)
+	si, ok := ServerInfoFromContext(baseContext(newPipeListener()))
+	assert.True(ok)
+	assert.Equal(ServerInfo{Name: "main", Address: "pipe"}, si)
+}
+
+func testNewBaseContextServer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		actual   ServerInfo
+		actualOK bool
+		region   interface{}
+
+		o = Options{
+			ServerInfo: true,
+			BaseContext: withServerName(
+				func() context.Context {
+					return context.WithValue(context.Background(), regionContextKey{}, "us-east")
+				},
+				"main",
+			),
+		}
+
+		server = httptest.NewUnstartedServer(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				actual, actualOK = ServerInfoFromContext(request.Context())
+				region = request.Context().Value(regionContextKey{})
+			}),
+		)
+	)
+
+	server.Config.BaseContext = New(o, log.NewNopLogger(), nil).(*http.Server).BaseContext
+	require.NotNil(server.Config.BaseContext)
+	server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	require.NoError(err)
+	response.Body.Close()
+
+	assert.True(actualOK)
+	assert.Equal("main", actual.Name)
+	assert.Equal(server.Listener.Addr().String(), actual.Address)
+	assert.Equal("us-east", region)
+}
+
+func TestNewBaseContext(t *testing.T) {
+	t.Run("None", testNewBaseContextNone)
+	t.Run("Custom", testNewBaseContextCustom)
+	t.Run("ServerInfo", testNewBaseContextServerInfo)
+	t.Run("Server", testNewBaseContextServer)
+}
+
+func TestServerInfoFromContext(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ok := ServerInfoFromContext(context.Background())
+	assert.False(ok)
+
+	si, ok := ServerInfoFromContext(WithServerInfo(context.Background(), ServerInfo{Name: "test", Address: ":8080"}))
+	assert.True(ok)
+	assert.Equal(ServerInfo{Name: "test", Address: ":8080"}, si)
+}
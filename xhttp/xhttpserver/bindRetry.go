@@ -0,0 +1,101 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// DefaultBindRetryBackoff is the wait before the first bind retry when BindRetry.Backoff is unset
+	DefaultBindRetryBackoff = 500 * time.Millisecond
+
+	attemptKey = "attempt"
+)
+
+// AttemptKey is the logging key for the number of a bind attempt
+func AttemptKey() interface{} {
+	return attemptKey
+}
+
+// BindRetry describes how a server retries binding its address at startup.  This smooths over transient failures,
+// such as a lingering socket from a previous instance during a fast restart.  Only failures to bind are retried,
+// and if every attempt fails, the last BindError is returned.
+type BindRetry struct {
+	// Attempts is the total number of times to try binding, including the first.  Values less than 2 disable retries.
+	Attempts int
+
+	// Backoff is the wait before the first retry, which doubles for each subsequent retry.  If unset,
+	// DefaultBindRetryBackoff is used.
+	Backoff time.Duration
+
+	// MaxBackoff is the optional upper bound on the wait between attempts
+	MaxBackoff time.Duration
+}
+
+// newListenerWithRetry invokes NewListener, retrying as configured by Options.BindRetry.  Each failed attempt is
+// logged, and waiting between attempts stops early if the context is canceled.
+func newListenerWithRetry(ctx context.Context, o Options, lcfg net.ListenConfig, tcfg *tls.Config, logger log.Logger) (*Listener, error) {
+	var (
+		attempts = 1
+		backoff  = DefaultBindRetryBackoff
+		max      time.Duration
+	)
+
+	if o.BindRetry != nil {
+		if o.BindRetry.Attempts > 1 {
+			attempts = o.BindRetry.Attempts
+		}
+
+		if o.BindRetry.Backoff > 0 {
+			backoff = o.BindRetry.Backoff
+		}
+
+		max = o.BindRetry.MaxBackoff
+	}
+
+	if o.ListenerLogger == nil {
+		o.ListenerLogger = logger
+	}
+
+	for attempt := 1; ; attempt++ {
+		l, err := NewListener(ctx, o, lcfg, tcfg)
+		be, ok := err.(BindError)
+		if !ok {
+			return l, err
+		}
+
+		be.Attempts = attempt
+		if attempt >= attempts {
+			return nil, be
+		}
+
+		if max > 0 && backoff > max {
+			backoff = max
+		}
+
+		logger.Log(
+			level.Key(), level.WarnValue(),
+			AddressKey(), be.Address,
+			AttemptKey(), attempt,
+			xlog.MessageKey(), "unable to bind; retrying",
+			xlog.ErrorKey(), be.Err,
+		)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, be
+		}
+
+		backoff *= 2
+	}
+}
@@ -0,0 +1,214 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBindRetryRecords decodes each JSON log record written to a buffer
+func testBindRetryRecords(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	var (
+		records []map[string]interface{}
+		decoder = json.NewDecoder(output)
+	)
+
+	for decoder.More() {
+		var record map[string]interface{}
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func testBindRetryNoRetry(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+	)
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer existing.Close()
+
+	l, err := newListenerWithRetry(
+		context.Background(),
+		Options{Address: existing.Addr().String()},
+		net.ListenConfig{},
+		nil,
+		log.NewJSONLogger(&output),
+	)
+
+	require.Error(err)
+	assert.Nil(l)
+	assert.Zero(output.Len())
+
+	be, ok := err.(BindError)
+	require.True(ok)
+	assert.Equal(1, be.Attempts)
+	assert.NotContains(be.Error(), "gave up")
+}
+
+func testBindRetryExhausted(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+	)
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer existing.Close()
+
+	l, err := newListenerWithRetry(
+		context.Background(),
+		Options{
+			Address: existing.Addr().String(),
+			BindRetry: &BindRetry{
+				Attempts:   3,
+				Backoff:    time.Hour,
+				MaxBackoff: time.Millisecond,
+			},
+		},
+		net.ListenConfig{},
+		nil,
+		log.NewJSONLogger(&output),
+	)
+
+	require.Error(err)
+	assert.Nil(l)
+
+	be, ok := err.(BindError)
+	require.True(ok)
+	assert.Equal(3, be.Attempts)
+	assert.True(be.AddressInUse())
+	assert.Contains(be.Error(), "already in use")
+	assert.Contains(be.Error(), "gave up after 3 attempts")
+
+	records := testBindRetryRecords(t, &output)
+	require.Len(records, 2)
+	for i, record := range records {
+		assert.Equal(float64(i+1), record[attemptKey])
+		assert.Equal(existing.Addr().String(), record[addressKey])
+		assert.Equal("warn", record["level"])
+	}
+}
+
+func testBindRetrySuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+	)
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	address := existing.Addr().String()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		existing.Close()
+	}()
+
+	l, err := newListenerWithRetry(
+		context.Background(),
+		Options{
+			Address: address,
+			BindRetry: &BindRetry{
+				Attempts: 100,
+				Backoff:  10 * time.Millisecond,
+			},
+		},
+		net.ListenConfig{},
+		nil,
+		log.NewJSONLogger(&output),
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	assert.Equal(address, l.Addr().String())
+	assert.NotEmpty(testBindRetryRecords(t, &output))
+}
+
+func testBindRetryCanceled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer existing.Close()
+
+	cancel()
+	l, err := newListenerWithRetry(
+		ctx,
+		Options{
+			Address:   existing.Addr().String(),
+			BindRetry: &BindRetry{Attempts: 100, Backoff: time.Hour},
+		},
+		net.ListenConfig{},
+		nil,
+		log.NewNopLogger(),
+	)
+
+	require.Error(err)
+	assert.Nil(l)
+
+	be, ok := err.(BindError)
+	require.True(ok)
+	assert.Equal(1, be.Attempts)
+}
+
+func testBindRetryNotBindError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output bytes.Buffer
+	)
+
+	l, err := newListenerWithRetry(
+		context.Background(),
+		Options{
+			Address:   ":0",
+			Interface: "nosuch-interface",
+			BindRetry: &BindRetry{Attempts: 100, Backoff: time.Hour},
+		},
+		net.ListenConfig{},
+		nil,
+		log.NewJSONLogger(&output),
+	)
+
+	assert.Error(err)
+	assert.Nil(l)
+	assert.Zero(output.Len())
+
+	_, ok := err.(BindError)
+	assert.False(ok)
+	assert.False(strings.Contains(err.Error(), "gave up"))
+}
+
+func TestBindRetry(t *testing.T) {
+	t.Run("NoRetry", testBindRetryNoRetry)
+	t.Run("Exhausted", testBindRetryExhausted)
+	t.Run("Success", testBindRetrySuccess)
+	t.Run("Canceled", testBindRetryCanceled)
+	t.Run("NotBindError", testBindRetryNotBindError)
+}
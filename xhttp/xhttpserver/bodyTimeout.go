@@ -0,0 +1,260 @@
+package xhttpserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// BodyReadTimeoutError is returned from a request body's Read method when the body was not
+// fully read before the configured deadline.  This error implements go-kit's StatusCoder, so that
+// error encoders will produce an http.StatusRequestTimeout.
+type BodyReadTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (brte BodyReadTimeoutError) Error() string {
+	return "The request body was not read within " + brte.Timeout.String()
+}
+
+func (brte BodyReadTimeoutError) StatusCode() int {
+	return http.StatusRequestTimeout
+}
+
+// deadlineBody is an io.ReadCloser decorator that enforces a deadline on reading a request body.  The deadline
+// is measured by the Clock, while realStart anchors the equivalent connection read deadline in real time.
+type deadlineBody struct {
+	io.ReadCloser
+	timeout   time.Duration
+	minRate   int64
+	start     time.Time
+	realStart time.Time
+	deadline  time.Time
+	clock     Clock
+
+	// readDeadline is the connection's read deadline imposed by the server's ReadTimeout, in real time.  It is
+	// zero if the server has no ReadTimeout.
+	readDeadline time.Time
+
+	// controller is used to set and clear the connection's read deadline.  It is nil when
+	// the body is not attached to a connection, e.g. under test.
+	controller *http.ResponseController
+
+	bytesRead int64
+	timedOut  bool
+	cleared   bool
+}
+
+// clearDeadline restores the connection's read deadline to the one imposed by the server's ReadTimeout, removing
+// it if there is none.  Once a body reaches EOF, net/http begins a background read on the connection, and that
+// read failing on our deadline would cancel the request's context.
+//
+// A body that timed out keeps its deadline, so that net/http does not block draining the rest of a stalled body
+// and instead closes the connection.
+func (db *deadlineBody) clearDeadline() {
+	if db.controller != nil && !db.cleared && !db.timedOut {
+		db.cleared = true
+		db.controller.SetReadDeadline(db.readDeadline)
+	}
+}
+
+// connectionDeadline returns the real time at which the connection's read deadline should expire.  The operating
+// system enforces that deadline, so it cannot be expressed in terms of the Clock.  The deadline never extends past
+// the one imposed by the server's ReadTimeout.
+func (db *deadlineBody) connectionDeadline() time.Time {
+	deadline := db.realStart.Add(db.deadline.Sub(db.start))
+	if !db.readDeadline.IsZero() && db.readDeadline.Before(deadline) {
+		return db.readDeadline
+	}
+
+	return deadline
+}
+
+// extendDeadline moves the deadline forward as bytes arrive, when a minimum throughput is enforced
+func (db *deadlineBody) extendDeadline() {
+	if db.minRate <= 0 {
+		return
+	}
+
+	// computed in floating point, since bytesRead*time.Second overflows for large bodies
+	db.deadline = db.start.Add(db.timeout + time.Duration(float64(db.bytesRead)/float64(db.minRate)*float64(time.Second)))
+	if db.controller != nil {
+		db.controller.SetReadDeadline(db.connectionDeadline())
+	}
+}
+
+func (db *deadlineBody) Read(p []byte) (int, error) {
+	if !db.clock.Now().Before(db.deadline) {
+		db.timedOut = true
+		return 0, BodyReadTimeoutError{Timeout: db.timeout}
+	}
+
+	n, err := db.ReadCloser.Read(p)
+	db.bytesRead += int64(n)
+	switch {
+	case err == io.EOF:
+		db.clearDeadline()
+
+	case err != nil && (errors.Is(err, os.ErrDeadlineExceeded) || !db.clock.Now().Before(db.deadline)):
+		db.timedOut = true
+		err = BodyReadTimeoutError{Timeout: db.timeout}
+
+	case n > 0:
+		db.extendDeadline()
+	}
+
+	return n, err
+}
+
+func (db *deadlineBody) Close() error {
+	db.clearDeadline()
+	return db.ReadCloser.Close()
+}
+
+// bodyTimeoutWriter is a decorated http.ResponseWriter that records whether the handler produced a response
+type bodyTimeoutWriter struct {
+	next    http.ResponseWriter
+	written bool
+}
+
+// Unwrap returns the decorated http.ResponseWriter
+func (btw *bodyTimeoutWriter) Unwrap() http.ResponseWriter {
+	return btw.next
+}
+
+func (btw *bodyTimeoutWriter) Header() http.Header {
+	return btw.next.Header()
+}
+
+func (btw *bodyTimeoutWriter) Write(b []byte) (int, error) {
+	btw.written = true
+	return btw.next.Write(b)
+}
+
+func (btw *bodyTimeoutWriter) WriteHeader(statusCode int) {
+	btw.written = true
+	btw.next.WriteHeader(statusCode)
+}
+
+func (btw *bodyTimeoutWriter) Flush() {
+	btw.written = true
+	if f, ok := btw.next.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (btw *bodyTimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := btw.next.(http.Hijacker); ok {
+		btw.written = true
+		return h.Hijack()
+	}
+
+	return nil, nil, ErrHijackerNotSupported
+}
+
+func (btw *bodyTimeoutWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := btw.next.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// BodyTimeout is an Alice-style decorator that enforces a deadline for reading the entire request body.
+// This protects against clients that trickle a request body, which server-wide timeouts cannot express per route.
+//
+// When the underlying connection supports it, a read deadline is set on the connection via http.ResponseController so
+// that blocked reads are interrupted.  That deadline replaces the one net/http sets for the server's ReadTimeout, so
+// once the body is fully read or closed, the connection's deadline is restored to ReadTimeout, measured from when
+// the request entered this decorator.  That is slightly later than net/http measures it, from when it began reading
+// the request.  If ReadTimeout is unset, the connection's deadline is simply cleared.  After the
+// deadline passes, the request body returns a BodyReadTimeoutError.  Handlers may render that error themselves,
+// but if a handler returns without writing anything after its body timed out, OnTimeout produces the response.
+type BodyTimeout struct {
+	// Timeout is the maximum time allowed to read the request body, measured from when the request enters
+	// this decorator.  If nonpositive, no decoration is done.
+	Timeout time.Duration
+
+	// MinBytesPerSecond is the optional minimum throughput for reading the request body.  When positive, Timeout
+	// is a grace period rather than an absolute limit:  each byte read extends the deadline by the time it takes
+	// to transfer one byte at this rate.  This allows large uploads from clients that keep up, while still
+	// cutting off clients that trickle data.
+	MinBytesPerSecond int64
+
+	// ReadTimeout is the server's http.Server.ReadTimeout, if any.  The connection's read deadline never extends
+	// past this timeout, and is restored to it once the body has been read.  If unset, the connection's read
+	// deadline is cleared once the body has been read, so this must match the server's ReadTimeout for that
+	// timeout to remain in effect.
+	ReadTimeout time.Duration
+
+	// OnTimeout is the optional handler invoked when a body read timed out and the decorated handler wrote
+	// no response.  If unset, an http.StatusRequestTimeout is returned.
+	OnTimeout http.Handler
+
+	// Clock is the optional source of time for deadlines.  If unset, SystemClock is used.  The connection's
+	// read deadline always uses real time, since the operating system enforces it.
+	Clock Clock
+}
+
+func (bt BodyTimeout) Then(next http.Handler) http.Handler {
+	if bt.Timeout <= 0 {
+		return next
+	}
+
+	onTimeout := bt.OnTimeout
+	if onTimeout == nil {
+		onTimeout = Constant{StatusCode: http.StatusRequestTimeout}.NewHandler()
+	}
+
+	clock := clockOrSystem(bt.Clock)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Body == nil || request.Body == http.NoBody {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		var (
+			start     = clock.Now()
+			realStart = time.Now()
+			body      = &deadlineBody{
+				ReadCloser: request.Body,
+				timeout:    bt.Timeout,
+				minRate:    bt.MinBytesPerSecond,
+				start:      start,
+				realStart:  realStart,
+				deadline:   start.Add(bt.Timeout),
+				clock:      clock,
+			}
+
+			writer = &bodyTimeoutWriter{next: response}
+		)
+
+		if bt.ReadTimeout > 0 {
+			body.readDeadline = realStart.Add(bt.ReadTimeout)
+		}
+
+		// not every connection supports deadlines, e.g. under test, so the deadline is only cleared
+		// later if it could be set in the first place
+		controller := http.NewResponseController(response)
+		if controller.SetReadDeadline(body.connectionDeadline()) == nil {
+			body.controller = controller
+		}
+
+		request.Body = body
+		next.ServeHTTP(writer, request)
+		body.clearDeadline()
+
+		if body.timedOut && !writer.written {
+			MarkRejected(request.Context(), "bodyTimeout")
+			onTimeout.ServeHTTP(response, request)
+		}
+	})
+}
+
+func (bt BodyTimeout) ThenFunc(next http.HandlerFunc) http.Handler {
+	return bt.Then(next)
+}
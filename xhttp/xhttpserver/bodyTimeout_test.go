@@ -0,0 +1,393 @@
+package xhttpserver
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyReadTimeoutError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		err    = BodyReadTimeoutError{Timeout: 15 * time.Second}
+	)
+
+	assert.Contains(err.Error(), "15s")
+	assert.Equal(http.StatusRequestTimeout, err.StatusCode())
+}
+
+func testBodyTimeoutNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next        = Constant{}.NewHandler()
+		bodyTimeout = BodyTimeout{}.Then(next)
+	)
+
+	assert.Equal(next, bodyTimeout)
+}
+
+func testBodyTimeoutWithinDeadline(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		bodyTimeout = BodyTimeout{Timeout: time.Minute}.ThenFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				assert.IsType((*deadlineBody)(nil), request.Body)
+				body, err := ioutil.ReadAll(request.Body)
+				assert.Equal("test body", string(body))
+				assert.NoError(err)
+				response.WriteHeader(299)
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	bodyTimeout.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("test body")))
+	assert.Equal(299, response.Code)
+}
+
+func testBodyTimeoutDeadlinePassed(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		clock = newTestClock()
+		body  = &deadlineBody{
+			ReadCloser: ioutil.NopCloser(strings.NewReader("test body")),
+			timeout:    time.Second,
+			deadline:   clock.Now().Add(time.Second),
+			clock:      clock,
+		}
+	)
+
+	buffer := make([]byte, 4)
+	n, err := body.Read(buffer)
+	assert.Equal(4, n)
+	assert.NoError(err)
+
+	clock.Add(2 * time.Second)
+	n, err = body.Read(buffer)
+	assert.Zero(n)
+	assert.Equal(BodyReadTimeoutError{Timeout: time.Second}, err)
+}
+
+func testBodyTimeoutClock(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		clock       = newTestClock()
+		bodyTimeout = BodyTimeout{Timeout: time.Second, Clock: clock}.ThenFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				buffer := make([]byte, 4)
+				n, err := request.Body.Read(buffer)
+				assert.Equal(4, n)
+				assert.NoError(err)
+
+				clock.Add(time.Second)
+				n, err = request.Body.Read(buffer)
+				assert.Zero(n)
+				assert.Equal(BodyReadTimeoutError{Timeout: time.Second}, err)
+				response.WriteHeader(299)
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	bodyTimeout.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("test body")))
+	assert.Equal(299, response.Code)
+}
+
+func testBodyTimeoutSlowClient(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewServer(
+			BodyTimeout{Timeout: 100 * time.Millisecond}.Then(
+				UseTrackingWriter(
+					http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+						_, err := ioutil.ReadAll(request.Body)
+						if assert.IsType(BodyReadTimeoutError{}, err) {
+							response.WriteHeader(err.(BodyReadTimeoutError).StatusCode())
+						}
+					}),
+				),
+			),
+		)
+
+		bodyReader, bodyWriter = io.Pipe()
+	)
+
+	defer server.Close()
+	defer bodyWriter.Close()
+
+	go func() {
+		// trickle a single byte, then stall
+		bodyWriter.Write([]byte("x"))
+	}()
+
+	request, err := http.NewRequest("POST", server.URL, bodyReader)
+	require.NoError(err)
+	request.ContentLength = 100
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(http.StatusRequestTimeout, response.StatusCode)
+}
+
+func testBodyTimeoutMinBytesPerSecond(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		clock = newTestClock()
+		body  = &deadlineBody{
+			ReadCloser: ioutil.NopCloser(strings.NewReader("test body")),
+			timeout:    time.Second,
+			minRate:    4,
+			start:      clock.Now(),
+			deadline:   clock.Now().Add(time.Second),
+			clock:      clock,
+		}
+	)
+
+	buffer := make([]byte, 4)
+	n, err := body.Read(buffer)
+	assert.Equal(4, n)
+	assert.NoError(err)
+
+	// the 4 bytes read extend the deadline by a second
+	clock.Add(1500 * time.Millisecond)
+	n, err = body.Read(buffer)
+	assert.Equal(4, n)
+	assert.NoError(err)
+
+	clock.Add(1500 * time.Millisecond)
+	n, err = body.Read(buffer)
+	assert.Zero(n)
+	assert.Equal(BodyReadTimeoutError{Timeout: time.Second}, err)
+}
+
+func testBodyTimeoutOnTimeout(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		clock       = newTestClock()
+		bodyTimeout = BodyTimeout{
+			Timeout:   time.Second,
+			OnTimeout: Constant{StatusCode: 599}.NewHandler(),
+			Clock:     clock,
+		}.ThenFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				clock.Add(time.Second)
+
+				// the error is ignored, so the decorator must produce the response
+				ioutil.ReadAll(request.Body)
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	bodyTimeout.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("test body")))
+	assert.Equal(599, response.Code)
+}
+
+func testBodyTimeoutHandlerIgnoresError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewServer(
+			BodyTimeout{Timeout: 100 * time.Millisecond}.ThenFunc(
+				func(response http.ResponseWriter, request *http.Request) {
+					ioutil.ReadAll(request.Body)
+				},
+			),
+		)
+
+		bodyReader, bodyWriter = io.Pipe()
+	)
+
+	defer server.Close()
+	defer bodyWriter.Close()
+
+	go func() {
+		bodyWriter.Write([]byte("x"))
+	}()
+
+	request, err := http.NewRequest("POST", server.URL, bodyReader)
+	require.NoError(err)
+	request.ContentLength = 100
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(http.StatusRequestTimeout, response.StatusCode)
+}
+
+// deadlineRecorder is an http.ResponseWriter that records the read deadlines set through an http.ResponseController
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (dr *deadlineRecorder) SetReadDeadline(deadline time.Time) error {
+	dr.deadlines = append(dr.deadlines, deadline)
+	return nil
+}
+
+func testBodyTimeoutClearsDeadline(t *testing.T) {
+	testData := []struct {
+		name    string
+		consume func(io.ReadCloser)
+	}{
+		{
+			name: "EOF",
+			consume: func(body io.ReadCloser) {
+				ioutil.ReadAll(body)
+			},
+		},
+		{
+			name: "Close",
+			consume: func(body io.ReadCloser) {
+				body.Close()
+			},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				clock    = newTestClock()
+				before   = time.Now()
+				response = &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+				cleared  bool
+
+				bodyTimeout = BodyTimeout{Timeout: time.Second, Clock: clock}.ThenFunc(
+					func(_ http.ResponseWriter, request *http.Request) {
+						record.consume(request.Body)
+						cleared = len(response.deadlines) == 2 && response.deadlines[1].IsZero()
+					},
+				)
+			)
+
+			bodyTimeout.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("test body")))
+			require.NotEmpty(response.deadlines)
+
+			// the connection deadline uses real time, regardless of the Clock
+			assert.False(response.deadlines[0].Before(before.Add(time.Second)))
+			assert.False(response.deadlines[0].After(time.Now().Add(time.Second)))
+			assert.True(cleared, "the deadline was not cleared before the handler returned")
+		})
+	}
+}
+
+func testBodyTimeoutContextAfterEOF(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		contextErr = make(chan error, 1)
+		server     = httptest.NewServer(
+			BodyTimeout{Timeout: 100 * time.Millisecond}.ThenFunc(
+				func(response http.ResponseWriter, request *http.Request) {
+					body, err := ioutil.ReadAll(request.Body)
+					assert.Equal("test body", string(body))
+					assert.NoError(err)
+
+					// outlive the body timeout, which must not cancel the request once the body is read
+					time.Sleep(300 * time.Millisecond)
+					contextErr <- request.Context().Err()
+					response.WriteHeader(299)
+				},
+			),
+		)
+	)
+
+	defer server.Close()
+
+	response, err := http.Post(server.URL, "text/plain", strings.NewReader("test body"))
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(299, response.StatusCode)
+	assert.NoError(<-contextErr)
+}
+
+func testBodyTimeoutReadTimeout(t *testing.T) {
+	testData := []struct {
+		name        string
+		timeout     time.Duration
+		readTimeout time.Duration
+		expected    time.Duration
+	}{
+		{
+			name:        "Longer",
+			timeout:     time.Second,
+			readTimeout: time.Minute,
+			expected:    time.Second,
+		},
+		{
+			name:        "Shorter",
+			timeout:     time.Minute,
+			readTimeout: time.Second,
+			expected:    time.Second,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				before   = time.Now()
+				response = &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+				bodyTimeout = BodyTimeout{Timeout: record.timeout, ReadTimeout: record.readTimeout}.ThenFunc(
+					func(_ http.ResponseWriter, request *http.Request) {
+						ioutil.ReadAll(request.Body)
+					},
+				)
+			)
+
+			bodyTimeout.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("test body")))
+			after := time.Now()
+			require.Len(response.deadlines, 2)
+
+			// the body deadline never extends past ReadTimeout
+			assert.False(response.deadlines[0].Before(before.Add(record.expected)))
+			assert.False(response.deadlines[0].After(after.Add(record.expected)))
+
+			// once the body is read, the ReadTimeout deadline is restored rather than cleared
+			assert.False(response.deadlines[1].Before(before.Add(record.readTimeout)))
+			assert.False(response.deadlines[1].After(after.Add(record.readTimeout)))
+		})
+	}
+}
+
+func TestBodyTimeout(t *testing.T) {
+	t.Run("NoDecoration", testBodyTimeoutNoDecoration)
+	t.Run("WithinDeadline", testBodyTimeoutWithinDeadline)
+	t.Run("DeadlinePassed", testBodyTimeoutDeadlinePassed)
+	t.Run("Clock", testBodyTimeoutClock)
+	t.Run("SlowClient", testBodyTimeoutSlowClient)
+	t.Run("MinBytesPerSecond", testBodyTimeoutMinBytesPerSecond)
+	t.Run("OnTimeout", testBodyTimeoutOnTimeout)
+	t.Run("HandlerIgnoresError", testBodyTimeoutHandlerIgnoresError)
+	t.Run("ClearsDeadline", testBodyTimeoutClearsDeadline)
+	t.Run("ReadTimeout", testBodyTimeoutReadTimeout)
+	t.Run("ContextAfterEOF", testBodyTimeoutContextAfterEOF)
+}
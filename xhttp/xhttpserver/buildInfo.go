@@ -0,0 +1,114 @@
+package xhttpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// DefaultInfoPath is the conventional path for the handler produced by NewInfoHandler
+const DefaultInfoPath = "/debug/info"
+
+// processStarted approximates when this process started, for BuildInfo values that omit Started
+var processStarted = time.Now()
+
+// BuildInfo describes the build of a running application.  Applications typically inject the values they know,
+// e.g. a version and git commit set with -ldflags, and leave the rest to NewInfoHandler.
+type BuildInfo struct {
+	// Version is the application's version.  If unset, the main module's version is used.
+	Version string `json:"version,omitempty"`
+
+	// GitCommit is the revision from which the application was built.  If unset, the vcs.revision build
+	// setting is used.
+	GitCommit string `json:"gitCommit,omitempty"`
+
+	// BuildTime is when the application was built.  If unset, the vcs.time build setting is used, which is
+	// actually the time of the commit.
+	BuildTime string `json:"buildTime,omitempty"`
+
+	// GoVersion is the Go release that built the application.  If unset, runtime.Version is used.
+	GoVersion string `json:"goVersion,omitempty"`
+
+	// Started is when the application started.  If unset, the time this package was initialized is used.
+	Started time.Time `json:"started"`
+
+	// Extra holds any additional values that the application wants to expose
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// withDefaults fills any unset values from the given build information, which may be nil
+func (bi BuildInfo) withDefaults(info *debug.BuildInfo) BuildInfo {
+	if info != nil {
+		if len(bi.Version) == 0 && info.Main.Version != "(devel)" {
+			bi.Version = info.Main.Version
+		}
+
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && len(bi.GitCommit) == 0:
+				bi.GitCommit = setting.Value
+
+			case setting.Key == "vcs.time" && len(bi.BuildTime) == 0:
+				bi.BuildTime = setting.Value
+			}
+		}
+
+		if len(bi.GoVersion) == 0 {
+			bi.GoVersion = info.GoVersion
+		}
+	}
+
+	if len(bi.GoVersion) == 0 {
+		bi.GoVersion = runtime.Version()
+	}
+
+	if bi.Started.IsZero() {
+		bi.Started = processStarted
+	}
+
+	return bi
+}
+
+// infoServer is the serialized form of the ServerInfo that carried a request to an info handler
+type infoServer struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// info is the serialized form of the response from an info handler
+type info struct {
+	BuildInfo
+	Server *infoServer `json:"server,omitempty"`
+}
+
+// NewInfoHandler produces an http.Handler that writes the given BuildInfo as JSON, with any unset values taken
+// from debug.ReadBuildInfo.  If the serving server was configured with Options.ServerInfo, its name and resolved
+// address are included as well.  The handler is intended for an administrative server, typically at DefaultInfoPath,
+// so that operators can confirm exactly which build is running where.
+func NewInfoHandler(bi BuildInfo) http.Handler {
+	buildInfo, _ := debug.ReadBuildInfo()
+	bi = bi.withDefaults(buildInfo)
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		i := info{BuildInfo: bi}
+		if si, ok := ServerInfoFromContext(request.Context()); ok {
+			i.Server = &infoServer{
+				Name:    si.Name,
+				Address: si.Address,
+			}
+		}
+
+		body, err := json.Marshal(i)
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response.Header().Set("Content-Type", "application/json")
+		response.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		response.Write(body)
+	})
+}
@@ -0,0 +1,94 @@
+package xhttpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInfoWithDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		info = &debug.BuildInfo{
+			GoVersion: "go1.99",
+			Main:      debug.Module{Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.time", Value: "2019-01-01T00:00:00Z"},
+			},
+		}
+	)
+
+	actual := BuildInfo{}.withDefaults(info)
+	assert.Equal("v1.2.3", actual.Version)
+	assert.Equal("abc123", actual.GitCommit)
+	assert.Equal("2019-01-01T00:00:00Z", actual.BuildTime)
+	assert.Equal("go1.99", actual.GoVersion)
+	assert.Equal(processStarted, actual.Started)
+
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	actual = BuildInfo{Version: "injected", GitCommit: "def456", Started: started}.withDefaults(info)
+	assert.Equal("injected", actual.Version)
+	assert.Equal("def456", actual.GitCommit)
+	assert.Equal("2019-01-01T00:00:00Z", actual.BuildTime)
+	assert.Equal(started, actual.Started)
+
+	actual = BuildInfo{}.withDefaults(nil)
+	assert.Empty(actual.Version)
+	assert.Equal(runtime.Version(), actual.GoVersion)
+
+	actual = BuildInfo{}.withDefaults(&debug.BuildInfo{Main: debug.Module{Version: "(devel)"}})
+	assert.Empty(actual.Version)
+	assert.Equal(runtime.Version(), actual.GoVersion)
+}
+
+func TestNewInfoHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handler = NewInfoHandler(BuildInfo{
+			Version:   "1.0.0",
+			GitCommit: "abc123",
+			Extra:     map[string]string{"region": "east"},
+		})
+	)
+
+	require.NotNil(handler)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", DefaultInfoPath, nil))
+	assert.Equal(200, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var actual map[string]interface{}
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &actual))
+	assert.Equal("1.0.0", actual["version"])
+	assert.Equal("abc123", actual["gitCommit"])
+	assert.NotEmpty(actual["goVersion"])
+	assert.NotEmpty(actual["started"])
+	assert.Equal(map[string]interface{}{"region": "east"}, actual["extra"])
+	assert.NotContains(actual, "server")
+
+	request := httptest.NewRequest("GET", DefaultInfoPath, nil)
+	request = request.WithContext(
+		WithServerInfo(context.Background(), ServerInfo{Name: "health", Address: "127.0.0.1:8084"}),
+	)
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	actual = nil
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &actual))
+	assert.Equal(
+		map[string]interface{}{"name": "health", "address": "127.0.0.1:8084"},
+		actual["server"],
+	)
+}
@@ -30,6 +30,7 @@ func (bh *busyHandler) end() {
 
 func (bh *busyHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	if !bh.tryStart() {
+		MarkRejected(request.Context(), "busy")
 		bh.onBusy.ServeHTTP(response, request)
 		return
 	}
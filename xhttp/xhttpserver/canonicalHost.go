@@ -0,0 +1,84 @@
+package xhttpserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostname returns the lowercased host of a Host header value, without any port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// hostMatches tests if a hostname matches a pattern.  A pattern is either an exact hostname, or a
+// wildcard such as *.example.com that matches any subdomain at any depth, but not example.com itself.
+func hostMatches(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+
+	return pattern == host
+}
+
+// CanonicalHost is an Alice-style decorator that redirects requests for alternate hostnames, e.g. www.example.com,
+// to a single canonical host, e.g. example.com.  This keeps URLs and cookies consistent without a proxy in front of
+// the server.  The redirect preserves the path, the query, and the scheme the client used, including any scheme
+// forwarded by a trusted proxy.  See RequestScheme.
+//
+// Requests for the canonical host itself are never redirected, so a pattern that also matches the canonical
+// host does not cause a redirect loop.
+type CanonicalHost struct {
+	// Host is the canonical host, optionally with a port, to which requests are redirected.  If unset, no
+	// decoration is done.
+	Host string
+
+	// From lists the hostnames that are redirected.  Each entry is either an exact hostname or a wildcard,
+	// e.g. *.example.com, and is matched case-insensitively against the request's host without its port.
+	// If empty, no decoration is done.
+	From []string
+
+	// StatusCode is the redirect status.  If unset, http.StatusMovedPermanently is used.  Use
+	// http.StatusPermanentRedirect to preserve the method and body of non-GET requests.
+	StatusCode int
+}
+
+func (ch CanonicalHost) Then(next http.Handler) http.Handler {
+	if len(ch.Host) == 0 || len(ch.From) == 0 {
+		return next
+	}
+
+	var (
+		canonical = hostname(ch.Host)
+		from      = append([]string{}, ch.From...)
+	)
+
+	statusCode := ch.StatusCode
+	if statusCode <= 0 {
+		statusCode = http.StatusMovedPermanently
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		host := hostname(request.Host)
+		if host != canonical {
+			for _, pattern := range from {
+				if hostMatches(pattern, host) {
+					location := RequestScheme(request) + "://" + ch.Host + request.URL.RequestURI()
+					http.Redirect(response, request, location, statusCode)
+					return
+				}
+			}
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (ch CanonicalHost) ThenFunc(next http.HandlerFunc) http.Handler {
+	return ch.Then(next)
+}
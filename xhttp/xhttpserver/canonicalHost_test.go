@@ -0,0 +1,152 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCanonicalHostNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = Constant{}.NewHandler()
+	)
+
+	assert.Equal(next, CanonicalHost{From: []string{"www.example.com"}}.Then(next))
+	assert.Equal(next, CanonicalHost{Host: "example.com"}.Then(next))
+}
+
+func testCanonicalHostRedirect(t *testing.T) {
+	testData := []struct {
+		name             string
+		canonicalHost    CanonicalHost
+		target           string
+		host             string
+		tls              bool
+		expectedCode     int
+		expectedLocation string
+	}{
+		{
+			name:          "Canonical",
+			canonicalHost: CanonicalHost{Host: "example.com", From: []string{"www.example.com"}},
+			target:        "/test",
+			host:          "example.com",
+			expectedCode:  299,
+		},
+		{
+			name:          "Unmatched",
+			canonicalHost: CanonicalHost{Host: "example.com", From: []string{"www.example.com"}},
+			target:        "/test",
+			host:          "api.example.com",
+			expectedCode:  299,
+		},
+		{
+			name:             "Exact",
+			canonicalHost:    CanonicalHost{Host: "example.com", From: []string{"www.example.com"}},
+			target:           "/test/path?a=1&b=2",
+			host:             "WWW.Example.com",
+			expectedCode:     http.StatusMovedPermanently,
+			expectedLocation: "http://example.com/test/path?a=1&b=2",
+		},
+		{
+			name:             "Port",
+			canonicalHost:    CanonicalHost{Host: "example.com:8443", From: []string{"www.example.com"}},
+			target:           "/test",
+			host:             "www.example.com:8080",
+			tls:              true,
+			expectedCode:     http.StatusMovedPermanently,
+			expectedLocation: "https://example.com:8443/test",
+		},
+		{
+			name:             "Apex",
+			canonicalHost:    CanonicalHost{Host: "www.example.com", From: []string{"example.com"}},
+			target:           "/",
+			host:             "example.com",
+			expectedCode:     http.StatusMovedPermanently,
+			expectedLocation: "http://www.example.com/",
+		},
+		{
+			name:             "Wildcard",
+			canonicalHost:    CanonicalHost{Host: "example.com", From: []string{"*.example.com"}},
+			target:           "/test",
+			host:             "a.b.example.com",
+			expectedCode:     http.StatusMovedPermanently,
+			expectedLocation: "http://example.com/test",
+		},
+		{
+			name:          "WildcardCanonical",
+			canonicalHost: CanonicalHost{Host: "www.example.com", From: []string{"*.example.com"}},
+			target:        "/test",
+			host:          "www.example.com",
+			expectedCode:  299,
+		},
+		{
+			name:             "StatusCode",
+			canonicalHost:    CanonicalHost{Host: "example.com", From: []string{"www.example.com"}, StatusCode: http.StatusPermanentRedirect},
+			target:           "/test",
+			host:             "www.example.com",
+			expectedCode:     http.StatusPermanentRedirect,
+			expectedLocation: "http://example.com/test",
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				decorated = record.canonicalHost.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+					response.WriteHeader(299)
+				})
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", record.target, nil)
+			)
+
+			request.Host = record.host
+			if record.tls {
+				request.TLS = new(tls.ConnectionState)
+			}
+
+			decorated.ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+			assert.Equal(record.expectedLocation, response.HeaderMap.Get("Location"))
+		})
+	}
+}
+
+func testCanonicalHostForwarded(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		_, trusted, _ = net.ParseCIDR("10.0.0.0/8")
+
+		decorated = ForwardedScheme{Trusted: []*net.IPNet{trusted}}.Then(
+			CanonicalHost{Host: "example.com", From: []string{"www.example.com"}}.ThenFunc(
+				func(response http.ResponseWriter, _ *http.Request) {
+					assert.Fail("The decorated handler should not have been called")
+				},
+			),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/test", nil)
+	)
+
+	request.Host = "www.example.com"
+	request.RemoteAddr = "10.1.2.3:1234"
+	request.Header.Set("X-Forwarded-Proto", "https")
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusMovedPermanently, response.Code)
+	assert.Equal("https://example.com/test", response.HeaderMap.Get("Location"))
+}
+
+func TestCanonicalHost(t *testing.T) {
+	t.Run("NoDecoration", testCanonicalHostNoDecoration)
+	t.Run("Redirect", testCanonicalHostRedirect)
+	t.Run("Forwarded", testCanonicalHostForwarded)
+}
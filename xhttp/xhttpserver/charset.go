@@ -0,0 +1,119 @@
+package xhttpserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultCharsetTypes returns the media type prefixes that Charset treats as textual when none are configured.  A
+// distinct slice is returned with each call.
+func DefaultCharsetTypes() []string {
+	return []string{"text/", "application/json"}
+}
+
+// charsetWriter is a decorated http.ResponseWriter that adds a charset to textual Content-Type headers
+// just before the header is written
+type charsetWriter struct {
+	next     http.ResponseWriter
+	prefixes []string
+	written  bool
+}
+
+func (cw *charsetWriter) apply() {
+	if cw.written {
+		return
+	}
+
+	cw.written = true
+	header := cw.next.Header()
+	contentType := header.Get("Content-Type")
+	if len(contentType) == 0 || strings.Contains(strings.ToLower(contentType), "charset=") {
+		return
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	for _, prefix := range cw.prefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			header.Set("Content-Type", contentType+"; charset=utf-8")
+			return
+		}
+	}
+}
+
+// Unwrap returns the decorated http.ResponseWriter
+func (cw *charsetWriter) Unwrap() http.ResponseWriter {
+	return cw.next
+}
+
+func (cw *charsetWriter) Header() http.Header {
+	return cw.next.Header()
+}
+
+func (cw *charsetWriter) Write(b []byte) (int, error) {
+	cw.apply()
+	return cw.next.Write(b)
+}
+
+func (cw *charsetWriter) WriteHeader(statusCode int) {
+	cw.apply()
+	cw.next.WriteHeader(statusCode)
+}
+
+func (cw *charsetWriter) Flush() {
+	cw.apply()
+	if f, ok := cw.next.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *charsetWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.next.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, ErrHijackerNotSupported
+}
+
+func (cw *charsetWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := cw.next.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// Charset is an Alice-style decorator that ensures textual responses declare charset=utf-8 in their Content-Type.
+// Responses whose Content-Type already has a charset, has no Content-Type, or has a media type that does not
+// match one of the configured prefixes, e.g. binary responses, are left untouched.
+//
+// Since this package always produces UTF-8, any Accept-Charset sent by clients is ignored, as permitted by RFC 7231.
+type Charset struct {
+	// Types are the case-insensitive media type prefixes that are considered textual, e.g. text/ or application/json.
+	// If unset, DefaultCharsetTypes is used.
+	Types []string
+}
+
+func (c Charset) Then(next http.Handler) http.Handler {
+	types := c.Types
+	if len(types) == 0 {
+		types = DefaultCharsetTypes()
+	}
+
+	prefixes := make([]string, len(types))
+	for i, t := range types {
+		prefixes[i] = strings.ToLower(t)
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		next.ServeHTTP(
+			&charsetWriter{next: response, prefixes: prefixes},
+			request,
+		)
+	})
+}
+
+func (c Charset) ThenFunc(next http.HandlerFunc) http.Handler {
+	return c.Then(next)
+}
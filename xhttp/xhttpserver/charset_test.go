@@ -0,0 +1,107 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCharsetTypes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"text/", "application/json"}, DefaultCharsetTypes())
+
+	// each call must return a distinct slice, so that callers cannot alter the defaults
+	DefaultCharsetTypes()[0] = "image/"
+	assert.Equal("text/", DefaultCharsetTypes()[0])
+}
+
+func testCharset(t *testing.T, c Charset, contentType, expected string, writeHeader bool) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = c.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			if len(contentType) > 0 {
+				response.Header().Set("Content-Type", contentType)
+			}
+
+			if writeHeader {
+				response.WriteHeader(299)
+			}
+
+			response.Write([]byte("body"))
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(expected, response.Header().Get("Content-Type"))
+	assert.Equal("body", response.Body.String())
+}
+
+func TestCharset(t *testing.T) {
+	testData := []struct {
+		name        string
+		charset     Charset
+		contentType string
+		expected    string
+	}{
+		{"JSON", Charset{}, "application/json", "application/json; charset=utf-8"},
+		{"Text", Charset{}, "text/plain", "text/plain; charset=utf-8"},
+		{"CaseInsensitive", Charset{}, "Application/JSON", "Application/JSON; charset=utf-8"},
+		{"OtherParameters", Charset{}, "text/html; level=1", "text/html; level=1; charset=utf-8"},
+		{"ExistingCharset", Charset{}, "text/plain; charset=ISO-8859-1", "text/plain; charset=ISO-8859-1"},
+		{"Binary", Charset{}, "application/octet-stream", "application/octet-stream"},
+		{"Custom", Charset{Types: []string{"application/xml"}}, "application/xml", "application/xml; charset=utf-8"},
+		{"CustomExcludes", Charset{Types: []string{"application/xml"}}, "application/json", "application/json"},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			t.Run("WriteHeader", func(t *testing.T) {
+				testCharset(t, record.charset, record.contentType, record.expected, true)
+			})
+
+			t.Run("Write", func(t *testing.T) {
+				testCharset(t, record.charset, record.contentType, record.expected, false)
+			})
+		})
+	}
+
+	t.Run("NoContentType", func(t *testing.T) {
+		// net/http sniffs the content type, which already includes a charset for text
+		testCharset(t, Charset{}, "", "text/plain; charset=utf-8", false)
+	})
+
+	t.Run("Interfaces", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			decorated = Charset{}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+				assert.Implements((*http.Flusher)(nil), response)
+				assert.Implements((*http.Hijacker)(nil), response)
+				assert.Implements((*http.Pusher)(nil), response)
+
+				response.Header().Set("Content-Type", "text/plain")
+				response.(http.Flusher).Flush()
+				assert.Equal(http.ErrNotSupported, response.(http.Pusher).Push("/", nil))
+
+				_, _, err := response.(http.Hijacker).Hijack()
+				assert.Equal(ErrHijackerNotSupported, err)
+			})
+
+			response = httptest.NewRecorder()
+		)
+
+		require.NotNil(decorated)
+		decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.True(response.Flushed)
+		assert.Equal("text/plain; charset=utf-8", response.Header().Get("Content-Type"))
+	})
+}
@@ -0,0 +1,86 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RequireClientCertificate is an Alice-style decorator that enforces mutual TLS for particular URI paths.  Since
+// a tls.Config's ClientAuth applies to an entire listener, this allows a single port to serve some paths with
+// client certificates and others without.  The listener should use tls.VerifyClientCertIfGiven, e.g. via
+// Tls.ClientCertificateOptional, so that any certificate a client does present is verified during the handshake.
+//
+// Requests to a protected path without any client certificate receive a 401.  Requests whose client certificate
+// was not verified, e.g. because the listener only requests certificates, receive a 403.  All other requests
+// are passed through unchanged.
+type RequireClientCertificate struct {
+	// Paths are the URI path prefixes that require a verified client certificate.  A prefix matches whole path
+	// segments, e.g. /admin matches /admin and /admin/users but not /administrator.  If empty, no decoration is done.
+	Paths []string
+
+	// OnMissing is the optional handler for requests without a client certificate.  If unset, a 401 is returned.
+	OnMissing http.Handler
+
+	// OnUnverified is the optional handler for requests with an unverified client certificate.  If unset,
+	// a 403 is returned.
+	OnUnverified http.Handler
+}
+
+// protectedPath tests if a URI path falls under one of the given prefixes, which must be cleaned and
+// have no trailing slash.  The path itself is cleaned first, so that dot segments cannot bypass a prefix.
+func protectedPath(prefixes []string, p string) bool {
+	p = path.Clean("/" + p)
+	for _, prefix := range prefixes {
+		if prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rcc RequireClientCertificate) Then(next http.Handler) http.Handler {
+	if len(rcc.Paths) == 0 {
+		return next
+	}
+
+	prefixes := make([]string, 0, len(rcc.Paths))
+	for _, p := range rcc.Paths {
+		prefixes = append(prefixes, path.Clean("/"+p))
+	}
+
+	onMissing := rcc.OnMissing
+	if onMissing == nil {
+		onMissing = Constant{StatusCode: http.StatusUnauthorized}.NewHandler()
+	}
+
+	onUnverified := rcc.OnUnverified
+	if onUnverified == nil {
+		onUnverified = Constant{StatusCode: http.StatusForbidden}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !protectedPath(prefixes, request.URL.Path) {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		switch {
+		case request.TLS == nil || len(request.TLS.PeerCertificates) == 0:
+			MarkRejected(request.Context(), "clientCertificate")
+			onMissing.ServeHTTP(response, request)
+
+		case len(request.TLS.VerifiedChains) == 0:
+			MarkRejected(request.Context(), "clientCertificate")
+			onUnverified.ServeHTTP(response, request)
+
+		default:
+			next.ServeHTTP(response, request)
+		}
+	})
+}
+
+func (rcc RequireClientCertificate) ThenFunc(next http.HandlerFunc) http.Handler {
+	return rcc.Then(next)
+}
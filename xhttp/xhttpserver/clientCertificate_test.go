@@ -0,0 +1,94 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRequireClientCertificateNoPaths(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = RequireClientCertificate{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testRequireClientCertificate(t *testing.T, onMissing, onUnverified http.Handler, expectedMissing, expectedUnverified int) {
+	var (
+		cert = new(x509.Certificate)
+
+		missing    = new(tls.ConnectionState)
+		unverified = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		verified   = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+
+		decorated = RequireClientCertificate{
+			Paths:        []string{"/admin/", "/internal"},
+			OnMissing:    onMissing,
+			OnUnverified: onUnverified,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+	)
+
+	testData := []struct {
+		path     string
+		tls      *tls.ConnectionState
+		expected int
+	}{
+		{"/public", nil, 299},
+		{"/public", missing, 299},
+		{"/administrator", missing, 299},
+		{"/internalize", nil, 299},
+		{"/admin", nil, expectedMissing},
+		{"/admin", missing, expectedMissing},
+		{"/admin/users", missing, expectedMissing},
+		{"/public/../admin/users", missing, expectedMissing},
+		{"/internal", unverified, expectedUnverified},
+		{"/internal/status", unverified, expectedUnverified},
+		{"/admin/users", verified, 299},
+		{"/internal", verified, 299},
+	}
+
+	for _, record := range testData {
+		t.Run(record.path, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", "/", nil)
+			)
+
+			request.URL.Path = record.path
+			request.TLS = record.tls
+			decorated.ServeHTTP(response, request)
+			assert.Equal(record.expected, response.Code)
+		})
+	}
+}
+
+func TestRequireClientCertificate(t *testing.T) {
+	t.Run("NoPaths", testRequireClientCertificateNoPaths)
+	t.Run("Defaults", func(t *testing.T) {
+		testRequireClientCertificate(t, nil, nil, http.StatusUnauthorized, http.StatusForbidden)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		testRequireClientCertificate(
+			t,
+			Constant{StatusCode: 598}.NewHandler(),
+			Constant{StatusCode: 599}.NewHandler(),
+			598,
+			599,
+		)
+	})
+}
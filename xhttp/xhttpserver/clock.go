@@ -0,0 +1,71 @@
+package xhttpserver
+
+import "time"
+
+// Timer is the subset of a *time.Timer's behavior used by this package
+type Timer interface {
+	// C returns the channel on which the time is delivered when this Timer fires
+	C() <-chan time.Time
+
+	// Stop prevents this Timer from firing, returning false if it already fired or was stopped
+	Stop() bool
+}
+
+// Clock is the source of time used by the time-sensitive parts of this package:  body read deadlines, queue and
+// handshake waits, connection ages, slow handshake logging, and debug request durations.  Tests can supply their
+// own implementation, via Options.Clock, to control time deterministically.
+//
+// A Clock only governs the decisions this package makes itself.  Deadlines enforced by the operating system or
+// net/http, such as a connection's read deadline or a context's deadline, always use the system's real time.
+// The TrackingWriter does not measure time, so there is no time to first byte to control.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Since returns the time elapsed since t
+	Since(t time.Time) time.Duration
+
+	// NewTimer creates a Timer that fires once d has elapsed
+	NewTimer(d time.Duration) Timer
+}
+
+// systemTimer is the Timer implementation backed by the time package
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (st systemTimer) C() <-chan time.Time {
+	return st.t.C
+}
+
+func (st systemTimer) Stop() bool {
+	return st.t.Stop()
+}
+
+// systemClock is the Clock implementation backed by the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{t: time.NewTimer(d)}
+}
+
+// SystemClock is the Clock that uses the system's real time.  This is the default for all components
+// that accept a Clock.
+var SystemClock Clock = systemClock{}
+
+// clockOrSystem returns the given Clock, or SystemClock if c is nil
+func clockOrSystem(c Clock) Clock {
+	if c != nil {
+		return c
+	}
+
+	return SystemClock
+}
@@ -0,0 +1,160 @@
+package xhttpserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is a Clock whose time only changes when Add is called
+type testClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*testTimer
+}
+
+// testTimer is a Timer that fires when its testClock is advanced past its deadline
+type testTimer struct {
+	clock *testClock
+	when  time.Time
+	c     chan time.Time
+}
+
+func (tt *testTimer) C() <-chan time.Time {
+	return tt.c
+}
+
+func (tt *testTimer) Stop() bool {
+	tt.clock.lock.Lock()
+	defer tt.clock.lock.Unlock()
+
+	for i, t := range tt.clock.timers {
+		if t == tt {
+			tt.clock.timers = append(tt.clock.timers[:i], tt.clock.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTestClock() *testClock {
+	return &testClock{
+		now: time.Date(2019, time.July, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func (tc *testClock) Now() time.Time {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	return tc.now
+}
+
+func (tc *testClock) Since(t time.Time) time.Duration {
+	return tc.Now().Sub(t)
+}
+
+func (tc *testClock) NewTimer(d time.Duration) Timer {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tt := &testTimer{
+		clock: tc,
+		when:  tc.now.Add(d),
+		c:     make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		tt.c <- tc.now
+	} else {
+		tc.timers = append(tc.timers, tt)
+	}
+
+	return tt
+}
+
+// Timers returns the number of timers that have neither fired nor been stopped
+func (tc *testClock) Timers() int {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	return len(tc.timers)
+}
+
+// Add advances this clock by the given duration, firing any timers that expire
+func (tc *testClock) Add(d time.Duration) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.now = tc.now.Add(d)
+	pending := tc.timers[:0]
+	for _, tt := range tc.timers {
+		if tt.when.After(tc.now) {
+			pending = append(pending, tt)
+		} else {
+			tt.c <- tc.now
+		}
+	}
+
+	tc.timers = pending
+}
+
+func TestSystemClock(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		before = time.Now()
+		now    = SystemClock.Now()
+	)
+
+	assert.False(now.Before(before))
+	assert.True(SystemClock.Since(before) >= 0)
+
+	timer := SystemClock.NewTimer(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(5 * time.Second):
+		assert.Fail("the timer did not fire")
+	}
+
+	assert.False(timer.Stop())
+	assert.True(SystemClock.NewTimer(time.Hour).Stop())
+}
+
+func TestTestClockTimers(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = newTestClock()
+
+		immediate = clock.NewTimer(0)
+		first     = clock.NewTimer(time.Second)
+		second    = clock.NewTimer(time.Minute)
+		stopped   = clock.NewTimer(time.Second)
+	)
+
+	assert.Len(immediate.C(), 1)
+	assert.Equal(3, clock.Timers())
+	assert.True(stopped.Stop())
+	assert.False(stopped.Stop())
+
+	clock.Add(time.Second)
+	assert.Len(first.C(), 1)
+	assert.Empty(second.C())
+	assert.Empty(stopped.C())
+	assert.Equal(1, clock.Timers())
+	assert.False(first.Stop())
+
+	clock.Add(time.Minute)
+	assert.Len(second.C(), 1)
+	assert.Zero(clock.Timers())
+}
+
+func TestClockOrSystem(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = newTestClock()
+	)
+
+	assert.Equal(SystemClock, clockOrSystem(nil))
+	assert.Equal(clock, clockOrSystem(clock))
+}
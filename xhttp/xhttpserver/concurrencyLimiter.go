@@ -0,0 +1,204 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	queueKey = "queue_ms"
+)
+
+// QueueKey is the logging key for the time, in milliseconds, that a request waited in a ConcurrencyLimiter's queue
+func QueueKey() interface{} {
+	return queueKey
+}
+
+type queueWaitContextKey struct{}
+
+// WithQueueWait returns a new context with the given queue wait time
+func WithQueueWait(ctx context.Context, wait time.Duration) context.Context {
+	return context.WithValue(ctx, queueWaitContextKey{}, wait)
+}
+
+// QueueWaitFromContext returns the time a request waited for a slot in a ConcurrencyLimiter, as stored by that
+// limiter.  The returned boolean is false if the request did not pass through a ConcurrencyLimiter.
+func QueueWaitFromContext(ctx context.Context) (time.Duration, bool) {
+	wait, ok := ctx.Value(queueWaitContextKey{}).(time.Duration)
+	return wait, ok
+}
+
+// ConcurrencyMetrics holds the optional gauges that report the state of a ConcurrencyLimiter.  Any labels,
+// such as the server name, must already be applied to these gauges, e.g. by currying.
+type ConcurrencyMetrics struct {
+	// Concurrent tracks the number of requests currently executing the decorated handler
+	Concurrent xmetrics.GaugeAdder
+
+	// Queued tracks the number of requests currently waiting to execute the decorated handler
+	Queued xmetrics.GaugeAdder
+}
+
+// ConcurrencyMetricsFactory is a creation strategy for server-specific ConcurrencyMetrics.  It is only
+// used for servers that configure a ConcurrencyLimit.
+type ConcurrencyMetricsFactory interface {
+	New(string, Options) (ConcurrencyMetrics, error)
+}
+
+type ConcurrencyMetricsFactoryFunc func(string, Options) (ConcurrencyMetrics, error)
+
+func (cmff ConcurrencyMetricsFactoryFunc) New(n string, o Options) (ConcurrencyMetrics, error) {
+	return cmff(n, o)
+}
+
+// gaugeAdd applies a delta to a gauge, if one is present
+func gaugeAdd(g xmetrics.GaugeAdder, delta float64) {
+	if g != nil {
+		g.GaugeAdd(nil, delta)
+	}
+}
+
+// concurrencyLimiterHandler is the internal http.Handler implementation that bounds concurrent executions
+// of another http.Handler, queueing requests that exceed that bound
+type concurrencyLimiterHandler struct {
+	next       http.Handler
+	onRejected http.Handler
+	metrics    ConcurrencyMetrics
+	clock      Clock
+
+	slots    chan struct{}
+	maxQueue int32
+	maxWait  time.Duration
+	queued   int32
+}
+
+// wait queues the current request until a slot is available.  This method returns false if the queue
+// is full, the maximum wait time elapses, or the request is canceled.
+func (clh *concurrencyLimiterHandler) wait(request *http.Request) bool {
+	if atomic.AddInt32(&clh.queued, 1) > clh.maxQueue {
+		atomic.AddInt32(&clh.queued, -1)
+		return false
+	}
+
+	gaugeAdd(clh.metrics.Queued, 1.0)
+	defer func() {
+		atomic.AddInt32(&clh.queued, -1)
+		gaugeAdd(clh.metrics.Queued, -1.0)
+	}()
+
+	var expired <-chan time.Time
+	if clh.maxWait > 0 {
+		timer := clh.clock.NewTimer(clh.maxWait)
+		defer timer.Stop()
+		expired = timer.C()
+	}
+
+	select {
+	case clh.slots <- struct{}{}:
+		return true
+
+	case <-expired:
+		return false
+
+	case <-request.Context().Done():
+		return false
+	}
+}
+
+func (clh *concurrencyLimiterHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	start := clh.clock.Now()
+	select {
+	case clh.slots <- struct{}{}:
+		// a slot was immediately available
+
+	default:
+		if !clh.wait(request) {
+			MarkRejected(request.Context(), "concurrencyLimit")
+			clh.onRejected.ServeHTTP(response, request)
+			return
+		}
+	}
+
+	gaugeAdd(clh.metrics.Concurrent, 1.0)
+	defer func() {
+		gaugeAdd(clh.metrics.Concurrent, -1.0)
+		<-clh.slots
+	}()
+
+	var (
+		wait = clh.clock.Since(start)
+		ctx  = WithQueueWait(request.Context(), wait)
+	)
+
+	if logger := xlog.GetDefault(ctx, nil); logger != nil {
+		ctx = xlog.With(ctx, log.With(logger, QueueKey(), int64(wait/time.Millisecond)))
+	}
+
+	clh.next.ServeHTTP(response, request.WithContext(ctx))
+}
+
+// ConcurrencyLimiter is an Alice-style decorator that bounds the number of concurrent executions of a handler.
+// Unlike Busy, which immediately rejects excess requests, requests beyond MaxConcurrent wait in a bounded queue
+// for a slot to become available.  This gives smoother behavior for CPU-bound handlers.
+//
+// The time each request spent waiting for a slot is available via QueueWaitFromContext.  If the request has a
+// contextual logger, that logger is enriched with the wait in milliseconds under QueueKey.  This distinguishes
+// latency due to saturation from latency due to slow handlers.
+type ConcurrencyLimiter struct {
+	// MaxConcurrent is the maximum number of concurrent executions of the decorated handler.  If this
+	// value is nonpositive, no decoration is done.
+	MaxConcurrent int
+
+	// MaxQueue is the maximum number of requests that may wait for a slot.  Requests beyond this limit are
+	// rejected immediately.  If this value is nonpositive, no requests are queued.
+	MaxQueue int
+
+	// MaxWait is the maximum time a request may wait in the queue before being rejected.  If unset,
+	// queued requests wait until a slot is available or the request is canceled.
+	MaxWait time.Duration
+
+	// OnRejected is the optional handler for rejected requests.  If unset, a 503 is returned.
+	OnRejected http.Handler
+
+	// Metrics are the optional gauges updated as requests execute and wait
+	Metrics ConcurrencyMetrics
+
+	// Clock is the optional source of time for measuring and expiring queue waits.  If unset, SystemClock is used.
+	Clock Clock
+}
+
+func (cl ConcurrencyLimiter) Then(next http.Handler) http.Handler {
+	if cl.MaxConcurrent < 1 {
+		return next
+	}
+
+	clh := &concurrencyLimiterHandler{
+		next:    next,
+		metrics: cl.Metrics,
+		clock:   clockOrSystem(cl.Clock),
+		slots:   make(chan struct{}, cl.MaxConcurrent),
+		maxWait: cl.MaxWait,
+	}
+
+	if cl.MaxQueue > 0 {
+		clh.maxQueue = int32(cl.MaxQueue)
+	}
+
+	if cl.OnRejected != nil {
+		clh.onRejected = cl.OnRejected
+	} else {
+		clh.onRejected = Constant{StatusCode: http.StatusServiceUnavailable}.NewHandler()
+	}
+
+	return clh
+}
+
+func (cl ConcurrencyLimiter) ThenFunc(next http.HandlerFunc) http.Handler {
+	return cl.Then(next)
+}
@@ -0,0 +1,290 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGauge is an xmetrics.GaugeAdder that simply tracks its current value
+type testGauge struct {
+	lock  sync.Mutex
+	value float64
+}
+
+func (tg *testGauge) GaugeAdd(_ *xmetrics.Labels, delta float64) {
+	tg.lock.Lock()
+	tg.value += delta
+	tg.lock.Unlock()
+}
+
+func (tg *testGauge) Value() float64 {
+	tg.lock.Lock()
+	defer tg.lock.Unlock()
+	return tg.value
+}
+
+func testConcurrencyLimiterNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next    = Constant{}.NewHandler()
+		limiter = ConcurrencyLimiter{MaxQueue: 10}.Then(next)
+	)
+
+	assert.Equal(next, limiter)
+}
+
+func testConcurrencyLimiterQueue(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		concurrent = new(testGauge)
+		queued     = new(testGauge)
+
+		entered = make(chan struct{}, 2)
+		block   = make(chan struct{})
+		limiter = ConcurrencyLimiter{
+			MaxConcurrent: 1,
+			MaxQueue:      1,
+			Metrics:       ConcurrencyMetrics{Concurrent: concurrent, Queued: queued},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			entered <- struct{}{}
+			<-block
+			response.WriteHeader(299)
+		})
+
+		responses = [2]*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder()}
+		finished  sync.WaitGroup
+	)
+
+	require.NotNil(limiter)
+	finished.Add(2)
+	go func() {
+		defer finished.Done()
+		limiter.ServeHTTP(responses[0], httptest.NewRequest("GET", "/", nil))
+	}()
+
+	<-entered
+	assert.Equal(1.0, concurrent.Value())
+
+	go func() {
+		defer finished.Done()
+		limiter.ServeHTTP(responses[1], httptest.NewRequest("GET", "/", nil))
+	}()
+
+	require.Eventually(func() bool { return queued.Value() == 1.0 }, 5*time.Second, 10*time.Millisecond)
+
+	// both the concurrency limit and the queue are full
+	rejected := httptest.NewRecorder()
+	limiter.ServeHTTP(rejected, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusServiceUnavailable, rejected.Code)
+
+	close(block)
+	finished.Wait()
+
+	assert.Equal(299, responses[0].Code)
+	assert.Equal(299, responses[1].Code)
+	assert.Equal(0.0, concurrent.Value())
+	assert.Equal(0.0, queued.Value())
+}
+
+func testConcurrencyLimiterMaxWait(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		clock  = newTestClock()
+		queued = new(testGauge)
+
+		entered = make(chan struct{})
+		block   = make(chan struct{})
+		limiter = ConcurrencyLimiter{
+			MaxConcurrent: 1,
+			MaxQueue:      1,
+			MaxWait:       50 * time.Millisecond,
+			OnRejected: http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(599)
+			}),
+			Metrics: ConcurrencyMetrics{Queued: queued},
+			Clock:   clock,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			close(entered)
+			<-block
+		})
+
+		finished sync.WaitGroup
+	)
+
+	require.NotNil(limiter)
+	finished.Add(1)
+	go func() {
+		defer finished.Done()
+		limiter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	<-entered
+	rejected := make(chan int, 1)
+	go func() {
+		response := httptest.NewRecorder()
+		limiter.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		rejected <- response.Code
+	}()
+
+	// the queued request is only rejected once the clock passes MaxWait
+	require.Eventually(func() bool { return clock.Timers() == 1 }, 5*time.Second, 10*time.Millisecond)
+	clock.Add(49 * time.Millisecond)
+	assert.Empty(rejected)
+	clock.Add(time.Millisecond)
+	assert.Equal(599, <-rejected)
+	assert.Equal(0.0, queued.Value())
+
+	close(block)
+	finished.Wait()
+}
+
+func testConcurrencyLimiterCanceled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		entered = make(chan struct{})
+		block   = make(chan struct{})
+		limiter = ConcurrencyLimiter{
+			MaxConcurrent: 1,
+			MaxQueue:      1,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			close(entered)
+			<-block
+		})
+
+		finished sync.WaitGroup
+	)
+
+	require.NotNil(limiter)
+	finished.Add(1)
+	go func() {
+		defer finished.Done()
+		limiter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	<-entered
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	response := httptest.NewRecorder()
+	limiter.ServeHTTP(response, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+
+	close(block)
+	finished.Wait()
+}
+
+func testConcurrencyLimiterQueueWait(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		clock  = newTestClock()
+		queued = new(testGauge)
+		output bytes.Buffer
+
+		entered = make(chan struct{}, 2)
+		block   = make(chan struct{})
+		waits   = make(chan time.Duration, 2)
+		limiter = ConcurrencyLimiter{
+			MaxConcurrent: 1,
+			MaxQueue:      1,
+			Clock:         clock,
+			Metrics:       ConcurrencyMetrics{Queued: queued},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			wait, ok := QueueWaitFromContext(request.Context())
+			assert.True(ok)
+			waits <- wait
+			entered <- struct{}{}
+			<-block
+		})
+
+		finished sync.WaitGroup
+	)
+
+	require.NotNil(limiter)
+	finished.Add(2)
+	go func() {
+		defer finished.Done()
+		limiter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	<-entered
+	assert.Equal(time.Duration(0), <-waits)
+
+	go func() {
+		defer finished.Done()
+		request := httptest.NewRequest("GET", "/", nil)
+		limiter.ServeHTTP(
+			httptest.NewRecorder(),
+			request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output))),
+		)
+	}()
+
+	require.Eventually(func() bool { return queued.Value() == 1.0 }, 5*time.Second, 10*time.Millisecond)
+	clock.Add(250 * time.Millisecond)
+	block <- struct{}{}
+
+	<-entered
+	assert.Equal(250*time.Millisecond, <-waits)
+	close(block)
+	finished.Wait()
+
+	_, ok := QueueWaitFromContext(context.Background())
+	assert.False(ok)
+	assert.Zero(output.Len())
+}
+
+func testConcurrencyLimiterQueueLogger(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output  bytes.Buffer
+		limiter = ConcurrencyLimiter{
+			MaxConcurrent: 1,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			xlog.Get(request.Context()).Log("message", "test")
+		})
+
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	limiter.ServeHTTP(
+		httptest.NewRecorder(),
+		request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output))),
+	)
+
+	var record map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &record))
+	assert.Equal(0.0, record[queueKey])
+	assert.Equal("test", record["message"])
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	t.Run("NoDecoration", testConcurrencyLimiterNoDecoration)
+	t.Run("Queue", testConcurrencyLimiterQueue)
+	t.Run("MaxWait", testConcurrencyLimiterMaxWait)
+	t.Run("Canceled", testConcurrencyLimiterCanceled)
+	t.Run("QueueWait", testConcurrencyLimiterQueueWait)
+	t.Run("QueueLogger", testConcurrencyLimiterQueueLogger)
+}
@@ -0,0 +1,237 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connectionInfo holds the facts about a single connection needed to enforce MaxConnectionAge
+type connectionInfo struct {
+	accepted time.Time
+	requests int64
+}
+
+type connectionInfoContextKey struct{}
+
+// WithConnectionAccepted returns a new context that records when the connection carrying its requests was
+// accepted.  This is normally done by the server's ConnContext.
+func WithConnectionAccepted(ctx context.Context, accepted time.Time) context.Context {
+	return context.WithValue(ctx, connectionInfoContextKey{}, &connectionInfo{accepted: accepted})
+}
+
+// ConnectionAcceptedFromContext returns the time that the connection carrying a request was accepted.  If the
+// context has no such time, this function returns false.
+func ConnectionAcceptedFromContext(ctx context.Context) (time.Time, bool) {
+	if ci, ok := ctx.Value(connectionInfoContextKey{}).(*connectionInfo); ok {
+		return ci.accepted, true
+	}
+
+	return time.Time{}, false
+}
+
+// newConnContext produces the http.Server.ConnContext for the given options, or nil if none is needed
+func newConnContext(o Options) func(context.Context, net.Conn) context.Context {
+	var (
+		policy = NewConnContext(o.ConnectionPolicy)
+		clock  = clockOrSystem(o.Clock)
+		age    = o.MaxConnectionAge > 0 || o.HTTP2MaxConnectionAge > 0 || o.HTTP2MaxConnectionRequests > 0
+	)
+
+	switch {
+	case !age:
+		return policy
+
+	case policy == nil:
+		return func(ctx context.Context, _ net.Conn) context.Context {
+			return WithConnectionAccepted(ctx, clock.Now())
+		}
+
+	default:
+		return func(ctx context.Context, conn net.Conn) context.Context {
+			return WithConnectionAccepted(policy(ctx, conn), clock.Now())
+		}
+	}
+}
+
+// MaxConnectionAge is an Alice-style decorator that asks clients to stop using connections that have been open,
+// or in use, for too long.  Limits are applied separately to HTTP/1.x and HTTP/2 connections, as detected via
+// DetectProtocol, since an HTTP/2 connection multiplexes what would otherwise be many HTTP/1.x connections.
+//
+// Once a connection exceeds its limits, each response on it carries a Connection: close header.  For HTTP/1.x,
+// net/http closes the connection after that response.  For HTTP/2, net/http instead sends a GOAWAY, so the client
+// opens a new connection for subsequent requests while streams already open complete normally.
+//
+// This decorator requires the server's ConnContext to record when each connection was accepted, as New does
+// whenever any of these limits are configured.  Requests without that information are never affected.  Since
+// this decorator only sees requests, New also closes idle connections once they exceed their maximum age.  Servers
+// built without New only close such connections after their next request or once IdleTimeout expires.
+//
+// Load balancers that balance by connection, rather than by request, never move long-lived connections.  After
+// a scale out or a rolling restart, existing clients remain pinned to the old instances.  A maximum age forces
+// clients to reconnect periodically, which lets the load balancer spread them over the current instances.  Ages
+// should be long compared to typical request latency, so that reconnection overhead stays small, and should be
+// jittered by the client or staggered across instances if many clients connect at once.
+type MaxConnectionAge struct {
+	// HTTP1 is the maximum age of HTTP/1.x connections.  If unset, HTTP/1.x connections have no maximum age.
+	HTTP1 time.Duration
+
+	// HTTP2 is the maximum age of HTTP/2 connections.  If unset, HTTP/2 connections have no maximum age.
+	HTTP2 time.Duration
+
+	// HTTP2MaxRequests is the maximum number of requests, i.e. streams, that each HTTP/2 connection may serve over
+	// its lifetime.  If unset, there is no such limit.
+	HTTP2MaxRequests int
+
+	// Clock is the optional source of time.  If unset, SystemClock is used.
+	Clock Clock
+}
+
+func (mca MaxConnectionAge) Then(next http.Handler) http.Handler {
+	if mca.HTTP1 <= 0 && mca.HTTP2 <= 0 && mca.HTTP2MaxRequests <= 0 {
+		return next
+	}
+
+	clock := clockOrSystem(mca.Clock)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ci, ok := request.Context().Value(connectionInfoContextKey{}).(*connectionInfo)
+		if ok {
+			var (
+				requests = atomic.AddInt64(&ci.requests, 1)
+				age      = clock.Since(ci.accepted)
+				expired  bool
+			)
+
+			if DetectProtocol(request) == ProtocolHTTP11 {
+				expired = mca.HTTP1 > 0 && age >= mca.HTTP1
+			} else {
+				expired = (mca.HTTP2 > 0 && age >= mca.HTTP2) ||
+					(mca.HTTP2MaxRequests > 0 && requests >= int64(mca.HTTP2MaxRequests))
+			}
+
+			if expired {
+				response.Header().Set("Connection", "close")
+			}
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (mca MaxConnectionAge) ThenFunc(next http.HandlerFunc) http.Handler {
+	return mca.Then(next)
+}
+
+// agedConnection is the state of a single connection tracked by an idleConnectionAger
+type agedConnection struct {
+	accepted time.Time
+	idle     bool
+	stop     chan struct{}
+}
+
+// idleConnectionAger closes idle connections that exceed their maximum age.  MaxConnectionAge only acts on
+// connections that serve requests, so without this an idle keep-alive connection would outlive its age.  Its
+// ConnState method is intended to be invoked from http.Server.ConnState.
+//
+// HTTP/2 connections are recognized by the protocol negotiated via TLS.  Any other connection is subject to the
+// HTTP/1.x age.  As with ConnectionTracker.CloseIdle, connections are closed while holding the lock, so a
+// connection that becomes active in the meantime is never closed mid-request.
+type idleConnectionAger struct {
+	http1 time.Duration
+	http2 time.Duration
+	clock Clock
+
+	lock  sync.Mutex
+	conns map[net.Conn]*agedConnection
+}
+
+// newIdleConnectionAger creates an idleConnectionAger for the given options, or returns nil if the options
+// configure no maximum age
+func newIdleConnectionAger(o Options) *idleConnectionAger {
+	if o.MaxConnectionAge <= 0 && o.HTTP2MaxConnectionAge <= 0 {
+		return nil
+	}
+
+	return &idleConnectionAger{
+		http1: o.MaxConnectionAge,
+		http2: o.HTTP2MaxConnectionAge,
+		clock: clockOrSystem(o.Clock),
+		conns: make(map[net.Conn]*agedConnection),
+	}
+}
+
+// maxAge returns the maximum age of the given connection, which is nonpositive if the connection has none
+func (ica *idleConnectionAger) maxAge(c net.Conn) time.Duration {
+	if cs, ok := c.(interface{ ConnectionState() tls.ConnectionState }); ok && cs.ConnectionState().NegotiatedProtocol == "h2" {
+		return ica.http2
+	}
+
+	return ica.http1
+}
+
+func (ica *idleConnectionAger) ConnState(c net.Conn, cs http.ConnState) {
+	ica.lock.Lock()
+	defer ica.lock.Unlock()
+
+	ac, ok := ica.conns[c]
+	switch {
+	case cs == http.StateNew:
+		ica.conns[c] = &agedConnection{accepted: ica.clock.Now()}
+
+	case !ok:
+		// not tracked, e.g. a connection accepted before this instance was in use
+
+	case cs == http.StateIdle:
+		ac.idle = true
+		maxAge := ica.maxAge(c)
+		if maxAge <= 0 {
+			return
+		}
+
+		remaining := maxAge - ica.clock.Since(ac.accepted)
+		if remaining <= 0 {
+			c.Close()
+			return
+		}
+
+		if ac.stop != nil {
+			// the timer for this connection is already running
+			return
+		}
+
+		ac.stop = make(chan struct{})
+		go ica.closeWhenIdle(c, ac, ica.clock.NewTimer(remaining))
+
+	case cs == http.StateActive:
+		ac.idle = false
+
+	default:
+		// hijacked or closed
+		if ac.stop != nil {
+			close(ac.stop)
+		}
+
+		delete(ica.conns, c)
+	}
+}
+
+// closeWhenIdle closes the given connection once the timer fires, if the connection is idle at that time.  A
+// connection that is active when its timer fires is left to MaxConnectionAge, which closes it after its response.
+func (ica *idleConnectionAger) closeWhenIdle(c net.Conn, ac *agedConnection, t Timer) {
+	select {
+	case <-t.C():
+		ica.lock.Lock()
+		if ac.idle {
+			c.Close()
+		}
+
+		ica.lock.Unlock()
+
+	case <-ac.stop:
+		t.Stop()
+	}
+}
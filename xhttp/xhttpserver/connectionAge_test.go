@@ -0,0 +1,358 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConnContextConnectionAge(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = newTestClock()
+	)
+
+	assert.Nil(newConnContext(Options{}))
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	accepted, ok := ConnectionAcceptedFromContext(context.Background())
+	assert.False(ok)
+	assert.True(accepted.IsZero())
+
+	connContext := newConnContext(Options{MaxConnectionAge: time.Minute, Clock: clock})
+	require.NotNil(connContext)
+	accepted, ok = ConnectionAcceptedFromContext(connContext(context.Background(), server))
+	assert.True(ok)
+	assert.Equal(clock.Now(), accepted)
+
+	connContext = newConnContext(Options{
+		HTTP2MaxConnectionRequests: 10,
+		ConnectionPolicy: func(net.Conn, *tls.ConnectionState) error {
+			return errors.New("expected")
+		},
+		Clock: clock,
+	})
+
+	require.NotNil(connContext)
+	ctx := connContext(context.Background(), server)
+	_, ok = ConnectionAcceptedFromContext(ctx)
+	assert.True(ok)
+	assert.EqualError(ConnectionPolicyResult(ctx), "expected")
+}
+
+func testMaxConnectionAgeUnconfigured(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	)
+
+	assert.NotNil(MaxConnectionAge{}.Then(next))
+	assert.NotNil(MaxConnectionAge{}.ThenFunc(next))
+}
+
+func testMaxConnectionAgeNoConnection(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		decorated = MaxConnectionAge{HTTP1: time.Nanosecond}.ThenFunc(
+			func(response http.ResponseWriter, _ *http.Request) {
+				response.WriteHeader(299)
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Empty(response.Header().Get("Connection"))
+}
+
+func testMaxConnectionAgeHTTP1(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = newTestClock()
+
+		decorated = MaxConnectionAge{
+			HTTP1:            time.Minute,
+			HTTP2:            time.Hour,
+			HTTP2MaxRequests: 1,
+			Clock:            clock,
+		}.ThenFunc(func(http.ResponseWriter, *http.Request) {})
+
+		ctx = WithConnectionAccepted(context.Background(), clock.Now())
+	)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Empty(response.Header().Get("Connection"))
+
+	// the request limit only applies to HTTP/2
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Empty(response.Header().Get("Connection"))
+
+	clock.Add(time.Minute)
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Equal("close", response.Header().Get("Connection"))
+}
+
+func testMaxConnectionAgeHTTP2(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = newTestClock()
+
+		decorated = MaxConnectionAge{
+			HTTP1:            time.Minute,
+			HTTP2:            time.Hour,
+			HTTP2MaxRequests: 3,
+			Clock:            clock,
+		}.ThenFunc(func(http.ResponseWriter, *http.Request) {})
+
+		newRequest = func(ctx context.Context) *http.Request {
+			request := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+			request.ProtoMajor = 2
+			return request
+		}
+	)
+
+	ctx := WithConnectionAccepted(context.Background(), clock.Now())
+	clock.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		response := httptest.NewRecorder()
+		decorated.ServeHTTP(response, newRequest(ctx))
+		assert.Empty(response.Header().Get("Connection"))
+	}
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, newRequest(ctx))
+	assert.Equal("close", response.Header().Get("Connection"))
+
+	ctx = WithConnectionAccepted(context.Background(), clock.Now())
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, newRequest(ctx))
+	assert.Empty(response.Header().Get("Connection"))
+
+	clock.Add(time.Hour)
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, newRequest(ctx))
+	assert.Equal("close", response.Header().Get("Connection"))
+}
+
+func testMaxConnectionAgeServer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewUnstartedServer(
+			MaxConnectionAge{HTTP1: time.Nanosecond}.ThenFunc(
+				func(response http.ResponseWriter, _ *http.Request) {
+					response.WriteHeader(299)
+				},
+			),
+		)
+	)
+
+	server.Config.ConnContext = newConnContext(Options{MaxConnectionAge: time.Nanosecond})
+	server.Start()
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL)
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(299, response.StatusCode)
+	assert.True(response.Close)
+}
+
+func TestMaxConnectionAge(t *testing.T) {
+	t.Run("Unconfigured", testMaxConnectionAgeUnconfigured)
+	t.Run("NoConnection", testMaxConnectionAgeNoConnection)
+	t.Run("HTTP1", testMaxConnectionAgeHTTP1)
+	t.Run("HTTP2", testMaxConnectionAgeHTTP2)
+	t.Run("Server", testMaxConnectionAgeServer)
+}
+
+// agedConn is a net.Conn that records when it is closed, optionally negotiating a protocol via TLS
+type agedConn struct {
+	net.Conn
+	protocol string
+	once     sync.Once
+	closed   chan struct{}
+}
+
+func newAgedConn(protocol string) *agedConn {
+	return &agedConn{protocol: protocol, closed: make(chan struct{})}
+}
+
+func (ac *agedConn) ConnectionState() tls.ConnectionState {
+	return tls.ConnectionState{NegotiatedProtocol: ac.protocol}
+}
+
+func (ac *agedConn) Close() error {
+	ac.once.Do(func() { close(ac.closed) })
+	return nil
+}
+
+func (ac *agedConn) isClosed() bool {
+	select {
+	case <-ac.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func testIdleConnectionAgerUnconfigured(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(newIdleConnectionAger(Options{}))
+	assert.Nil(newIdleConnectionAger(Options{HTTP2MaxConnectionRequests: 10}))
+}
+
+func testIdleConnectionAgerIdle(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = newTestClock()
+
+		ager = newIdleConnectionAger(Options{
+			MaxConnectionAge:      time.Minute,
+			HTTP2MaxConnectionAge: time.Hour,
+			Clock:                 clock,
+		})
+
+		http1 = newAgedConn("")
+		http2 = newAgedConn("h2")
+	)
+
+	require.NotNil(ager)
+	for _, c := range []*agedConn{http1, http2} {
+		ager.ConnState(c, http.StateNew)
+		ager.ConnState(c, http.StateActive)
+		ager.ConnState(c, http.StateIdle)
+	}
+
+	require.Equal(2, clock.Timers())
+	clock.Add(time.Minute)
+	<-http1.closed
+	assert.False(http2.isClosed())
+
+	clock.Add(time.Hour)
+	<-http2.closed
+}
+
+func testIdleConnectionAgerActive(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = newTestClock()
+
+		ager = newIdleConnectionAger(Options{MaxConnectionAge: time.Minute, Clock: clock})
+		c    = newAgedConn("")
+	)
+
+	require.NotNil(ager)
+	ager.ConnState(c, http.StateNew)
+	ager.ConnState(c, http.StateIdle)
+	require.Equal(1, clock.Timers())
+
+	// a connection that is active when it reaches its age is left alone until it is idle again
+	ager.ConnState(c, http.StateActive)
+	clock.Add(time.Minute)
+	ager.ConnState(c, http.StateActive)
+	assert.False(c.isClosed())
+
+	ager.ConnState(c, http.StateIdle)
+	assert.True(c.isClosed())
+}
+
+func testIdleConnectionAgerClosed(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = newTestClock()
+
+		ager = newIdleConnectionAger(Options{MaxConnectionAge: time.Minute, Clock: clock})
+		c    = newAgedConn("")
+	)
+
+	require.NotNil(ager)
+
+	// untracked connections are ignored
+	ager.ConnState(c, http.StateIdle)
+	assert.Zero(clock.Timers())
+
+	ager.ConnState(c, http.StateNew)
+	ager.ConnState(c, http.StateIdle)
+	require.Equal(1, clock.Timers())
+
+	ager.ConnState(c, http.StateClosed)
+	require.Eventually(
+		func() bool { return clock.Timers() == 0 },
+		time.Second,
+		10*time.Millisecond,
+	)
+
+	clock.Add(time.Minute)
+	assert.False(c.isClosed())
+	assert.Empty(ager.conns)
+}
+
+func testIdleConnectionAgerServer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		closed = make(chan struct{})
+		s      = New(
+			Options{MaxConnectionAge: 100 * time.Millisecond},
+			log.NewNopLogger(),
+			http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+				response.WriteHeader(299)
+			}),
+			func(_ net.Conn, cs http.ConnState) {
+				if cs == http.StateClosed {
+					close(closed)
+				}
+			},
+		)
+	)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	response, err := http.Get("http://" + l.Addr().String())
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(299, response.StatusCode)
+
+	// the idle keep-alive connection is closed once it reaches its maximum age
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		assert.Fail("the idle connection was not closed")
+	}
+}
+
+func TestIdleConnectionAger(t *testing.T) {
+	t.Run("Unconfigured", testIdleConnectionAgerUnconfigured)
+	t.Run("Idle", testIdleConnectionAgerIdle)
+	t.Run("Active", testIdleConnectionAgerActive)
+	t.Run("Closed", testIdleConnectionAgerClosed)
+	t.Run("Server", testIdleConnectionAgerServer)
+}
@@ -0,0 +1,156 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConnectionPolicy evaluates connection-level facts, e.g. the negotiated TLS version, cipher suite, or client
+// certificates, in order to decide whether requests on a connection are acceptable.  The TLS state is nil for
+// connections that do not use TLS.  A nil error allows every request on the connection.  Otherwise, the error
+// rejects every request on the connection.  An error that is an HTTPError determines the status code and message
+// of those rejections, which otherwise are http.StatusForbidden.
+type ConnectionPolicy func(net.Conn, *tls.ConnectionState) error
+
+// ConnectionPolicyError is an HTTPError for ConnectionPolicy implementations that need a particular status code
+type ConnectionPolicyError struct {
+	Code   int
+	Reason string
+}
+
+func (cpe ConnectionPolicyError) Error() string {
+	return cpe.Reason
+}
+
+func (cpe ConnectionPolicyError) StatusCode() int {
+	return cpe.Code
+}
+
+// RequireCipherSuites produces a ConnectionPolicy that rejects TLS connections that negotiated any cipher suite other
+// than those given, with an http.StatusUpgradeRequired.  Connections without TLS are allowed, as are TLS 1.3
+// connections, whose cipher suites are not configurable.
+func RequireCipherSuites(suites ...uint16) ConnectionPolicy {
+	allowed := make(map[uint16]bool, len(suites))
+	for _, s := range suites {
+		allowed[s] = true
+	}
+
+	return func(_ net.Conn, state *tls.ConnectionState) error {
+		if state == nil || state.Version >= tls.VersionTLS13 || allowed[state.CipherSuite] {
+			return nil
+		}
+
+		return ConnectionPolicyError{
+			Code:   http.StatusUpgradeRequired,
+			Reason: fmt.Sprintf("The cipher suite %s is not allowed", tls.CipherSuiteName(state.CipherSuite)),
+		}
+	}
+}
+
+// connectionVerdict holds the outcome of a ConnectionPolicy for a single connection
+type connectionVerdict struct {
+	conn   net.Conn
+	policy ConnectionPolicy
+	once   sync.Once
+	err    error
+}
+
+// evaluate applies the policy the first time it is called, returning the cached outcome thereafter
+func (cv *connectionVerdict) evaluate() error {
+	cv.once.Do(func() {
+		var state *tls.ConnectionState
+		if tc, ok := cv.conn.(TlsConn); ok {
+			cs := tc.ConnectionState()
+			state = &cs
+		}
+
+		cv.err = cv.policy(cv.conn, state)
+	})
+
+	return cv.err
+}
+
+type connectionVerdictContextKey struct{}
+
+// WithConnectionPolicy returns a new context that evaluates the given policy against the given connection.
+// This is normally done by NewConnContext.
+func WithConnectionPolicy(ctx context.Context, conn net.Conn, policy ConnectionPolicy) context.Context {
+	return context.WithValue(ctx, connectionVerdictContextKey{}, &connectionVerdict{conn: conn, policy: policy})
+}
+
+// ConnectionPolicyResult returns the outcome of the ConnectionPolicy for the connection that carried a request.
+// The policy is evaluated at most once per connection, so this function is cheap to call from any middleware.  If
+// the context has no ConnectionPolicy, this function returns nil.
+func ConnectionPolicyResult(ctx context.Context) error {
+	if cv, ok := ctx.Value(connectionVerdictContextKey{}).(*connectionVerdict); ok {
+		return cv.evaluate()
+	}
+
+	return nil
+}
+
+// NewConnContext produces an http.Server.ConnContext that makes the given policy available to each request via
+// ConnectionPolicyResult.  If the policy is nil, this function returns nil.
+//
+// The http.Server invokes ConnContext as soon as a connection is accepted, which precedes any TLS handshake, so
+// the policy is evaluated when the first request on a connection asks for it.  The handshake is complete by then.
+func NewConnContext(policy ConnectionPolicy) func(context.Context, net.Conn) context.Context {
+	if policy == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, conn net.Conn) context.Context {
+		return WithConnectionPolicy(ctx, conn, policy)
+	}
+}
+
+// ConnectionPolicyCheck is an Alice-style decorator that rejects requests on connections that failed the server's
+// ConnectionPolicy.  Requests on servers without a ConnectionPolicy are always allowed.
+//
+// With HTTP keep-alives, a connection carries many requests, and every one of them shares the same outcome.  Each
+// rejected response asks the client to close the connection, so that a client which, for example, upgrades its TLS
+// configuration does so on a new connection.  HTTP/2 multiplexes requests over a single connection, so every stream
+// of a rejected connection is rejected.
+type ConnectionPolicyCheck struct {
+	// ErrorEncoder is the optional strategy for rendering rejections.  If unset, DefaultErrorEncoder is used.
+	ErrorEncoder ErrorEncoder
+}
+
+func (cpc ConnectionPolicyCheck) Then(next http.Handler) http.Handler {
+	errorEncoder := cpc.ErrorEncoder
+	if errorEncoder == nil {
+		errorEncoder = DefaultErrorEncoder
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		err := ConnectionPolicyResult(request.Context())
+		if err == nil {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		var (
+			statusCode = http.StatusForbidden
+			message    = http.StatusText(http.StatusForbidden)
+			httpErr    HTTPError
+		)
+
+		if errors.As(err, &httpErr) {
+			statusCode = httpErr.StatusCode()
+			message = httpErr.Error()
+		}
+
+		response.Header().Set("Connection", "close")
+		MarkRejected(request.Context(), "connectionPolicy")
+		errorEncoder(response, statusCode, message)
+	})
+}
+
+func (cpc ConnectionPolicyCheck) ThenFunc(next http.HandlerFunc) http.Handler {
+	return cpc.Then(next)
+}
@@ -0,0 +1,184 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireCipherSuites(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		policy = RequireCipherSuites(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+	)
+
+	assert.NoError(policy(nil, nil))
+	assert.NoError(policy(nil, &tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}))
+	assert.NoError(policy(nil, &tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}))
+
+	err := policy(nil, &tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_RSA_WITH_AES_128_CBC_SHA})
+	assert.Error(err)
+
+	var httpErr HTTPError
+	if assert.True(errors.As(err, &httpErr)) {
+		assert.Equal(http.StatusUpgradeRequired, httpErr.StatusCode())
+		assert.Contains(httpErr.Error(), "TLS_RSA_WITH_AES_128_CBC_SHA")
+	}
+}
+
+func TestNewConnContext(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(NewConnContext(nil))
+
+	var (
+		evaluations int
+		connContext = NewConnContext(func(net.Conn, *tls.ConnectionState) error {
+			evaluations++
+			return errors.New("expected")
+		})
+	)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	assert.NoError(ConnectionPolicyResult(context.Background()))
+
+	ctx := connContext(context.Background(), server)
+	assert.Zero(evaluations)
+	assert.EqualError(ConnectionPolicyResult(ctx), "expected")
+	assert.EqualError(ConnectionPolicyResult(ctx), "expected")
+	assert.Equal(1, evaluations)
+}
+
+func testConnectionPolicyCheck(t *testing.T, policy ConnectionPolicy, ee ErrorEncoder, expectedStatusCode int, expectedBody string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = ConnectionPolicyCheck{ErrorEncoder: ee}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	require.NotNil(decorated)
+	if policy != nil {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		request = request.WithContext(WithConnectionPolicy(request.Context(), server, policy))
+	}
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, request)
+	assert.Equal(expectedStatusCode, response.Code)
+	assert.Equal(expectedBody, response.Body.String())
+	if expectedStatusCode != 299 {
+		assert.Equal("close", response.Header().Get("Connection"))
+	}
+}
+
+func TestConnectionPolicyCheck(t *testing.T) {
+	var (
+		allow = func(net.Conn, *tls.ConnectionState) error {
+			return nil
+		}
+
+		reject = func(net.Conn, *tls.ConnectionState) error {
+			return errors.New("expected")
+		}
+
+		upgrade = func(net.Conn, *tls.ConnectionState) error {
+			return ConnectionPolicyError{Code: http.StatusUpgradeRequired, Reason: "Use a stronger cipher"}
+		}
+	)
+
+	t.Run("NoPolicy", func(t *testing.T) {
+		testConnectionPolicyCheck(t, nil, nil, 299, "")
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		testConnectionPolicyCheck(t, allow, nil, 299, "")
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		testConnectionPolicyCheck(t, reject, nil, http.StatusForbidden, "Forbidden\n")
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		testConnectionPolicyCheck(t, upgrade, nil, http.StatusUpgradeRequired, "Use a stronger cipher\n")
+	})
+
+	t.Run("ErrorEncoder", func(t *testing.T) {
+		testConnectionPolicyCheck(
+			t,
+			upgrade,
+			JSONErrorEncoder,
+			http.StatusUpgradeRequired,
+			`{"error":{"code":426,"message":"Use a stronger cipher"}}`,
+		)
+	})
+}
+
+func testConnectionPolicyKeepAlive(t *testing.T, allowed uint16, expectedStatusCode, expectedEvaluations int) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		evaluations int
+		policy      = func(_ net.Conn, state *tls.ConnectionState) error {
+			evaluations++
+			return RequireCipherSuites(allowed)(nil, state)
+		}
+
+		o = Options{ConnectionPolicy: policy}
+
+		server = httptest.NewUnstartedServer(
+			NewServerChain(o, log.NewNopLogger()).ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(299)
+			}),
+		)
+	)
+
+	server.Config.ConnContext = New(o, log.NewNopLogger(), nil).(*http.Server).ConnContext
+	server.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+
+	server.StartTLS()
+	defer server.Close()
+
+	client := server.Client()
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL)
+		require.NoError(err)
+		response.Body.Close()
+		assert.Equal(expectedStatusCode, response.StatusCode)
+	}
+
+	assert.Equal(expectedEvaluations, evaluations)
+}
+
+func TestConnectionPolicyKeepAlive(t *testing.T) {
+	t.Run("Allowed", func(t *testing.T) {
+		// both requests share a connection, so the policy is evaluated once
+		testConnectionPolicyKeepAlive(t, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, 299, 1)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		// each rejection closes the connection, so every request arrives on a new connection
+		testConnectionPolicyKeepAlive(t, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, http.StatusUpgradeRequired, 2)
+	})
+}
@@ -0,0 +1,112 @@
+package xhttpserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConnectionTracker keeps track of the state of each of a server's connections.  Its ConnState method
+// is intended to be used as, or invoked from, http.Server.ConnState.
+//
+// Hijacked and closed connections are no longer tracked.
+type ConnectionTracker struct {
+	lock  sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+// NewConnectionTracker creates an empty ConnectionTracker
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{
+		conns: make(map[net.Conn]http.ConnState),
+	}
+}
+
+// ConnState records the state transition for a connection
+func (ct *ConnectionTracker) ConnState(c net.Conn, cs http.ConnState) {
+	ct.lock.Lock()
+	switch cs {
+	case http.StateHijacked, http.StateClosed:
+		delete(ct.conns, c)
+
+	default:
+		ct.conns[c] = cs
+	}
+
+	ct.lock.Unlock()
+}
+
+// Len returns the total number of connections currently tracked
+func (ct *ConnectionTracker) Len() int {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	return len(ct.conns)
+}
+
+// Active returns the number of connections that currently have a request in flight
+func (ct *ConnectionTracker) Active() int {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	count := 0
+	for _, cs := range ct.conns {
+		if cs == http.StateActive {
+			count++
+		}
+	}
+
+	return count
+}
+
+// CloseIdle immediately closes each idle connection, returning the number of connections closed.
+// Connections in any other state are left alone.
+//
+// Connections are closed while holding this tracker's lock, so that a connection which becomes active
+// because a keep-alive request just arrived cannot be closed mid-request.  Closing a connection does not
+// synchronously invoke ConnState, so this cannot deadlock.
+func (ct *ConnectionTracker) CloseIdle() int {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	// closing will eventually result in a StateClosed transition, which untracks each connection
+	count := 0
+	for c, cs := range ct.conns {
+		if cs == http.StateIdle {
+			c.Close()
+			count++
+		}
+	}
+
+	return count
+}
+
+// Shutdown gracefully shuts down a server whose connections are tracked by this instance.  Keep-alives are
+// disabled first, so that connections are closed as soon as their in-flight requests complete.  Then, any idle
+// keep-alive connections are closed immediately, rather than waiting on http.Server.Shutdown to poll them.
+// Finally, http.Server.Shutdown is invoked to close listeners and wait on connections with in-flight requests.
+func (ct *ConnectionTracker) Shutdown(ctx context.Context, s *http.Server) error {
+	s.SetKeepAlivesEnabled(false)
+	ct.CloseIdle()
+	return s.Shutdown(ctx)
+}
+
+// trackedServer is the Interface implementation used when connections are tracked for shutdown
+type trackedServer struct {
+	*http.Server
+	tracker   *ConnectionTracker
+	closeIdle bool
+}
+
+func (ts trackedServer) Shutdown(ctx context.Context) error {
+	if ts.closeIdle {
+		return ts.tracker.Shutdown(ctx, ts.Server)
+	}
+
+	return ts.Server.Shutdown(ctx)
+}
+
+// Connections returns the number of connections currently open
+func (ts trackedServer) Connections() int {
+	return ts.tracker.Len()
+}
@@ -0,0 +1,181 @@
+package xhttpserver
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConnectionTrackerStates(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		tracker  = NewConnectionTracker()
+		c1, peer = net.Pipe()
+		c2, _    = net.Pipe()
+	)
+
+	defer peer.Close()
+	assert.Zero(tracker.Len())
+	assert.Zero(tracker.Active())
+
+	tracker.ConnState(c1, http.StateNew)
+	tracker.ConnState(c2, http.StateNew)
+	assert.Equal(2, tracker.Len())
+	assert.Zero(tracker.Active())
+
+	tracker.ConnState(c1, http.StateActive)
+	tracker.ConnState(c2, http.StateActive)
+	assert.Equal(2, tracker.Active())
+
+	tracker.ConnState(c2, http.StateHijacked)
+	assert.Equal(1, tracker.Len())
+	assert.Equal(1, tracker.Active())
+
+	tracker.ConnState(c1, http.StateIdle)
+	assert.Equal(1, tracker.Len())
+	assert.Zero(tracker.Active())
+
+	assert.Equal(1, tracker.CloseIdle())
+	_, err := peer.Read(make([]byte, 1))
+	assert.Equal(io.EOF, err)
+
+	tracker.ConnState(c1, http.StateClosed)
+	assert.Zero(tracker.Len())
+	assert.Zero(tracker.CloseIdle())
+}
+
+func testConnectionTrackerShutdown(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		s = New(
+			Options{CloseIdleOnShutdown: true},
+			log.NewNopLogger(),
+			http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+				response.WriteHeader(299)
+			}),
+		)
+
+		serveResult = make(chan error, 1)
+	)
+
+	require.IsType(trackedServer{}, s)
+	tracker := s.(trackedServer).tracker
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	go func() {
+		serveResult <- s.Serve(l)
+	}()
+
+	client := &http.Client{Transport: new(http.Transport)}
+	response, err := client.Get("http://" + l.Addr().String())
+	require.NoError(err)
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	assert.Equal(299, response.StatusCode)
+
+	// the client is holding an idle keep-alive connection
+	require.Eventually(
+		func() bool { return tracker.Len() == 1 && tracker.Active() == 0 },
+		time.Second,
+		10*time.Millisecond,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(s.Shutdown(ctx))
+
+	select {
+	case err := <-serveResult:
+		assert.Equal(http.ErrServerClosed, err)
+	case <-time.After(time.Second):
+		assert.Fail("Serve did not exit")
+	}
+}
+
+// stateCheckConn is a net.Conn that records, when it is closed, the state its tracker held for it and whether
+// the tracker's lock was held
+type stateCheckConn struct {
+	net.Conn
+	tracker     *ConnectionTracker
+	onClose     func()
+	closed      bool
+	closedState http.ConnState
+	locked      bool
+}
+
+func (scc *stateCheckConn) Close() error {
+	// CloseIdle holds the tracker's lock while closing connections
+	scc.closed = true
+	scc.closedState = scc.tracker.conns[scc]
+	scc.locked = !scc.tracker.lock.TryLock()
+	if !scc.locked {
+		scc.tracker.lock.Unlock()
+	}
+
+	if scc.onClose != nil {
+		scc.onClose()
+	}
+
+	return nil
+}
+
+func testConnectionTrackerCloseIdleRace(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		tracker   = NewConnectionTracker()
+		c1        = &stateCheckConn{tracker: tracker}
+		c2        = &stateCheckConn{tracker: tracker}
+		activate  sync.Once
+		activated = make(chan struct{})
+	)
+
+	// whichever connection is closed first has a keep-alive request arrive on the other one.  That transition
+	// must wait on the tracker's lock, so the other connection is still idle when it is closed.
+	onClose := func(c net.Conn) func() {
+		return func() {
+			activate.Do(func() {
+				go func() {
+					tracker.ConnState(c, http.StateActive)
+					close(activated)
+				}()
+			})
+		}
+	}
+
+	c1.onClose = onClose(c2)
+	c2.onClose = onClose(c1)
+	tracker.ConnState(c1, http.StateIdle)
+	tracker.ConnState(c2, http.StateIdle)
+
+	assert.Equal(2, tracker.CloseIdle())
+	<-activated
+
+	for _, c := range []*stateCheckConn{c1, c2} {
+		assert.True(c.closed)
+		assert.True(c.locked)
+		assert.Equal(http.StateIdle, c.closedState)
+	}
+
+	// exactly one connection became active, after CloseIdle released the lock
+	assert.Equal(1, tracker.Active())
+}
+
+func TestConnectionTracker(t *testing.T) {
+	t.Run("States", testConnectionTrackerStates)
+	t.Run("CloseIdleRace", testConnectionTrackerCloseIdleRace)
+	t.Run("Shutdown", testConnectionTrackerShutdown)
+}
@@ -0,0 +1,85 @@
+package xhttpserver
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultContentTypeMethods are the HTTP methods checked by ContentType when no methods are configured
+var DefaultContentTypeMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+
+// contentTypeHandler is the internal http.Handler implementation that enforces allowed request media types
+type contentTypeHandler struct {
+	next          http.Handler
+	onUnsupported http.Handler
+
+	methods map[string]bool
+	allowed map[string]bool
+}
+
+func (cth *contentTypeHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if cth.methods[request.Method] {
+		mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if err != nil || !cth.allowed[mediaType] {
+			MarkRejected(request.Context(), "contentType")
+			cth.onUnsupported.ServeHTTP(response, request)
+			return
+		}
+	}
+
+	cth.next.ServeHTTP(response, request)
+}
+
+// ContentType is an Alice-style decorator that enforces an allowlist of request media types for
+// certain HTTP methods.  Parameters such as charset are permitted and ignored when matching.  A request
+// with a missing or malformed Content-Type is rejected just as an unsupported media type is.
+type ContentType struct {
+	// Allowed is the set of media types, e.g. application/json, that are allowed.  If empty,
+	// no decoration is done.  Matching is case insensitive.
+	Allowed []string
+
+	// Methods is the set of HTTP methods that are checked.  If unset, DefaultContentTypeMethods is used.
+	Methods []string
+
+	// OnUnsupported is the optional handler invoked for requests with an unsupported media type.  If unset,
+	// an http.StatusUnsupportedMediaType is returned.
+	OnUnsupported http.Handler
+}
+
+func (ct ContentType) Then(next http.Handler) http.Handler {
+	if len(ct.Allowed) == 0 {
+		return next
+	}
+
+	cth := &contentTypeHandler{
+		next:    next,
+		methods: make(map[string]bool),
+		allowed: make(map[string]bool, len(ct.Allowed)),
+	}
+
+	methods := ct.Methods
+	if len(methods) == 0 {
+		methods = DefaultContentTypeMethods
+	}
+
+	for _, m := range methods {
+		cth.methods[strings.ToUpper(m)] = true
+	}
+
+	for _, a := range ct.Allowed {
+		cth.allowed[strings.ToLower(a)] = true
+	}
+
+	if ct.OnUnsupported != nil {
+		cth.onUnsupported = ct.OnUnsupported
+	} else {
+		cth.onUnsupported = Constant{StatusCode: http.StatusUnsupportedMediaType}.NewHandler()
+	}
+
+	return cth
+}
+
+func (ct ContentType) ThenFunc(next http.HandlerFunc) http.Handler {
+	return ct.Then(next)
+}
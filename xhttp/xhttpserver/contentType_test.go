@@ -0,0 +1,90 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testContentTypeNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next        = Constant{}.NewHandler()
+		contentType = ContentType{}.Then(next)
+	)
+
+	assert.Equal(next, contentType)
+}
+
+func testContentTypeDefaultOnUnsupported(t *testing.T) {
+	testData := []struct {
+		method       string
+		contentType  string
+		expectedCode int
+	}{
+		{http.MethodGet, "", 299},
+		{http.MethodGet, "text/plain", 299},
+		{http.MethodPost, "application/json", 299},
+		{http.MethodPost, "application/json; charset=utf-8", 299},
+		{http.MethodPut, "Application/JSON", 299},
+		{http.MethodPatch, "text/plain", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "this is not a media type;;", http.StatusUnsupportedMediaType},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				contentType = ContentType{
+					Allowed: []string{"application/json"},
+				}.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+					response.WriteHeader(299)
+				})
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest(record.method, "/", nil)
+			)
+
+			if len(record.contentType) > 0 {
+				request.Header.Set("Content-Type", record.contentType)
+			}
+
+			contentType.ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+		})
+	}
+}
+
+func testContentTypeCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		contentType = ContentType{
+			Allowed:       []string{"application/json"},
+			Methods:       []string{"delete"},
+			OnUnsupported: Constant{StatusCode: 476}.NewHandler(),
+		}.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(299)
+		})
+	)
+
+	response := httptest.NewRecorder()
+	contentType.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(299, response.Code)
+
+	response = httptest.NewRecorder()
+	contentType.ServeHTTP(response, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(476, response.Code)
+}
+
+func TestContentType(t *testing.T) {
+	t.Run("NoDecoration", testContentTypeNoDecoration)
+	t.Run("DefaultOnUnsupported", testContentTypeDefaultOnUnsupported)
+	t.Run("Custom", testContentTypeCustom)
+}
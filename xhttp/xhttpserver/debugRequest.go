@@ -0,0 +1,272 @@
+package xhttpserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// DefaultDebugHeader is the request header that asks for a request to be debugged when no header is configured
+	DefaultDebugHeader = "X-Debug"
+
+	// DefaultDebugMaxBodyBytes is the number of request and response body bytes logged for debugged requests
+	// when no limit is configured
+	DefaultDebugMaxBodyBytes = 4096
+
+	debugLevelKey = "debugLevel"
+)
+
+// DefaultDebugRedactHeaders returns the headers whose values are replaced with Redacted in the entries logged
+// for debugged requests when no headers are configured.  A distinct slice is returned with each call.
+func DefaultDebugRedactHeaders() []string {
+	return []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+}
+
+// redactHeader returns a copy of the given header with the values of each of the redact headers replaced by
+// Redacted.  The original header is left untouched, as it is still in use by the request or response.
+func redactHeader(h http.Header, redact []string) http.Header {
+	redacted := h.Clone()
+	for _, name := range redact {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted[http.CanonicalHeaderKey(name)] = []string{Redacted}
+		}
+	}
+
+	return redacted
+}
+
+// DebugLevelKey is the logging key under which the contextual logger of a debugged request records each
+// entry's level.  See DebugRequest.
+func DebugLevelKey() interface{} {
+	return debugLevelKey
+}
+
+type debugContextKey struct{}
+
+// IsDebugRequest tests if the given context belongs to a request being debugged by DebugRequest.  Handlers can
+// use this to emit additional, expensive diagnostics only when they are wanted.
+func IsDebugRequest(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugContextKey{}).(bool)
+	return debug
+}
+
+// debugLogger records the level of each entry as a plain string under DebugLevelKey.  go-kit level filters
+// recognize entries by their level values, so this prevents those filters from discarding any entries.
+type debugLogger struct {
+	next log.Logger
+}
+
+func (dl debugLogger) Log(keyvals ...interface{}) error {
+	rewritten := make([]interface{}, len(keyvals))
+	copy(rewritten, keyvals)
+	for i := 0; i < len(rewritten)-1; i += 2 {
+		if rewritten[i] == level.Key() {
+			rewritten[i] = DebugLevelKey()
+			rewritten[i+1] = fmt.Sprint(rewritten[i+1])
+		}
+	}
+
+	return dl.next.Log(rewritten...)
+}
+
+// limitedBuffer retains at most max bytes written to it, discarding the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := lb.max - lb.Len(); remaining > 0 {
+		if len(p) > remaining {
+			lb.Buffer.Write(p[:remaining])
+		} else {
+			lb.Buffer.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+// debugBody captures the request body as it is read by a handler
+type debugBody struct {
+	io.Reader
+	io.Closer
+}
+
+// debugWriter captures the status code and body written by a handler
+type debugWriter struct {
+	next       http.ResponseWriter
+	statusCode int
+	body       *limitedBuffer
+}
+
+// Unwrap returns the decorated http.ResponseWriter
+func (dw *debugWriter) Unwrap() http.ResponseWriter {
+	return dw.next
+}
+
+func (dw *debugWriter) Header() http.Header {
+	return dw.next.Header()
+}
+
+func (dw *debugWriter) Write(b []byte) (int, error) {
+	if dw.statusCode == 0 {
+		dw.statusCode = http.StatusOK
+	}
+
+	dw.body.Write(b)
+	return dw.next.Write(b)
+}
+
+func (dw *debugWriter) WriteHeader(statusCode int) {
+	if dw.statusCode == 0 {
+		dw.statusCode = statusCode
+	}
+
+	dw.next.WriteHeader(statusCode)
+}
+
+func (dw *debugWriter) Flush() {
+	if f, ok := dw.next.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (dw *debugWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := dw.next.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, ErrHijackerNotSupported
+}
+
+func (dw *debugWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := dw.next.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// DebugRequest is an Alice-style decorator that enables verbose logging for individual requests.  A request from
+// a trusted address that carries a true value, e.g. 1 or true, in the debug header is debugged:
+//
+//   - every entry written to its contextual logger is emitted regardless of the configured log level, with the
+//     entry's level recorded under DebugLevelKey
+//   - once the handler completes, a single entry records the request and response headers, the first
+//     MaxBodyBytes of each body, the response status, and the duration
+//   - IsDebugRequest returns true for its context
+//   - all of its entries are emitted even if xloghttp.Logging would otherwise discard them because of their
+//     response status, i.e. Logging.MinStatusCode
+//
+// This decorator must follow the one that creates the contextual logger, e.g. xloghttp.Logging.  Since debugging
+// is expensive and may expose sensitive data, only requests from Trusted networks are ever debugged, and the
+// values of the Redact headers are never logged.
+type DebugRequest struct {
+	// Header is the request header that asks for debugging.  If unset, DefaultDebugHeader is used.
+	Header string
+
+	// Trusted are the networks from which debugging may be requested.  If empty, no decoration is done.
+	//
+	// Only the immediate peer of the connection, http.Request.RemoteAddr, is checked.  Forwarded addresses,
+	// e.g. X-Forwarded-For, are never consulted since any client can set them.  Trusted must therefore not
+	// contain the addresses of proxies or load balancers, as that would trust every client behind them.  When
+	// the PROXY protocol is enabled, RemoteAddr is the client address the proxy reported.
+	Trusted []*net.IPNet
+
+	// Redact are the request and response headers whose values are replaced with Redacted in the logged entry.
+	// If nil, DefaultDebugRedactHeaders is used.  If empty but non-nil, no headers are redacted.
+	Redact []string
+
+	// MaxBodyBytes is the maximum number of bytes of each body that are logged.  If zero, DefaultDebugMaxBodyBytes
+	// is used.  If negative, bodies are not logged.
+	MaxBodyBytes int
+
+	// Clock is the optional source of time for the logged duration.  If unset, SystemClock is used.
+	Clock Clock
+}
+
+func (dr DebugRequest) Then(next http.Handler) http.Handler {
+	if len(dr.Trusted) == 0 {
+		return next
+	}
+
+	header := dr.Header
+	if len(header) == 0 {
+		header = DefaultDebugHeader
+	}
+
+	maxBodyBytes := dr.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = DefaultDebugMaxBodyBytes
+	} else if maxBodyBytes < 0 {
+		maxBodyBytes = 0
+	}
+
+	redact := dr.Redact
+	if redact == nil {
+		redact = DefaultDebugRedactHeaders()
+	}
+
+	clock := clockOrSystem(dr.Clock)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if debug, _ := strconv.ParseBool(request.Header.Get(header)); !debug || !trustedAddress(dr.Trusted, request.RemoteAddr) {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		xloghttp.EmitAll(request.Context())
+
+		var (
+			start  = clock.Now()
+			logger = debugLogger{next: xloghttp.LoggerFromContext(request.Context())}
+
+			requestBody = &limitedBuffer{max: maxBodyBytes}
+			writer      = &debugWriter{next: response, body: &limitedBuffer{max: maxBodyBytes}}
+		)
+
+		if request.Body != nil && request.Body != http.NoBody {
+			request.Body = debugBody{
+				Reader: io.TeeReader(request.Body, requestBody),
+				Closer: request.Body,
+			}
+		}
+
+		ctx := context.WithValue(request.Context(), debugContextKey{}, true)
+		ctx = xlog.With(ctx, logger)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+
+		statusCode := writer.statusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+
+		logger.Log(
+			level.Key(), level.DebugValue(),
+			xlog.MessageKey(), "debug request",
+			"requestHeader", redactHeader(request.Header, redact),
+			"requestBody", requestBody.String(),
+			"responseStatus", statusCode,
+			"responseHeader", redactHeader(response.Header(), redact),
+			"responseBody", writer.body.String(),
+			"duration_ms", int64(clock.Since(start)/time.Millisecond),
+		)
+	})
+}
+
+func (dr DebugRequest) ThenFunc(next http.HandlerFunc) http.Handler {
+	return dr.Then(next)
+}
@@ -0,0 +1,315 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDebugRequestNoTrusted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = DebugRequest{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testDebugRequestUntrusted(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		trusted, err = ParseNetworks([]string{"10.0.0.0/8"})
+		output       bytes.Buffer
+		debugged     = true
+
+		decorated = DebugRequest{
+			Trusted: trusted,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			debugged = IsDebugRequest(request.Context())
+			response.WriteHeader(299)
+		})
+	)
+
+	require.NoError(err)
+	for _, header := range []string{"", "0", "false", "garbage"} {
+		t.Run(header, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = "10.1.2.3:1234"
+			request.Header.Set(DefaultDebugHeader, header)
+			request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+
+			response := httptest.NewRecorder()
+			decorated.ServeHTTP(response, request)
+			assert.Equal(299, response.Code)
+			assert.False(debugged)
+			assert.Zero(output.Len())
+		})
+	}
+
+	t.Run("UntrustedAddress", func(t *testing.T) {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "192.168.1.1:1234"
+		request.Header.Set(DefaultDebugHeader, "1")
+		request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+
+		response := httptest.NewRecorder()
+		decorated.ServeHTTP(response, request)
+		assert.Equal(299, response.Code)
+		assert.False(debugged)
+		assert.Zero(output.Len())
+	})
+}
+
+func testDebugRequestTrusted(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		trusted, err = ParseNetworks([]string{"10.0.0.0/8"})
+		output       bytes.Buffer
+		debugged     = false
+		clock        = newTestClock()
+
+		decorated = DebugRequest{
+			Header:       "X-Custom-Debug",
+			Trusted:      trusted,
+			MaxBodyBytes: 5,
+			Clock:        clock,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			debugged = IsDebugRequest(request.Context())
+			clock.Add(125 * time.Millisecond)
+			body, err := ioutil.ReadAll(request.Body)
+			assert.NoError(err)
+			assert.Equal("request body", string(body))
+
+			xlog.Get(request.Context()).Log(level.Key(), level.DebugValue(), xlog.MessageKey(), "handler")
+			response.Header().Set("X-Test", "value")
+			response.WriteHeader(299)
+			response.Write([]byte("response body"))
+		})
+	)
+
+	require.NoError(err)
+
+	request := httptest.NewRequest("POST", "/", strings.NewReader("request body"))
+	request.RemoteAddr = "10.1.2.3:1234"
+	request.Header.Set("X-Custom-Debug", "1")
+	request = request.WithContext(xlog.With(
+		request.Context(),
+		level.NewFilter(log.NewJSONLogger(&output), level.AllowInfo()),
+	))
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+	assert.Equal("response body", response.Body.String())
+	assert.True(debugged)
+
+	decoder := json.NewDecoder(&output)
+
+	var handlerRecord map[string]interface{}
+	require.NoError(decoder.Decode(&handlerRecord))
+	assert.Equal("handler", handlerRecord[xlog.MessageKey().(string)])
+	assert.Equal("debug", handlerRecord[debugLevelKey])
+	assert.NotContains(handlerRecord, "level")
+
+	var debugRecord map[string]interface{}
+	require.NoError(decoder.Decode(&debugRecord))
+	assert.Equal("debug", debugRecord[debugLevelKey])
+	assert.Equal("reque", debugRecord["requestBody"])
+	assert.Equal("respo", debugRecord["responseBody"])
+	assert.Equal(299.0, debugRecord["responseStatus"])
+	assert.Contains(debugRecord, "requestHeader")
+	assert.Contains(debugRecord, "responseHeader")
+	assert.Equal(125.0, debugRecord["duration_ms"])
+}
+
+func testDebugRequestNoBodies(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		trusted, err = ParseNetworks([]string{"127.0.0.1"})
+		output       bytes.Buffer
+
+		decorated = DebugRequest{
+			Trusted:      trusted,
+			MaxBodyBytes: -1,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			ioutil.ReadAll(request.Body)
+			response.Write([]byte("response body"))
+		})
+	)
+
+	require.NoError(err)
+
+	request := httptest.NewRequest("POST", "/", strings.NewReader("request body"))
+	request.RemoteAddr = "127.0.0.1:1234"
+	request.Header.Set(DefaultDebugHeader, "true")
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+
+	var record map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &record))
+	assert.Equal("", record["requestBody"])
+	assert.Equal("", record["responseBody"])
+	assert.Equal(200.0, record["responseStatus"])
+}
+
+func testDebugRequestRedact(t *testing.T) {
+	testData := []struct {
+		redact   []string
+		expected map[string]string
+	}{
+		{
+			redact: nil,
+			expected: map[string]string{
+				"Authorization": Redacted,
+				"Cookie":        Redacted,
+				"Set-Cookie":    Redacted,
+				"X-Other":       "visible",
+			},
+		},
+		{
+			redact: []string{"x-other"},
+			expected: map[string]string{
+				"Authorization": "Basic dXNlcjpwYXNz",
+				"Cookie":        "session=secret",
+				"Set-Cookie":    "session=secret",
+				"X-Other":       Redacted,
+			},
+		},
+		{
+			redact: []string{},
+			expected: map[string]string{
+				"Authorization": "Basic dXNlcjpwYXNz",
+				"X-Other":       "visible",
+			},
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				trusted, err = ParseNetworks([]string{"127.0.0.1"})
+				output       bytes.Buffer
+
+				decorated = DebugRequest{
+					Trusted: trusted,
+					Redact:  record.redact,
+				}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+					response.Header().Set("Set-Cookie", "session=secret")
+					response.Header().Set("X-Other", "visible")
+				})
+			)
+
+			require.NoError(err)
+
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = "127.0.0.1:1234"
+			request.Header.Set(DefaultDebugHeader, "true")
+			request.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			request.Header.Set("Cookie", "session=secret")
+			request.Header.Set("X-Other", "visible")
+			request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+
+			response := httptest.NewRecorder()
+			decorated.ServeHTTP(response, request)
+
+			// the request and response themselves must be untouched
+			assert.Equal("Basic dXNlcjpwYXNz", request.Header.Get("Authorization"))
+			assert.Equal("session=secret", response.Header().Get("Set-Cookie"))
+
+			var debugRecord struct {
+				RequestHeader  http.Header `json:"requestHeader"`
+				ResponseHeader http.Header `json:"responseHeader"`
+			}
+
+			require.NoError(json.Unmarshal(output.Bytes(), &debugRecord))
+			for name, value := range record.expected {
+				actual := debugRecord.RequestHeader.Get(name)
+				if name == "Set-Cookie" {
+					actual = debugRecord.ResponseHeader.Get(name)
+				}
+
+				assert.Equal(value, actual, name)
+			}
+
+			assert.Equal(record.expected["X-Other"], debugRecord.ResponseHeader.Get("X-Other"))
+		})
+	}
+}
+
+func testDebugRequestMinStatusCode(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		trusted, err = ParseNetworks([]string{"127.0.0.1"})
+		output       bytes.Buffer
+
+		decorated = alice.New(
+			TrackingStage(),
+			xloghttp.Logging{Base: log.NewJSONLogger(&output), MinStatusCode: http.StatusInternalServerError}.Then,
+			DebugRequest{Trusted: trusted}.Then,
+		).ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			xlog.Get(request.Context()).Log(xlog.MessageKey(), "handler")
+			response.WriteHeader(http.StatusOK)
+		})
+	)
+
+	require.NoError(err)
+
+	for _, debug := range []bool{false, true} {
+		output.Reset()
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "127.0.0.1:1234"
+		request.Header.Set(DefaultDebugHeader, strconv.FormatBool(debug))
+
+		response := httptest.NewRecorder()
+		decorated.ServeHTTP(response, request)
+		assert.Equal(http.StatusOK, response.Code)
+
+		if debug {
+			// a debugged request is logged in full, even though its status is below the minimum
+			assert.Contains(output.String(), "handler")
+			assert.Contains(output.String(), "debug request")
+		} else {
+			assert.Zero(output.Len())
+		}
+	}
+}
+
+func TestDebugRequest(t *testing.T) {
+	t.Run("NoTrusted", testDebugRequestNoTrusted)
+	t.Run("Untrusted", testDebugRequestUntrusted)
+	t.Run("Trusted", testDebugRequestTrusted)
+	t.Run("NoBodies", testDebugRequestNoBodies)
+	t.Run("Redact", testDebugRequestRedact)
+	t.Run("MinStatusCode", testDebugRequestMinStatusCode)
+}
@@ -0,0 +1,68 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"time"
+)
+
+// Drain is an Alice-style decorator that rejects requests once a ShutdownSignal is triggered.  net/http continues
+// to serve requests on open connections until a server is fully shut down, so without this decorator requests that
+// arrive during shutdown trickle through.  Rejected requests receive a 503 and Connection: close, so that clients
+// retry elsewhere immediately.
+//
+// Use CancelOnStop to trigger the ShutdownSignal before servers begin draining.
+type Drain struct {
+	// ShutdownSignal indicates when draining begins.  If unset, no decoration is done.
+	ShutdownSignal *ShutdownSignal
+
+	// RetryAfter is the optional interval sent in the Retry-After header of rejected requests.  If unset,
+	// no Retry-After header is sent.
+	RetryAfter time.Duration
+
+	// Exempt is the optional set of URI paths, e.g. health endpoints, that are never rejected
+	Exempt []string
+
+	// OnDraining is the optional handler for rejected requests.  If unset, a 503 is returned.  In either case,
+	// the Connection and any Retry-After headers are set prior to invoking this handler.
+	OnDraining http.Handler
+}
+
+func (d Drain) Then(next http.Handler) http.Handler {
+	if d.ShutdownSignal == nil {
+		return next
+	}
+
+	var (
+		retryAfter = retryAfterValue(d.RetryAfter)
+		exempt     = newExemptPaths(d.Exempt)
+	)
+
+	onDraining := d.OnDraining
+	if onDraining == nil {
+		onDraining = Constant{StatusCode: http.StatusServiceUnavailable}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		select {
+		case <-d.ShutdownSignal.Done():
+			if !exempt.contains(request) {
+				response.Header().Set("Connection", "close")
+				if len(retryAfter) > 0 {
+					response.Header().Set("Retry-After", retryAfter)
+				}
+
+				MarkRejected(request.Context(), "drain")
+				onDraining.ServeHTTP(response, request)
+				return
+			}
+
+		default:
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (d Drain) ThenFunc(next http.HandlerFunc) http.Handler {
+	return d.Then(next)
+}
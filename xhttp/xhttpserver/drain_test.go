@@ -0,0 +1,89 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDrainNoShutdownSignal(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = Drain{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testDrainDefaults(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ss        = NewShutdownSignal()
+		decorated = Drain{
+			ShutdownSignal: ss,
+			Exempt:         []string{"/health"},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+	)
+
+	require.NotNil(decorated)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Empty(response.Header().Get("Connection"))
+
+	ss.Cancel()
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal("close", response.Header().Get("Connection"))
+	assert.Empty(response.Header().Get("Retry-After"))
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(299, response.Code)
+	assert.Empty(response.Header().Get("Connection"))
+}
+
+func testDrainCustom(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ss        = NewShutdownSignal()
+		decorated = Drain{
+			ShutdownSignal: ss,
+			RetryAfter:     1500 * time.Millisecond,
+			OnDraining: http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(599)
+			}),
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Fail("The next handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	ss.Cancel()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(599, response.Code)
+	assert.Equal("close", response.Header().Get("Connection"))
+	assert.Equal("2", response.Header().Get("Retry-After"))
+}
+
+func TestDrain(t *testing.T) {
+	t.Run("NoShutdownSignal", testDrainNoShutdownSignal)
+	t.Run("Defaults", testDrainDefaults)
+	t.Run("Custom", testDrainCustom)
+}
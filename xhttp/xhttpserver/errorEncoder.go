@@ -0,0 +1,57 @@
+package xhttpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ErrorEncoder is a strategy for rendering the error responses produced by the middleware in this package,
+// e.g. http.StatusTooManyRequests from Busy.  This allows an application to enforce a single error format.
+type ErrorEncoder func(response http.ResponseWriter, statusCode int, message string)
+
+// DefaultErrorEncoder writes a text/plain error response, in the same manner as http.Error
+func DefaultErrorEncoder(response http.ResponseWriter, statusCode int, message string) {
+	http.Error(response, message, statusCode)
+}
+
+// jsonError is the serialized form of an error produced by JSONErrorEncoder
+type jsonError struct {
+	Error jsonErrorDetail `json:"error"`
+}
+
+type jsonErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSONErrorEncoder writes an application/json error response with the envelope:
+//
+//    {"error": {"code": 429, "message": "Too Many Requests"}}
+func JSONErrorEncoder(response http.ResponseWriter, statusCode int, message string) {
+	body, _ := json.Marshal(jsonError{
+		Error: jsonErrorDetail{
+			Code:    statusCode,
+			Message: message,
+		},
+	})
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	response.WriteHeader(statusCode)
+	response.Write(body)
+}
+
+// NewErrorHandler produces an http.Handler that uses the given ErrorEncoder to render a response with a fixed
+// status code.  The message is the standard text for the status code.  If the ErrorEncoder is nil,
+// DefaultErrorEncoder is used.
+func NewErrorHandler(ee ErrorEncoder, statusCode int) http.Handler {
+	if ee == nil {
+		ee = DefaultErrorEncoder
+	}
+
+	message := http.StatusText(statusCode)
+	return http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		ee(response, statusCode, message)
+	})
+}
@@ -0,0 +1,67 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultErrorEncoder(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+	)
+
+	DefaultErrorEncoder(response, 499, "test message")
+	assert.Equal(499, response.Code)
+	assert.Contains(response.Header().Get("Content-Type"), "text/plain")
+	assert.Equal("test message\n", response.Body.String())
+}
+
+func TestJSONErrorEncoder(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+	)
+
+	JSONErrorEncoder(response, 499, "test message")
+	assert.Equal(499, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+	assert.JSONEq(`{"error": {"code": 499, "message": "test message"}}`, response.Body.String())
+}
+
+func TestNewErrorHandler(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			handler  = NewErrorHandler(nil, http.StatusTooManyRequests)
+			response = httptest.NewRecorder()
+		)
+
+		require.NotNil(handler)
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(http.StatusTooManyRequests, response.Code)
+		assert.Equal("text/plain; charset=utf-8", response.Header().Get("Content-Type"))
+		assert.Equal("Too Many Requests\n", response.Body.String())
+	})
+
+	t.Run("Encoder", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			handler  = NewErrorHandler(JSONErrorEncoder, http.StatusTooManyRequests)
+			response = httptest.NewRecorder()
+		)
+
+		require.NotNil(handler)
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(http.StatusTooManyRequests, response.Code)
+		assert.JSONEq(`{"error": {"code": 429, "message": "Too Many Requests"}}`, response.Body.String())
+	})
+}
@@ -0,0 +1,63 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpectCTHeader is the response header that asks browsers to enforce, or report on, Certificate Transparency
+const ExpectCTHeader = "Expect-CT"
+
+// ExpectCT is an Alice-style decorator that emits the Expect-CT header on responses to TLS requests, including
+// requests that a trusted proxy received over TLS.  See RequestScheme.  Browsers ignore this header over plaintext,
+// so plaintext requests are left untouched.
+//
+// Browsers have deprecated Expect-CT in favor of enforcing Certificate Transparency for all certificates.  This
+// decorator exists for compliance requirements that still call for the header, and it is never enabled by default.
+type ExpectCT struct {
+	// MaxAge is the duration for which browsers remember this policy.  Any fractional seconds are truncated.
+	MaxAge time.Duration
+
+	// Enforce indicates whether browsers should refuse connections that violate the policy, rather than only
+	// reporting violations
+	Enforce bool
+
+	// ReportURI is the optional absolute URI to which browsers report violations
+	ReportURI string
+}
+
+// HeaderValue produces the value of the Expect-CT header for this policy
+func (ect ExpectCT) HeaderValue() string {
+	maxAge := int64(ect.MaxAge / time.Second)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	directives := []string{"max-age=" + strconv.FormatInt(maxAge, 10)}
+	if ect.Enforce {
+		directives = append(directives, "enforce")
+	}
+
+	if len(ect.ReportURI) > 0 {
+		directives = append(directives, "report-uri="+strconv.Quote(ect.ReportURI))
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+func (ect ExpectCT) Then(next http.Handler) http.Handler {
+	value := ect.HeaderValue()
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if RequestScheme(request) == "https" {
+			response.Header().Set(ExpectCTHeader, value)
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (ect ExpectCT) ThenFunc(next http.HandlerFunc) http.Handler {
+	return ect.Then(next)
+}
@@ -0,0 +1,85 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectCTHeaderValue(t *testing.T) {
+	testData := []struct {
+		expectCT ExpectCT
+		expected string
+	}{
+		{ExpectCT{}, "max-age=0"},
+		{ExpectCT{MaxAge: -time.Hour}, "max-age=0"},
+		{ExpectCT{MaxAge: 24*time.Hour + 500*time.Millisecond}, "max-age=86400"},
+		{ExpectCT{MaxAge: time.Minute, Enforce: true}, "max-age=60, enforce"},
+		{
+			ExpectCT{MaxAge: time.Minute, Enforce: true, ReportURI: "https://example.com/report"},
+			`max-age=60, enforce, report-uri="https://example.com/report"`,
+		},
+		{
+			ExpectCT{MaxAge: time.Minute, ReportURI: "https://example.com/report"},
+			`max-age=60, report-uri="https://example.com/report"`,
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, record.expected, record.expectCT.HeaderValue())
+		})
+	}
+}
+
+func TestExpectCT(t *testing.T) {
+	var (
+		decorated = ExpectCT{
+			MaxAge:  time.Hour,
+			Enforce: true,
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+	)
+
+	t.Run("Plaintext", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(299, response.Code)
+		assert.Empty(response.Header().Get(ExpectCTHeader))
+	})
+
+	t.Run("Forwarded", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("GET", "/", nil)
+		)
+
+		decorated.ServeHTTP(response, request.WithContext(WithScheme(request.Context(), "https")))
+		assert.Equal(299, response.Code)
+		assert.Equal("max-age=3600, enforce", response.Header().Get(ExpectCTHeader))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("GET", "/", nil)
+		)
+
+		request.TLS = new(tls.ConnectionState)
+		decorated.ServeHTTP(response, request)
+		assert.Equal(299, response.Code)
+		assert.Equal("max-age=3600, enforce", response.Header().Get(ExpectCTHeader))
+	})
+}
@@ -0,0 +1,91 @@
+package xhttpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// FaviconPath is the URI path browsers request for a site's icon
+	FaviconPath = "/favicon.ico"
+
+	// DefaultFaviconContentType is the Content-Type used for a Favicon body when none is configured
+	DefaultFaviconContentType = "image/x-icon"
+)
+
+// Favicon is an Alice-style decorator that answers GET and HEAD requests for FaviconPath without invoking the
+// decorated handler.  Browsers request this path relentlessly, which otherwise fills access logs with 404s.
+// All other requests are passed through unchanged.
+//
+// If there is no Body, a 204 with no content is returned.  Otherwise, the Body is served with a 200.
+type Favicon struct {
+	// File is the optional path to an icon file.  Servers created by Unmarshal load this file into Body.
+	// Code that uses Favicon directly must set Body instead.
+	File string
+
+	// ContentType is the Content-Type of Body.  If unset, DefaultFaviconContentType is used.
+	ContentType string
+
+	// MaxAge is the optional duration for which clients may cache the icon
+	MaxAge time.Duration
+
+	// Body is the icon content.  This field cannot be unmarshalled.  Use File to configure an icon externally.
+	Body []byte `json:"-"`
+}
+
+// load reads File into Body, if File is set and Body is empty
+func (f *Favicon) load() error {
+	if len(f.File) == 0 || len(f.Body) > 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadFile(f.File)
+	if err != nil {
+		return err
+	}
+
+	f.Body = body
+	return nil
+}
+
+// newHandler creates the http.Handler that serves this favicon
+func (f Favicon) newHandler() http.Handler {
+	c := Constant{
+		StatusCode: http.StatusNoContent,
+		Header:     http.Header{},
+	}
+
+	if len(f.Body) > 0 {
+		c.StatusCode = http.StatusOK
+		c.Body = f.Body
+		if len(f.ContentType) > 0 {
+			c.Header.Set("Content-Type", f.ContentType)
+		} else {
+			c.Header.Set("Content-Type", DefaultFaviconContentType)
+		}
+	}
+
+	if f.MaxAge > 0 {
+		c.Header.Set("Cache-Control", "max-age="+strconv.FormatInt(int64(f.MaxAge/time.Second), 10))
+	}
+
+	return c.NewHandler()
+}
+
+func (f Favicon) Then(next http.Handler) http.Handler {
+	favicon := f.newHandler()
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == FaviconPath && (request.Method == http.MethodGet || request.Method == http.MethodHead) {
+			favicon.ServeHTTP(response, request)
+			return
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (f Favicon) ThenFunc(next http.HandlerFunc) http.Handler {
+	return f.Then(next)
+}
@@ -0,0 +1,108 @@
+package xhttpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFaviconEmpty(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		favicon = Favicon{}.ThenFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	favicon.ServeHTTP(response, httptest.NewRequest("GET", FaviconPath, nil))
+	assert.Equal(http.StatusNoContent, response.Code)
+	assert.Empty(response.Header().Get("Content-Type"))
+	assert.Empty(response.Header().Get("Cache-Control"))
+	assert.Zero(response.Body.Len())
+}
+
+func testFaviconBody(t *testing.T, contentType, expectedContentType string) {
+	var (
+		assert = assert.New(t)
+
+		favicon = Favicon{
+			ContentType: contentType,
+			MaxAge:      time.Hour,
+			Body:        []byte("icon"),
+		}.ThenFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	favicon.ServeHTTP(response, httptest.NewRequest("GET", FaviconPath, nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal(expectedContentType, response.Header().Get("Content-Type"))
+	assert.Equal("max-age=3600", response.Header().Get("Cache-Control"))
+	assert.Equal("icon", response.Body.String())
+}
+
+func testFaviconPassThrough(t *testing.T, method, path string) {
+	var (
+		assert = assert.New(t)
+
+		favicon = Favicon{Body: []byte("icon")}.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	favicon.ServeHTTP(response, httptest.NewRequest(method, path, nil))
+	assert.Equal(299, response.Code)
+}
+
+func testFaviconLoad(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	file, err := ioutil.TempFile("", "favicon.*.ico")
+	require.NoError(err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write([]byte("icon"))
+	file.Close()
+	require.NoError(err)
+
+	f := Favicon{File: file.Name()}
+	assert.NoError(f.load())
+	assert.Equal([]byte("icon"), f.Body)
+
+	f = Favicon{File: file.Name(), Body: []byte("existing")}
+	assert.NoError(f.load())
+	assert.Equal([]byte("existing"), f.Body)
+
+	f = Favicon{}
+	assert.NoError(f.load())
+	assert.Empty(f.Body)
+
+	f = Favicon{File: file.Name() + ".missing"}
+	assert.Error(f.load())
+	assert.Empty(f.Body)
+}
+
+func TestFavicon(t *testing.T) {
+	t.Run("Empty", testFaviconEmpty)
+	t.Run("DefaultContentType", func(t *testing.T) { testFaviconBody(t, "", DefaultFaviconContentType) })
+	t.Run("CustomContentType", func(t *testing.T) { testFaviconBody(t, "image/png", "image/png") })
+	t.Run("OtherPath", func(t *testing.T) { testFaviconPassThrough(t, "GET", "/test") })
+	t.Run("OtherMethod", func(t *testing.T) { testFaviconPassThrough(t, "POST", FaviconPath) })
+	t.Run("Load", testFaviconLoad)
+}
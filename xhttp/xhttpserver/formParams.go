@@ -0,0 +1,178 @@
+package xhttpserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// FormParamsError is returned when a request has more query and form parameters than allowed.  This error implements
+// go-kit's StatusCoder, so that error encoders will produce an http.StatusBadRequest.
+type FormParamsError struct {
+	Max int
+}
+
+func (fpe FormParamsError) Error() string {
+	return fmt.Sprintf("The request has more than %d query and form parameters", fpe.Max)
+}
+
+func (fpe FormParamsError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// paramCounter counts the URL-encoded parameters in a stream of bytes.  Empty parameters are not counted, exactly
+// as with url.ParseQuery.
+type paramCounter struct {
+	count   int
+	pending bool
+}
+
+func (pc *paramCounter) write(p []byte) {
+	for _, b := range p {
+		if b == '&' {
+			if pc.pending {
+				pc.count++
+				pc.pending = false
+			}
+		} else {
+			pc.pending = true
+		}
+	}
+}
+
+// total returns the number of parameters seen so far, including any partial parameter at the end
+func (pc *paramCounter) total() int {
+	if pc.pending {
+		return pc.count + 1
+	}
+
+	return pc.count
+}
+
+// formParamsBody is an io.ReadCloser decorator that fails once a URL-encoded body has too many parameters
+type formParamsBody struct {
+	io.ReadCloser
+	counter paramCounter
+	max     int
+}
+
+func (fpb *formParamsBody) Read(p []byte) (int, error) {
+	n, err := fpb.ReadCloser.Read(p)
+	fpb.counter.write(p[:n])
+	if fpb.counter.total() > fpb.max {
+		return n, FormParamsError{Max: fpb.max}
+	}
+
+	return n, err
+}
+
+// FormParamsLimit is an Alice-style decorator that limits the combined number of query and form parameters of each
+// request.  This guards against requests with huge numbers of parameters, which make parsing allocate heavily,
+// while leaving form parsing enabled.
+//
+// Requests whose query alone exceeds the limit are rejected before the decorated handler executes.  For
+// application/x-www-form-urlencoded bodies, which cannot be counted without reading them, the body is counted as it
+// is read and fails with a FormParamsError once the limit is exceeded.  Since that error is returned from
+// http.Request.ParseForm, handlers should check the error from ParseForm.  Multipart bodies are not counted.
+type FormParamsLimit struct {
+	// Max is the largest number of query and form parameters allowed.  If nonpositive, no decoration is done.
+	Max int
+
+	// OnExceeded is the optional handler for requests whose query exceeds Max.  If unset, a 400 is returned.
+	OnExceeded http.Handler
+}
+
+func (fpl FormParamsLimit) Then(next http.Handler) http.Handler {
+	if fpl.Max <= 0 {
+		return next
+	}
+
+	onExceeded := fpl.OnExceeded
+	if onExceeded == nil {
+		onExceeded = Constant{StatusCode: http.StatusBadRequest}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var counter paramCounter
+		counter.write([]byte(request.URL.RawQuery))
+		if counter.total() > fpl.Max {
+			MarkRejected(request.Context(), "formParams")
+			onExceeded.ServeHTTP(response, request)
+			return
+		}
+
+		if request.Body != nil && request.Body != http.NoBody {
+			if mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type")); err == nil && mediaType == "application/x-www-form-urlencoded" {
+				request.Body = &formParamsBody{
+					ReadCloser: request.Body,
+					// a query and a body are joined into one set of parameters
+					counter: paramCounter{count: counter.total()},
+					max:     fpl.Max,
+				}
+			}
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (fpl FormParamsLimit) ThenFunc(next http.HandlerFunc) http.Handler {
+	return fpl.Then(next)
+}
+
+// StrictForm is an Alice-style decorator that parses each request's query and form, via http.Request.ParseForm,
+// before the decorated handler executes.  A request whose form cannot be parsed, e.g. because of a malformed
+// URL-encoded body, is rejected rather than reaching a handler that would otherwise see empty form values.  The
+// parse error is logged with the request's contextual logger, so this decorator should follow the logging stage.
+//
+// Errors that are HTTPErrors, such as a FormParamsError or an IOLimitError, determine the status code and message
+// of the rejection.  Any other error results in an http.StatusBadRequest.
+type StrictForm struct {
+	// ErrorEncoder is the optional strategy for rendering rejections.  If unset, DefaultErrorEncoder is used.
+	ErrorEncoder ErrorEncoder
+}
+
+func (sf StrictForm) Then(next http.Handler) http.Handler {
+	errorEncoder := sf.ErrorEncoder
+	if errorEncoder == nil {
+		errorEncoder = DefaultErrorEncoder
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		err := request.ParseForm()
+		if err == nil {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		xlog.Get(request.Context()).Log(
+			level.Key(), level.InfoValue(),
+			xlog.MessageKey(), "unable to parse form",
+			xlog.ErrorKey(), err,
+		)
+
+		var (
+			statusCode = http.StatusBadRequest
+			message    = http.StatusText(http.StatusBadRequest)
+			httpErr    HTTPError
+		)
+
+		if errors.As(err, &httpErr) {
+			statusCode = httpErr.StatusCode()
+			message = httpErr.Error()
+		}
+
+		MarkRejected(request.Context(), "strictForm")
+		errorEncoder(response, statusCode, message)
+	})
+}
+
+func (sf StrictForm) ThenFunc(next http.HandlerFunc) http.Handler {
+	return sf.Then(next)
+}
@@ -0,0 +1,229 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormParamsError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		err    = FormParamsError{Max: 123}
+	)
+
+	assert.Contains(err.Error(), "123")
+	assert.Equal(http.StatusBadRequest, err.StatusCode())
+}
+
+func TestParamCounter(t *testing.T) {
+	testData := []struct {
+		value    string
+		expected int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"a=1", 1},
+		{"a=1&b=2", 2},
+		{"a=1&&&b=2&", 2},
+		{"&&&", 0},
+		{"a&b&c&d", 4},
+	}
+
+	for _, record := range testData {
+		t.Run(record.value, func(t *testing.T) {
+			var pc paramCounter
+			pc.write([]byte(record.value))
+			assert.Equal(t, record.expected, pc.total())
+		})
+	}
+}
+
+func testFormParamsLimitNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = Constant{StatusCode: 299}.NewHandler()
+	)
+
+	assert.Equal(next, FormParamsLimit{}.Then(next))
+}
+
+func testFormParamsLimitQuery(t *testing.T, onExceeded http.Handler, expectedStatusCode int) {
+	var (
+		assert = assert.New(t)
+
+		decorated = FormParamsLimit{Max: 3, OnExceeded: onExceeded}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.NoError(request.ParseForm())
+			response.WriteHeader(299)
+		})
+	)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test?a=1&b=2&c=3", nil))
+	assert.Equal(299, response.Code)
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test?a=1&b=2&c=3&d=4", nil))
+	assert.Equal(expectedStatusCode, response.Code)
+}
+
+func testFormParamsLimitBody(t *testing.T, target, body string, expectedErr bool) {
+	var (
+		assert = assert.New(t)
+
+		decorated = FormParamsLimit{Max: 3}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			err := request.ParseForm()
+			if expectedErr {
+				var fpe FormParamsError
+				assert.True(errors.As(err, &fpe))
+				assert.Equal(3, fpe.Max)
+				response.WriteHeader(fpe.StatusCode())
+				return
+			}
+
+			assert.NoError(err)
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", target, strings.NewReader(body))
+	)
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	decorated.ServeHTTP(response, request)
+	if expectedErr {
+		assert.Equal(http.StatusBadRequest, response.Code)
+	} else {
+		assert.Equal(299, response.Code)
+	}
+}
+
+func testFormParamsLimitOtherBody(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = FormParamsLimit{Max: 1}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			_, ok := request.Body.(*formParamsBody)
+			assert.False(ok)
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/test", strings.NewReader(`{"a": 1, "b": 2}`))
+	)
+
+	request.Header.Set("Content-Type", "application/json")
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func TestFormParamsLimit(t *testing.T) {
+	t.Run("NoDecoration", testFormParamsLimitNoDecoration)
+	t.Run("Query", func(t *testing.T) {
+		testFormParamsLimitQuery(t, nil, http.StatusBadRequest)
+	})
+
+	t.Run("QueryCustom", func(t *testing.T) {
+		testFormParamsLimitQuery(t, Constant{StatusCode: 599}.NewHandler(), 599)
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		testFormParamsLimitBody(t, "/test", "a=1&b=2&c=3", false)
+	})
+
+	t.Run("BodyExceeded", func(t *testing.T) {
+		testFormParamsLimitBody(t, "/test", "a=1&b=2&c=3&d=4", true)
+	})
+
+	t.Run("QueryAndBody", func(t *testing.T) {
+		testFormParamsLimitBody(t, "/test?a=1&b=2", "c=3", false)
+	})
+
+	t.Run("QueryAndBodyExceeded", func(t *testing.T) {
+		testFormParamsLimitBody(t, "/test?a=1&b=2", "c=3&d=4", true)
+	})
+
+	t.Run("OtherBody", testFormParamsLimitOtherBody)
+}
+
+func testStrictForm(t *testing.T, sf StrictForm, decorators []func(http.Handler) http.Handler, target, body string, expectedStatusCode int) {
+	var (
+		assert = assert.New(t)
+
+		output  bytes.Buffer
+		handler http.Handler = sf.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.NotNil(request.Form)
+			assert.Equal("1", request.Form.Get("a"))
+			response.WriteHeader(299)
+		})
+
+		request = httptest.NewRequest("POST", target, strings.NewReader(body))
+	)
+
+	for _, d := range decorators {
+		handler = d(handler)
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	assert.Equal(expectedStatusCode, response.Code)
+	if expectedStatusCode == 299 {
+		assert.Zero(output.Len())
+	} else {
+		assert.Contains(output.String(), "unable to parse form")
+	}
+}
+
+func TestStrictForm(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		testStrictForm(t, StrictForm{}, nil, "/?a=1", "b=2", 299)
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		testStrictForm(t, StrictForm{}, nil, "/?a=1&b=%zz", "", http.StatusBadRequest)
+	})
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		testStrictForm(t, StrictForm{}, nil, "/", "a=1&b=%zz", http.StatusBadRequest)
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		testStrictForm(
+			t,
+			StrictForm{},
+			[]func(http.Handler) http.Handler{FormParamsLimit{Max: 2}.Then},
+			"/?a=1",
+			"b=2&c=3",
+			http.StatusBadRequest,
+		)
+	})
+
+	t.Run("ErrorEncoder", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			called bool
+
+			sf = StrictForm{
+				ErrorEncoder: func(response http.ResponseWriter, statusCode int, message string) {
+					called = true
+					assert.Equal(http.StatusBadRequest, statusCode)
+					response.WriteHeader(statusCode)
+				},
+			}
+		)
+
+		testStrictForm(t, sf, nil, "/", "a=%zz", http.StatusBadRequest)
+		assert.True(called)
+	})
+}
@@ -0,0 +1,106 @@
+package xhttpserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type schemeContextKey struct{}
+
+// WithScheme returns a new context with the given URL scheme, e.g. https
+func WithScheme(ctx context.Context, scheme string) context.Context {
+	return context.WithValue(ctx, schemeContextKey{}, scheme)
+}
+
+// SchemeFromContext returns the scheme that a client used to reach a trusted proxy, as stored by ForwardedScheme.
+// The returned boolean is false if no such scheme was stored.
+func SchemeFromContext(ctx context.Context) (string, bool) {
+	scheme, ok := ctx.Value(schemeContextKey{}).(string)
+	return scheme, ok
+}
+
+// RequestScheme returns the scheme that the client used to make a request.  The scheme forwarded from a trusted
+// proxy is used if present, followed by the scheme of the connection to this server.  Code that builds
+// absolute URLs should use this function rather than checking request.TLS.
+func RequestScheme(request *http.Request) string {
+	if scheme, ok := SchemeFromContext(request.Context()); ok {
+		return scheme
+	}
+
+	if request.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// forwardedProto returns the proto parameter of the first element of a Forwarded header, as described by RFC 7239
+func forwardedProto(forwarded string) string {
+	first := strings.SplitN(forwarded, ",", 2)[0]
+	for _, pair := range strings.Split(first, ";") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "proto") {
+			return strings.Trim(kv[1], `"`)
+		}
+	}
+
+	return ""
+}
+
+// forwardedScheme determines the scheme forwarded by a proxy.  The standard Forwarded header takes precedence
+// over X-Forwarded-Proto.  Only http and https are recognized, and an empty string is returned otherwise.
+func forwardedScheme(h http.Header) string {
+	scheme := forwardedProto(h.Get("Forwarded"))
+	if len(scheme) == 0 {
+		scheme = strings.TrimSpace(strings.SplitN(h.Get("X-Forwarded-Proto"), ",", 2)[0])
+	}
+
+	switch scheme = strings.ToLower(scheme); scheme {
+	case "http", "https":
+		return scheme
+
+	default:
+		return ""
+	}
+}
+
+// ForwardedScheme is an Alice-style decorator that determines the scheme clients used to reach a proxy, e.g.
+// when TLS is terminated at a load balancer.  For requests from Trusted proxies, the scheme from the Forwarded
+// or X-Forwarded-Proto header is set as the request URL's Scheme and stored in the context, where it is available
+// via SchemeFromContext and RequestScheme.  These headers are ignored for requests from any other address,
+// since clients can send anything.
+type ForwardedScheme struct {
+	// Trusted are the networks of proxies whose forwarded headers are believed.  If empty, no decoration is done.
+	Trusted []*net.IPNet
+}
+
+func (fs ForwardedScheme) Then(next http.Handler) http.Handler {
+	if len(fs.Trusted) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !trustedAddress(fs.Trusted, request.RemoteAddr) {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		scheme := forwardedScheme(request.Header)
+		if len(scheme) == 0 {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		forwarded := request.WithContext(WithScheme(request.Context(), scheme))
+		url := *forwarded.URL
+		url.Scheme = scheme
+		forwarded.URL = &url
+		next.ServeHTTP(response, forwarded)
+	})
+}
+
+func (fs ForwardedScheme) ThenFunc(next http.HandlerFunc) http.Handler {
+	return fs.Then(next)
+}
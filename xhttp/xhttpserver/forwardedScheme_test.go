@@ -0,0 +1,105 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestScheme(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	assert.Equal("http", RequestScheme(request))
+
+	request.TLS = new(tls.ConnectionState)
+	assert.Equal("https", RequestScheme(request))
+
+	request = request.WithContext(WithScheme(context.Background(), "http"))
+	assert.Equal("http", RequestScheme(request))
+
+	scheme, ok := SchemeFromContext(request.Context())
+	assert.True(ok)
+	assert.Equal("http", scheme)
+
+	_, ok = SchemeFromContext(context.Background())
+	assert.False(ok)
+}
+
+func testForwardedSchemeNoTrusted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = ForwardedScheme{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testForwardedSchemeHeaders(t *testing.T) {
+	trusted, err := ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{"None", "10.1.1.1:1234", http.Header{}, ""},
+		{"XForwardedProto", "10.1.1.1:1234", http.Header{"X-Forwarded-Proto": {"HTTPS"}}, "https"},
+		{"XForwardedProtoList", "10.1.1.1:1234", http.Header{"X-Forwarded-Proto": {"https, http"}}, "https"},
+		{"Forwarded", "10.1.1.1:1234", http.Header{"Forwarded": {`for=192.0.2.60;proto=https;by=203.0.113.43`}}, "https"},
+		{"ForwardedQuoted", "10.1.1.1:1234", http.Header{"Forwarded": {`Proto="https", proto=http`}}, "https"},
+		{"ForwardedPrecedence", "10.1.1.1:1234", http.Header{"Forwarded": {"proto=http"}, "X-Forwarded-Proto": {"https"}}, "http"},
+		{"ForwardedNoProto", "10.1.1.1:1234", http.Header{"Forwarded": {"for=192.0.2.60"}, "X-Forwarded-Proto": {"https"}}, "https"},
+		{"Unrecognized", "10.1.1.1:1234", http.Header{"X-Forwarded-Proto": {"gopher"}}, ""},
+		{"Untrusted", "192.168.1.1:1234", http.Header{"X-Forwarded-Proto": {"https"}}, ""},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				decorated = ForwardedScheme{
+					Trusted: trusted,
+				}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+					scheme, ok := SchemeFromContext(request.Context())
+					if len(record.expected) > 0 {
+						assert.True(ok)
+						assert.Equal(record.expected, scheme)
+						assert.Equal(record.expected, request.URL.Scheme)
+						assert.Equal(record.expected, RequestScheme(request))
+					} else {
+						assert.False(ok)
+						assert.Equal("http", RequestScheme(request))
+					}
+
+					response.WriteHeader(299)
+				})
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", "/", nil)
+			)
+
+			request.RemoteAddr = record.remoteAddr
+			request.Header = record.header
+			decorated.ServeHTTP(response, request)
+			assert.Equal(299, response.Code)
+		})
+	}
+}
+
+func TestForwardedScheme(t *testing.T) {
+	t.Run("NoTrusted", testForwardedSchemeNoTrusted)
+	t.Run("Headers", testForwardedSchemeHeaders)
+}
@@ -0,0 +1,36 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryAfterValue formats a duration as the value of a Retry-After header, which is expressed in whole seconds.
+// Durations are rounded up, so that clients never retry too early.  Nonpositive durations produce an empty value,
+// meaning no Retry-After header should be sent.
+func retryAfterValue(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// exemptPaths is the set of URI paths, e.g. health checks, that a gate such as StartupGate, Drain, or
+// MaintenanceMode never rejects
+type exemptPaths map[string]bool
+
+func newExemptPaths(paths []string) exemptPaths {
+	ep := make(exemptPaths, len(paths))
+	for _, path := range paths {
+		ep[path] = true
+	}
+
+	return ep
+}
+
+// contains tests if the request's full path is exempt
+func (ep exemptPaths) contains(request *http.Request) bool {
+	return ep[request.URL.Path]
+}
@@ -0,0 +1,43 @@
+package xhttpserver
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfterValue(t *testing.T) {
+	testData := []struct {
+		retryAfter time.Duration
+		expected   string
+	}{
+		{retryAfter: -time.Second, expected: ""},
+		{retryAfter: 0, expected: ""},
+		{retryAfter: time.Millisecond, expected: "1"},
+		{retryAfter: time.Second, expected: "1"},
+		{retryAfter: 1500 * time.Millisecond, expected: "2"},
+		{retryAfter: time.Minute, expected: "60"},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, record.expected, retryAfterValue(record.retryAfter))
+		})
+	}
+}
+
+func TestExemptPaths(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ep     = newExemptPaths([]string{"/health", "/ready"})
+	)
+
+	assert.True(ep.contains(httptest.NewRequest("GET", "/health", nil)))
+	assert.True(ep.contains(httptest.NewRequest("GET", "/ready?verbose=true", nil)))
+	assert.False(ep.contains(httptest.NewRequest("GET", "/health/", nil)))
+	assert.False(ep.contains(httptest.NewRequest("GET", "/", nil)))
+	assert.False(newExemptPaths(nil).contains(httptest.NewRequest("GET", "/health", nil)))
+}
@@ -0,0 +1,172 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// timeoutWriter buffers a handler's response so that it can be discarded if the handler times out
+type timeoutWriter struct {
+	logger log.Logger
+
+	lock        sync.Mutex
+	header      http.Header
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	timedOut    bool
+	lateLogged  bool
+}
+
+// late logs, at most once, that a handler used the response after its timeout.  The lock must be held.
+func (tw *timeoutWriter) late() {
+	if !tw.lateLogged {
+		tw.lateLogged = true
+		tw.logger.Log(
+			level.Key(), level.DebugValue(),
+			xlog.MessageKey(), "discarding response written after handler timeout",
+		)
+	}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+	if tw.timedOut {
+		tw.late()
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+	if tw.timedOut {
+		tw.late()
+		return
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(statusCode)
+	}
+}
+
+// writeHeader records the status code.  The lock must be held.
+func (tw *timeoutWriter) writeHeader(statusCode int) {
+	tw.wroteHeader = true
+	tw.statusCode = statusCode
+}
+
+// HandlerTimeout is an Alice-style decorator that bounds the time a handler has to produce its response.  It is
+// similar to http.TimeoutHandler, save that anything the handler does after its timeout, including writing to the
+// response or panicking, is discarded with a debug log to the request's contextual logger rather than surfacing
+// as an error.  A slow handler that finishes late therefore neither crashes nor produces noise.
+//
+// As with http.TimeoutHandler, the handler's response is buffered, and the handler's request context is canceled
+// when the timeout elapses.  Decorated handlers cannot hijack connections or flush responses.  Panics that occur
+// before the timeout are propagated to the caller, e.g. a Recovery decorator.
+type HandlerTimeout struct {
+	// Timeout is the maximum time the decorated handler has to produce its response.  If nonpositive,
+	// no decoration is done.
+	Timeout time.Duration
+
+	// OnTimeout is the optional handler for requests that time out.  If unset, a 503 is returned.
+	OnTimeout http.Handler
+}
+
+func (ht HandlerTimeout) Then(next http.Handler) http.Handler {
+	if ht.Timeout <= 0 {
+		return next
+	}
+
+	onTimeout := ht.OnTimeout
+	if onTimeout == nil {
+		onTimeout = Constant{StatusCode: http.StatusServiceUnavailable}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ctx, cancel := context.WithTimeout(request.Context(), ht.Timeout)
+		defer cancel()
+
+		var (
+			tw = &timeoutWriter{
+				logger: xlog.Get(ctx),
+				header: make(http.Header),
+			}
+
+			done   = make(chan struct{})
+			panics = make(chan interface{}, 1)
+		)
+
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					tw.lock.Lock()
+					if tw.timedOut {
+						tw.logger.Log(
+							level.Key(), level.DebugValue(),
+							xlog.MessageKey(), "discarding handler panic after handler timeout",
+							PanicKey(), v,
+						)
+					}
+
+					tw.lock.Unlock()
+					panics <- v
+				}
+			}()
+
+			next.ServeHTTP(tw, request.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case v := <-panics:
+			panic(v)
+
+		case <-done:
+			tw.lock.Lock()
+			defer tw.lock.Unlock()
+
+			dst := response.Header()
+			for name, values := range tw.header {
+				dst[name] = values
+			}
+
+			if !tw.wroteHeader {
+				tw.statusCode = http.StatusOK
+			}
+
+			response.WriteHeader(tw.statusCode)
+			response.Write(tw.body.Bytes())
+
+		case <-ctx.Done():
+			tw.lock.Lock()
+			tw.timedOut = true
+			tw.lock.Unlock()
+			MarkRejected(request.Context(), "handlerTimeout")
+			onTimeout.ServeHTTP(response, request)
+		}
+	})
+}
+
+func (ht HandlerTimeout) ThenFunc(next http.HandlerFunc) http.Handler {
+	return ht.Then(next)
+}
@@ -0,0 +1,210 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a goroutine-safe bytes.Buffer for capturing log output
+type syncBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.buffer.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.buffer.String()
+}
+
+func testHandlerTimeoutNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = HandlerTimeout{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testHandlerTimeoutWithinTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = HandlerTimeout{Timeout: time.Minute}.ThenFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				_, ok := request.Context().Deadline()
+				assert.True(ok)
+				response.Header().Set("X-Test", "value")
+				response.WriteHeader(299)
+				response.Write([]byte("test body"))
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Equal("value", response.Header().Get("X-Test"))
+	assert.Equal("test body", response.Body.String())
+}
+
+func testHandlerTimeoutImplicitStatus(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = HandlerTimeout{Timeout: time.Minute}.ThenFunc(
+			func(response http.ResponseWriter, _ *http.Request) {
+				response.Write([]byte("test body"))
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("test body", response.Body.String())
+}
+
+func testHandlerTimeoutLateWrite(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output   syncBuffer
+		finished = make(chan error, 1)
+
+		decorated = Recovery{
+			OnPanic: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				assert.Fail("No panic should have reached the recovery middleware")
+			}),
+		}.Then(
+			HandlerTimeout{Timeout: 50 * time.Millisecond}.ThenFunc(
+				func(response http.ResponseWriter, request *http.Request) {
+					<-request.Context().Done()
+					time.Sleep(50 * time.Millisecond)
+					response.Header().Set("X-Late", "true")
+					response.WriteHeader(299)
+					_, err := response.Write([]byte("late"))
+					finished <- err
+				},
+			),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Empty(response.Header().Get("X-Late"))
+
+	select {
+	case err := <-finished:
+		assert.Equal(http.ErrHandlerTimeout, err)
+	case <-time.After(5 * time.Second):
+		require.Fail("The handler did not finish")
+	}
+
+	assert.Contains(output.String(), "discarding response written after handler timeout")
+}
+
+func testHandlerTimeoutLatePanic(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output    syncBuffer
+		panicking = make(chan struct{})
+
+		decorated = Recovery{
+			OnPanic: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				assert.Fail("No panic should have reached the recovery middleware")
+			}),
+		}.Then(
+			HandlerTimeout{
+				Timeout:   50 * time.Millisecond,
+				OnTimeout: Constant{StatusCode: 599}.NewHandler(),
+			}.ThenFunc(
+				func(_ http.ResponseWriter, request *http.Request) {
+					<-request.Context().Done()
+					time.Sleep(50 * time.Millisecond)
+					close(panicking)
+					panic("expected")
+				},
+			),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(599, response.Code)
+
+	select {
+	case <-panicking:
+	case <-time.After(5 * time.Second):
+		require.Fail("The handler did not panic")
+	}
+
+	assert.Eventually(
+		func() bool { return strings.Contains(output.String(), "discarding handler panic") },
+		5*time.Second,
+		10*time.Millisecond,
+	)
+}
+
+func testHandlerTimeoutEarlyPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output    bytes.Buffer
+		decorated = Recovery{Logger: log.NewJSONLogger(&output)}.Then(
+			HandlerTimeout{Timeout: time.Minute}.ThenFunc(
+				func(http.ResponseWriter, *http.Request) {
+					panic("expected")
+				},
+			),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Contains(output.String(), "expected")
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	t.Run("NoDecoration", testHandlerTimeoutNoDecoration)
+	t.Run("WithinTimeout", testHandlerTimeoutWithinTimeout)
+	t.Run("ImplicitStatus", testHandlerTimeoutImplicitStatus)
+	t.Run("LateWrite", testHandlerTimeoutLateWrite)
+	t.Run("LatePanic", testHandlerTimeoutLatePanic)
+	t.Run("EarlyPanic", testHandlerTimeoutEarlyPanic)
+}
@@ -0,0 +1,206 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/themis/xmetrics"
+)
+
+const (
+	// DefaultHandshakeTimeout is the maximum duration of a TLS handshake when MaxConcurrentHandshakes is set
+	// and no HandshakeTimeout is configured.  This prevents slow clients from holding a handshake slot indefinitely.
+	DefaultHandshakeTimeout = 10 * time.Second
+
+	// DefaultHandshakeWaitTimeout is the maximum time a new connection waits for a handshake slot when
+	// MaxConcurrentHandshakes is set and no HandshakeWaitTimeout is configured
+	DefaultHandshakeWaitTimeout = 5 * time.Second
+
+	// DefaultMaxPendingHandshakes is the maximum number of new connections waiting for a handshake slot when
+	// MaxConcurrentHandshakes is set and no MaxPendingHandshakes is configured
+	DefaultMaxPendingHandshakes = 128
+)
+
+// HandshakeWaitFactory is a creation strategy for server-specific metrics that observe how long, in milliseconds,
+// new TLS connections wait for a handshake slot.  It is only used for TLS servers that configure MaxConcurrentHandshakes.
+type HandshakeWaitFactory interface {
+	New(string, Options) (xmetrics.Observer, error)
+}
+
+type HandshakeWaitFactoryFunc func(string, Options) (xmetrics.Observer, error)
+
+func (hwff HandshakeWaitFactoryFunc) New(n string, o Options) (xmetrics.Observer, error) {
+	return hwff(n, o)
+}
+
+// handshakeLimiter performs TLS handshakes in the background on behalf of a Listener, allowing only a
+// fixed number of handshakes to be in progress at any time.  Only connections that complete a handshake
+// are returned from Accept.  A bounded number of connections may wait for a handshake slot, and any
+// connections beyond that are closed immediately, so that a flood of connections cannot exhaust memory.
+type handshakeLimiter struct {
+	tlsConfig *tls.Config
+	slots     chan struct{}
+	pending   chan struct{}
+	maxWait   time.Duration
+	timeout   time.Duration
+	wait      xmetrics.Observer
+	clock     Clock
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	ready     chan net.Conn
+	errs      chan error
+	closed    chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+func newHandshakeLimiter(o Options, tcfg *tls.Config) *handshakeLimiter {
+	hl := &handshakeLimiter{
+		tlsConfig: tcfg,
+		slots:     make(chan struct{}, o.MaxConcurrentHandshakes),
+		maxWait:   o.HandshakeWaitTimeout,
+		timeout:   o.HandshakeTimeout,
+		wait:      o.HandshakeWait,
+		clock:     clockOrSystem(o.Clock),
+		ready:     make(chan net.Conn),
+		errs:      make(chan error),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if hl.timeout <= 0 {
+		hl.timeout = DefaultHandshakeTimeout
+	}
+
+	if hl.maxWait == 0 {
+		hl.maxWait = DefaultHandshakeWaitTimeout
+	}
+
+	maxPending := o.MaxPendingHandshakes
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPendingHandshakes
+	}
+
+	hl.pending = make(chan struct{}, maxPending)
+	return hl
+}
+
+// start begins accepting connections with the given function, if that hasn't already been done
+func (hl *handshakeLimiter) start(accept func() (net.Conn, error)) {
+	hl.startOnce.Do(func() {
+		go hl.acceptLoop(accept)
+	})
+}
+
+func (hl *handshakeLimiter) acceptLoop(accept func() (net.Conn, error)) {
+	defer close(hl.done)
+	for {
+		conn, err := accept()
+		if err == nil {
+			select {
+			case hl.pending <- struct{}{}:
+				go hl.handshake(conn)
+
+			default:
+				// too many connections are already waiting for a slot
+				conn.Close()
+			}
+
+			continue
+		}
+
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			select {
+			case hl.errs <- err:
+				continue
+			case <-hl.closed:
+			}
+		}
+
+		hl.err = err
+		return
+	}
+}
+
+// acquire waits for a handshake slot, giving up this connection's place among the pending connections once
+// the wait is over.  This method returns false if the maximum wait elapses or this limiter is closed first.
+func (hl *handshakeLimiter) acquire() bool {
+	defer func() { <-hl.pending }()
+
+	var (
+		start   = hl.clock.Now()
+		expired <-chan time.Time
+	)
+
+	if hl.maxWait > 0 {
+		timer := hl.clock.NewTimer(hl.maxWait)
+		defer timer.Stop()
+		expired = timer.C()
+	}
+
+	select {
+	case hl.slots <- struct{}{}:
+		if hl.wait != nil {
+			hl.wait.Observe(nil, float64(hl.clock.Since(start)/time.Millisecond))
+		}
+
+		return true
+
+	case <-expired:
+		return false
+
+	case <-hl.closed:
+		return false
+	}
+}
+
+func (hl *handshakeLimiter) handshake(conn net.Conn) {
+	if !hl.acquire() {
+		conn.Close()
+		return
+	}
+
+	tc := tls.Server(conn, hl.tlsConfig)
+	// the connection's deadline is enforced by the operating system, so it must use real time
+	conn.SetDeadline(time.Now().Add(hl.timeout))
+	err := tc.Handshake()
+	<-hl.slots
+
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	// the HTTP server applies its own deadlines
+	conn.SetDeadline(time.Time{})
+
+	select {
+	case hl.ready <- tc:
+	case <-hl.closed:
+		tc.Close()
+	}
+}
+
+// accept returns the next connection that has completed its handshake
+func (hl *handshakeLimiter) accept() (net.Conn, error) {
+	select {
+	case conn := <-hl.ready:
+		return conn, nil
+
+	case err := <-hl.errs:
+		return nil, err
+
+	case <-hl.done:
+		return nil, hl.err
+	}
+}
+
+// close causes any pending handshakes to be abandoned
+func (hl *handshakeLimiter) close() {
+	hl.closeOnce.Do(func() {
+		close(hl.closed)
+	})
+}
@@ -0,0 +1,142 @@
+package xhttpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// DefaultMaxHeaderValueBytes is the longest single header value allowed by HeaderHardening when no limit is
+	// configured
+	DefaultMaxHeaderValueBytes = 8192
+)
+
+// DefaultUniqueHeaders returns the headers that HeaderHardening allows at most once per request when none are
+// configured.  Repeating any of these is a common way to exploit differences between how proxies and servers
+// interpret a request.
+func DefaultUniqueHeaders() []string {
+	return []string{"Authorization", "Content-Length", "Content-Type"}
+}
+
+// HeaderError describes why a request's header was rejected by HeaderHardening
+type HeaderError struct {
+	// Name is the canonical name of the offending header
+	Name string
+
+	// Reason describes what was wrong with the header
+	Reason string
+}
+
+func (he HeaderError) Error() string {
+	return fmt.Sprintf("Invalid header %s: %s", he.Name, he.Reason)
+}
+
+func (he HeaderError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// HeaderHardening is an Alice-style decorator that rejects requests with suspicious or malformed headers with an
+// http.StatusBadRequest.  A request is rejected if any header value contains a control character other than a
+// horizontal tab, e.g. a NUL or a CR/LF injection attempt, if any header value is longer than MaxValueBytes, or
+// if any of the UniqueHeaders appears more than once.
+//
+// net/http already refuses most of these on the wire, and merges repeated identical Content-Length values.  This
+// decorator is defense in depth against the quirks net/http tolerates, such as repeated Authorization headers or
+// very long values within MaxHeaderBytes, and against headers introduced by proxies or earlier middleware.  This
+// decorator should precede any other middleware that acts on request headers, so that malformed headers are
+// rejected before anything reads them.  The reason for each rejection is logged with the request's contextual
+// logger, or with Logger if the request has none.  Header values are never logged, as they may be large or
+// sensitive.
+type HeaderHardening struct {
+	// MaxValueBytes is the longest single header value allowed.  If unset, DefaultMaxHeaderValueBytes is used.
+	MaxValueBytes int
+
+	// UniqueHeaders are the names of headers that may appear at most once.  If unset, DefaultUniqueHeaders is used.
+	UniqueHeaders []string
+
+	// ErrorEncoder is the optional strategy for rendering rejections.  If unset, DefaultErrorEncoder is used.
+	ErrorEncoder ErrorEncoder
+
+	// Logger is the optional logger for rejections of requests that have no contextual logger.  If unset,
+	// xlog.Default is used.
+	Logger log.Logger
+}
+
+// checkHeader returns a HeaderError if the given header violates the limits, or nil if it is acceptable
+func checkHeader(header http.Header, maxValueBytes int, unique []string) error {
+	for _, name := range unique {
+		if len(header[name]) > 1 {
+			return HeaderError{Name: name, Reason: "duplicated"}
+		}
+	}
+
+	for name, values := range header {
+		for _, v := range values {
+			if len(v) > maxValueBytes {
+				return HeaderError{Name: name, Reason: fmt.Sprintf("value longer than %d bytes", maxValueBytes)}
+			}
+
+			for i := 0; i < len(v); i++ {
+				if b := v[i]; (b < ' ' && b != '\t') || b == 0x7f {
+					return HeaderError{Name: name, Reason: "control character in value"}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (hh HeaderHardening) Then(next http.Handler) http.Handler {
+	maxValueBytes := hh.MaxValueBytes
+	if maxValueBytes <= 0 {
+		maxValueBytes = DefaultMaxHeaderValueBytes
+	}
+
+	unique := hh.UniqueHeaders
+	if len(unique) == 0 {
+		unique = DefaultUniqueHeaders()
+	}
+
+	canonicalUnique := make([]string, len(unique))
+	for i, name := range unique {
+		canonicalUnique[i] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+
+	errorEncoder := hh.ErrorEncoder
+	if errorEncoder == nil {
+		errorEncoder = DefaultErrorEncoder
+	}
+
+	logger := hh.Logger
+	if logger == nil {
+		logger = xlog.Default()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		err := checkHeader(request.Header, maxValueBytes, canonicalUnique)
+		if err == nil {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		xlog.GetDefault(request.Context(), logger).Log(
+			level.Key(), level.InfoValue(),
+			xlog.MessageKey(), "suspicious request header",
+			xlog.ErrorKey(), err,
+		)
+
+		MarkRejected(request.Context(), "headerHardening")
+		errorEncoder(response, http.StatusBadRequest, err.Error())
+	})
+}
+
+func (hh HeaderHardening) ThenFunc(next http.HandlerFunc) http.Handler {
+	return hh.Then(next)
+}
@@ -0,0 +1,126 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		err    = HeaderError{Name: "X-Test", Reason: "duplicated"}
+	)
+
+	assert.Contains(err.Error(), "X-Test")
+	assert.Contains(err.Error(), "duplicated")
+	assert.Equal(http.StatusBadRequest, err.StatusCode())
+}
+
+func testHeaderHardeningAllowed(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		decorated = HeaderHardening{}.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Authorization", "Bearer token")
+	request.Header.Set("X-Tabbed", "a\tb")
+	request.Header.Add("Accept", "text/plain")
+	request.Header.Add("Accept", "application/json")
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func testHeaderHardeningRejected(t *testing.T) {
+	testData := []struct {
+		hardening HeaderHardening
+		header    http.Header
+		expected  string
+	}{
+		{
+			header:   http.Header{"Content-Length": {"5", "5"}},
+			expected: "Content-Length",
+		},
+		{
+			header:   http.Header{"Authorization": {"a", "b"}},
+			expected: "Authorization",
+		},
+		{
+			hardening: HeaderHardening{UniqueHeaders: []string{"x-custom"}},
+			header:    http.Header{"X-Custom": {"a", "b"}},
+			expected:  "X-Custom",
+		},
+		{
+			header:   http.Header{"X-Null": {"a\x00b"}},
+			expected: "control character",
+		},
+		{
+			header:   http.Header{"X-Crlf": {"a\r\nSet-Cookie: b"}},
+			expected: "control character",
+		},
+		{
+			header:   http.Header{"X-Long": {strings.Repeat("a", DefaultMaxHeaderValueBytes+1)}},
+			expected: "longer than",
+		},
+		{
+			hardening: HeaderHardening{MaxValueBytes: 4},
+			header:    http.Header{"X-Long": {"abcde"}},
+			expected:  "longer than 4",
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+				output bytes.Buffer
+
+				decorated = record.hardening.Then(Constant{StatusCode: 299}.NewHandler())
+				request   = httptest.NewRequest("GET", "/", nil)
+				response  = httptest.NewRecorder()
+			)
+
+			request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+			request.Header = record.header
+			decorated.ServeHTTP(response, request)
+			assert.Equal(http.StatusBadRequest, response.Code)
+			assert.Contains(response.Body.String(), record.expected)
+			assert.Contains(output.String(), "suspicious request header")
+			assert.Contains(output.String(), record.expected)
+		})
+	}
+}
+
+func testHeaderHardeningErrorEncoder(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		decorated = HeaderHardening{ErrorEncoder: JSONErrorEncoder}.Then(Constant{StatusCode: 299}.NewHandler())
+
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("X-Null", "\x00")
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+}
+
+func TestHeaderHardening(t *testing.T) {
+	t.Run("Allowed", testHeaderHardeningAllowed)
+	t.Run("Rejected", testHeaderHardeningRejected)
+	t.Run("ErrorEncoder", testHeaderHardeningErrorEncoder)
+}
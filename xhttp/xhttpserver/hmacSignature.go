@@ -0,0 +1,236 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultSignatureHeader is the default HTTP header that carries a request's HMAC signature
+	DefaultSignatureHeader = "X-Signature"
+
+	// DefaultSignatureMaxBodyBytes is the default limit on the size of a request body that is buffered
+	// in order to verify its signature
+	DefaultSignatureMaxBodyBytes = 1024 * 1024
+)
+
+// SignatureEncoding decodes the textual form of a signature, as sent in a request header
+type SignatureEncoding func(string) ([]byte, error)
+
+// HexSignature is a SignatureEncoding for hexadecimal signatures, in either case
+func HexSignature(v string) ([]byte, error) {
+	return hex.DecodeString(v)
+}
+
+// Base64Signature is a SignatureEncoding for standard, padded base64 signatures
+func Base64Signature(v string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// ParseSignatureAlgorithm returns the hash for an HMAC algorithm name, which is one of sha1, sha256, or sha512.
+// Names are case-insensitive.  This function is useful when an HMACSignature is configured externally.
+func ParseSignatureAlgorithm(name string) (func() hash.Hash, error) {
+	switch strings.ToLower(name) {
+	case "sha1":
+		return sha1.New, nil
+
+	case "sha256":
+		return sha256.New, nil
+
+	case "sha512":
+		return sha512.New, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported signature algorithm: %s", name)
+	}
+}
+
+// ParseSignatureEncoding returns the SignatureEncoding for an encoding name, which is either hex or base64.
+// Names are case-insensitive.  This function is useful when an HMACSignature is configured externally.
+func ParseSignatureEncoding(name string) (SignatureEncoding, error) {
+	switch strings.ToLower(name) {
+	case "hex":
+		return HexSignature, nil
+
+	case "base64":
+		return Base64Signature, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported signature encoding: %s", name)
+	}
+}
+
+// HMACSignature is an Alice-style decorator that verifies an HMAC signature computed over each request's raw body
+// with a shared secret, as is done by webhook senders such as GitHub.  Requests without a valid signature are
+// rejected before the decorated handler executes.  Signatures are compared in constant time.
+//
+// Verifying the signature requires the entire body, so the body is buffered up to MaxBodyBytes.  The decorated
+// handler receives the buffered body and can read it as usual.  A body that cannot be read is rejected:  if the
+// read error is an HTTPError, e.g. a BodyReadTimeoutError or an IOLimitError, the response has that error's status
+// code, while any other error is treated as an invalid signature.
+type HMACSignature struct {
+	// Secrets are the shared secrets.  A signature computed with any of them is accepted, which allows secrets
+	// to be rotated without downtime.  If empty, no decoration is done.
+	Secrets [][]byte
+
+	// Header is the HTTP header that carries the signature.  If unset, DefaultSignatureHeader is used.
+	Header string
+
+	// Prefix is the optional text that precedes the encoded signature in the header, e.g. sha256=.  Signatures
+	// without this prefix are rejected.
+	Prefix string
+
+	// Hash is the hash used to compute the HMAC.  If unset, sha256.New is used.  See ParseSignatureAlgorithm.
+	Hash func() hash.Hash
+
+	// Encoding decodes the signature in the header.  If unset, HexSignature is used.  See ParseSignatureEncoding.
+	Encoding SignatureEncoding
+
+	// MaxBodyBytes is the largest body that is verified.  If nonpositive, DefaultSignatureMaxBodyBytes is used.
+	MaxBodyBytes int64
+
+	// OnInvalid is the optional handler for requests with a missing or invalid signature, including requests whose
+	// bodies could not be read.  If unset, a 401 is returned.
+	OnInvalid http.Handler
+
+	// OnTooLarge is the optional handler for requests whose body exceeds MaxBodyBytes, or whose body could not be
+	// read because of a 413 error such as an IOLimitError.  If unset, a 413 is returned.
+	OnTooLarge http.Handler
+}
+
+func (hs HMACSignature) Then(next http.Handler) http.Handler {
+	if len(hs.Secrets) == 0 {
+		return next
+	}
+
+	var (
+		secrets = append([][]byte{}, hs.Secrets...)
+		header  = hs.Header
+		newHash = hs.Hash
+		decode  = hs.Encoding
+		max     = hs.MaxBodyBytes
+
+		onInvalid  = hs.OnInvalid
+		onTooLarge = hs.OnTooLarge
+	)
+
+	if len(header) == 0 {
+		header = DefaultSignatureHeader
+	}
+
+	if newHash == nil {
+		newHash = sha256.New
+	}
+
+	if decode == nil {
+		decode = HexSignature
+	}
+
+	if max <= 0 {
+		max = DefaultSignatureMaxBodyBytes
+	}
+
+	if onInvalid == nil {
+		onInvalid = Constant{StatusCode: http.StatusUnauthorized}.NewHandler()
+	}
+
+	if onTooLarge == nil {
+		onTooLarge = Constant{StatusCode: http.StatusRequestEntityTooLarge}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		value := request.Header.Get(header)
+		if len(value) == 0 || !strings.HasPrefix(value, hs.Prefix) {
+			MarkRejected(request.Context(), "hmacSignature")
+			onInvalid.ServeHTTP(response, request)
+			return
+		}
+
+		signature, err := decode(value[len(hs.Prefix):])
+		if err != nil {
+			MarkRejected(request.Context(), "hmacSignature")
+			onInvalid.ServeHTTP(response, request)
+			return
+		}
+
+		if request.ContentLength > max {
+			MarkRejected(request.Context(), "hmacSignature")
+			onTooLarge.ServeHTTP(response, request)
+			return
+		}
+
+		var body []byte
+		if request.Body != nil && request.Body != http.NoBody {
+			// read one more byte than allowed, so that bodies of unknown length that exceed the limit are detected
+			body, err = ioutil.ReadAll(io.LimitReader(request.Body, max+1))
+			if err != nil {
+				MarkRejected(request.Context(), "hmacSignature")
+				onReadError(err, onInvalid, onTooLarge).ServeHTTP(response, request)
+				return
+			} else if int64(len(body)) > max {
+				MarkRejected(request.Context(), "hmacSignature")
+				onTooLarge.ServeHTTP(response, request)
+				return
+			}
+		}
+
+		// check every secret, so that timing does not reveal which secret matched
+		valid := false
+		for _, secret := range secrets {
+			mac := hmac.New(newHash, secret)
+			mac.Write(body)
+			if hmac.Equal(signature, mac.Sum(nil)) {
+				valid = true
+			}
+		}
+
+		if !valid {
+			MarkRejected(request.Context(), "hmacSignature")
+			onInvalid.ServeHTTP(response, request)
+			return
+		}
+
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		request.ContentLength = int64(len(body))
+		next.ServeHTTP(response, request)
+	})
+}
+
+// onReadError selects the handler for a request whose body could not be read
+func onReadError(err error, onInvalid, onTooLarge http.Handler) http.Handler {
+	var (
+		httpErr     HTTPError
+		maxBytesErr *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return onTooLarge
+
+	case errors.As(err, &httpErr):
+		if httpErr.StatusCode() == http.StatusRequestEntityTooLarge {
+			return onTooLarge
+		}
+
+		return Constant{StatusCode: httpErr.StatusCode()}.NewHandler()
+
+	default:
+		return onInvalid
+	}
+}
+
+func (hs HMACSignature) ThenFunc(next http.HandlerFunc) http.Handler {
+	return hs.Then(next)
+}
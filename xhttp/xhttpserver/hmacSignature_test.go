@@ -0,0 +1,263 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(h func() hash.Hash, secret, body string) []byte {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	testData := []struct {
+		name     string
+		expected func() hash.Hash
+	}{
+		{"sha1", sha1.New},
+		{"SHA256", sha256.New},
+		{"sha512", sha512.New},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			h, err := ParseSignatureAlgorithm(record.name)
+			require.NoError(err)
+			require.NotNil(h)
+			assert.Equal(record.expected().Size(), h().Size())
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		assert := assert.New(t)
+		h, err := ParseSignatureAlgorithm("md5")
+		assert.Nil(h)
+		assert.Error(err)
+	})
+}
+
+func TestParseSignatureEncoding(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	e, err := ParseSignatureEncoding("HEX")
+	require.NoError(err)
+	decoded, err := e("0aff")
+	assert.NoError(err)
+	assert.Equal([]byte{0x0a, 0xff}, decoded)
+
+	e, err = ParseSignatureEncoding("base64")
+	require.NoError(err)
+	decoded, err = e("Cv8=")
+	assert.NoError(err)
+	assert.Equal([]byte{0x0a, 0xff}, decoded)
+
+	e, err = ParseSignatureEncoding("base32")
+	assert.Nil(e)
+	assert.Error(err)
+}
+
+func testHMACSignatureNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = Constant{StatusCode: 299}.NewHandler()
+	)
+
+	assert.Equal(next, HMACSignature{}.Then(next))
+}
+
+func testHMACSignatureDefaults(t *testing.T) {
+	const body = "webhook payload"
+
+	var (
+		hs = HMACSignature{
+			Secrets: [][]byte{[]byte("old"), []byte("new")},
+		}
+
+		decorated = hs.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			b, err := ioutil.ReadAll(request.Body)
+			if assert.NoError(t, err) {
+				assert.Equal(t, body, string(b))
+			}
+
+			assert.Equal(t, int64(len(body)), request.ContentLength)
+			response.WriteHeader(299)
+		})
+	)
+
+	testData := []struct {
+		description        string
+		signature          string
+		expectedStatusCode int
+	}{
+		{"New", hex.EncodeToString(sign(sha256.New, "new", body)), 299},
+		{"Old", hex.EncodeToString(sign(sha256.New, "old", body)), 299},
+		{"Uppercase", strings.ToUpper(hex.EncodeToString(sign(sha256.New, "new", body))), 299},
+		{"WrongSecret", hex.EncodeToString(sign(sha256.New, "wrong", body)), http.StatusUnauthorized},
+		{"WrongAlgorithm", hex.EncodeToString(sign(sha1.New, "new", body)), http.StatusUnauthorized},
+		{"WrongBody", hex.EncodeToString(sign(sha256.New, "new", "other")), http.StatusUnauthorized},
+		{"NotHex", "this is not hex", http.StatusUnauthorized},
+		{"Missing", "", http.StatusUnauthorized},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+			)
+
+			if len(record.signature) > 0 {
+				request.Header.Set(DefaultSignatureHeader, record.signature)
+			}
+
+			decorated.ServeHTTP(response, request)
+			assert.Equal(record.expectedStatusCode, response.Code)
+		})
+	}
+}
+
+func testHMACSignatureCustom(t *testing.T) {
+	const body = "webhook payload"
+
+	var (
+		assert = assert.New(t)
+
+		decorated = HMACSignature{
+			Secrets:   [][]byte{[]byte("secret")},
+			Header:    "X-Hub-Signature-512",
+			Prefix:    "sha512=",
+			Hash:      sha512.New,
+			Encoding:  Base64Signature,
+			OnInvalid: Constant{StatusCode: 499}.NewHandler(),
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		signature = base64.StdEncoding.EncodeToString(sign(sha512.New, "secret", body))
+	)
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	request.Header.Set("X-Hub-Signature-512", "sha512="+signature)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	request.Header.Set("X-Hub-Signature-512", signature)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(499, response.Code)
+}
+
+func testHMACSignatureTooLarge(t *testing.T, contentLength int64, onTooLarge http.Handler, expectedStatusCode int) {
+	const body = "this body is too large"
+
+	var (
+		assert = assert.New(t)
+
+		decorated = HMACSignature{
+			Secrets:      [][]byte{[]byte("secret")},
+			MaxBodyBytes: 5,
+			OnTooLarge:   onTooLarge,
+		}.ThenFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	)
+
+	request.ContentLength = contentLength
+	request.Header.Set(DefaultSignatureHeader, hex.EncodeToString(sign(sha256.New, "secret", body)))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(expectedStatusCode, response.Code)
+}
+
+func testHMACSignatureReadError(t *testing.T, readErr error, expectedStatusCode int) {
+	var (
+		assert = assert.New(t)
+
+		decorated = HMACSignature{
+			Secrets:    [][]byte{[]byte("secret")},
+			OnInvalid:  Constant{StatusCode: 598}.NewHandler(),
+			OnTooLarge: Constant{StatusCode: 599}.NewHandler(),
+		}.ThenFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		ctx      = context.WithValue(context.Background(), rejectionContextKey{}, new(rejection))
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/hook", iotest.ErrReader(readErr)).WithContext(ctx)
+	)
+
+	request.Header.Set(DefaultSignatureHeader, hex.EncodeToString(sign(sha256.New, "secret", "")))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(expectedStatusCode, response.Code)
+
+	by, ok := RejectedByFromContext(request.Context())
+	assert.True(ok)
+	assert.Equal("hmacSignature", by)
+}
+
+func TestHMACSignature(t *testing.T) {
+	t.Run("NoDecoration", testHMACSignatureNoDecoration)
+	t.Run("Defaults", testHMACSignatureDefaults)
+	t.Run("Custom", testHMACSignatureCustom)
+	t.Run("ContentLength", func(t *testing.T) {
+		testHMACSignatureTooLarge(t, 22, nil, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("UnknownLength", func(t *testing.T) {
+		testHMACSignatureTooLarge(t, -1, nil, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("CustomTooLarge", func(t *testing.T) {
+		testHMACSignatureTooLarge(t, -1, Constant{StatusCode: 599}.NewHandler(), 599)
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		t.Run("Invalid", func(t *testing.T) {
+			testHMACSignatureReadError(t, errors.New("expected"), 598)
+		})
+
+		t.Run("Timeout", func(t *testing.T) {
+			testHMACSignatureReadError(t, BodyReadTimeoutError{Timeout: time.Second}, http.StatusRequestTimeout)
+		})
+
+		t.Run("IOLimit", func(t *testing.T) {
+			testHMACSignatureReadError(t, IOLimitError{Max: 10}, 599)
+		})
+
+		t.Run("MaxBytes", func(t *testing.T) {
+			testHMACSignatureReadError(t, &http.MaxBytesError{Limit: 10}, 599)
+		})
+	})
+}
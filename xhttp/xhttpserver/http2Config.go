@@ -0,0 +1,33 @@
+package xhttpserver
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// newHTTP2Server creates the explicit HTTP/2 configuration for a server's options.  Unset options are left
+// as zero values, which select golang.org/x/net/http2's defaults.
+func newHTTP2Server(o Options) *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams:         uint32(o.HTTP2MaxConcurrentStreams),
+		MaxReadFrameSize:             uint32(o.HTTP2MaxReadFrameSize),
+		MaxUploadBufferPerConnection: int32(o.HTTP2MaxUploadBufferPerConnection),
+		MaxUploadBufferPerStream:     int32(o.HTTP2MaxUploadBufferPerStream),
+		IdleTimeout:                  o.HTTP2IdleTimeout,
+	}
+}
+
+// configureHTTP2 applies any HTTP/2 options to a server using golang.org/x/net/http2, which replaces net/http's
+// implicit HTTP/2 support for that server.  Nothing is done if no HTTP/2 options are set, or if the server has
+// its own TLSNextProto, since net/http does not serve HTTP/2 in that case either.
+//
+// This function must be called before the server's TLSConfig is set, as http2.ConfigureServer can only fail
+// when validating an existing TLSConfig.
+func configureHTTP2(s *http.Server, o Options) error {
+	if !o.http2Configured() || s.TLSNextProto != nil {
+		return nil
+	}
+
+	return http2.ConfigureServer(s, newHTTP2Server(o))
+}
@@ -0,0 +1,121 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTP2Server(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		s = newHTTP2Server(Options{
+			HTTP2MaxConcurrentStreams:         17,
+			HTTP2IdleTimeout:                  time.Minute,
+			HTTP2MaxReadFrameSize:             64 << 10,
+			HTTP2MaxUploadBufferPerConnection: 2 << 20,
+			HTTP2MaxUploadBufferPerStream:     512 << 10,
+		})
+	)
+
+	assert.Equal(uint32(17), s.MaxConcurrentStreams)
+	assert.Equal(time.Minute, s.IdleTimeout)
+	assert.Equal(uint32(64<<10), s.MaxReadFrameSize)
+	assert.Equal(int32(2<<20), s.MaxUploadBufferPerConnection)
+	assert.Equal(int32(512<<10), s.MaxUploadBufferPerStream)
+}
+
+func testConfigureHTTP2Unset(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		s = New(Options{}, log.NewNopLogger(), http.NotFoundHandler())
+	)
+
+	require.IsType((*http.Server)(nil), s)
+	assert.Nil(s.(*http.Server).TLSNextProto)
+}
+
+func testConfigureHTTP2Configured(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		s = New(Options{HTTP2MaxConcurrentStreams: 17}, log.NewNopLogger(), http.NotFoundHandler())
+	)
+
+	require.IsType((*http.Server)(nil), s)
+	assert.Contains(s.(*http.Server).TLSNextProto, ProtocolHTTP2)
+}
+
+func testConfigureHTTP2TLSNextProto(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tlsNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){
+			"custom": func(*http.Server, *tls.Conn, http.Handler) {},
+		}
+
+		s = New(
+			Options{
+				HTTP2MaxConcurrentStreams: 17,
+				TLSNextProto:              tlsNextProto,
+			},
+			log.NewNopLogger(),
+			http.NotFoundHandler(),
+		)
+	)
+
+	require.IsType((*http.Server)(nil), s)
+	assert.Len(s.(*http.Server).TLSNextProto, 1)
+	assert.NotContains(s.(*http.Server).TLSNextProto, ProtocolHTTP2)
+	assert.NotContains(tlsNextProto, ProtocolHTTP2)
+}
+
+func testConfigureHTTP2Serve(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handler = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		server = httptest.NewUnstartedServer(handler)
+	)
+
+	server.Config = New(
+		Options{
+			HTTP2MaxConcurrentStreams: 17,
+			HTTP2IdleTimeout:          time.Minute,
+		},
+		log.NewNopLogger(),
+		handler,
+	).(*http.Server)
+
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL)
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(299, response.StatusCode)
+	assert.Equal(2, response.ProtoMajor)
+}
+
+func TestConfigureHTTP2(t *testing.T) {
+	t.Run("Unset", testConfigureHTTP2Unset)
+	t.Run("Configured", testConfigureHTTP2Configured)
+	t.Run("TLSNextProto", testConfigureHTTP2TLSNextProto)
+	t.Run("Serve", testConfigureHTTP2Serve)
+}
@@ -0,0 +1,24 @@
+package xhttpserver
+
+// http2Configured tests whether any options require an explicit HTTP/2 configuration
+func (o Options) http2Configured() bool {
+	return o.HTTP2MaxConcurrentStreams > 0 ||
+		o.HTTP2MaxReadFrameSize > 0 ||
+		o.HTTP2MaxUploadBufferPerConnection > 0 ||
+		o.HTTP2MaxUploadBufferPerStream > 0 ||
+		o.HTTP2IdleTimeout > 0
+}
+
+// validHTTP2Buffers tests whether each configured HTTP/2 frame and buffer size is in the range HTTP/2 allows.
+// golang.org/x/net/http2 silently replaces invalid sizes with its defaults, which would hide configuration mistakes.
+func (o Options) validHTTP2Buffers() bool {
+	// unset, i.e. zero, values are always valid and select the defaults
+	inRange := func(v, min, max int) bool {
+		return v == 0 || (v >= min && v <= max)
+	}
+
+	// flow control windows may be at most 2^31-1 bytes, and a connection's window starts at 65535 bytes
+	return inRange(o.HTTP2MaxReadFrameSize, 16<<10, 16<<20-1) &&
+		inRange(o.HTTP2MaxUploadBufferPerConnection, 64<<10-1, 1<<31-1) &&
+		inRange(o.HTTP2MaxUploadBufferPerStream, 1, 1<<31-1)
+}
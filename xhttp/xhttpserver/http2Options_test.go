@@ -0,0 +1,46 @@
+package xhttpserver
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsValidHTTP2Buffers(t *testing.T) {
+	testData := []struct {
+		options  Options
+		expected bool
+	}{
+		{Options{}, true},
+		{Options{HTTP2MaxReadFrameSize: 16 << 10}, true},
+		{Options{HTTP2MaxReadFrameSize: 16<<20 - 1}, true},
+		{Options{HTTP2MaxReadFrameSize: 16 << 20}, false},
+		{Options{HTTP2MaxReadFrameSize: 1024}, false},
+		{Options{HTTP2MaxReadFrameSize: 32 << 20}, false},
+		{Options{HTTP2MaxUploadBufferPerConnection: 64<<10 - 1}, true},
+		{Options{HTTP2MaxUploadBufferPerConnection: 1024}, false},
+		{Options{HTTP2MaxUploadBufferPerConnection: 4 << 20}, true},
+		{Options{HTTP2MaxUploadBufferPerConnection: 1<<31 - 1}, true},
+		{Options{HTTP2MaxUploadBufferPerStream: 1024}, true},
+		{Options{HTTP2MaxUploadBufferPerStream: 4 << 20}, true},
+		{Options{HTTP2MaxUploadBufferPerStream: -1}, false},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.New(t).Equal(record.expected, record.options.validHTTP2Buffers())
+		})
+	}
+}
+
+func TestOptionsHTTP2Configured(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(Options{}.http2Configured())
+	assert.True(Options{HTTP2MaxConcurrentStreams: 100}.http2Configured())
+	assert.True(Options{HTTP2MaxReadFrameSize: 16 << 10}.http2Configured())
+	assert.True(Options{HTTP2MaxUploadBufferPerConnection: 64 << 10}.http2Configured())
+	assert.True(Options{HTTP2MaxUploadBufferPerStream: 1024}.http2Configured())
+	assert.True(Options{HTTP2IdleTimeout: time.Minute}.http2Configured())
+}
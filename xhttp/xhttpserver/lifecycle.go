@@ -3,31 +3,71 @@ package xhttpserver
 import (
 	"context"
 	"net"
+	"net/http"
 
 	"github.com/xmidt-org/themis/xlog"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"go.uber.org/fx"
 )
 
-// OnStart produces a closure that will start the given server appropriately
+// OnStart produces a closure that will start the given server appropriately.  The onExit closure, if supplied,
+// is invoked whenever the server's Serve method returns, including after a graceful shutdown.
 func OnStart(o Options, s Interface, logger log.Logger, onExit func()) func(context.Context) error {
+	var exit func(error)
+	if onExit != nil {
+		exit = func(error) { onExit() }
+	}
+
+	return onStart("", o, s, logger, exit, nil)
+}
+
+// shutdownOnError produces an onStart exit closure that shuts down the enclosing fx.App when a server's
+// Serve method returns anything other than http.ErrServerClosed.  Without this, the application would
+// continue running, and passing health checks, with a dead listener.
+func shutdownOnError(shutdowner fx.Shutdowner) func(error) {
+	return func(err error) {
+		if err != http.ErrServerClosed {
+			shutdowner.Shutdown()
+		}
+	}
+}
+
+// onStart is the internal implementation of OnStart.  If a TlsPolicy is supplied and the server uses TLS,
+// the initial TLS configuration is installed in that policy and the listener delegates each handshake to it.
+// The server name, if supplied, is reported in any BindError.  The onExit closure, if supplied, receives the
+// error returned by Serve after that error has been logged.
+func onStart(name string, o Options, s Interface, logger log.Logger, onExit func(error), policy *TlsPolicy) func(context.Context) error {
 	return func(ctx context.Context) error {
 		tcfg, err := NewTlsConfig(o.Tls)
 		if err != nil {
 			return err
 		}
 
-		l, err := NewListener(ctx, o, net.ListenConfig{}, tcfg)
-		if err != nil {
+		if o.Tls != nil && o.Tls.ValidateOnStart {
+			if err := ValidateTlsConfig(tcfg); err != nil {
+				return err
+			}
+		}
+
+		appendNextProtos(tcfg, o.TLSNextProto)
+		if tcfg != nil && policy != nil {
+			policy.Set(tcfg)
+			tcfg = policy.Config()
+		}
+
+		tcfg = logSlowHandshakes(tcfg, o.SlowHandshakeThreshold, logger, o.Clock)
+
+		l, err := newListenerWithRetry(ctx, o, net.ListenConfig{}, tcfg, logger)
+		if be, ok := err.(BindError); ok {
+			be.Server = name
+			return be
+		} else if err != nil {
 			return err
 		}
 
 		go func() {
-			if onExit != nil {
-				defer onExit()
-			}
-
 			address := l.Addr().String()
 			logger.Log(
 				level.Key(), level.InfoValue(),
@@ -36,12 +76,24 @@ func OnStart(o Options, s Interface, logger log.Logger, onExit func()) func(cont
 			)
 
 			err := s.Serve(l)
-			logger.Log(
-				level.Key(), level.ErrorValue(),
-				AddressKey(), address,
-				xlog.MessageKey(), "listener exited",
-				xlog.ErrorKey(), err,
-			)
+			if err == http.ErrServerClosed {
+				logger.Log(
+					level.Key(), level.InfoValue(),
+					AddressKey(), address,
+					xlog.MessageKey(), "server closed",
+				)
+			} else {
+				logger.Log(
+					level.Key(), level.ErrorValue(),
+					AddressKey(), address,
+					xlog.MessageKey(), "listener exited",
+					xlog.ErrorKey(), err,
+				)
+			}
+
+			if onExit != nil {
+				onExit(err)
+			}
 		}()
 
 		return nil
@@ -50,12 +102,17 @@ func OnStart(o Options, s Interface, logger log.Logger, onExit func()) func(cont
 
 // OnStop produces a closure that will shutdown the server appropriately
 func OnStop(s Interface, logger log.Logger) func(context.Context) error {
+	return onStop(Options{}, s, logger)
+}
+
+// onStop is the internal implementation of OnStop, which applies the ShutdownTimeout and ShutdownDeadline options
+func onStop(o Options, s Interface, logger log.Logger) func(context.Context) error {
 	return func(ctx context.Context) error {
 		logger.Log(
 			level.Key(), level.InfoValue(),
 			xlog.MessageKey(), "server stopping",
 		)
 
-		return s.Shutdown(ctx)
+		return Shutdown(ctx, s, logger, o.ShutdownTimeout, o.ShutdownDeadline)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
 )
 
 func testOnStartNewListenerError(t *testing.T) {
@@ -44,6 +46,80 @@ func testOnStartNewListenerError(t *testing.T) {
 	s.AssertExpectations(t)
 }
 
+func testOnStartValidateTls(t *testing.T) {
+	certificateFile, keyFile := createServerFiles(t)
+	defer os.Remove(certificateFile)
+	defer os.Remove(keyFile)
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+
+		s       = new(mockServer)
+		onStart = OnStart(
+			Options{
+				Address: ":0",
+				Tls: &Tls{
+					CertificateFile: certificateFile,
+					KeyFile:         keyFile,
+					ValidateOnStart: true,
+				},
+			},
+			s,
+			log.NewJSONLogger(&output),
+			func() {
+				assert.Fail("onExit should not have been called")
+			},
+		)
+	)
+
+	require.NotNil(onStart)
+
+	// the prebaked certificate has expired, so startup must fail before listening
+	err := onStart(context.Background())
+	assert.IsType(TlsValidationError{}, err)
+	assert.Zero(output.Len())
+	s.AssertExpectations(t)
+}
+
+func testOnStartAddressInUse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		s = new(mockServer)
+	)
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer existing.Close()
+
+	start := onStart(
+		"main",
+		Options{Address: existing.Addr().String()},
+		s,
+		xlogtest.New(t),
+		func(error) {
+			assert.Fail("onExit should not have been called")
+		},
+		nil,
+	)
+
+	err = start(context.Background())
+	require.Error(err)
+
+	var be BindError
+	require.True(errors.As(err, &be))
+	assert.Equal("main", be.Server)
+	assert.True(be.AddressInUse())
+	assert.Contains(err.Error(), "main")
+	assert.Contains(err.Error(), existing.Addr().String())
+	assert.Contains(err.Error(), "already in use")
+	s.AssertExpectations(t)
+}
+
 func testOnStartSuccess(t *testing.T) {
 	var (
 		assert  = assert.New(t)
@@ -86,9 +162,80 @@ func testOnStartSuccess(t *testing.T) {
 	s.AssertExpectations(t)
 }
 
+func testOnStartServeError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output      bytes.Buffer
+		expectedErr = errors.New("expected")
+		exitErr     = make(chan error, 1)
+		s           = new(mockServer)
+		start       = onStart(
+			"main",
+			Options{},
+			s,
+			log.NewJSONLogger(&output),
+			func(err error) {
+				exitErr <- err
+			},
+			nil,
+		)
+	)
+
+	s.ExpectServe(mock.MatchedBy(func(net.Listener) bool { return true })).Once().Return(expectedErr).
+		Run(func(arguments mock.Arguments) {
+			arguments.Get(0).(net.Listener).Close()
+		})
+
+	require.NoError(start(context.Background()))
+	select {
+	case err := <-exitErr:
+		assert.Equal(expectedErr, err)
+	case <-time.After(time.Second):
+		assert.Fail("onExit was not called")
+	}
+
+	// the error must be logged before onExit is invoked
+	assert.Contains(output.String(), "listener exited")
+	assert.Contains(output.String(), "expected")
+	s.AssertExpectations(t)
+}
+
 func TestOnStart(t *testing.T) {
 	t.Run("NewListenerError", testOnStartNewListenerError)
+	t.Run("ValidateTls", testOnStartValidateTls)
+	t.Run("AddressInUse", testOnStartAddressInUse)
 	t.Run("Success", testOnStartSuccess)
+	t.Run("ServeError", testOnStartServeError)
+}
+
+// testShutdowner is an fx.Shutdowner that counts calls to Shutdown
+type testShutdowner struct {
+	calls int
+}
+
+func (ts *testShutdowner) Shutdown(...fx.ShutdownOption) error {
+	ts.calls++
+	return nil
+}
+
+func TestShutdownOnError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		shutdowner = new(testShutdowner)
+		onExit     = shutdownOnError(shutdowner)
+	)
+
+	onExit(http.ErrServerClosed)
+	assert.Zero(shutdowner.calls)
+
+	onExit(errors.New("expected"))
+	assert.Equal(1, shutdowner.calls)
+
+	onExit(nil)
+	assert.Equal(2, shutdowner.calls)
 }
 
 func TestOnStop(t *testing.T) {
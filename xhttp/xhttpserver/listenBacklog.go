@@ -0,0 +1,35 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package xhttpserver
+
+import (
+	"net"
+	"syscall"
+)
+
+// setListenBacklog changes the accept backlog of a bound socket.  The net package always passes its own backlog
+// to listen(2), and a ListenConfig.Control function runs before that call, so the only way to change the backlog is
+// to invoke listen(2) again on the already listening socket.  Unix systems permit this and apply the new backlog.
+func setListenBacklog(l net.Listener, backlog int) error {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return ErrListenBacklogNotSupported
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var listenErr error
+	err = rc.Control(func(fd uintptr) {
+		listenErr = syscall.Listen(int(fd), backlog)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return listenErr
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package xhttpserver
+
+import "net"
+
+// setListenBacklog is not supported on this platform
+func setListenBacklog(net.Listener, int) error {
+	return ErrListenBacklogNotSupported
+}
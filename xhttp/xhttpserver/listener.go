@@ -3,13 +3,26 @@ package xhttpserver
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/go-kit/kit/log"
 )
 
 const (
 	defaultTCPKeepAlivePeriod time.Duration = 3 * time.Minute // the value used internally by net/http
+
+	// defaultAcceptInterval is how often a responsive Accept checks whether its listener has been closed
+	defaultAcceptInterval time.Duration = 500 * time.Millisecond
+)
+
+var (
+	ErrListenBacklogNotSupported = errors.New("Setting the listen backlog is not supported for this listener or platform")
 )
 
 // Releasable is implemented by connections returned by Listener that can be marked as freed without closing
@@ -29,23 +42,124 @@ type TlsConn interface {
 	VerifyHostname(string) error
 }
 
+// keepAliveConn is the behavior of connections that support TCP keep-alives, e.g. *net.TCPConn
+type keepAliveConn interface {
+	SetKeepAlive(bool) error
+	SetKeepAlivePeriod(time.Duration) error
+}
+
+// deadlineListener is the behavior of listeners, e.g. *net.TCPListener, whose Accept can time out
+type deadlineListener interface {
+	SetDeadline(time.Time) error
+}
+
+// noDelayConn is the behavior of connections whose use of Nagle's algorithm can be changed, e.g. *net.TCPConn
+type noDelayConn interface {
+	SetNoDelay(bool) error
+}
+
 // Listener is a configurable net.Listener that provides the following features via options
 type Listener struct {
-	tcpListener        *net.TCPListener
+	listener           net.Listener
+	readBufferSize     int
+	writeBufferSize    int
 	tcpKeepAlivePeriod time.Duration
+	tcpKeepAliveJitter float64
+	tcpNoDelay         *bool
+	random             func() float64
 	tlsConfig          *tls.Config
+	handshakes         *handshakeLimiter
+
+	// proxyTrusted are the networks from which PROXY protocol headers are honored.  If empty, headers are not
+	// processed at all.  Rejected headers are logged to logger, which is never nil.
+	proxyTrusted []*net.IPNet
+	logger       log.Logger
+
+	// acceptInterval is the deadline applied to each wait in Accept.  If nonpositive, Accept blocks indefinitely.
+	acceptInterval time.Duration
+	closed         int32
+}
+
+// keepAlivePeriod computes the TCP keep-alive period for a newly accepted connection.  If jitter is
+// configured, the period is randomly chosen from [period*(1-jitter), period*(1+jitter)).
+func (l *Listener) keepAlivePeriod() time.Duration {
+	if l.tcpKeepAliveJitter <= 0 {
+		return l.tcpKeepAlivePeriod
+	}
+
+	factor := 1.0 + l.tcpKeepAliveJitter*(2.0*l.random()-1.0)
+	return time.Duration(float64(l.tcpKeepAlivePeriod) * factor)
 }
 
 func (l *Listener) Accept() (net.Conn, error) {
-	conn, err := l.tcpListener.AcceptTCP()
+	if l.handshakes != nil {
+		l.handshakes.start(l.accept)
+		return l.handshakes.accept()
+	}
+
+	conn, err := l.accept()
 	if err != nil {
 		return nil, err
 	}
 
-	if l.tcpKeepAlivePeriod > 0 {
-		err := conn.SetKeepAlive(true)
+	if l.tlsConfig != nil {
+		return tls.Server(conn, l.tlsConfig), nil
+	}
+
+	return conn, nil
+}
+
+// acceptConn obtains the next connection from the underlying listener.  If responsive accepts are configured and
+// the underlying listener supports deadlines, waiting is done in intervals so that closing this listener is noticed
+// promptly even on platforms where closing a socket does not interrupt a pending accept.
+func (l *Listener) acceptConn() (net.Conn, error) {
+	dl, ok := l.listener.(deadlineListener)
+	if !ok || l.acceptInterval <= 0 {
+		return l.listener.Accept()
+	}
+
+	for atomic.LoadInt32(&l.closed) == 0 {
+		if err := dl.SetDeadline(time.Now().Add(l.acceptInterval)); err != nil {
+			return nil, err
+		}
+
+		conn, err := l.listener.Accept()
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			continue
+		}
+
+		return conn, err
+	}
+
+	// once closed, the underlying listener returns its own error immediately
+	return l.listener.Accept()
+}
+
+// accept obtains the next connection from the underlying listener and applies any keep-alive settings
+func (l *Listener) accept() (net.Conn, error) {
+	conn, err := l.acceptConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.setBufferSizes(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// the net package sets TCP_NODELAY on each accepted connection, so it cannot be configured on the listening socket
+	if ndc, ok := conn.(noDelayConn); ok && l.tcpNoDelay != nil {
+		if err := ndc.SetNoDelay(*l.tcpNoDelay); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	// connections from custom listeners need not support keep-alives
+	if kac, ok := conn.(keepAliveConn); ok && l.tcpKeepAlivePeriod > 0 {
+		err := kac.SetKeepAlive(true)
 		if err == nil {
-			err = conn.SetKeepAlivePeriod(l.tcpKeepAlivePeriod)
+			err = kac.SetKeepAlivePeriod(l.keepAlivePeriod())
 		}
 
 		if err != nil {
@@ -54,44 +168,277 @@ func (l *Listener) Accept() (net.Conn, error) {
 		}
 	}
 
-	if l.tlsConfig != nil {
-		return tls.Server(conn, l.tlsConfig), nil
+	// the PROXY header precedes any TLS handshake, so this must be the innermost decoration
+	if len(l.proxyTrusted) > 0 {
+		conn = &proxyConn{Conn: conn, trusted: l.proxyTrusted, logger: l.logger}
 	}
 
 	return conn, nil
 }
 
+// bufferedConn is implemented by connections, such as *net.TCPConn, whose socket buffers can be sized
+type bufferedConn interface {
+	SetReadBuffer(int) error
+	SetWriteBuffer(int) error
+}
+
+// setBufferSizes applies any configured socket buffer sizes to a newly accepted connection.  Connections from
+// custom listeners need not support this.
+func (l *Listener) setBufferSizes(conn net.Conn) error {
+	bc, ok := conn.(bufferedConn)
+	if !ok {
+		return nil
+	}
+
+	if l.readBufferSize > 0 {
+		if err := bc.SetReadBuffer(l.readBufferSize); err != nil {
+			return err
+		}
+	}
+
+	if l.writeBufferSize > 0 {
+		if err := bc.SetWriteBuffer(l.writeBufferSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (l *Listener) Close() error {
-	return l.tcpListener.Close()
+	atomic.StoreInt32(&l.closed, 1)
+	if l.handshakes != nil {
+		l.handshakes.close()
+	}
+
+	return l.listener.Close()
 }
 
 func (l *Listener) Addr() net.Addr {
-	return l.tcpListener.Addr()
+	return l.listener.Addr()
+}
+
+// composeControl produces a net.ListenConfig.Control function that invokes each non-nil control function in order,
+// stopping at the first error.  If there are no non-nil control functions, this function returns nil.
+func composeControl(controls ...func(string, string, syscall.RawConn) error) func(string, string, syscall.RawConn) error {
+	var composed []func(string, string, syscall.RawConn) error
+	for _, c := range controls {
+		if c != nil {
+			composed = append(composed, c)
+		}
+	}
+
+	switch len(composed) {
+	case 0:
+		return nil
+
+	case 1:
+		return composed[0]
+
+	default:
+		return func(network, address string, rc syscall.RawConn) error {
+			for _, c := range composed {
+				if err := c(network, address, rc); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+}
+
+// BindError is returned by NewListener when a server's address cannot be bound
+type BindError struct {
+	// Server is the name of the server, if known
+	Server string
+
+	Network string
+	Address string
+	Err     error
+
+	// Attempts is the number of times binding was tried, when that is known.  See BindRetry.
+	Attempts int
+}
+
+// AddressInUse tests if this error resulted from another socket already being bound to the address
+func (be BindError) AddressInUse() bool {
+	return errors.Is(be.Err, syscall.EADDRINUSE)
+}
+
+func (be BindError) Error() string {
+	var server, attempts string
+	if len(be.Server) > 0 {
+		server = fmt.Sprintf("Server [%s]: ", be.Server)
+	}
+
+	if be.Attempts > 1 {
+		attempts = fmt.Sprintf(" (gave up after %d attempts)", be.Attempts)
+	}
+
+	if be.AddressInUse() {
+		return fmt.Sprintf(
+			"%saddress [%s] already in use; another process may be bound or a previous instance didn't exit%s",
+			server,
+			be.Address,
+			attempts,
+		)
+	}
+
+	return fmt.Sprintf("%sunable to bind network [%s] and address [%s]: %s%s", server, be.Network, be.Address, be.Err, attempts)
+}
+
+func (be BindError) Unwrap() error {
+	return be.Err
+}
+
+// interfaceAddress produces the address to bind to when a server is configured with a network interface.
+// The host is the first suitable address of the named interface, and the port is taken from address.
+func interfaceAddress(name, network, address string) (string, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid address [%s] for interface [%s]: %s", address, name, err)
+	}
+
+	i, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("Unable to find interface [%s]: %s", name, err)
+	}
+
+	addrs, err := i.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("Unable to obtain addresses for interface [%s]: %s", name, err)
+	}
+
+	var ipv6 *net.IPNet
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		if ipnet.IP.To4() != nil {
+			if network != "tcp6" {
+				return net.JoinHostPort(ipnet.IP.String(), port), nil
+			}
+		} else if ipv6 == nil && network != "tcp4" {
+			ipv6 = ipnet
+		}
+	}
+
+	if ipv6 != nil {
+		host := ipv6.IP.String()
+		if ipv6.IP.IsLinkLocalUnicast() {
+			host += "%" + name
+		}
+
+		return net.JoinHostPort(host, port), nil
+	}
+
+	return "", fmt.Errorf("Interface [%s] has no address suitable for network [%s]", name, network)
 }
 
 // NewListener constructs a net.Listener appropriate for the server configuration.  This function
 // binds to the address specified in the options or an autoselected address if that field is one
 // of the values mentioned at https://godoc.org/net#Listen.
+//
+// If the address cannot be bound, the returned error is a BindError.
+//
+// If Options.Interface is set, the listener binds to that interface's address using the port from Options.Address.
+//
+// If Options.Network is unix, Options.Address is the path of a unix socket, and any configured socket permissions
+// are applied before clients can connect.  Otherwise, the network must produce a TCP listener.
+//
+// If Options.ListenBacklog is positive, the accept backlog of the bound socket is changed to that value.
+//
+// If Options.ControlFunc is set, it is invoked after any Control function set on the supplied net.ListenConfig.
+//
+// If Options.ListenerFactory is set, it is used to create the underlying net.Listener instead of the
+// net.ListenConfig, and any kind of listener is allowed.  TLS and TCP keep-alives are still applied
+// to the accepted connections, where supported.
+//
+// If a tls.Config is supplied and Options.MaxConcurrentHandshakes is positive, TLS handshakes are performed by
+// the listener itself, with at most that many in progress at once.  Connections that wait longer than
+// Options.HandshakeWaitTimeout for a handshake to begin, or whose handshake fails, are closed and never returned
+// from Accept.
+//
+// If Options.ProxyProtocolTrusted is set, connections from those networks may begin with a PROXY protocol header,
+// which supplies the RemoteAddr of the accepted connection.  PROXY headers from any other peer cause the connection
+// to be closed.  Rejected headers are logged to Options.ListenerLogger, if set.
 func NewListener(ctx context.Context, o Options, lcfg net.ListenConfig, tcfg *tls.Config) (*Listener, error) {
+	proxyTrusted, err := ParseNetworks(o.ProxyProtocolTrusted)
+	if err != nil {
+		return nil, err
+	}
+
 	network := o.Network
 	if len(network) == 0 {
 		network = "tcp"
 	}
 
-	l, err := lcfg.Listen(ctx, network, o.Address)
-	if err != nil {
-		return nil, err
+	var (
+		l       net.Listener
+		address = o.Address
+	)
+
+	if len(o.Interface) > 0 {
+		address, err = interfaceAddress(o.Interface, network, address)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	tcpListener, ok := l.(*net.TCPListener)
-	if !ok {
-		l.Close()
-		return nil, fmt.Errorf("Network [%s] and address [%s] does not result in a TCPListener", network, o.Address)
+	if o.ListenerFactory != nil {
+		l, err = o.ListenerFactory(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+	} else if network == "unix" {
+		lcfg.Control = composeControl(lcfg.Control, o.ControlFunc)
+		l, err = listenUnix(ctx, o, lcfg, address)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		lcfg.Control = composeControl(lcfg.Control, o.ControlFunc)
+		l, err = lcfg.Listen(ctx, network, address)
+		if err != nil {
+			return nil, BindError{Network: network, Address: address, Err: err}
+		}
+
+		if _, ok := l.(*net.TCPListener); !ok {
+			l.Close()
+			return nil, fmt.Errorf("Network [%s] and address [%s] does not result in a TCPListener", network, address)
+		}
+
+		if o.ListenBacklog > 0 {
+			if err := setListenBacklog(l, o.ListenBacklog); err != nil {
+				l.Close()
+				return nil, fmt.Errorf("Unable to set listen backlog [%d] for address [%s]: %s", o.ListenBacklog, address, err)
+			}
+		}
 	}
 
 	listener := &Listener{
-		tcpListener: tcpListener,
-		tlsConfig:   tcfg,
+		listener:        l,
+		readBufferSize:  o.ReadBufferSize,
+		writeBufferSize: o.WriteBufferSize,
+		tcpNoDelay:      o.TCPNoDelay,
+		tlsConfig:       tcfg,
+		proxyTrusted:    proxyTrusted,
+		logger:          o.ListenerLogger,
+	}
+
+	if listener.logger == nil {
+		listener.logger = log.NewNopLogger()
+	}
+
+	if o.ResponsiveAccept {
+		listener.acceptInterval = defaultAcceptInterval
+	}
+
+	if tcfg != nil && o.MaxConcurrentHandshakes > 0 {
+		listener.handshakes = newHandshakeLimiter(o, tcfg)
 	}
 
 	if !o.DisableTCPKeepAlives {
@@ -101,6 +448,12 @@ func NewListener(ctx context.Context, o Options, lcfg net.ListenConfig, tcfg *tl
 		}
 
 		listener.tcpKeepAlivePeriod = period
+
+		// jitter must be less than 1 in order to guarantee a positive period
+		if o.TCPKeepAliveJitter > 0 && o.TCPKeepAliveJitter < 1 {
+			listener.tcpKeepAliveJitter = o.TCPKeepAliveJitter
+			listener.random = rand.Float64
+		}
 	}
 
 	return listener, nil
@@ -1,14 +1,23 @@
 package xhttpserver
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,6 +29,36 @@ func testNewListenerInvalidAddress(t *testing.T) {
 	if !assert.Nil(l) {
 		l.Close()
 	}
+
+	be, ok := err.(BindError)
+	if assert.True(ok) {
+		assert.False(be.AddressInUse())
+		assert.Contains(be.Error(), "invalid address")
+	}
+}
+
+func testNewListenerAddressInUse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer existing.Close()
+
+	l, err := NewListener(context.Background(), Options{Address: existing.Addr().String()}, net.ListenConfig{}, nil)
+	require.Error(err)
+	assert.Nil(l)
+
+	be, ok := err.(BindError)
+	require.True(ok)
+	assert.Empty(be.Server)
+	assert.Equal("tcp", be.Network)
+	assert.Equal(existing.Addr().String(), be.Address)
+	assert.True(be.AddressInUse())
+	assert.True(errors.Is(err, syscall.EADDRINUSE))
+	assert.Contains(be.Error(), "already in use")
 }
 
 func testNewListenerNonTLS(t *testing.T) {
@@ -122,8 +161,906 @@ func testNewListenerTLS(t *testing.T) {
 	assert.Equal(expectedMessage, actualMessage)
 }
 
+func testNewListenerControlFunc(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		calls []string
+		lcfg  = net.ListenConfig{
+			Control: func(network, address string, c syscall.RawConn) error {
+				calls = append(calls, "listenConfig")
+				return nil
+			},
+		}
+
+		o = Options{
+			Address: "127.0.0.1:0",
+			ControlFunc: func(network, address string, c syscall.RawConn) error {
+				assert.Equal("tcp4", network)
+				assert.NotNil(c)
+				calls = append(calls, "options")
+				return nil
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, lcfg, nil)
+	require.NoError(err)
+	require.NotNil(l)
+	l.Close()
+
+	assert.Equal([]string{"listenConfig", "options"}, calls)
+}
+
+func testNewListenerControlFuncError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		expectedErr = errors.New("expected control error")
+
+		o = Options{
+			Address: "127.0.0.1:0",
+			ControlFunc: func(string, string, syscall.RawConn) error {
+				return expectedErr
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, net.ListenConfig{}, nil)
+	assert.True(errors.Is(err, expectedErr))
+	if !assert.Nil(l) {
+		l.Close()
+	}
+}
+
+func testNewListenerKeepAliveJitter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", TCPKeepAlivePeriod: 100 * time.Second, TCPKeepAliveJitter: 0.1},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	l.random = func() float64 { return 0.0 }
+	assert.Equal(90*time.Second, l.keepAlivePeriod())
+
+	l.random = func() float64 { return 0.5 }
+	assert.Equal(100*time.Second, l.keepAlivePeriod())
+
+	l.random = func() float64 { return 0.75 }
+	assert.Equal(105*time.Second, l.keepAlivePeriod())
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer c.Close()
+
+	accepted, err := l.Accept()
+	require.NoError(err)
+	accepted.Close()
+}
+
+func testNewListenerNoKeepAliveJitter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	for _, jitter := range []float64{-1.0, 0.0, 1.0, 2.5} {
+		l, err := NewListener(
+			context.Background(),
+			Options{Address: "127.0.0.1:0", TCPKeepAliveJitter: jitter},
+			net.ListenConfig{},
+			nil,
+		)
+
+		require.NoError(err)
+		require.NotNil(l)
+		assert.Equal(defaultTCPKeepAlivePeriod, l.keepAlivePeriod())
+		l.Close()
+	}
+}
+
+// pipeListener is an in-memory net.Listener whose connections are created with net.Pipe
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (pl *pipeListener) Dial() net.Conn {
+	client, server := net.Pipe()
+	pl.conns <- server
+	return client
+}
+
+func (pl *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-pl.conns:
+		return c, nil
+	case <-pl.closed:
+		return nil, errors.New("closed")
+	}
+}
+
+func (pl *pipeListener) Close() error {
+	pl.once.Do(func() { close(pl.closed) })
+	return nil
+}
+
+func (pl *pipeListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: "pipe", Net: "pipe"}
+}
+
+// bufferedPipeConn is a net.Pipe connection that records the socket buffer sizes applied to it
+type bufferedPipeConn struct {
+	net.Conn
+	readBufferSize  int
+	writeBufferSize int
+	err             error
+}
+
+func (bpc *bufferedPipeConn) SetReadBuffer(v int) error {
+	bpc.readBufferSize = v
+	return bpc.err
+}
+
+func (bpc *bufferedPipeConn) SetWriteBuffer(v int) error {
+	bpc.writeBufferSize = v
+	return bpc.err
+}
+
+func testNewListenerBufferSizes(t *testing.T, readBufferSize, writeBufferSize int) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		pipe = newPipeListener()
+		o    = Options{
+			ReadBufferSize:  readBufferSize,
+			WriteBufferSize: writeBufferSize,
+			ListenerFactory: func(context.Context, string, string) (net.Listener, error) {
+				return pipe, nil
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, net.ListenConfig{}, nil)
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	bpc := &bufferedPipeConn{Conn: server}
+	go func() {
+		pipe.conns <- bpc
+	}()
+
+	accepted, err := l.Accept()
+	require.NoError(err)
+	require.NotNil(accepted)
+	assert.Equal(readBufferSize, bpc.readBufferSize)
+	assert.Equal(writeBufferSize, bpc.writeBufferSize)
+	accepted.Close()
+}
+
+func testNewListenerBufferSizesError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedError = errors.New("expected")
+		pipe          = newPipeListener()
+		o             = Options{
+			ReadBufferSize: 1024,
+			ListenerFactory: func(context.Context, string, string) (net.Listener, error) {
+				return pipe, nil
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, net.ListenConfig{}, nil)
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		pipe.conns <- &bufferedPipeConn{Conn: server, err: expectedError}
+	}()
+
+	accepted, err := l.Accept()
+	assert.Equal(expectedError, err)
+	assert.Nil(accepted)
+}
+
+func testNewListenerTCPBufferSizes(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", ReadBufferSize: 65536, WriteBufferSize: 65536},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer client.Close()
+
+	accepted, err := l.Accept()
+	assert.NoError(err)
+	require.NotNil(accepted)
+	accepted.Close()
+}
+
+// noDelayPipeConn is a net.Pipe connection that records the TCP_NODELAY setting applied to it
+type noDelayPipeConn struct {
+	net.Conn
+	noDelay *bool
+	err     error
+}
+
+func (ndpc *noDelayPipeConn) SetNoDelay(v bool) error {
+	ndpc.noDelay = &v
+	return ndpc.err
+}
+
+func testNewListenerTCPNoDelay(t *testing.T, noDelay *bool, connErr error) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		pipe = newPipeListener()
+		o    = Options{
+			TCPNoDelay: noDelay,
+			ListenerFactory: func(context.Context, string, string) (net.Listener, error) {
+				return pipe, nil
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, net.ListenConfig{}, nil)
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	ndpc := &noDelayPipeConn{Conn: server, err: connErr}
+	go func() {
+		pipe.conns <- ndpc
+	}()
+
+	accepted, err := l.Accept()
+	assert.Equal(noDelay, ndpc.noDelay)
+	if connErr != nil {
+		assert.Equal(connErr, err)
+		assert.Nil(accepted)
+		return
+	}
+
+	require.NoError(err)
+	require.NotNil(accepted)
+	accepted.Close()
+}
+
+func testNewListenerTCPNoDelayTCP(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		noDelay = false
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", TCPNoDelay: &noDelay},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer client.Close()
+
+	accepted, err := l.Accept()
+	assert.NoError(err)
+	require.NotNil(accepted)
+	accepted.Close()
+}
+
+func testNewListenerResponsiveAccept(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", ResponsiveAccept: true},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	assert.Equal(defaultAcceptInterval, l.acceptInterval)
+
+	// shorten the interval so that several deadlines expire during this test
+	l.acceptInterval = 10 * time.Millisecond
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			defer client.Close()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	accepted, err := l.Accept()
+	require.NoError(err)
+	require.NotNil(accepted)
+	accepted.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		result <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.Close()
+
+	select {
+	case err := <-result:
+		assert.Error(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("Accept did not return after the listener was closed")
+	}
+}
+
+func testNewListenerListenBacklog(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", ListenBacklog: 16},
+		net.ListenConfig{},
+		nil,
+	)
+
+	if err != nil {
+		assert.Contains(err.Error(), ErrListenBacklogNotSupported.Error())
+		return
+	}
+
+	require.NotNil(l)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer client.Close()
+
+	accepted, err := l.Accept()
+	assert.NoError(err)
+	require.NotNil(accepted)
+	accepted.Close()
+}
+
+func TestSetListenBacklog(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(ErrListenBacklogNotSupported, setListenBacklog(newPipeListener(), 16))
+}
+
+func testNewListenerListenerFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedCtx = context.WithValue(context.Background(), "foo", "bar")
+		pipe        = newPipeListener()
+
+		o = Options{
+			Address:     "test",
+			ControlFunc: func(string, string, syscall.RawConn) error { return errors.New("should not be called") },
+			ListenerFactory: func(ctx context.Context, network, address string) (net.Listener, error) {
+				assert.Equal(expectedCtx, ctx)
+				assert.Equal("tcp", network)
+				assert.Equal("test", address)
+				return pipe, nil
+			},
+		}
+	)
+
+	l, err := NewListener(expectedCtx, o, net.ListenConfig{}, nil)
+	require.NoError(err)
+	require.NotNil(l)
+	assert.Equal(pipe.Addr(), l.Addr())
+
+	go func() {
+		c := pipe.Dial()
+		c.Write([]byte("hello"))
+		c.Close()
+	}()
+
+	accepted, err := l.Accept()
+	require.NoError(err)
+	require.NotNil(accepted)
+
+	message := make([]byte, 5)
+	_, err = io.ReadFull(accepted, message)
+	assert.NoError(err)
+	assert.Equal("hello", string(message))
+	accepted.Close()
+
+	assert.NoError(l.Close())
+	_, err = l.Accept()
+	assert.Error(err)
+}
+
+func testNewListenerListenerFactoryError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+
+		o = Options{
+			ListenerFactory: func(context.Context, string, string) (net.Listener, error) {
+				return nil, expectedError
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, net.ListenConfig{}, nil)
+	assert.Equal(expectedError, err)
+	assert.Nil(l)
+}
+
+// loopbackInterface returns the name of the host's loopback interface, skipping the test if there isn't one
+func loopbackInterface(t *testing.T) string {
+	interfaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, i := range interfaces {
+		if i.Flags&net.FlagLoopback != 0 && i.Flags&net.FlagUp != 0 {
+			return i.Name
+		}
+	}
+
+	t.Skip("No loopback interface is available")
+	return ""
+}
+
+func testNewListenerInterface(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: ":0", Network: "tcp4", Interface: loopbackInterface(t)},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	tcpAddr, ok := l.Addr().(*net.TCPAddr)
+	require.True(ok)
+	assert.True(tcpAddr.IP.IsLoopback())
+	assert.NotZero(tcpAddr.Port)
+}
+
+func testNewListenerInterfaceListenerFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		actualAddress string
+		pl            = newPipeListener()
+
+		o = Options{
+			Address:   ":8080",
+			Network:   "tcp4",
+			Interface: loopbackInterface(t),
+			ListenerFactory: func(_ context.Context, _, address string) (net.Listener, error) {
+				actualAddress = address
+				return pl, nil
+			},
+		}
+	)
+
+	l, err := NewListener(context.Background(), o, net.ListenConfig{}, nil)
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	assert.Equal("127.0.0.1:8080", actualAddress)
+}
+
+func testNewListenerInterfaceMissing(t *testing.T) {
+	assert := assert.New(t)
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: ":0", Interface: "nosuchinterface"},
+		net.ListenConfig{},
+		nil,
+	)
+
+	assert.Error(err)
+	assert.Nil(l)
+}
+
+func testNewListenerInterfaceInvalidAddress(t *testing.T) {
+	assert := assert.New(t)
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "invalid address", Interface: loopbackInterface(t)},
+		net.ListenConfig{},
+		nil,
+	)
+
+	assert.Error(err)
+	assert.Nil(l)
+}
+
+// testObserver is an xmetrics.Observer that records its observations
+type testObserver struct {
+	lock         sync.Mutex
+	observations []float64
+}
+
+func (to *testObserver) Observe(_ *xmetrics.Labels, v float64) {
+	to.lock.Lock()
+	to.observations = append(to.observations, v)
+	to.lock.Unlock()
+}
+
+func (to *testObserver) Len() int {
+	to.lock.Lock()
+	defer to.lock.Unlock()
+	return len(to.observations)
+}
+
+func testNewListenerMaxConcurrentHandshakes(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tlsConfig = addServerCertificate(t, nil)
+		wait      = new(testObserver)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", MaxConcurrentHandshakes: 1, HandshakeWait: wait},
+		net.ListenConfig{},
+		tlsConfig,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+
+	// a connection that fails its handshake is never returned from Accept
+	garbage, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	garbage.Write([]byte("this is not a TLS client hello"))
+	defer garbage.Close()
+
+	clients := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+			if err == nil {
+				c.Write([]byte("x"))
+				defer c.Close()
+				_, err = c.Read(make([]byte, 1))
+			}
+
+			clients <- err
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		c, err := l.Accept()
+		require.NoError(err)
+		require.IsType((*tls.Conn)(nil), c)
+		assert.True(c.(*tls.Conn).ConnectionState().HandshakeComplete)
+
+		b := make([]byte, 1)
+		_, err = io.ReadFull(c, b)
+		assert.NoError(err)
+		c.Write(b)
+		c.Close()
+	}
+
+	assert.NoError(<-clients)
+	assert.NoError(<-clients)
+	assert.Eventually(func() bool { return wait.Len() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(l.Close())
+	_, err = l.Accept()
+	assert.Error(err)
+}
+
+func testNewListenerHandshakeWaitTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tlsConfig = addServerCertificate(t, nil)
+		clock     = newTestClock()
+		wait      = new(testObserver)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{
+			Address:                 "127.0.0.1:0",
+			MaxConcurrentHandshakes: 1,
+			HandshakeWaitTimeout:    50 * time.Millisecond,
+			HandshakeTimeout:        5 * time.Second,
+			HandshakeWait:           wait,
+			Clock:                   clock,
+		},
+		net.ListenConfig{},
+		tlsConfig,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			c.Close()
+		}
+	}()
+
+	// this connection never sends a client hello, so it holds the only handshake slot
+	stalled, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer stalled.Close()
+	require.Eventually(func() bool { return wait.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	dialed := make(chan error, 1)
+	go func() {
+		c, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			c.Close()
+		}
+
+		dialed <- err
+	}()
+
+	// the wait only expires once the clock passes HandshakeWaitTimeout
+	require.Eventually(func() bool { return clock.Timers() == 1 }, 5*time.Second, 10*time.Millisecond)
+	clock.Add(50 * time.Millisecond)
+	assert.Error(<-dialed)
+	assert.Equal(1, wait.Len())
+}
+
+func testNewListenerMaxPendingHandshakes(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tlsConfig = addServerCertificate(t, nil)
+		wait      = new(testObserver)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{
+			Address:                 "127.0.0.1:0",
+			MaxConcurrentHandshakes: 1,
+			MaxPendingHandshakes:    1,
+			HandshakeWaitTimeout:    -1,
+			HandshakeWait:           wait,
+		},
+		net.ListenConfig{},
+		tlsConfig,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			c.Close()
+		}
+	}()
+
+	// this connection never sends a client hello, so it holds the only handshake slot
+	stalled, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer stalled.Close()
+	require.Eventually(func() bool { return wait.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// this connection waits for the slot, filling the queue
+	pending, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer pending.Close()
+	require.Eventually(func() bool { return len(l.handshakes.pending) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// this connection has nowhere to wait, so it is closed right away
+	rejected, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer rejected.Close()
+
+	rejected.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = rejected.Read(make([]byte, 1))
+	assert.Equal(io.EOF, err)
+	assert.Equal(1, len(l.handshakes.pending))
+	assert.Equal(1, wait.Len())
+}
+
+func testNewListenerHandshakeDefaults(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", MaxConcurrentHandshakes: 1},
+		net.ListenConfig{},
+		addServerCertificate(t, nil),
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	assert.Equal(DefaultHandshakeWaitTimeout, l.handshakes.maxWait)
+	assert.Equal(DefaultHandshakeTimeout, l.handshakes.timeout)
+	assert.Equal(DefaultMaxPendingHandshakes, cap(l.handshakes.pending))
+}
+
 func TestNewListener(t *testing.T) {
 	t.Run("InvalidAddress", testNewListenerInvalidAddress)
+	t.Run("AddressInUse", testNewListenerAddressInUse)
 	t.Run("NonTLS", testNewListenerNonTLS)
 	t.Run("TLS", testNewListenerTLS)
+	t.Run("ControlFunc", testNewListenerControlFunc)
+	t.Run("ControlFuncError", testNewListenerControlFuncError)
+	t.Run("KeepAliveJitter", testNewListenerKeepAliveJitter)
+	t.Run("NoKeepAliveJitter", testNewListenerNoKeepAliveJitter)
+	t.Run("ListenBacklog", testNewListenerListenBacklog)
+	t.Run("ResponsiveAccept", testNewListenerResponsiveAccept)
+	t.Run("ListenerFactory", testNewListenerListenerFactory)
+	t.Run("BufferSizes", func(t *testing.T) { testNewListenerBufferSizes(t, 1024, 2048) })
+	t.Run("NoBufferSizes", func(t *testing.T) { testNewListenerBufferSizes(t, 0, 0) })
+	t.Run("BufferSizesError", testNewListenerBufferSizesError)
+	t.Run("TCPBufferSizes", testNewListenerTCPBufferSizes)
+	t.Run("TCPNoDelay", func(t *testing.T) {
+		for _, v := range []bool{true, false} {
+			v := v
+			testNewListenerTCPNoDelay(t, &v, nil)
+		}
+	})
+
+	t.Run("NoTCPNoDelay", func(t *testing.T) { testNewListenerTCPNoDelay(t, nil, nil) })
+	t.Run("TCPNoDelayError", func(t *testing.T) {
+		noDelay := false
+		testNewListenerTCPNoDelay(t, &noDelay, errors.New("expected"))
+	})
+
+	t.Run("TCPNoDelayTCP", testNewListenerTCPNoDelayTCP)
+	t.Run("ListenerFactoryError", testNewListenerListenerFactoryError)
+	t.Run("Interface", testNewListenerInterface)
+	t.Run("InterfaceListenerFactory", testNewListenerInterfaceListenerFactory)
+	t.Run("InterfaceMissing", testNewListenerInterfaceMissing)
+	t.Run("InterfaceInvalidAddress", testNewListenerInterfaceInvalidAddress)
+	t.Run("MaxConcurrentHandshakes", testNewListenerMaxConcurrentHandshakes)
+	t.Run("HandshakeWaitTimeout", testNewListenerHandshakeWaitTimeout)
+	t.Run("MaxPendingHandshakes", testNewListenerMaxPendingHandshakes)
+	t.Run("HandshakeDefaults", testNewListenerHandshakeDefaults)
+}
+
+// benchmarkSocketBufferSizes measures requests with the given body size served through a listener with the
+// given socket buffer sizes
+func benchmarkSocketBufferSizes(b *testing.B, bufferSize, bodySize int) {
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", ReadBufferSize: bufferSize, WriteBufferSize: bufferSize},
+		net.ListenConfig{},
+		nil,
+	)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	s := New(
+		Options{},
+		log.NewNopLogger(),
+		http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			io.Copy(ioutil.Discard, request.Body)
+			response.Write([]byte("ok"))
+		}),
+	)
+
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	var (
+		body   = bytes.Repeat([]byte("a"), bodySize)
+		url    = "http://" + l.Addr().String()
+		client = &http.Client{Transport: new(http.Transport)}
+	)
+
+	b.SetBytes(int64(bodySize))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		response, err := client.Post(url, "application/octet-stream", bytes.NewReader(body))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+}
+
+// BenchmarkSocketBufferSizes compares ReadBufferSize and WriteBufferSize settings for small and large requests.
+// A buffer size of 0 uses the operating system defaults.  Results depend heavily on the operating system and the
+// network, so this should be run on hardware representative of production before changing either option.
+func BenchmarkSocketBufferSizes(b *testing.B) {
+	for _, bodySize := range []int{128, 1 << 20} {
+		for _, bufferSize := range []int{0, 16384, 65536, 1 << 20} {
+			b.Run(fmt.Sprintf("body=%d/buffer=%d", bodySize, bufferSize), func(b *testing.B) {
+				benchmarkSocketBufferSizes(b, bufferSize, bodySize)
+			})
+		}
+	}
 }
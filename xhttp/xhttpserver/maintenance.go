@@ -0,0 +1,182 @@
+package xhttpserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"go.uber.org/fx"
+)
+
+const (
+	toggledByKey = "toggledBy"
+)
+
+// ToggledByKey is the logging key for whoever enabled or disabled maintenance mode
+func ToggledByKey() interface{} {
+	return toggledByKey
+}
+
+// Maintenance is a concurrency-safe switch that controls whether servers configured with MaintenanceMode
+// reject requests.  Unlike a Gate, this switch is meant to be toggled by operators at runtime, e.g. via
+// a MaintenanceHandler.  Each change is logged.
+type Maintenance struct {
+	enabled int32
+	logger  log.Logger
+}
+
+// NewMaintenance creates a Maintenance switch, initially disabled, that logs changes to the given logger.
+// If the logger is nil, changes are not logged.
+func NewMaintenance(logger log.Logger) *Maintenance {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	return &Maintenance{
+		logger: logger,
+	}
+}
+
+// MaintenanceIn defines the dependencies for ProvideMaintenance
+type MaintenanceIn struct {
+	fx.In
+
+	Logger log.Logger
+}
+
+// ProvideMaintenance is an uber/fx provider that creates a disabled Maintenance switch
+func ProvideMaintenance(in MaintenanceIn) *Maintenance {
+	return NewMaintenance(in.Logger)
+}
+
+// IsEnabled tests if maintenance mode is enabled
+func (m *Maintenance) IsEnabled() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+// Set enables or disables maintenance mode.  The by parameter describes who made the change, e.g. a user
+// name or address, and may be empty.  Only actual changes are logged.
+func (m *Maintenance) Set(enabled bool, by string) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+
+	if atomic.SwapInt32(&m.enabled, value) != value {
+		message := "maintenance mode disabled"
+		if enabled {
+			message = "maintenance mode enabled"
+		}
+
+		m.logger.Log(
+			level.Key(), level.InfoValue(),
+			xlog.MessageKey(), message,
+			ToggledByKey(), by,
+		)
+	}
+}
+
+// MaintenanceHandler is an http.Handler that reports and toggles a Maintenance switch.  GET requests receive
+// the current state as JSON, e.g. {"enabled": true}.  PUT and POST requests set the state from the enabled query
+// parameter, e.g. ?enabled=true, and receive the new state.  The change is attributed to the client address
+// along with any basic auth user name.
+//
+// Since this handler changes how every server configured with MaintenanceMode behaves, it should only be exposed
+// on an administrative server or behind authorization.
+type MaintenanceHandler struct {
+	Maintenance *Maintenance
+}
+
+// toggledBy describes who made a change via the given request
+func toggledBy(request *http.Request) string {
+	if user, _, ok := request.BasicAuth(); ok && len(user) > 0 {
+		return user + "@" + request.RemoteAddr
+	}
+
+	return request.RemoteAddr
+}
+
+func (mh MaintenanceHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+
+	case http.MethodPut, http.MethodPost:
+		enabled, err := strconv.ParseBool(request.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(response, "The enabled parameter must be a boolean", http.StatusBadRequest)
+			return
+		}
+
+		mh.Maintenance.Set(enabled, toggledBy(request))
+
+	default:
+		response.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(response, `{"enabled": %t}`, mh.Maintenance.IsEnabled())
+}
+
+// MaintenanceMode is an Alice-style decorator that rejects requests while a Maintenance switch is enabled.  This
+// allows planned maintenance to be announced to clients without a redeploy.
+type MaintenanceMode struct {
+	// Maintenance is the switch that controls this decorator.  If unset, no decoration is done.
+	Maintenance *Maintenance
+
+	// RetryAfter is the optional interval sent in the Retry-After header of rejected requests.  If unset,
+	// no Retry-After header is sent.
+	RetryAfter time.Duration
+
+	// Exempt is the optional set of URI paths, e.g. health endpoints, that are never rejected
+	Exempt []string
+
+	// Trusted are the optional networks, e.g. those of administrators, from which requests are never rejected
+	Trusted []*net.IPNet
+
+	// OnMaintenance is the optional handler for rejected requests, e.g. a friendly page.  If unset, a 503
+	// is returned.  In either case, any Retry-After header is set prior to invoking this handler.
+	OnMaintenance http.Handler
+}
+
+func (mm MaintenanceMode) Then(next http.Handler) http.Handler {
+	if mm.Maintenance == nil {
+		return next
+	}
+
+	var (
+		retryAfter = retryAfterValue(mm.RetryAfter)
+		exempt     = newExemptPaths(mm.Exempt)
+	)
+
+	onMaintenance := mm.OnMaintenance
+	if onMaintenance == nil {
+		onMaintenance = Constant{StatusCode: http.StatusServiceUnavailable}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !mm.Maintenance.IsEnabled() || exempt.contains(request) || trustedAddress(mm.Trusted, request.RemoteAddr) {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		if len(retryAfter) > 0 {
+			response.Header().Set("Retry-After", retryAfter)
+		}
+
+		MarkRejected(request.Context(), "maintenance")
+		onMaintenance.ServeHTTP(response, request)
+	})
+}
+
+func (mm MaintenanceMode) ThenFunc(next http.HandlerFunc) http.Handler {
+	return mm.Then(next)
+}
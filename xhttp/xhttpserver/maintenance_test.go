@@ -0,0 +1,211 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output bytes.Buffer
+		m      = NewMaintenance(log.NewJSONLogger(&output))
+	)
+
+	assert.False(m.IsEnabled())
+
+	m.Set(true, "admin")
+	assert.True(m.IsEnabled())
+	assert.Contains(output.String(), "maintenance mode enabled")
+	assert.Contains(output.String(), `"toggledBy":"admin"`)
+
+	// unchanged state is not logged
+	output.Reset()
+	m.Set(true, "admin")
+	assert.True(m.IsEnabled())
+	assert.Zero(output.Len())
+
+	m.Set(false, "admin")
+	assert.False(m.IsEnabled())
+	assert.Contains(output.String(), "maintenance mode disabled")
+
+	// a nil logger is permitted
+	m = NewMaintenance(nil)
+	m.Set(true, "")
+	assert.True(m.IsEnabled())
+}
+
+func TestProvideMaintenance(t *testing.T) {
+	m := ProvideMaintenance(MaintenanceIn{Logger: log.NewNopLogger()})
+	require.NotNil(t, m)
+	assert.False(t, m.IsEnabled())
+}
+
+func testMaintenanceHandlerGet(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		m        = NewMaintenance(nil)
+		handler  = MaintenanceHandler{Maintenance: m}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/maintenance", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+	assert.JSONEq(`{"enabled": false}`, response.Body.String())
+}
+
+func testMaintenanceHandlerSet(t *testing.T, method string) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		m        = NewMaintenance(log.NewJSONLogger(&output))
+		handler  = MaintenanceHandler{Maintenance: m}
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest(method, "/maintenance?enabled=true", nil)
+	)
+
+	request.SetBasicAuth("joe", "password")
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(`{"enabled": true}`, response.Body.String())
+	assert.True(m.IsEnabled())
+	assert.Contains(output.String(), "joe@"+request.RemoteAddr)
+
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest(method, "/maintenance?enabled=false", nil)
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(`{"enabled": false}`, response.Body.String())
+	assert.False(m.IsEnabled())
+	assert.Contains(output.String(), `"toggledBy":"`+request.RemoteAddr+`"`)
+}
+
+func testMaintenanceHandlerBadRequest(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		m        = NewMaintenance(nil)
+		handler  = MaintenanceHandler{Maintenance: m}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("PUT", "/maintenance?enabled=maybe", nil))
+	assert.Equal(http.StatusBadRequest, response.Code)
+	assert.False(m.IsEnabled())
+}
+
+func testMaintenanceHandlerMethodNotAllowed(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		handler  = MaintenanceHandler{Maintenance: NewMaintenance(nil)}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("DELETE", "/maintenance", nil))
+	assert.Equal(http.StatusMethodNotAllowed, response.Code)
+	assert.Equal("GET, HEAD, POST, PUT", response.HeaderMap.Get("Allow"))
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	t.Run("Get", testMaintenanceHandlerGet)
+	t.Run("Put", func(t *testing.T) { testMaintenanceHandlerSet(t, "PUT") })
+	t.Run("Post", func(t *testing.T) { testMaintenanceHandlerSet(t, "POST") })
+	t.Run("BadRequest", testMaintenanceHandlerBadRequest)
+	t.Run("MethodNotAllowed", testMaintenanceHandlerMethodNotAllowed)
+}
+
+func testMaintenanceModeNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = MaintenanceMode{Exempt: []string{"/health"}}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testMaintenanceModeDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		_, trusted, _ = net.ParseCIDR("10.0.0.0/8")
+
+		m         = NewMaintenance(nil)
+		decorated = MaintenanceMode{
+			Maintenance: m,
+			Exempt:      []string{"/health"},
+			Trusted:     []*net.IPNet{trusted},
+		}.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		serve = func(path, remoteAddr string) *httptest.ResponseRecorder {
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", path, nil)
+			request.RemoteAddr = remoteAddr
+			decorated.ServeHTTP(response, request)
+			return response
+		}
+	)
+
+	require.NotNil(decorated)
+	assert.Equal(299, serve("/test", "192.168.1.1:1234").Code)
+
+	m.Set(true, "")
+	response := serve("/test", "192.168.1.1:1234")
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Empty(response.HeaderMap.Get("Retry-After"))
+
+	assert.Equal(299, serve("/health", "192.168.1.1:1234").Code)
+	assert.Equal(299, serve("/test", "10.1.2.3:1234").Code)
+
+	m.Set(false, "")
+	assert.Equal(299, serve("/test", "192.168.1.1:1234").Code)
+}
+
+func testMaintenanceModeCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		m         = NewMaintenance(nil)
+		decorated = MaintenanceMode{
+			Maintenance: m,
+			RetryAfter:  1500 * time.Millisecond,
+			OnMaintenance: Constant{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       []byte("back soon"),
+			}.NewHandler(),
+		}.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	m.Set(true, "")
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal("2", response.HeaderMap.Get("Retry-After"))
+	assert.Equal("back soon", response.Body.String())
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Run("NoDecoration", testMaintenanceModeNoDecoration)
+	t.Run("Default", testMaintenanceModeDefault)
+	t.Run("Custom", testMaintenanceModeCustom)
+}
@@ -0,0 +1,140 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	routeKey = "route"
+)
+
+// RouteKey is the logging key for the route template that matched a request
+func RouteKey() interface{} {
+	return routeKey
+}
+
+type routeContextKey struct{}
+
+// WithRoute returns a new context with the given route template
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeContextKey{}, route)
+}
+
+// RouteFromContext returns the route template that matched the current request.  If no route is
+// present, this function returns the empty string.
+func RouteFromContext(ctx context.Context) string {
+	r, _ := ctx.Value(routeContextKey{}).(string)
+	return r
+}
+
+// MethodMux is a minimal http.Handler that dispatches on the exact request path and method.  It is useful
+// for simple servers that do not need a full router.  A request for an unregistered path results in a 404,
+// while a request for a registered path with an unregistered method results in a 405 with an Allow header.
+// HEAD requests are dispatched to the GET handler when no HEAD handler is registered.
+//
+// The matched path is stored in the request context, and is available via RouteFromContext.  If the request
+// has a contextual logger, e.g. from the Logging decorator, that logger is enriched with the route.  Likewise,
+// a span started by Trace is given the route as an attribute.
+//
+// All handlers must be registered prior to serving requests.
+type MethodMux struct {
+	// NotFound is the optional handler for requests to unregistered paths.  If unset, http.NotFoundHandler is used.
+	NotFound http.Handler
+
+	// ErrorEncoder is the optional strategy for rendering 405 responses, as well as 404 responses when
+	// NotFound is unset.
+	ErrorEncoder ErrorEncoder
+
+	routes map[string]*methodRoute
+}
+
+type methodRoute struct {
+	handlers map[string]http.Handler
+	allow    string
+}
+
+// Handle registers a handler for the given method and exact path
+func (mm *MethodMux) Handle(method, path string, h http.Handler) *MethodMux {
+	if mm.routes == nil {
+		mm.routes = make(map[string]*methodRoute)
+	}
+
+	mr, ok := mm.routes[path]
+	if !ok {
+		mr = &methodRoute{handlers: make(map[string]http.Handler)}
+		mm.routes[path] = mr
+	}
+
+	mr.handlers[strings.ToUpper(method)] = h
+
+	var allow []string
+	for m := range mr.handlers {
+		allow = append(allow, m)
+	}
+
+	if _, ok := mr.handlers[http.MethodGet]; ok {
+		if _, ok := mr.handlers[http.MethodHead]; !ok {
+			allow = append(allow, http.MethodHead)
+		}
+	}
+
+	sort.Strings(allow)
+	mr.allow = strings.Join(allow, ", ")
+	return mm
+}
+
+// HandleFunc registers a handler function for the given method and exact path
+func (mm *MethodMux) HandleFunc(method, path string, f func(http.ResponseWriter, *http.Request)) *MethodMux {
+	return mm.Handle(method, path, http.HandlerFunc(f))
+}
+
+func (mm *MethodMux) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	mr, ok := mm.routes[request.URL.Path]
+	if !ok {
+		switch {
+		case mm.NotFound != nil:
+			mm.NotFound.ServeHTTP(response, request)
+
+		case mm.ErrorEncoder != nil:
+			mm.ErrorEncoder(response, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+
+		default:
+			http.NotFound(response, request)
+		}
+
+		return
+	}
+
+	h, ok := mr.handlers[request.Method]
+	if !ok && request.Method == http.MethodHead {
+		h, ok = mr.handlers[http.MethodGet]
+	}
+
+	if !ok {
+		response.Header().Set("Allow", mr.allow)
+		if mm.ErrorEncoder != nil {
+			mm.ErrorEncoder(response, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		} else {
+			response.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+		return
+	}
+
+	ctx := WithRoute(request.Context(), request.URL.Path)
+	if logger := xlog.GetDefault(ctx, nil); logger != nil {
+		ctx = xlog.With(ctx, log.With(logger, RouteKey(), request.URL.Path))
+	}
+
+	// this is a no-op if the request is not being traced
+	trace.SpanFromContext(ctx).SetAttributes(RouteAttribute.String(request.URL.Path))
+	h.ServeHTTP(response, request.WithContext(ctx))
+}
@@ -0,0 +1,123 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteFromContext(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(RouteFromContext(context.Background()))
+	assert.Equal("/test", RouteFromContext(WithRoute(context.Background(), "/test")))
+}
+
+func testMethodMuxDispatch(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		mm = new(MethodMux).
+			HandleFunc("get", "/test", func(response http.ResponseWriter, request *http.Request) {
+				assert.Equal("/test", RouteFromContext(request.Context()))
+				response.WriteHeader(291)
+			}).
+			HandleFunc("POST", "/test", func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(292)
+			})
+	)
+
+	response := httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(291, response.Code)
+
+	response = httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("HEAD", "/test", nil))
+	assert.Equal(291, response.Code)
+
+	response = httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("POST", "/test", nil))
+	assert.Equal(292, response.Code)
+
+	response = httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("DELETE", "/test", nil))
+	assert.Equal(http.StatusMethodNotAllowed, response.Code)
+	assert.Equal("GET, HEAD, POST", response.Header().Get("Allow"))
+
+	response = httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("GET", "/nosuch", nil))
+	assert.Equal(http.StatusNotFound, response.Code)
+}
+
+func testMethodMuxCustomNotFound(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		mm = MethodMux{
+			NotFound: Constant{StatusCode: 499}.NewHandler(),
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	mm.ServeHTTP(response, httptest.NewRequest("GET", "/nosuch", nil))
+	assert.Equal(499, response.Code)
+}
+
+func testMethodMuxErrorEncoder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		mm = MethodMux{ErrorEncoder: JSONErrorEncoder}
+	)
+
+	mm.Handle("GET", "/test", Constant{}.NewHandler())
+
+	response := httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("GET", "/nosuch", nil))
+	assert.Equal(http.StatusNotFound, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	response = httptest.NewRecorder()
+	mm.ServeHTTP(response, httptest.NewRequest("PUT", "/test", nil))
+	assert.Equal(http.StatusMethodNotAllowed, response.Code)
+	assert.Equal("GET, HEAD", response.Header().Get("Allow"))
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+}
+
+func testMethodMuxLogging(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		mm     = new(MethodMux).
+			HandleFunc("GET", "/test", func(response http.ResponseWriter, request *http.Request) {
+				xlog.Get(request.Context()).Log("msg", "test")
+				response.WriteHeader(299)
+			})
+
+		handler  = xloghttp.Logging{Base: log.NewJSONLogger(&output)}.Then(mm)
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(handler)
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Contains(output.String(), `"route":"/test"`)
+}
+
+func TestMethodMux(t *testing.T) {
+	t.Run("Dispatch", testMethodMuxDispatch)
+	t.Run("CustomNotFound", testMethodMuxCustomNotFound)
+	t.Run("ErrorEncoder", testMethodMuxErrorEncoder)
+	t.Run("Logging", testMethodMuxLogging)
+}
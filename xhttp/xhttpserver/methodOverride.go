@@ -0,0 +1,91 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// DefaultMethodOverrideHeader is the request header that carries the intended method when none is configured
+	DefaultMethodOverrideHeader = "X-HTTP-Method-Override"
+
+	originalMethodKey = "originalMethod"
+	methodKey         = "method"
+)
+
+// DefaultMethodOverrideMethods returns the methods that a POST may be overridden to when none are configured
+func DefaultMethodOverrideMethods() []string {
+	return []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+}
+
+// OriginalMethodKey is the logging key for the method a request was actually sent with
+func OriginalMethodKey() interface{} {
+	return originalMethodKey
+}
+
+// MethodKey is the logging key for the method a request is handled as
+func MethodKey() interface{} {
+	return methodKey
+}
+
+// MethodOverride is an Alice-style decorator that allows POST requests to be handled as some other method.  This
+// exists for clients behind proxies that block methods other than GET and POST.  Such clients send a POST with the
+// intended method in the override header, and the request's Method is rewritten before it reaches the decorated
+// handler, e.g. a router.
+//
+// Only POST requests are rewritten, and only to the allowed Methods.  Any other value of the override header
+// is ignored.  When a request is rewritten and has a contextual logger, an entry records both methods.
+type MethodOverride struct {
+	// Header is the request header that carries the intended method.  If unset, DefaultMethodOverrideHeader is used.
+	Header string
+
+	// Methods are the methods a POST may be overridden to, matched case-insensitively.  If unset,
+	// DefaultMethodOverrideMethods is used.
+	Methods []string
+}
+
+func (mo MethodOverride) Then(next http.Handler) http.Handler {
+	header := mo.Header
+	if len(header) == 0 {
+		header = DefaultMethodOverrideHeader
+	}
+
+	methods := mo.Methods
+	if len(methods) == 0 {
+		methods = DefaultMethodOverrideMethods()
+	}
+
+	allowed := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		allowed[strings.ToUpper(m)] = true
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodPost {
+			if override := strings.ToUpper(strings.TrimSpace(request.Header.Get(header))); allowed[override] {
+				if logger := xlog.GetDefault(request.Context(), nil); logger != nil {
+					logger.Log(
+						level.Key(), level.InfoValue(),
+						xlog.MessageKey(), "method overridden",
+						OriginalMethodKey(), request.Method,
+						MethodKey(), override,
+					)
+				}
+
+				// copy the request, so that the caller's request is not modified
+				request = request.WithContext(request.Context())
+				request.Method = override
+			}
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (mo MethodOverride) ThenFunc(next http.HandlerFunc) http.Handler {
+	return mo.Then(next)
+}
@@ -0,0 +1,89 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultMethodOverrideMethods(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"PUT", "PATCH", "DELETE"}, DefaultMethodOverrideMethods())
+
+	// each call must return a distinct slice, so that callers cannot alter the defaults
+	DefaultMethodOverrideMethods()[0] = "GET"
+	assert.Equal("PUT", DefaultMethodOverrideMethods()[0])
+}
+
+func testMethodOverride(t *testing.T, mo MethodOverride, method, header, value, expectedMethod string, expectLog bool) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		actual string
+
+		decorated = mo.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			actual = request.Method
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest(method, "/test", nil)
+	)
+
+	require.NotNil(decorated)
+	if len(header) > 0 {
+		request.Header.Set(header, value)
+	}
+
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+	assert.Equal(expectedMethod, actual)
+	assert.Equal(method, request.Method, "the original request should not have been modified")
+
+	if expectLog {
+		assert.Contains(output.String(), "method overridden")
+		assert.Contains(output.String(), method)
+		assert.Contains(output.String(), expectedMethod)
+	} else {
+		assert.Zero(output.Len())
+	}
+}
+
+func TestMethodOverride(t *testing.T) {
+	testData := []struct {
+		name           string
+		override       MethodOverride
+		method         string
+		header         string
+		value          string
+		expectedMethod string
+		expectLog      bool
+	}{
+		{"NoHeader", MethodOverride{}, "POST", "", "", "POST", false},
+		{"Put", MethodOverride{}, "POST", DefaultMethodOverrideHeader, "PUT", "PUT", true},
+		{"Patch", MethodOverride{}, "POST", DefaultMethodOverrideHeader, "patch", "PATCH", true},
+		{"Delete", MethodOverride{}, "POST", DefaultMethodOverrideHeader, " DELETE ", "DELETE", true},
+		{"NotAllowed", MethodOverride{}, "POST", DefaultMethodOverrideHeader, "CONNECT", "POST", false},
+		{"NotPost", MethodOverride{}, "GET", DefaultMethodOverrideHeader, "DELETE", "GET", false},
+		{"CustomHeader", MethodOverride{Header: "X-Method"}, "POST", "X-Method", "PUT", "PUT", true},
+		{"CustomHeaderIgnoresDefault", MethodOverride{Header: "X-Method"}, "POST", DefaultMethodOverrideHeader, "PUT", "POST", false},
+		{"CustomMethods", MethodOverride{Methods: []string{"purge"}}, "POST", DefaultMethodOverrideHeader, "PURGE", "PURGE", true},
+		{"CustomMethodsExcludeDefaults", MethodOverride{Methods: []string{"PURGE"}}, "POST", DefaultMethodOverrideHeader, "PUT", "POST", false},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			testMethodOverride(t, record.override, record.method, record.header, record.value, record.expectedMethod, record.expectLog)
+		})
+	}
+}
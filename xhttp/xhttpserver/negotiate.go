@@ -0,0 +1,201 @@
+package xhttpserver
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ResponseEncoder renders values in a particular media type
+type ResponseEncoder struct {
+	// MediaType is the media type produced by this encoder, e.g. application/json
+	MediaType string
+
+	// Encode writes a value to the response.  Implementations are responsible for setting
+	// the Content-Type header.
+	Encode func(http.ResponseWriter, interface{}) error
+}
+
+// NewJSONResponseEncoder creates a ResponseEncoder for application/json
+func NewJSONResponseEncoder() ResponseEncoder {
+	return ResponseEncoder{
+		MediaType: "application/json",
+		Encode: func(response http.ResponseWriter, v interface{}) error {
+			response.Header().Set("Content-Type", "application/json")
+			return json.NewEncoder(response).Encode(v)
+		},
+	}
+}
+
+type responseEncoderContextKey struct{}
+
+// WithResponseEncoder returns a new context with the given ResponseEncoder
+func WithResponseEncoder(ctx context.Context, re ResponseEncoder) context.Context {
+	return context.WithValue(ctx, responseEncoderContextKey{}, re)
+}
+
+// ResponseEncoderFromContext returns the ResponseEncoder chosen by Negotiate.  If no encoder is present
+// in the context, this function returns false.
+func ResponseEncoderFromContext(ctx context.Context) (ResponseEncoder, bool) {
+	re, ok := ctx.Value(responseEncoderContextKey{}).(ResponseEncoder)
+	return re, ok
+}
+
+// mediaRange is a single, parsed element of an Accept header
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// specificity returns how closely this range matches a media type.  Larger values are more specific, and
+// a negative value means the range does not match at all.
+func (mr mediaRange) specificity(mediaType string) int {
+	switch {
+	case mr.mediaType == mediaType:
+		return 2
+
+	case mr.mediaType == "*/*":
+		return 0
+
+	case strings.HasSuffix(mr.mediaType, "/*") && strings.HasPrefix(mediaType, mr.mediaType[:len(mr.mediaType)-1]):
+		return 1
+
+	default:
+		return -1
+	}
+}
+
+// parseAccept parses the value of an Accept header.  Malformed media ranges are skipped, as are
+// quality values outside of the interval [0, 1].
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, element := range strings.Split(accept, ",") {
+		element = strings.TrimSpace(element)
+		if len(element) == 0 {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(element)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	return ranges
+}
+
+// quality determines the quality an Accept header assigns to a media type, using the most specific
+// matching range as required by RFC 7231.  A media type that no range matches has a quality of zero.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	var (
+		best = -1
+		q    float64
+	)
+
+	for _, mr := range ranges {
+		if s := mr.specificity(mediaType); s > best {
+			best = s
+			q = mr.quality
+		}
+	}
+
+	return q
+}
+
+// negotiateHandler is the internal http.Handler implementation that selects a ResponseEncoder for each request
+type negotiateHandler struct {
+	next            http.Handler
+	onNotAcceptable http.Handler
+	encoders        []ResponseEncoder
+}
+
+func (nh *negotiateHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	AddVary(response.Header(), "Accept")
+
+	var (
+		chosen ResponseEncoder
+		found  bool
+	)
+
+	accept := request.Header.Get("Accept")
+	if len(accept) == 0 {
+		chosen, found = nh.encoders[0], true
+	} else {
+		var (
+			ranges = parseAccept(accept)
+			best   float64
+		)
+
+		// ties go to the earliest encoder, which is the server's preference
+		for _, re := range nh.encoders {
+			if q := quality(ranges, re.MediaType); q > best {
+				chosen, found, best = re, true, q
+			}
+		}
+	}
+
+	if !found {
+		MarkRejected(request.Context(), "negotiate")
+		nh.onNotAcceptable.ServeHTTP(response, request)
+		return
+	}
+
+	nh.next.ServeHTTP(
+		response,
+		request.WithContext(WithResponseEncoder(request.Context(), chosen)),
+	)
+}
+
+// Negotiate is an Alice-style decorator that performs content negotiation using the Accept header.  The
+// ResponseEncoder with the highest quality value is stored in the request context, where handlers can obtain
+// it via ResponseEncoderFromContext.  If no encoder is acceptable, an http.StatusNotAcceptable is returned.
+// A request without an Accept header is given the first encoder.
+type Negotiate struct {
+	// Encoders are the available ResponseEncoders, in order of preference.  If empty, no decoration is done.
+	Encoders []ResponseEncoder
+
+	// OnNotAcceptable is the optional handler invoked when no encoder is acceptable.  If unset,
+	// an http.StatusNotAcceptable is returned.
+	OnNotAcceptable http.Handler
+}
+
+func (n Negotiate) Then(next http.Handler) http.Handler {
+	if len(n.Encoders) == 0 {
+		return next
+	}
+
+	nh := &negotiateHandler{
+		next:     next,
+		encoders: make([]ResponseEncoder, len(n.Encoders)),
+	}
+
+	for i, re := range n.Encoders {
+		re.MediaType = strings.ToLower(re.MediaType)
+		nh.encoders[i] = re
+	}
+
+	if n.OnNotAcceptable != nil {
+		nh.onNotAcceptable = n.OnNotAcceptable
+	} else {
+		nh.onNotAcceptable = Constant{StatusCode: http.StatusNotAcceptable}.NewHandler()
+	}
+
+	return nh
+}
+
+func (n Negotiate) ThenFunc(next http.HandlerFunc) http.Handler {
+	return n.Then(next)
+}
@@ -0,0 +1,173 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseEncoderFromContext(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		re     = NewJSONResponseEncoder()
+	)
+
+	_, ok := ResponseEncoderFromContext(context.Background())
+	assert.False(ok)
+
+	actual, ok := ResponseEncoderFromContext(WithResponseEncoder(context.Background(), re))
+	assert.True(ok)
+	assert.Equal(re.MediaType, actual.MediaType)
+}
+
+func TestNewJSONResponseEncoder(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		re       = NewJSONResponseEncoder()
+		response = httptest.NewRecorder()
+	)
+
+	assert.Equal("application/json", re.MediaType)
+	assert.NoError(re.Encode(response, map[string]int{"value": 1}))
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+	assert.JSONEq(`{"value": 1}`, response.Body.String())
+}
+
+func testNegotiateNoEncoders(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			_, ok := ResponseEncoderFromContext(request.Context())
+			assert.False(ok)
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	Negotiate{}.Then(next).ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func testNegotiateAccept(t *testing.T) {
+	testData := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/x-protobuf", "application/x-protobuf"},
+		{"APPLICATION/X-PROTOBUF", "application/x-protobuf"},
+		{"application/json;q=0.5, application/x-protobuf", "application/x-protobuf"},
+		{"application/*;q=0.9, application/json;q=0.1", "application/x-protobuf"},
+		{"*/*;q=0.1, application/json;q=0.2", "application/json"},
+		{"application/x-protobuf;q=0.5, application/json;q=0.5", "application/json"},
+		{"text/html, application/json;q=0.8", "application/json"},
+		{"application/json;q=abc, application/x-protobuf;q=0.1", "application/x-protobuf"},
+		{"bad, application/x-protobuf", "application/x-protobuf"},
+		{"application/json;q=0, */*", "application/x-protobuf"},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					re, ok := ResponseEncoderFromContext(request.Context())
+					require.True(ok)
+					assert.Equal(record.expected, re.MediaType)
+					response.WriteHeader(299)
+				})
+
+				decorated = Negotiate{
+					Encoders: []ResponseEncoder{
+						NewJSONResponseEncoder(),
+						{MediaType: "application/X-Protobuf"},
+					},
+				}.ThenFunc(next)
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", "/", nil)
+			)
+
+			if len(record.accept) > 0 {
+				request.Header.Set("Accept", record.accept)
+			}
+
+			decorated.ServeHTTP(response, request)
+			assert.Equal(299, response.Code)
+			assert.Equal("Accept", response.Header().Get("Vary"))
+		})
+	}
+}
+
+func testNegotiateNotAcceptable(t *testing.T) {
+	testData := []struct {
+		onNotAcceptable http.Handler
+		expected        int
+	}{
+		{nil, http.StatusNotAcceptable},
+		{Constant{StatusCode: 599}.NewHandler(), 599},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					assert.Fail("The next handler should not have been called")
+				})
+
+				decorated = Negotiate{
+					Encoders:        []ResponseEncoder{NewJSONResponseEncoder()},
+					OnNotAcceptable: record.onNotAcceptable,
+				}.Then(next)
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", "/", nil)
+			)
+
+			request.Header.Set("Accept", "text/html, application/json;q=0")
+			decorated.ServeHTTP(response, request)
+			assert.Equal(record.expected, response.Code)
+		})
+	}
+}
+
+func testNegotiateVary(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = Negotiate{
+			Encoders: []ResponseEncoder{NewJSONResponseEncoder()},
+		}.Then(
+			Vary{Headers: []string{"Origin", "accept"}}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(299)
+			}),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	response.Header().Set("Vary", "Accept-Encoding")
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Equal([]string{"Accept-Encoding", "Accept", "Origin"}, response.Header()["Vary"])
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Run("NoEncoders", testNegotiateNoEncoders)
+	t.Run("Accept", testNegotiateAccept)
+	t.Run("NotAcceptable", testNegotiateNotAcceptable)
+	t.Run("Vary", testNegotiateVary)
+}
@@ -0,0 +1,80 @@
+package xhttpserver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseNetworks parses a list of CIDRs, e.g. 10.0.0.0/8, or single IP addresses into networks
+func ParseNetworks(v []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(v))
+	for _, s := range v {
+		if strings.Contains(s, "/") {
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+
+			networks = append(networks, n)
+			continue
+		}
+
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid IP address or CIDR: %s", s)
+		}
+
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	return networks, nil
+}
+
+// trustedAddress tests if a remote address, with or without a port, falls within any of the given networks.
+// Callers pass http.Request.RemoteAddr, which is the immediate peer of the connection rather than any address
+// forwarded by a proxy.
+func trustedAddress(networks []*net.IPNet, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateNetworks checks that every option holding trusted networks parses, naming the first option that
+// does not.  Unmarshal.Provide calls this, so invalid networks are reported rather than silently untrusted.
+func (o Options) validateNetworks() error {
+	for _, option := range []struct {
+		name     string
+		networks []string
+	}{
+		{name: "TrustedProxies", networks: o.TrustedProxies},
+		{name: "ProxyProtocolTrusted", networks: o.ProxyProtocolTrusted},
+		{name: "DebugTrusted", networks: o.DebugTrusted},
+		{name: "MaintenanceTrusted", networks: o.MaintenanceTrusted},
+	} {
+		if _, err := ParseNetworks(option.networks); err != nil {
+			return fmt.Errorf("Invalid %s: %s", option.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package xhttpserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testParseNetworks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	require.NoError(err)
+	require.Len(networks, 3)
+	assert.Equal("10.0.0.0/8", networks[0].String())
+	assert.Equal("192.168.1.1/32", networks[1].String())
+	assert.Equal("::1/128", networks[2].String())
+
+	_, err = ParseNetworks([]string{"10.0.0.0/99"})
+	assert.Error(err)
+
+	_, err = ParseNetworks([]string{"not an address"})
+	assert.Error(err)
+}
+
+func testTrustedAddress(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", "::1"})
+	require.NoError(err)
+
+	assert.True(trustedAddress(networks, "10.1.2.3:1234"))
+	assert.True(trustedAddress(networks, "10.1.2.3"))
+	assert.True(trustedAddress(networks, "[::1]:1234"))
+	assert.False(trustedAddress(networks, "192.168.1.1:1234"))
+	assert.False(trustedAddress(networks, "not an address"))
+	assert.False(trustedAddress(nil, "10.1.2.3:1234"))
+}
+
+func testValidateNetworks(t *testing.T) {
+	assert.NoError(t, Options{}.validateNetworks())
+	assert.NoError(t, Options{
+		TrustedProxies:       []string{"10.0.0.0/8"},
+		ProxyProtocolTrusted: []string{"192.168.1.1"},
+		DebugTrusted:         []string{"::1"},
+		MaintenanceTrusted:   []string{"127.0.0.1"},
+	}.validateNetworks())
+
+	for _, name := range []string{"TrustedProxies", "ProxyProtocolTrusted", "DebugTrusted", "MaintenanceTrusted"} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				o       Options
+				invalid = []string{"10.0.0.0/8", "not an address"}
+			)
+
+			switch name {
+			case "TrustedProxies":
+				o.TrustedProxies = invalid
+			case "ProxyProtocolTrusted":
+				o.ProxyProtocolTrusted = invalid
+			case "DebugTrusted":
+				o.DebugTrusted = invalid
+			case "MaintenanceTrusted":
+				o.MaintenanceTrusted = invalid
+			}
+
+			err := o.validateNetworks()
+			assert.Error(err)
+			assert.Contains(err.Error(), name)
+		})
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	t.Run("ParseNetworks", testParseNetworks)
+	t.Run("TrustedAddress", testTrustedAddress)
+	t.Run("ValidateNetworks", testValidateNetworks)
+}
@@ -0,0 +1,56 @@
+package xhttpserver
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Redacted is the value written in place of sensitive configuration values
+const Redacted = "<redacted>"
+
+// RedactOptions returns a copy of the given Options with sensitive values replaced by Redacted.  The following
+// values are redacted:
+//
+//   - Tls.KeyFile, the location of the server's private key
+//   - Tls.ClientCACertificateFile, the location of the certificates trusted to authenticate clients
+//   - the values of any Header whose name is one of DefaultDebugRedactHeaders, e.g. a static Set-Cookie
+//
+// Secrets that only exist in code, such as HMACSignature.Secrets, are never part of Options.  The original
+// Options, including any Tls options and Header, are not modified.
+func RedactOptions(o Options) Options {
+	if o.Tls != nil {
+		redacted := *o.Tls
+		if len(redacted.KeyFile) > 0 {
+			redacted.KeyFile = Redacted
+		}
+
+		if len(redacted.ClientCACertificateFile) > 0 {
+			redacted.ClientCACertificateFile = Redacted
+		}
+
+		o.Tls = &redacted
+	}
+
+	if len(o.Header) > 0 {
+		o.Header = redactHeader(o.Header, DefaultDebugRedactHeaders())
+	}
+
+	return o
+}
+
+// NewOptionsHandler produces an http.Handler that writes the given server Options as JSON, after
+// applying RedactOptions.  This is useful as an admin endpoint to verify the configuration a server is
+// actually running with, including any overrides from the command line or environment.
+func NewOptionsHandler(o Options) (http.Handler, error) {
+	body, err := json.MarshalIndent(RedactOptions(o), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return Constant{
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+		Body: body,
+	}.NewHandler(), nil
+}
@@ -0,0 +1,115 @@
+package xhttpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactOptions(t *testing.T) {
+	t.Run("NoTls", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Equal(
+			Options{Address: ":8080"},
+			RedactOptions(Options{Address: ":8080"}),
+		)
+	})
+
+	t.Run("Redacted", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			original = Options{
+				Address: ":8080",
+				Header: http.Header{
+					"Set-Cookie":    {"session=secret"},
+					"Authorization": {"Bearer secret"},
+					"X-Custom":      {"value"},
+				},
+				Tls: &Tls{
+					CertificateFile:         "cert.pem",
+					KeyFile:                 "key.pem",
+					ClientCACertificateFile: "ca.pem",
+				},
+			}
+
+			redacted = RedactOptions(original)
+		)
+
+		require.NotNil(redacted.Tls)
+
+		// each of these values is sensitive
+		for name, actual := range map[string]string{
+			"Tls.KeyFile":                 redacted.Tls.KeyFile,
+			"Tls.ClientCACertificateFile": redacted.Tls.ClientCACertificateFile,
+			"Header[Set-Cookie]":          redacted.Header.Get("Set-Cookie"),
+			"Header[Authorization]":       redacted.Header.Get("Authorization"),
+		} {
+			assert.Equal(Redacted, actual, name)
+		}
+
+		assert.Equal(":8080", redacted.Address)
+		assert.Equal("cert.pem", redacted.Tls.CertificateFile)
+		assert.Equal("value", redacted.Header.Get("X-Custom"))
+
+		// the original must be untouched
+		assert.Equal("key.pem", original.Tls.KeyFile)
+		assert.Equal("ca.pem", original.Tls.ClientCACertificateFile)
+		assert.Equal("session=secret", original.Header.Get("Set-Cookie"))
+		assert.Equal("Bearer secret", original.Header.Get("Authorization"))
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			redacted = RedactOptions(Options{Tls: &Tls{CertificateFile: "cert.pem"}})
+		)
+
+		// empty values stay empty, so that it remains clear they were never configured
+		assert.Empty(redacted.Tls.KeyFile)
+		assert.Empty(redacted.Tls.ClientCACertificateFile)
+		assert.Nil(redacted.Header)
+	})
+}
+
+func TestNewOptionsHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handler, err = NewOptionsHandler(Options{
+			Address: ":8080",
+			Tls: &Tls{
+				CertificateFile:         "cert.pem",
+				KeyFile:                 "key.pem",
+				ClientCACertificateFile: "ca.pem",
+			},
+			ControlFunc: func(string, string, syscall.RawConn) error { return nil },
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NoError(err)
+	require.NotNil(handler)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var actual Options
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &actual))
+	assert.Equal(":8080", actual.Address)
+	require.NotNil(actual.Tls)
+	assert.Equal("cert.pem", actual.Tls.CertificateFile)
+	assert.Equal(Redacted, actual.Tls.KeyFile)
+	assert.Equal(Redacted, actual.Tls.ClientCACertificateFile)
+	assert.NotContains(response.Body.String(), "key.pem")
+	assert.NotContains(response.Body.String(), "ca.pem")
+}
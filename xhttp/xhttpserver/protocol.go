@@ -0,0 +1,63 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// ProtocolHTTP2 is the normalized protocol for HTTP/2 over TLS
+	ProtocolHTTP2 = "h2"
+
+	// ProtocolH2C is the normalized protocol for cleartext HTTP/2
+	ProtocolH2C = "h2c"
+
+	// ProtocolHTTP11 is the normalized protocol for HTTP/1.x, with or without TLS
+	ProtocolHTTP11 = "http/1.1"
+)
+
+type protocolContextKey struct{}
+
+// DetectProtocol returns the normalized protocol for a request.  Unlike r.TLS.NegotiatedProtocol,
+// this function also detects cleartext HTTP/2 (h2c).  The return value is always one of ProtocolHTTP2,
+// ProtocolH2C, or ProtocolHTTP11.
+func DetectProtocol(r *http.Request) string {
+	switch {
+	case r.ProtoMajor == 2 && r.TLS != nil:
+		return ProtocolHTTP2
+
+	case r.ProtoMajor == 2:
+		return ProtocolH2C
+
+	case r.TLS != nil && r.TLS.NegotiatedProtocol == ProtocolHTTP2:
+		return ProtocolHTTP2
+
+	default:
+		return ProtocolHTTP11
+	}
+}
+
+// WithProtocol returns a new context with the given normalized protocol
+func WithProtocol(ctx context.Context, protocol string) context.Context {
+	return context.WithValue(ctx, protocolContextKey{}, protocol)
+}
+
+// ProtocolFromContext returns the normalized protocol stored in the context by UseProtocol.  If no protocol
+// is present, this function returns the empty string.
+func ProtocolFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(protocolContextKey{}).(string)
+	return p
+}
+
+// UseProtocol is an Alice-style constructor that stores the normalized protocol of each request
+// in the request context.  Handler code can retrieve it via ProtocolFromContext.
+func UseProtocol(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		next.ServeHTTP(
+			response,
+			request.WithContext(
+				WithProtocol(request.Context(), DetectProtocol(request)),
+			),
+		)
+	})
+}
@@ -0,0 +1,90 @@
+package xhttpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProtocol(t *testing.T) {
+	testData := []struct {
+		protoMajor int
+		tls        *tls.ConnectionState
+		expected   string
+	}{
+		{
+			protoMajor: 1,
+			expected:   ProtocolHTTP11,
+		},
+		{
+			protoMajor: 1,
+			tls:        &tls.ConnectionState{},
+			expected:   ProtocolHTTP11,
+		},
+		{
+			protoMajor: 1,
+			tls:        &tls.ConnectionState{NegotiatedProtocol: "http/1.1"},
+			expected:   ProtocolHTTP11,
+		},
+		{
+			protoMajor: 1,
+			tls:        &tls.ConnectionState{NegotiatedProtocol: "h2"},
+			expected:   ProtocolHTTP2,
+		},
+		{
+			protoMajor: 2,
+			tls:        &tls.ConnectionState{NegotiatedProtocol: "h2"},
+			expected:   ProtocolHTTP2,
+		},
+		{
+			protoMajor: 2,
+			expected:   ProtocolH2C,
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				request = httptest.NewRequest("GET", "/", nil)
+			)
+
+			request.ProtoMajor = record.protoMajor
+			request.TLS = record.tls
+			assert.Equal(record.expected, DetectProtocol(request))
+		})
+	}
+}
+
+func TestProtocolFromContext(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(ProtocolFromContext(context.Background()))
+	assert.Equal(ProtocolH2C, ProtocolFromContext(WithProtocol(context.Background(), ProtocolH2C)))
+}
+
+func TestUseProtocol(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Equal(ProtocolH2C, ProtocolFromContext(request.Context()))
+			response.WriteHeader(299)
+		})
+
+		handler  = UseProtocol(next)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	require.NotNil(handler)
+	request.ProtoMajor = 2
+	handler.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
@@ -0,0 +1,296 @@
+package xhttpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// proxyHeaderTimeout bounds how long a connection may take to send, or to begin sending, its PROXY header
+	proxyHeaderTimeout = 5 * time.Second
+
+	// proxyHeaderMaxLength is the longest PROXY protocol version 1 header, including its CRLF
+	proxyHeaderMaxLength = 107
+
+	// proxyHeaderV2Length is the length of the fixed part of a PROXY protocol version 2 header, which precedes
+	// the addresses and any TLVs
+	proxyHeaderV2Length = 16
+)
+
+var (
+	// proxyHeaderPrefix is how every PROXY protocol version 1 header begins
+	proxyHeaderPrefix = []byte("PROXY ")
+
+	// proxyHeaderV2Signature is how every PROXY protocol version 2 header begins
+	proxyHeaderV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrUntrustedProxyHeader = errors.New("A PROXY header was sent by an untrusted peer")
+)
+
+// InvalidProxyHeaderError indicates that a trusted peer sent a malformed PROXY protocol header
+type InvalidProxyHeaderError struct {
+	Header string
+	Reason string
+}
+
+func (ipe InvalidProxyHeaderError) Error() string {
+	return fmt.Sprintf("Invalid PROXY header [%s]: %s", ipe.Header, ipe.Reason)
+}
+
+// parseProxyHeader parses a PROXY protocol version 1 header, including its trailing CRLF.  The returned address
+// is the original client's address, which is nil when the header uses the UNKNOWN protocol.
+func parseProxyHeader(header string) (net.Addr, error) {
+	line := strings.TrimSuffix(header, "\r\n")
+	if len(line) == len(header) {
+		return nil, InvalidProxyHeaderError{Header: header, Reason: "missing CRLF"}
+	}
+
+	fields := strings.Split(line, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		// the receiver must ignore everything after UNKNOWN and use the real connection's addresses
+		return nil, nil
+	}
+
+	if len(fields) != 6 {
+		return nil, InvalidProxyHeaderError{Header: line, Reason: "wrong number of fields"}
+	}
+
+	ip := net.ParseIP(fields[2])
+	switch {
+	case fields[1] != "TCP4" && fields[1] != "TCP6":
+		return nil, InvalidProxyHeaderError{Header: line, Reason: "unsupported protocol"}
+
+	case ip == nil:
+		return nil, InvalidProxyHeaderError{Header: line, Reason: "invalid source address"}
+
+	case (fields[1] == "TCP4") != (ip.To4() != nil):
+		return nil, InvalidProxyHeaderError{Header: line, Reason: "source address does not match protocol"}
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, InvalidProxyHeaderError{Header: line, Reason: "invalid source port"}
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyHeaderV2 reads a binary PROXY protocol version 2 header, including any TLVs, whose signature has
+// already been peeked from the reader.  The returned address is the original client's address, which is nil when
+// the header is a LOCAL command or carries an address family other than TCP over IPv4 or IPv6.  TLVs are ignored.
+func readProxyHeaderV2(r io.Reader) (net.Addr, error) {
+	var fixed [proxyHeaderV2Length]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, InvalidProxyHeaderError{Header: fmt.Sprintf("%x", fixed[:]), Reason: err.Error()}
+	}
+
+	header := fmt.Sprintf("%x", fixed[:])
+	if fixed[12]>>4 != 2 {
+		return nil, InvalidProxyHeaderError{Header: header, Reason: "unsupported version"}
+	}
+
+	// the addresses and TLVs must always be consumed, even if unused, so that they do not reach the server
+	addresses := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, addresses); err != nil {
+		return nil, InvalidProxyHeaderError{Header: header, Reason: err.Error()}
+	}
+
+	switch command := fixed[12] & 0x0f; {
+	case command == 0x0:
+		// a LOCAL connection, e.g. a health check, originates from the proxy itself
+		return nil, nil
+
+	case command != 0x1:
+		return nil, InvalidProxyHeaderError{Header: header, Reason: "unsupported command"}
+	}
+
+	switch fixed[13] {
+	case 0x11: // TCP over IPv4
+		if len(addresses) < 12 {
+			return nil, InvalidProxyHeaderError{Header: header, Reason: "addresses too short"}
+		}
+
+		return &net.TCPAddr{
+			IP:   net.IPv4(addresses[0], addresses[1], addresses[2], addresses[3]),
+			Port: int(binary.BigEndian.Uint16(addresses[8:])),
+		}, nil
+
+	case 0x21: // TCP over IPv6
+		if len(addresses) < 36 {
+			return nil, InvalidProxyHeaderError{Header: header, Reason: "addresses too short"}
+		}
+
+		return &net.TCPAddr{
+			IP:   net.IP(append([]byte(nil), addresses[:16]...)),
+			Port: int(binary.BigEndian.Uint16(addresses[32:])),
+		}, nil
+
+	default:
+		// the receiver must use the real connection's addresses for unspecified or unsupported families
+		return nil, nil
+	}
+}
+
+// proxyConn is a net.Conn that honors a PROXY protocol header, either the text version 1 or the binary version 2,
+// at the start of the connection, but only when the peer is trusted.  The header is processed lazily, on the first
+// Read or RemoteAddr, so that a slow peer never blocks a Listener's Accept.
+//
+// Since net/http obtains RemoteAddr before reading a request, RemoteAddr waits for the header, up to
+// proxyHeaderTimeout, when it is called before the first Read.  net/http runs ConnState and ConnContext hooks on
+// the goroutine that accepts connections, so hooks must not call RemoteAddr for new connections.  Doing so would
+// stall every accept behind a slow peer.
+//
+// Connections from untrusted peers are treated as direct clients, and any PROXY header they send is rejected
+// by closing the connection.  This prevents clients from forging their apparent address.
+type proxyConn struct {
+	net.Conn
+	trusted []*net.IPNet
+	logger  log.Logger
+
+	once       sync.Once
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	err        error
+
+	// readDeadline is the last read deadline set by the caller, which is restored once the header is read
+	lock         sync.Mutex
+	readDeadline time.Time
+}
+
+// headerDeadline returns the read deadline used while waiting for a PROXY header, which never extends
+// any deadline set by the caller
+func (pc *proxyConn) headerDeadline() time.Time {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	deadline := time.Now().Add(proxyHeaderTimeout)
+	if !pc.readDeadline.IsZero() && pc.readDeadline.Before(deadline) {
+		deadline = pc.readDeadline
+	}
+
+	return deadline
+}
+
+// restoreDeadline reinstates the caller's read deadline
+func (pc *proxyConn) restoreDeadline() {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	pc.Conn.SetReadDeadline(pc.readDeadline)
+}
+
+// init reads any PROXY header exactly once
+func (pc *proxyConn) init() {
+	pc.once.Do(func() {
+		pc.remoteAddr = pc.Conn.RemoteAddr()
+		pc.reader = bufio.NewReaderSize(pc.Conn, proxyHeaderMaxLength)
+
+		pc.Conn.SetReadDeadline(pc.headerDeadline())
+		defer pc.restoreDeadline()
+
+		// a short or failed peek simply means there is no header, and the error will surface again on Read.
+		// Only the first byte is awaited up front, so that short requests from direct clients are never delayed.
+		var signature []byte
+		if first, _ := pc.reader.Peek(1); len(first) > 0 {
+			switch first[0] {
+			case proxyHeaderPrefix[0]:
+				signature = proxyHeaderPrefix
+
+			case proxyHeaderV2Signature[0]:
+				signature = proxyHeaderV2Signature
+			}
+		}
+
+		if prefix, _ := pc.reader.Peek(len(signature)); len(signature) == 0 || !bytes.Equal(prefix, signature) {
+			return
+		}
+
+		if !trustedAddress(pc.trusted, pc.remoteAddr.String()) {
+			pc.reject(ErrUntrustedProxyHeader, "rejected PROXY header from untrusted peer")
+			return
+		}
+
+		var (
+			source net.Addr
+			err    error
+		)
+
+		if len(signature) == len(proxyHeaderV2Signature) {
+			source, err = readProxyHeaderV2(pc.reader)
+		} else {
+			var header []byte
+			header, err = pc.reader.ReadSlice('\n')
+			if err != nil {
+				pc.reject(InvalidProxyHeaderError{Header: string(header), Reason: err.Error()}, "unable to read PROXY header")
+				return
+			}
+
+			source, err = parseProxyHeader(string(header))
+		}
+
+		if err != nil {
+			pc.reject(err, "invalid PROXY header")
+			return
+		}
+
+		if source != nil {
+			pc.remoteAddr = source
+		}
+	})
+}
+
+// reject logs why this connection's PROXY header was refused and closes the connection
+func (pc *proxyConn) reject(err error, message string) {
+	pc.err = err
+	pc.logger.Log(
+		level.Key(), level.WarnValue(),
+		RemoteAddressKey(), pc.remoteAddr.String(),
+		xlog.MessageKey(), message,
+		xlog.ErrorKey(), err,
+	)
+
+	pc.Conn.Close()
+}
+
+func (pc *proxyConn) Read(b []byte) (int, error) {
+	pc.init()
+	if pc.err != nil {
+		return 0, pc.err
+	}
+
+	return pc.reader.Read(b)
+}
+
+// RemoteAddr returns the original client's address from a trusted PROXY header, or the peer's address otherwise.
+// If the header has not yet been read, this method waits for it.
+func (pc *proxyConn) RemoteAddr() net.Addr {
+	pc.init()
+	return pc.remoteAddr
+}
+
+func (pc *proxyConn) SetDeadline(t time.Time) error {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	pc.readDeadline = t
+	return pc.Conn.SetDeadline(t)
+}
+
+func (pc *proxyConn) SetReadDeadline(t time.Time) error {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	pc.readDeadline = t
+	return pc.Conn.SetReadDeadline(t)
+}
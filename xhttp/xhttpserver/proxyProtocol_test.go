@@ -0,0 +1,287 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProxyHeader(t *testing.T) {
+	testData := []struct {
+		header   string
+		expected string
+		invalid  bool
+	}{
+		{header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n", expected: "192.0.2.1:56324"},
+		{header: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", expected: "[2001:db8::1]:56324"},
+		{header: "PROXY UNKNOWN\r\n"},
+		{header: "PROXY UNKNOWN 192.0.2.1 198.51.100.1 56324 443\r\n"},
+		{header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n", invalid: true},
+		{header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n", invalid: true},
+		{header: "PROXY UDP4 192.0.2.1 198.51.100.1 56324 443\r\n", invalid: true},
+		{header: "PROXY TCP4 nonsense 198.51.100.1 56324 443\r\n", invalid: true},
+		{header: "PROXY TCP4 2001:db8::1 2001:db8::2 56324 443\r\n", invalid: true},
+		{header: "PROXY TCP6 192.0.2.1 198.51.100.1 56324 443\r\n", invalid: true},
+		{header: "PROXY TCP4 192.0.2.1 198.51.100.1 99999 443\r\n", invalid: true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.header, func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := parseProxyHeader(record.header)
+			switch {
+			case record.invalid:
+				assert.IsType(InvalidProxyHeaderError{}, err)
+				assert.Nil(actual)
+
+			case len(record.expected) == 0:
+				assert.NoError(err)
+				assert.Nil(actual)
+
+			default:
+				assert.NoError(err)
+				if assert.NotNil(actual) {
+					assert.Equal(record.expected, actual.String())
+				}
+			}
+		})
+	}
+}
+
+// proxyHeaderV2 builds a binary PROXY protocol version 2 header with the given command, family, and addresses
+func proxyHeaderV2(command, family byte, addresses ...byte) string {
+	header := append([]byte(nil), proxyHeaderV2Signature...)
+	header = append(header, 0x20|command, family, byte(len(addresses)>>8), byte(len(addresses)))
+	return string(append(header, addresses...))
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	var (
+		tcp4 = []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb}
+		tcp6 = []byte{
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+			0xdc, 0x04, 0x01, 0xbb,
+		}
+
+		testData = []struct {
+			name     string
+			header   string
+			expected string
+			invalid  bool
+		}{
+			{name: "TCP4", header: proxyHeaderV2(0x1, 0x11, tcp4...), expected: "192.0.2.1:56324"},
+			{name: "TCP6", header: proxyHeaderV2(0x1, 0x21, tcp6...), expected: "[2001:db8::1]:56324"},
+			{name: "TLVs", header: proxyHeaderV2(0x1, 0x11, append(tcp4, 0x04, 0x00, 0x01, 0xff)...), expected: "192.0.2.1:56324"},
+			{name: "Local", header: proxyHeaderV2(0x0, 0x00)},
+			{name: "Unspecified", header: proxyHeaderV2(0x1, 0x00)},
+			{name: "UDP4", header: proxyHeaderV2(0x1, 0x12, tcp4...)},
+			{name: "BadVersion", header: string(proxyHeaderV2Signature) + "\x11\x11\x00\x00", invalid: true},
+			{name: "BadCommand", header: proxyHeaderV2(0x2, 0x11, tcp4...), invalid: true},
+			{name: "ShortTCP4", header: proxyHeaderV2(0x1, 0x11, tcp4[:8]...), invalid: true},
+			{name: "ShortTCP6", header: proxyHeaderV2(0x1, 0x21, tcp4...), invalid: true},
+			{name: "Truncated", header: proxyHeaderV2(0x1, 0x11, tcp4...)[:20], invalid: true},
+		}
+	)
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := readProxyHeaderV2(strings.NewReader(record.header))
+			switch {
+			case record.invalid:
+				assert.IsType(InvalidProxyHeaderError{}, err)
+				assert.Nil(actual)
+
+			case len(record.expected) == 0:
+				assert.NoError(err)
+				assert.Nil(actual)
+
+			default:
+				assert.NoError(err)
+				if assert.NotNil(actual) {
+					assert.Equal(record.expected, actual.String())
+				}
+			}
+		})
+	}
+}
+
+// acceptProxyConn sends data over a new loopback connection to a listener configured with the trusted networks,
+// returning the accepted connection and the log output
+func acceptProxyConn(t *testing.T, trusted []string, data string) (net.Conn, *bytes.Buffer) {
+	var (
+		require = require.New(t)
+		output  bytes.Buffer
+	)
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", ProxyProtocolTrusted: trusted, ListenerLogger: log.NewJSONLogger(&output)},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	t.Cleanup(func() { l.Close() })
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+
+	_, err = client.Write([]byte(data))
+	require.NoError(err)
+	client.Close()
+
+	accepted, err := l.Accept()
+	require.NoError(err)
+	require.NotNil(accepted)
+	t.Cleanup(func() { accepted.Close() })
+
+	return accepted, &output
+}
+
+func testProxyProtocolTrusted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(
+			t,
+			[]string{"127.0.0.0/8"},
+			"PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1\r\n\r\n",
+		)
+	)
+
+	assert.Equal("192.0.2.1:56324", accepted.RemoteAddr().String())
+	body, err := ioutil.ReadAll(accepted)
+	assert.NoError(err)
+	assert.Equal("GET / HTTP/1.1\r\n\r\n", string(body))
+	assert.Zero(output.Len())
+}
+
+func testProxyProtocolTrustedV2(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(
+			t,
+			[]string{"127.0.0.0/8"},
+			proxyHeaderV2(0x1, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb)+"GET / HTTP/1.1\r\n\r\n",
+		)
+	)
+
+	assert.Equal("192.0.2.1:56324", accepted.RemoteAddr().String())
+	body, err := ioutil.ReadAll(accepted)
+	assert.NoError(err)
+	assert.Equal("GET / HTTP/1.1\r\n\r\n", string(body))
+	assert.Zero(output.Len())
+}
+
+func testProxyProtocolUntrustedV2(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(
+			t,
+			[]string{"10.0.0.0/8"},
+			proxyHeaderV2(0x1, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb)+"GET / HTTP/1.1\r\n\r\n",
+		)
+	)
+
+	_, err := accepted.Read(make([]byte, 64))
+	assert.Equal(ErrUntrustedProxyHeader, err)
+	assert.Contains(output.String(), "rejected PROXY header from untrusted peer")
+}
+
+func testProxyProtocolTrustedNoHeader(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(t, []string{"127.0.0.0/8"}, "GET / HTTP/1.1\r\n\r\n")
+	)
+
+	host, _, err := net.SplitHostPort(accepted.RemoteAddr().String())
+	assert.NoError(err)
+	assert.Equal("127.0.0.1", host)
+
+	body, err := ioutil.ReadAll(accepted)
+	assert.NoError(err)
+	assert.Equal("GET / HTTP/1.1\r\n\r\n", string(body))
+	assert.Zero(output.Len())
+}
+
+func testProxyProtocolTrustedInvalid(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(t, []string{"127.0.0.0/8"}, "PROXY TCP4 nonsense\r\nGET / HTTP/1.1\r\n\r\n")
+	)
+
+	_, err := accepted.Read(make([]byte, 64))
+	assert.IsType(InvalidProxyHeaderError{}, err)
+	assert.Contains(output.String(), "invalid PROXY header")
+}
+
+func testProxyProtocolUntrusted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(
+			t,
+			[]string{"10.0.0.0/8"},
+			"PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1\r\n\r\n",
+		)
+	)
+
+	host, _, err := net.SplitHostPort(accepted.RemoteAddr().String())
+	assert.NoError(err)
+	assert.Equal("127.0.0.1", host)
+
+	_, err = accepted.Read(make([]byte, 64))
+	assert.Equal(ErrUntrustedProxyHeader, err)
+	assert.Contains(output.String(), "rejected PROXY header from untrusted peer")
+}
+
+func testProxyProtocolUntrustedNoHeader(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		accepted, output = acceptProxyConn(t, []string{"10.0.0.0/8"}, "GET / HTTP/1.1\r\n\r\n")
+	)
+
+	body, err := ioutil.ReadAll(accepted)
+	assert.NoError(err)
+	assert.Equal("GET / HTTP/1.1\r\n\r\n", string(body))
+	assert.Zero(output.Len())
+}
+
+func testProxyProtocolInvalidNetworks(t *testing.T) {
+	assert := assert.New(t)
+	l, err := NewListener(
+		context.Background(),
+		Options{Address: "127.0.0.1:0", ProxyProtocolTrusted: []string{"nonsense"}},
+		net.ListenConfig{},
+		nil,
+	)
+
+	assert.Error(err)
+	assert.Nil(l)
+}
+
+func TestProxyProtocol(t *testing.T) {
+	t.Run("Trusted", testProxyProtocolTrusted)
+	t.Run("TrustedV2", testProxyProtocolTrustedV2)
+	t.Run("TrustedNoHeader", testProxyProtocolTrustedNoHeader)
+	t.Run("TrustedInvalid", testProxyProtocolTrustedInvalid)
+	t.Run("Untrusted", testProxyProtocolUntrusted)
+	t.Run("UntrustedV2", testProxyProtocolUntrustedV2)
+	t.Run("UntrustedNoHeader", testProxyProtocolUntrustedNoHeader)
+	t.Run("InvalidNetworks", testProxyProtocolInvalidNetworks)
+}
@@ -0,0 +1,141 @@
+package xhttpserver
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	panicKey = "panic"
+)
+
+// PanicKey is the logging key for a value recovered from a panic
+func PanicKey() interface{} {
+	return panicKey
+}
+
+// HTTPError is an error that carries the HTTP status of the response it should produce.  Handlers with deep
+// call stacks may panic with an HTTPError, possibly wrapped, rather than returning it through every layer.
+// Recovery renders such panics with the error's status code and message.  The standard server chain only
+// includes Recovery when Options.RecoverPanics or Options.OnPanic is set.
+type HTTPError interface {
+	error
+	StatusCode() int
+}
+
+// PanicReporter is a callback for panics recovered from handlers, e.g. to alert via an external service.  The stack
+// is that of the panicking goroutine.  A PanicReporter is invoked synchronously, before the response is written, so
+// it should not block for long.  Any panic from a PanicReporter is logged and otherwise ignored.
+type PanicReporter func(request *http.Request, recovered interface{}, stack []byte)
+
+// Recovery is an Alice-style decorator that recovers from panics in the decorated handler.
+// The panic is logged, and OnPanic is invoked to write the response.
+//
+// A panic with an HTTPError is an expected way of ending a request, so the response uses that error's status code
+// and message, rendered by the ErrorEncoder, instead of OnPanic.  Such panics are only logged as errors when their
+// status code is http.StatusInternalServerError or higher.
+//
+// As with net/http, a panic with http.ErrAbortHandler is not recovered.
+type Recovery struct {
+	// Logger is the optional logger to which panics are written.  If unset, the request's
+	// contextual logger is used.
+	Logger log.Logger
+
+	// OnPanic is the optional handler invoked after a panic is recovered.  If unset,
+	// an http.StatusInternalServerError is returned.
+	OnPanic http.Handler
+
+	// ErrorEncoder is the optional strategy for rendering panics with an HTTPError.  If unset,
+	// DefaultErrorEncoder is used.
+	ErrorEncoder ErrorEncoder
+
+	// Reporter is the optional PanicReporter invoked for each panic that is logged as an error
+	Reporter PanicReporter
+}
+
+// report invokes a PanicReporter, logging rather than propagating any panic from the reporter itself
+func report(reporter PanicReporter, logger log.Logger, request *http.Request, v interface{}, stack []byte) {
+	defer func() {
+		if rv := recover(); rv != nil {
+			logger.Log(
+				level.Key(), level.ErrorValue(),
+				xlog.MessageKey(), "panic reporter panicked",
+				PanicKey(), rv,
+			)
+		}
+	}()
+
+	reporter(request, v, stack)
+}
+
+func (r Recovery) Then(next http.Handler) http.Handler {
+	onPanic := r.OnPanic
+	if onPanic == nil {
+		onPanic = Constant{StatusCode: http.StatusInternalServerError}.NewHandler()
+	}
+
+	errorEncoder := r.ErrorEncoder
+	if errorEncoder == nil {
+		errorEncoder = DefaultErrorEncoder
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			} else if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			logger := r.Logger
+			if logger == nil {
+				logger = xlog.Get(request.Context())
+			}
+
+			var (
+				httpErr HTTPError
+				isHTTP  bool
+				isError = true
+			)
+
+			if err, ok := v.(error); ok && errors.As(err, &httpErr) {
+				isHTTP = true
+				isError = httpErr.StatusCode() >= http.StatusInternalServerError
+			}
+
+			logLevel := level.ErrorValue()
+			if !isError {
+				logLevel = level.InfoValue()
+			}
+
+			logger.Log(
+				level.Key(), logLevel,
+				xlog.MessageKey(), "recovered from handler panic",
+				PanicKey(), v,
+			)
+
+			if r.Reporter != nil && isError {
+				report(r.Reporter, logger, request, v, debug.Stack())
+			}
+
+			if isHTTP {
+				errorEncoder(response, httpErr.StatusCode(), httpErr.Error())
+			} else {
+				onPanic.ServeHTTP(response, request)
+			}
+		}()
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (r Recovery) ThenFunc(next http.HandlerFunc) http.Handler {
+	return r.Then(next)
+}
@@ -0,0 +1,245 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecoveryNoPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		recovery = Recovery{Logger: log.NewJSONLogger(&output)}.ThenFunc(
+			func(response http.ResponseWriter, _ *http.Request) {
+				response.WriteHeader(299)
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	recovery.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Zero(output.Len())
+}
+
+func testRecoveryDefaultOnPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		recovery = Recovery{Logger: log.NewJSONLogger(&output)}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic("expected panic")
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	recovery.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Contains(output.String(), "expected panic")
+}
+
+func testRecoveryCustomOnPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		recovery = Recovery{OnPanic: Constant{StatusCode: 599}.NewHandler()}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic("expected panic")
+			},
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	// the contextual logger is used when no Logger is configured
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+	recovery.ServeHTTP(response, request)
+	assert.Equal(599, response.Code)
+	assert.Contains(output.String(), "expected panic")
+}
+
+func testRecoveryAbortHandler(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		recovery = Recovery{Logger: log.NewNopLogger()}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic(http.ErrAbortHandler)
+			},
+		)
+	)
+
+	assert.PanicsWithValue(http.ErrAbortHandler, func() {
+		recovery.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
+
+// testHTTPError is an HTTPError with an arbitrary status code
+type testHTTPError struct {
+	statusCode int
+}
+
+func (the testHTTPError) Error() string {
+	return "expected HTTP error"
+}
+
+func (the testHTTPError) StatusCode() int {
+	return the.statusCode
+}
+
+func testRecoveryHTTPError(t *testing.T, v interface{}, errorEncoder ErrorEncoder, expectedStatusCode int, expectedLevel, expectedBody string) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		recovery = Recovery{
+			Logger:       log.NewJSONLogger(&output),
+			OnPanic:      Constant{StatusCode: 599}.NewHandler(),
+			ErrorEncoder: errorEncoder,
+		}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic(v)
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	recovery.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(expectedStatusCode, response.Code)
+	assert.Contains(response.Body.String(), expectedBody)
+	assert.Contains(output.String(), `"level":"`+expectedLevel+`"`)
+}
+
+func testRecoveryReporter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output   bytes.Buffer
+		reported []interface{}
+		stack    []byte
+		request  = httptest.NewRequest("GET", "/", nil)
+
+		recovery = Recovery{
+			Logger: log.NewJSONLogger(&output),
+			Reporter: func(r *http.Request, v interface{}, s []byte) {
+				assert.Equal(request, r)
+				reported = append(reported, v)
+				stack = s
+			},
+		}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic("expected panic")
+			},
+		)
+	)
+
+	response := httptest.NewRecorder()
+	recovery.ServeHTTP(response, request)
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	require.Len(reported, 1)
+	assert.Equal("expected panic", reported[0])
+	assert.Contains(string(stack), "testRecoveryReporter")
+}
+
+func testRecoveryReporterHTTPError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		reported bool
+		recovery = Recovery{
+			Logger: log.NewNopLogger(),
+			Reporter: func(*http.Request, interface{}, []byte) {
+				reported = true
+			},
+		}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic(testHTTPError{statusCode: http.StatusNotFound})
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	recovery.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusNotFound, response.Code)
+	assert.False(reported)
+}
+
+func testRecoveryReporterPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		recovery = Recovery{
+			Logger: log.NewJSONLogger(&output),
+			Reporter: func(*http.Request, interface{}, []byte) {
+				panic("reporter panic")
+			},
+		}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				panic("expected panic")
+			},
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	assert.NotPanics(func() {
+		recovery.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	})
+
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Contains(output.String(), "panic reporter panicked")
+	assert.Contains(output.String(), "reporter panic")
+}
+
+func TestRecovery(t *testing.T) {
+	t.Run("NoPanic", testRecoveryNoPanic)
+	t.Run("DefaultOnPanic", testRecoveryDefaultOnPanic)
+	t.Run("CustomOnPanic", testRecoveryCustomOnPanic)
+	t.Run("AbortHandler", testRecoveryAbortHandler)
+	t.Run("HTTPError", func(t *testing.T) {
+		testRecoveryHTTPError(t, IOLimitError{Max: 123}, nil, http.StatusRequestEntityTooLarge, "info", "123 bytes")
+	})
+
+	t.Run("WrappedHTTPError", func(t *testing.T) {
+		testRecoveryHTTPError(
+			t,
+			fmt.Errorf("wrapped: %w", testHTTPError{statusCode: http.StatusNotFound}),
+			JSONErrorEncoder,
+			http.StatusNotFound,
+			"info",
+			`{"error":{"code":404,"message":"expected HTTP error"}}`,
+		)
+	})
+
+	t.Run("ServerHTTPError", func(t *testing.T) {
+		testRecoveryHTTPError(t, testHTTPError{statusCode: http.StatusBadGateway}, nil, http.StatusBadGateway, "error", "expected HTTP error")
+	})
+
+	t.Run("Reporter", testRecoveryReporter)
+	t.Run("ReporterHTTPError", testRecoveryReporterHTTPError)
+	t.Run("ReporterPanic", testRecoveryReporterPanic)
+	t.Run("OtherError", func(t *testing.T) {
+		testRecoveryHTTPError(t, errors.New("expected"), nil, 599, "error", "")
+	})
+}
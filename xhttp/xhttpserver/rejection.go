@@ -0,0 +1,133 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	rejectedByKey = "rejectedBy"
+)
+
+// RejectedByKey is the logging key for the name of the middleware that rejected a request, e.g. busy
+func RejectedByKey() interface{} {
+	return rejectedByKey
+}
+
+// rejection records which middleware, if any, rejected a request.  If the request had a contextual logger
+// when it was rejected, that logger is kept so that the rejection is logged with the request's fields.
+type rejection struct {
+	lock   sync.Mutex
+	by     string
+	logger log.Logger
+}
+
+func (r *rejection) set(by string, logger log.Logger) {
+	r.lock.Lock()
+	if len(r.by) == 0 {
+		r.by = by
+		r.logger = logger
+	}
+
+	r.lock.Unlock()
+}
+
+func (r *rejection) get() (string, log.Logger) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.by, r.logger
+}
+
+type rejectionContextKey struct{}
+
+// MarkRejected records that the named middleware rejected the request that owns the given context.  Middleware
+// calls this just before writing its rejection, so that otherwise identical responses, e.g. two different 403s,
+// can be told apart in logs.  The name is added to the request's contextual logger under RejectedByKey, if the
+// request has one.  Rejections are logged by LogRejections.
+func MarkRejected(ctx context.Context, by string) {
+	var logger log.Logger
+	if xloghttp.AddLogField(ctx, RejectedByKey(), by) {
+		logger = xloghttp.LoggerFromContext(ctx)
+	}
+
+	if r, ok := ctx.Value(rejectionContextKey{}).(*rejection); ok {
+		r.set(by, logger)
+	}
+}
+
+// RejectedByFromContext returns the name of the middleware that rejected a request.  The returned boolean is false
+// if the request was not rejected or did not pass through a LogRejections decorator.
+func RejectedByFromContext(ctx context.Context) (string, bool) {
+	if r, ok := ctx.Value(rejectionContextKey{}).(*rejection); ok {
+		by, _ := r.get()
+		return by, len(by) > 0
+	}
+
+	return "", false
+}
+
+// LogRejections is an Alice-style decorator that logs an entry for each request rejected by middleware, along with
+// the name of that middleware under RejectedByKey.  Nothing else logs an entry when a request completes, so without
+// this decorator a rejection is only visible in entries that handlers happen to log.  See MarkRejected.
+//
+// Requests rejected after a contextual logger was bound to them, e.g. by Drain or MaintenanceMode in the standard
+// server chain, are logged with that contextual logger, so the entry carries the request's fields and is subject to
+// Logging.MinStatusCode.  Requests rejected before that, e.g. by Busy, are logged with Logger and Builders.
+//
+// This decorator must precede any middleware that may reject requests.
+type LogRejections struct {
+	// Logger is the logger for rejections.  If unset, no decoration is done.
+	Logger log.Logger
+
+	// Builders are the optional parameter builders applied to each rejection's log entry
+	Builders xloghttp.ParameterBuilders
+}
+
+func (lr LogRejections) Then(next http.Handler) http.Handler {
+	if lr.Logger == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		r := new(rejection)
+		request = request.WithContext(
+			context.WithValue(request.Context(), rejectionContextKey{}, r),
+		)
+
+		next.ServeHTTP(response, request)
+		by, logger := r.get()
+		switch {
+		case len(by) == 0:
+			// the request was not rejected
+
+		case logger != nil:
+			// the contextual logger already carries RejectedByKey
+			logger.Log(
+				level.Key(), level.InfoValue(),
+				xlog.MessageKey(), "request rejected",
+			)
+
+		default:
+			logger = xloghttp.LoggerFromContext(
+				xloghttp.WithRequest(request, lr.Logger, lr.Builders...).Context(),
+			)
+
+			logger.Log(
+				level.Key(), level.InfoValue(),
+				xlog.MessageKey(), "request rejected",
+				RejectedByKey(), by,
+			)
+		}
+	})
+}
+
+func (lr LogRejections) ThenFunc(next http.HandlerFunc) http.Handler {
+	return lr.Then(next)
+}
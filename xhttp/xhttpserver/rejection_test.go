@@ -0,0 +1,167 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	// neither a LogRejections nor a contextual logger is required
+	MarkRejected(context.Background(), "test")
+	by, ok := RejectedByFromContext(context.Background())
+	assert.Empty(by)
+	assert.False(ok)
+}
+
+func testLogRejectionsNoLogger(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = LogRejections{}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			MarkRejected(request.Context(), "test")
+			_, ok := RejectedByFromContext(request.Context())
+			assert.False(ok)
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+}
+
+func testLogRejectionsBeforeLogging(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+
+		decorated = LogRejections{
+			Logger:   log.NewJSONLogger(&output),
+			Builders: xloghttp.ParameterBuilders{xloghttp.URI("uri")},
+		}.Then(
+			ContentType{Allowed: []string{"application/json"}}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+				assert.Fail("the handler should not have been called")
+			}),
+		)
+
+		request = httptest.NewRequest("POST", "/test", bytes.NewBufferString("body"))
+	)
+
+	require.NotNil(decorated)
+	request.Header.Set("Content-Type", "text/plain")
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusUnsupportedMediaType, response.Code)
+	assert.Contains(output.String(), `"rejectedBy":"contentType"`)
+	assert.Contains(output.String(), `"uri":"/test"`)
+	assert.Contains(output.String(), `"msg":"request rejected"`)
+}
+
+func testLogRejectionsAfterLogging(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		logger = log.NewJSONLogger(&output)
+
+		decorated = LogRejections{Logger: logger}.Then(
+			xloghttp.Logging{Base: logger}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+				MarkRejected(request.Context(), "test")
+				by, ok := RejectedByFromContext(request.Context())
+				assert.Equal("test", by)
+				assert.True(ok)
+
+				xloghttp.LoggerFromContext(request.Context()).Log(xlog.MessageKey(), "rejecting")
+				response.WriteHeader(http.StatusForbidden)
+			}),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusForbidden, response.Code)
+
+	// the rejection is logged once more with the contextual logger, which carries the rejection
+	decoder := json.NewDecoder(&output)
+
+	var handlerRecord map[string]interface{}
+	require.NoError(decoder.Decode(&handlerRecord))
+	assert.Equal("rejecting", handlerRecord["msg"])
+	assert.Equal("test", handlerRecord["rejectedBy"])
+
+	var rejectedRecord map[string]interface{}
+	require.NoError(decoder.Decode(&rejectedRecord))
+	assert.Equal("request rejected", rejectedRecord["msg"])
+	assert.Equal("test", rejectedRecord["rejectedBy"])
+	assert.False(decoder.More())
+}
+
+func testLogRejectionsMaintenance(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output      bytes.Buffer
+		logger      = log.NewJSONLogger(&output)
+		maintenance = NewMaintenance(nil)
+
+		// the standard order:  maintenance rejects after the logging stage, and nothing in the chain logs
+		decorated = LogRejections{Logger: logger}.Then(
+			TrackingStage()(
+				xloghttp.Logging{
+					Base:          logger,
+					Builders:      xloghttp.ParameterBuilders{xloghttp.URI("uri")},
+					MinStatusCode: 500,
+				}.Then(
+					MaintenanceMode{Maintenance: maintenance}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+						response.WriteHeader(299)
+					}),
+				),
+			),
+		)
+	)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Zero(output.Len())
+
+	maintenance.Set(true, "test")
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+
+	var record map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &record))
+	assert.Equal("request rejected", record["msg"])
+	assert.Equal("maintenance", record["rejectedBy"])
+	assert.Equal("/test", record["uri"])
+}
+
+func TestLogRejections(t *testing.T) {
+	t.Run("NoLogger", testLogRejectionsNoLogger)
+	t.Run("BeforeLogging", testLogRejectionsBeforeLogging)
+	t.Run("AfterLogging", testLogRejectionsAfterLogging)
+	t.Run("Maintenance", testLogRejectionsMaintenance)
+}
@@ -0,0 +1,238 @@
+package xhttpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+var (
+	ErrAlreadyServing = errors.New("The server is already serving requests")
+)
+
+// acceptResult is the outcome of a single Accept on a sharedListener
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// sharedListener accepts connections from a net.Listener in the background so that several servers, one after
+// another, can accept from it without closing it
+type sharedListener struct {
+	net.Listener
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	results   chan acceptResult
+	closed    chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+func newSharedListener(l net.Listener) *sharedListener {
+	return &sharedListener{
+		Listener: l,
+		results:  make(chan acceptResult),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (sl *sharedListener) start() {
+	sl.startOnce.Do(func() {
+		go sl.acceptLoop()
+	})
+}
+
+func (sl *sharedListener) acceptLoop() {
+	defer close(sl.done)
+	for {
+		conn, err := sl.Listener.Accept()
+		if ne, ok := err.(net.Error); err != nil && !(ok && ne.Temporary()) {
+			sl.err = err
+			return
+		}
+
+		select {
+		case sl.results <- acceptResult{conn: conn, err: err}:
+		case <-sl.closed:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+}
+
+// Close closes the underlying net.Listener
+func (sl *sharedListener) Close() error {
+	var err error
+	sl.closeOnce.Do(func() {
+		close(sl.closed)
+		err = sl.Listener.Close()
+	})
+
+	return err
+}
+
+// generationListener is the net.Listener given to a single server.  Closing it, as http.Server.Shutdown
+// does, only stops that server from accepting connections.
+type generationListener struct {
+	shared    *sharedListener
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (gl *generationListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-gl.shared.results:
+		return r.conn, r.err
+
+	case <-gl.shared.done:
+		return nil, gl.shared.err
+
+	case <-gl.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (gl *generationListener) Close() error {
+	gl.closeOnce.Do(func() {
+		close(gl.closed)
+	})
+
+	return nil
+}
+
+func (gl *generationListener) Addr() net.Addr {
+	return gl.shared.Addr()
+}
+
+// Reloadable is a server whose handler and options can be replaced while it is running, without closing its
+// listener.  Each call to Reload starts a new http.Server on the same listener and then gracefully shuts down the
+// previous one, so no connections are refused and in-flight requests complete on the server that accepted them.
+// This is a more surgical alternative to a process restart for changes to middleware, handlers, or timeouts.
+//
+// Options that apply to the listener, such as Address, Tls, and the TCP settings, cannot be changed by Reload and
+// are ignored.  Since Reloadable implements Interface, it can be used with OnStart and OnStop.
+type Reloadable struct {
+	logger log.Logger
+
+	lock       sync.Mutex
+	current    Interface
+	generation int
+	shared     *sharedListener
+	shutdown   bool
+
+	finishOnce sync.Once
+	done       chan struct{}
+	err        error
+}
+
+// NewReloadable creates a Reloadable whose initial server is produced by New with the given parameters.
+// The logger is also used for every server created by Reload.
+func NewReloadable(o Options, l log.Logger, h http.Handler, cs ...func(net.Conn, http.ConnState)) *Reloadable {
+	return &Reloadable{
+		logger:  l,
+		current: New(o, l, h, cs...),
+		done:    make(chan struct{}),
+	}
+}
+
+// finish causes Serve to return with the given error.  Only the first call has any effect.
+func (r *Reloadable) finish(err error) {
+	r.finishOnce.Do(func() {
+		r.err = err
+		close(r.done)
+	})
+}
+
+// serve starts the current server on a new generation of the shared listener.  The lock must be held.
+func (r *Reloadable) serve() {
+	var (
+		s          = r.current
+		generation = r.generation
+		gl         = &generationListener{shared: r.shared, closed: make(chan struct{})}
+	)
+
+	go func() {
+		err := s.Serve(gl)
+
+		// a server that was replaced by Reload exits normally, and is not an error
+		r.lock.Lock()
+		if generation == r.generation && !r.shutdown {
+			r.finish(err)
+		}
+
+		r.lock.Unlock()
+	}()
+}
+
+// Serve begins serving requests from the given listener.  Like http.Server.Serve, this method blocks until
+// the server exits, which is not affected by any calls to Reload.  After Shutdown, http.ErrServerClosed is returned.
+func (r *Reloadable) Serve(l net.Listener) error {
+	r.lock.Lock()
+	switch {
+	case r.shutdown:
+		r.lock.Unlock()
+		return http.ErrServerClosed
+
+	case r.shared != nil:
+		r.lock.Unlock()
+		return ErrAlreadyServing
+	}
+
+	r.shared = newSharedListener(l)
+	r.shared.start()
+	r.serve()
+	r.lock.Unlock()
+
+	<-r.done
+	r.shared.Close()
+	return r.err
+}
+
+// Reload replaces the running server with one produced by New from the given parameters.  The new server begins
+// accepting connections immediately, after which the previous server is gracefully shut down using the given
+// context.  Any error from that shutdown is returned.  If Serve has not yet been called, the new server simply
+// replaces the initial one.
+func (r *Reloadable) Reload(ctx context.Context, o Options, h http.Handler, cs ...func(net.Conn, http.ConnState)) error {
+	next := New(o, r.logger, h, cs...)
+
+	r.lock.Lock()
+	if r.shutdown {
+		r.lock.Unlock()
+		return http.ErrServerClosed
+	}
+
+	previous := r.current
+	r.current = next
+	r.generation++
+	serving := r.shared != nil
+	if serving {
+		r.serve()
+	}
+
+	r.lock.Unlock()
+
+	if serving {
+		return previous.Shutdown(ctx)
+	}
+
+	return nil
+}
+
+// Shutdown gracefully shuts down the current server and closes the listener
+func (r *Reloadable) Shutdown(ctx context.Context) error {
+	r.lock.Lock()
+	r.shutdown = true
+	current := r.current
+	r.lock.Unlock()
+
+	err := current.Shutdown(ctx)
+	r.finish(http.ErrServerClosed)
+	return err
+}
@@ -0,0 +1,178 @@
+package xhttpserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog/xlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusHandler returns an http.Handler that responds with the given status code
+func statusHandler(statusCode int) http.Handler {
+	return Constant{StatusCode: statusCode}.NewHandler()
+}
+
+// testReloadableGet issues a GET, on a new connection, to the given address and returns the status code.
+// This function may be called from any goroutine, and returns zero if the request failed.
+func testReloadableGet(t *testing.T, address string) int {
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: true},
+		Timeout:   5 * time.Second,
+	}
+
+	response, err := client.Get("http://" + address)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func testReloadableReload(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		r        = NewReloadable(Options{}, xlogtest.New(t), statusHandler(291))
+		serveErr = make(chan error, 1)
+	)
+
+	// reloading prior to Serve replaces the initial server
+	require.NoError(r.Reload(context.Background(), Options{}, statusHandler(292)))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	address := l.Addr().String()
+
+	go func() {
+		serveErr <- r.Serve(l)
+	}()
+
+	assert.Equal(292, testReloadableGet(t, address))
+	assert.Equal(ErrAlreadyServing, r.Serve(l))
+
+	require.NoError(r.Reload(context.Background(), Options{}, statusHandler(293)))
+	assert.Equal(293, testReloadableGet(t, address))
+
+	require.NoError(r.Reload(context.Background(), Options{}, statusHandler(294)))
+	assert.Equal(294, testReloadableGet(t, address))
+
+	select {
+	case err := <-serveErr:
+		assert.Fail("Serve should not have returned", "error: %s", err)
+	default:
+	}
+
+	require.NoError(r.Shutdown(context.Background()))
+	select {
+	case err := <-serveErr:
+		assert.Equal(http.ErrServerClosed, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("Serve did not return after Shutdown")
+	}
+
+	_, err = net.Dial("tcp", address)
+	assert.Error(err)
+
+	assert.Equal(http.ErrServerClosed, r.Reload(context.Background(), Options{}, statusHandler(295)))
+	assert.Equal(http.ErrServerClosed, r.Serve(l))
+}
+
+func testReloadableInFlight(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		entered = make(chan struct{})
+		block   = make(chan struct{})
+		old     = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			close(entered)
+			<-block
+			response.WriteHeader(291)
+		})
+
+		r        = NewReloadable(Options{}, xlogtest.New(t), old)
+		serveErr = make(chan error, 1)
+		inFlight = make(chan int, 1)
+		reloaded = make(chan error, 1)
+	)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	address := l.Addr().String()
+
+	go func() {
+		serveErr <- r.Serve(l)
+	}()
+
+	go func() {
+		inFlight <- testReloadableGet(t, address)
+	}()
+
+	<-entered
+	go func() {
+		reloaded <- r.Reload(context.Background(), Options{}, statusHandler(292))
+	}()
+
+	// the new server handles requests while the old one is still draining
+	assert.Eventually(
+		func() bool { return testReloadableGet(t, address) == 292 },
+		5*time.Second,
+		10*time.Millisecond,
+	)
+
+	select {
+	case <-reloaded:
+		assert.Fail("Reload should wait for in-flight requests")
+	default:
+	}
+
+	close(block)
+	assert.Equal(291, <-inFlight)
+	assert.NoError(<-reloaded)
+
+	require.NoError(r.Shutdown(context.Background()))
+	assert.Equal(http.ErrServerClosed, <-serveErr)
+}
+
+func testReloadableListenerError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		r = NewReloadable(Options{}, xlogtest.New(t), statusHandler(291))
+	)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- r.Serve(l)
+	}()
+
+	assert.Equal(291, testReloadableGet(t, l.Addr().String()))
+	l.Close()
+
+	select {
+	case err := <-serveErr:
+		assert.Error(err)
+		assert.NotEqual(http.ErrServerClosed, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("Serve did not return after the listener was closed")
+	}
+}
+
+func TestReloadable(t *testing.T) {
+	t.Run("Reload", testReloadableReload)
+	t.Run("InFlight", testReloadableInFlight)
+	t.Run("ListenerError", testReloadableListenerError)
+}
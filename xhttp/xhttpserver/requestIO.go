@@ -0,0 +1,216 @@
+package xhttpserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	bytesReadKey    = "bytesRead"
+	bytesWrittenKey = "bytesWritten"
+)
+
+// BytesReadKey is the logging key for the number of request body bytes read by a handler
+func BytesReadKey() interface{} {
+	return bytesReadKey
+}
+
+// BytesWrittenKey is the logging key for the number of response body bytes written by a handler
+func BytesWrittenKey() interface{} {
+	return bytesWrittenKey
+}
+
+// IOLimitError is returned when reading a request body or writing a response body would take a request's
+// combined I/O beyond its limit.  This error implements go-kit's StatusCoder, so that error encoders will
+// produce an http.StatusRequestEntityTooLarge.
+type IOLimitError struct {
+	Max int64
+}
+
+func (ile IOLimitError) Error() string {
+	return fmt.Sprintf("The request exceeded its I/O limit of %d bytes", ile.Max)
+}
+
+func (ile IOLimitError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// RequestIO reports the body I/O performed by a single request.  The current values are available at any point
+// while the request is handled.
+type RequestIO struct {
+	bytesRead int64
+	writer    TrackingWriter
+}
+
+// BytesRead returns the number of request body bytes read so far
+func (rio *RequestIO) BytesRead() int64 {
+	return atomic.LoadInt64(&rio.bytesRead)
+}
+
+// BytesWritten returns the number of response body bytes written so far
+func (rio *RequestIO) BytesWritten() int64 {
+	return int64(rio.writer.BytesWritten())
+}
+
+// total returns the combined I/O of the request
+func (rio *RequestIO) total() int64 {
+	return rio.BytesRead() + rio.BytesWritten()
+}
+
+type requestIOContextKey struct{}
+
+// WithRequestIO returns a new context with the given RequestIO
+func WithRequestIO(ctx context.Context, rio *RequestIO) context.Context {
+	return context.WithValue(ctx, requestIOContextKey{}, rio)
+}
+
+// RequestIOFromContext returns the RequestIO stored by an IOAccounting decorator.  The returned boolean is
+// false if the request did not pass through an IOAccounting decorator.
+func RequestIOFromContext(ctx context.Context) (*RequestIO, bool) {
+	rio, ok := ctx.Value(requestIOContextKey{}).(*RequestIO)
+	return rio, ok
+}
+
+// IOMetrics holds the optional metrics that observe each request's I/O once that request completes.  Any labels,
+// such as the server name, must already be applied to these metrics, e.g. by currying.
+type IOMetrics struct {
+	// BytesRead observes the number of request body bytes read
+	BytesRead xmetrics.Observer
+
+	// BytesWritten observes the number of response body bytes written
+	BytesWritten xmetrics.Observer
+}
+
+// countingBody is an io.ReadCloser decorator that counts, and optionally limits, the bytes read from a request body
+type countingBody struct {
+	io.ReadCloser
+	rio *RequestIO
+	max int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	if cb.max > 0 {
+		remaining := cb.max - cb.rio.total()
+		if remaining < 0 {
+			return 0, IOLimitError{Max: cb.max}
+		} else if int64(len(p)) > remaining+1 {
+			// read at most one more byte than allowed, so that exceeding the limit is detected
+			// while a body that ends exactly at the limit still reaches io.EOF
+			p = p[:remaining+1]
+		}
+	}
+
+	n, err := cb.ReadCloser.Read(p)
+	atomic.AddInt64(&cb.rio.bytesRead, int64(n))
+	if cb.max > 0 && cb.rio.total() > cb.max {
+		err = IOLimitError{Max: cb.max}
+	}
+
+	return n, err
+}
+
+// limitedTrackingWriter is a TrackingWriter that rejects writes beyond a request's I/O limit
+type limitedTrackingWriter struct {
+	TrackingWriter
+	rio *RequestIO
+	max int64
+}
+
+// Unwrap returns the decorated http.ResponseWriter
+func (ltw *limitedTrackingWriter) Unwrap() http.ResponseWriter {
+	return ltw.TrackingWriter
+}
+
+func (ltw *limitedTrackingWriter) Write(b []byte) (int, error) {
+	if ltw.rio.total()+int64(len(b)) > ltw.max {
+		return 0, IOLimitError{Max: ltw.max}
+	}
+
+	return ltw.TrackingWriter.Write(b)
+}
+
+// IOAccounting is an Alice-style decorator that tracks the request and response body bytes of each request.  This
+// is the basis for attributing resource usage, e.g. to tenants.  The running totals are available via
+// RequestIOFromContext, and the request's contextual logger, if any, is enriched with them under BytesReadKey and
+// BytesWrittenKey.  Those values are computed as each entry is logged.  Once the request completes, the totals are
+// observed by any configured Metrics.
+//
+// When MaxBytes is set, it bounds the combined bytes read and written.  Requests whose Content-Length alone
+// exceeds it are rejected before the decorated handler executes.  Otherwise, reads and writes that would exceed
+// it fail with an IOLimitError, which handlers should return or encode.
+//
+// This decorator must follow the one that creates the contextual logger, e.g. xloghttp.Logging.  Response bytes
+// are counted by a TrackingWriter, which is created if the response writer is not already one.
+type IOAccounting struct {
+	// MaxBytes is the limit on each request's combined body I/O.  If nonpositive, I/O is not limited.
+	MaxBytes int64
+
+	// OnExceeded is the optional handler for requests rejected due to their Content-Length.  If unset,
+	// a 413 is returned.
+	OnExceeded http.Handler
+
+	// Metrics are the optional observers of each request's I/O
+	Metrics IOMetrics
+}
+
+func (ia IOAccounting) Then(next http.Handler) http.Handler {
+	onExceeded := ia.OnExceeded
+	if onExceeded == nil {
+		onExceeded = Constant{StatusCode: http.StatusRequestEntityTooLarge}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if ia.MaxBytes > 0 && request.ContentLength > ia.MaxBytes {
+			MarkRejected(request.Context(), "ioLimit")
+			onExceeded.ServeHTTP(response, request)
+			return
+		}
+
+		var (
+			tw  = newRequestTrackingWriter(response, request)
+			rio = &RequestIO{writer: tw}
+			ctx = WithRequestIO(request.Context(), rio)
+		)
+
+		if logger := xlog.GetDefault(ctx, nil); logger != nil {
+			ctx = xlog.With(ctx, log.With(logger,
+				BytesReadKey(), log.Valuer(func() interface{} { return rio.BytesRead() }),
+				BytesWrittenKey(), log.Valuer(func() interface{} { return rio.BytesWritten() }),
+			))
+		}
+
+		if ia.MaxBytes > 0 {
+			tw = &limitedTrackingWriter{TrackingWriter: tw, rio: rio, max: ia.MaxBytes}
+		}
+
+		request = request.WithContext(ctx)
+		if request.Body != nil && request.Body != http.NoBody {
+			request.Body = &countingBody{
+				ReadCloser: request.Body,
+				rio:        rio,
+				max:        ia.MaxBytes,
+			}
+		}
+
+		next.ServeHTTP(tw, request)
+		if ia.Metrics.BytesRead != nil {
+			ia.Metrics.BytesRead.Observe(nil, float64(rio.BytesRead()))
+		}
+
+		if ia.Metrics.BytesWritten != nil {
+			ia.Metrics.BytesWritten.Observe(nil, float64(rio.BytesWritten()))
+		}
+	})
+}
+
+func (ia IOAccounting) ThenFunc(next http.HandlerFunc) http.Handler {
+	return ia.Then(next)
+}
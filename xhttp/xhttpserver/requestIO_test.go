@@ -0,0 +1,233 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIOLimitError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		err    = IOLimitError{Max: 123}
+	)
+
+	assert.Contains(err.Error(), "123")
+	assert.Equal(http.StatusRequestEntityTooLarge, err.StatusCode())
+}
+
+func testIOAccountingTracking(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output       bytes.Buffer
+		bytesRead    = new(testObserver)
+		bytesWritten = new(testObserver)
+
+		decorated = IOAccounting{
+			Metrics: IOMetrics{
+				BytesRead:    bytesRead,
+				BytesWritten: bytesWritten,
+			},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			rio, ok := RequestIOFromContext(request.Context())
+			require.True(ok)
+			require.NotNil(rio)
+
+			body, err := ioutil.ReadAll(request.Body)
+			require.NoError(err)
+			assert.Equal("request body", string(body))
+			assert.Equal(int64(12), rio.BytesRead())
+
+			_, ok = response.(TrackingWriter)
+			assert.True(ok)
+
+			response.Write([]byte("response"))
+			assert.Equal(int64(8), rio.BytesWritten())
+
+			xlog.GetDefault(request.Context(), nil).Log(xlog.MessageKey(), "done")
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/test", strings.NewReader("request body"))
+	)
+
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("response", response.Body.String())
+
+	var entry map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(float64(12), entry[bytesReadKey])
+	assert.Equal(float64(8), entry[bytesWrittenKey])
+
+	assert.Equal([]float64{12}, bytesRead.observations)
+	assert.Equal([]float64{8}, bytesWritten.observations)
+}
+
+func testIOAccountingHead(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output       bytes.Buffer
+		bytesWritten = new(testObserver)
+
+		decorated = IOAccounting{
+			Metrics: IOMetrics{
+				BytesWritten: bytesWritten,
+			},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Header().Set("X-Test", "value")
+			response.WriteHeader(299)
+			c, err := response.Write([]byte("discarded body"))
+			assert.Equal(len("discarded body"), c)
+			assert.NoError(err)
+
+			xlog.GetDefault(request.Context(), nil).Log(xlog.MessageKey(), "done")
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("HEAD", "/test", nil)
+	)
+
+	request = request.WithContext(xlog.With(request.Context(), log.NewJSONLogger(&output)))
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+	assert.Equal("value", response.Header().Get("X-Test"))
+
+	var entry map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(float64(0), entry[bytesWrittenKey])
+	assert.Equal([]float64{0}, bytesWritten.observations)
+}
+
+func testIOAccountingNoLogger(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = IOAccounting{}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			_, ok := RequestIOFromContext(request.Context())
+			assert.True(ok)
+			assert.Nil(xlog.GetDefault(request.Context(), nil))
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+}
+
+func testIOAccountingContentLength(t *testing.T, onExceeded http.Handler, expectedStatusCode int) {
+	var (
+		assert = assert.New(t)
+
+		decorated = IOAccounting{MaxBytes: 5, OnExceeded: onExceeded}.ThenFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("POST", "/test", strings.NewReader("too large")))
+	assert.Equal(expectedStatusCode, response.Code)
+}
+
+func testIOAccountingReadLimit(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = IOAccounting{MaxBytes: 5}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			_, err := ioutil.ReadAll(request.Body)
+
+			var ile IOLimitError
+			assert.True(errors.As(err, &ile))
+			assert.Equal(int64(5), ile.Max)
+			response.WriteHeader(ile.StatusCode())
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/test", strings.NewReader("too large"))
+	)
+
+	// simulate a body of unknown length, e.g. chunked
+	request.ContentLength = -1
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusRequestEntityTooLarge, response.Code)
+}
+
+func testIOAccountingReadExactly(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = IOAccounting{MaxBytes: 5}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			body, err := ioutil.ReadAll(request.Body)
+			assert.NoError(err)
+			assert.Equal("exact", string(body))
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("POST", "/test", strings.NewReader("exact")))
+	assert.Equal(299, response.Code)
+}
+
+func testIOAccountingWriteLimit(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = IOAccounting{MaxBytes: 10}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			ioutil.ReadAll(request.Body)
+
+			n, err := response.Write([]byte("12345"))
+			assert.Equal(5, n)
+			assert.NoError(err)
+
+			n, err = response.Write([]byte("678"))
+			assert.Zero(n)
+			assert.IsType(IOLimitError{}, err)
+
+			_, ok := response.(TrackingWriter)
+			assert.True(ok)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("POST", "/test", strings.NewReader("abc")))
+	assert.Equal("12345", response.Body.String())
+}
+
+func TestIOAccounting(t *testing.T) {
+	t.Run("Tracking", testIOAccountingTracking)
+	t.Run("Head", testIOAccountingHead)
+	t.Run("NoLogger", testIOAccountingNoLogger)
+	t.Run("ContentLength", func(t *testing.T) {
+		testIOAccountingContentLength(t, nil, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("ContentLengthCustom", func(t *testing.T) {
+		testIOAccountingContentLength(t, Constant{StatusCode: 599}.NewHandler(), 599)
+	})
+
+	t.Run("ReadLimit", testIOAccountingReadLimit)
+	t.Run("ReadExactly", testIOAccountingReadExactly)
+	t.Run("WriteLimit", testIOAccountingWriteLimit)
+}
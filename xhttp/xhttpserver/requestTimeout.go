@@ -0,0 +1,165 @@
+package xhttpserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+const (
+	// RequestTimeoutHeader is the conventional header through which clients send the time, as a Go duration
+	// or an integral number of seconds, that they will wait for a response
+	RequestTimeoutHeader = "X-Request-Timeout"
+
+	// GrpcTimeoutHeader is the header used by gRPC clients to send their timeout, e.g. 250m or 5S
+	GrpcTimeoutHeader = "Grpc-Timeout"
+)
+
+var (
+	errInvalidRequestTimeout = errors.New("Invalid request timeout")
+)
+
+// grpcTimeoutUnits maps the unit suffixes of the Grpc-Timeout header onto durations
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// maxTimeout is the largest time.Duration, to which timeouts too large to represent are reduced
+const maxTimeout = time.Duration(math.MaxInt64)
+
+// multiplyTimeout computes n units, reducing results that would overflow a time.Duration to maxTimeout.
+// Nonpositive results are invalid, since a client cannot sensibly ask for no time at all.
+func multiplyTimeout(n int64, unit time.Duration) (time.Duration, error) {
+	switch {
+	case n <= 0:
+		return 0, errInvalidRequestTimeout
+
+	case n > int64(maxTimeout/unit):
+		return maxTimeout, nil
+
+	default:
+		return time.Duration(n) * unit, nil
+	}
+}
+
+// parseGrpcTimeout parses a value in the format of the Grpc-Timeout header, which is at most 8 digits
+// followed by a single unit character.  Values too large to represent are reduced to maxTimeout.
+func parseGrpcTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, errInvalidRequestTimeout
+	}
+
+	unit, ok := grpcTimeoutUnits[v[len(v)-1]]
+	if !ok {
+		return 0, errInvalidRequestTimeout
+	}
+
+	// the 8 digit limit means this cannot fail with a range error
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if err != nil {
+		return 0, errInvalidRequestTimeout
+	}
+
+	return multiplyTimeout(int64(n), unit)
+}
+
+// parseRequestTimeout parses a timeout header value, which is either a Go duration such as 1.5s
+// or an integral number of seconds.  Numbers of seconds too large to represent are reduced to maxTimeout,
+// while nonpositive values and Go durations that overflow are invalid.
+func parseRequestTimeout(v string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return multiplyTimeout(seconds, time.Second)
+	} else if err.(*strconv.NumError).Err == strconv.ErrRange && len(v) > 0 && v[0] != '-' {
+		return maxTimeout, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, errInvalidRequestTimeout
+	}
+
+	return d, nil
+}
+
+// RequestTimeout is an Alice-style decorator that applies the timeout sent by a client, in a request header,
+// as the deadline of the request's context.  This allows handlers and downstream calls to respect the client's
+// budget.  Requests whose context is already done on arrival, e.g. because the client disconnected, are rejected
+// without invoking the decorated handler.
+//
+// If Header is GrpcTimeoutHeader, values are parsed in the gRPC format.  Otherwise, values may be Go durations,
+// e.g. 1500ms, or integral numbers of seconds.  Requests without the header, or with an invalid value, are passed
+// through unchanged.  Zero and negative timeouts are invalid, while timeouts too large to represent are reduced to
+// Max, if set.
+type RequestTimeout struct {
+	// Header is the name of the request header carrying the client's timeout.  If unset, no decoration is done.
+	Header string
+
+	// Max is the largest timeout honored from a client.  Larger values are reduced to this maximum.  If nonpositive,
+	// client timeouts are not bounded.
+	Max time.Duration
+
+	// OnExpired is the optional handler for requests whose context is already done.  If unset, a 408 is returned.
+	OnExpired http.Handler
+}
+
+func (rt RequestTimeout) Then(next http.Handler) http.Handler {
+	if len(rt.Header) == 0 {
+		return next
+	}
+
+	var (
+		header    = textproto.CanonicalMIMEHeaderKey(rt.Header)
+		parse     = parseRequestTimeout
+		onExpired = rt.OnExpired
+	)
+
+	if header == GrpcTimeoutHeader {
+		parse = parseGrpcTimeout
+	}
+
+	if onExpired == nil {
+		onExpired = Constant{StatusCode: http.StatusRequestTimeout}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		v := request.Header.Get(header)
+		if len(v) == 0 {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		timeout, err := parse(v)
+		if err != nil {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		if rt.Max > 0 && timeout > rt.Max {
+			timeout = rt.Max
+		}
+
+		if request.Context().Err() != nil {
+			MarkRejected(request.Context(), "requestTimeout")
+			onExpired.ServeHTTP(response, request)
+			return
+		}
+
+		// context deadlines are enforced by the runtime's timers, so this uses real time rather than a Clock
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(response, request.WithContext(ctx))
+	})
+}
+
+func (rt RequestTimeout) ThenFunc(next http.HandlerFunc) http.Handler {
+	return rt.Then(next)
+}
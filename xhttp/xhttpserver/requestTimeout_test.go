@@ -0,0 +1,199 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGrpcTimeout(t *testing.T) {
+	testData := []struct {
+		value    string
+		expected time.Duration
+		invalid  bool
+	}{
+		{value: "2H", expected: 2 * time.Hour},
+		{value: "3M", expected: 3 * time.Minute},
+		{value: "5S", expected: 5 * time.Second},
+		{value: "250m", expected: 250 * time.Millisecond},
+		{value: "17u", expected: 17 * time.Microsecond},
+		{value: "99999999n", expected: 99999999 * time.Nanosecond},
+		{value: "99999999H", expected: maxTimeout},
+		{value: "99999999M", expected: 99999999 * time.Minute},
+		{value: "", invalid: true},
+		{value: "0S", invalid: true},
+		{value: "S", invalid: true},
+		{value: "5s", invalid: true},
+		{value: "123456789S", invalid: true},
+		{value: "-5S", invalid: true},
+		{value: "abcS", invalid: true},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := parseGrpcTimeout(record.value)
+			if record.invalid {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(record.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseRequestTimeout(t *testing.T) {
+	testData := []struct {
+		value    string
+		expected time.Duration
+		invalid  bool
+	}{
+		{value: "15", expected: 15 * time.Second},
+		{value: "1500ms", expected: 1500 * time.Millisecond},
+		{value: "2m", expected: 2 * time.Minute},
+		{value: "9999999999999", expected: maxTimeout},
+		{value: "99999999999999999999", expected: maxTimeout},
+		{value: "0", invalid: true},
+		{value: "0s", invalid: true},
+		{value: "-1", invalid: true},
+		{value: "-1s", invalid: true},
+		{value: "-99999999999999999999", invalid: true},
+		{value: "9999999999h", invalid: true},
+		{value: "", invalid: true},
+		{value: "soon", invalid: true},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := parseRequestTimeout(record.value)
+			if record.invalid {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(record.expected, actual)
+			}
+		})
+	}
+}
+
+func testRequestTimeoutNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next           = Constant{}.NewHandler()
+		requestTimeout = RequestTimeout{Max: time.Minute}.Then(next)
+	)
+
+	assert.Equal(next, requestTimeout)
+}
+
+func testRequestTimeoutPassThrough(t *testing.T, value string) {
+	var (
+		assert = assert.New(t)
+
+		requestTimeout = RequestTimeout{Header: RequestTimeoutHeader, Max: time.Minute}.ThenFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				_, ok := request.Context().Deadline()
+				assert.False(ok)
+				response.WriteHeader(299)
+			},
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	if len(value) > 0 {
+		request.Header.Set(RequestTimeoutHeader, value)
+	}
+
+	requestTimeout.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func testRequestTimeoutDeadline(t *testing.T, header, value string, max, expected time.Duration) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		before         = time.Now()
+		requestTimeout = RequestTimeout{Header: header, Max: max}.ThenFunc(
+			func(response http.ResponseWriter, request *http.Request) {
+				deadline, ok := request.Context().Deadline()
+				require.True(ok)
+				assert.False(deadline.Before(before.Add(expected)))
+				assert.False(deadline.After(time.Now().Add(expected)))
+				response.WriteHeader(299)
+			},
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request.Header.Set(header, value)
+	requestTimeout.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func testRequestTimeoutCanceled(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		requestTimeout = RequestTimeout{
+			Header:    RequestTimeoutHeader,
+			OnExpired: Constant{StatusCode: 599}.NewHandler(),
+		}.ThenFunc(
+			func(http.ResponseWriter, *http.Request) {
+				assert.Fail("The decorated handler should not have been called")
+			},
+		)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		response    = httptest.NewRecorder()
+		request     = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	)
+
+	cancel()
+	request.Header.Set(RequestTimeoutHeader, "10s")
+	requestTimeout.ServeHTTP(response, request)
+	assert.Equal(599, response.Code)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	t.Run("NoDecoration", testRequestTimeoutNoDecoration)
+	t.Run("NoHeader", func(t *testing.T) { testRequestTimeoutPassThrough(t, "") })
+	t.Run("InvalidHeader", func(t *testing.T) { testRequestTimeoutPassThrough(t, "soon") })
+	t.Run("Zero", func(t *testing.T) { testRequestTimeoutPassThrough(t, "0") })
+	t.Run("Negative", func(t *testing.T) { testRequestTimeoutPassThrough(t, "-5") })
+
+	t.Run("Deadline", func(t *testing.T) {
+		testRequestTimeoutDeadline(t, RequestTimeoutHeader, "1500ms", 0, 1500*time.Millisecond)
+	})
+
+	t.Run("Max", func(t *testing.T) {
+		testRequestTimeoutDeadline(t, RequestTimeoutHeader, "60", 10*time.Second, 10*time.Second)
+	})
+
+	t.Run("Grpc", func(t *testing.T) {
+		testRequestTimeoutDeadline(t, "grpc-timeout", "250m", time.Minute, 250*time.Millisecond)
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		testRequestTimeoutDeadline(t, RequestTimeoutHeader, "9999999999999", 10*time.Second, 10*time.Second)
+	})
+
+	t.Run("GrpcOverflow", func(t *testing.T) {
+		testRequestTimeoutDeadline(t, "grpc-timeout", "99999999H", 10*time.Second, 10*time.Second)
+	})
+
+	t.Run("Canceled", testRequestTimeoutCanceled)
+}
@@ -8,6 +8,12 @@ import (
 
 type ResponseHeaders struct {
 	Header http.Header
+
+	// PreserveCase is the optional list of header names that are written exactly as given here, rather than
+	// in canonical form.  This is only useful for interoperating with clients that mishandle canonical header
+	// names, e.g. that require WWW-authenticate.  Only headers in Header are affected, and HTTP/2 always sends
+	// lowercase names regardless.
+	PreserveCase []string
 }
 
 func (rh ResponseHeaders) Then(next http.Handler) http.Handler {
@@ -15,9 +21,27 @@ func (rh ResponseHeaders) Then(next http.Handler) http.Handler {
 		return next
 	}
 
-	header := xhttp.CanonicalizeHeaders(rh.Header)
+	var (
+		header    = xhttp.CanonicalizeHeaders(rh.Header)
+		preserved = make(http.Header, len(rh.PreserveCase))
+	)
+
+	for _, name := range rh.PreserveCase {
+		canonical := http.CanonicalHeaderKey(name)
+		if values, ok := header[canonical]; ok {
+			preserved[name] = values
+			delete(header, canonical)
+		}
+	}
+
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
 		xhttp.SetHeaders(response.Header(), header)
+
+		// direct map assignment bypasses the canonicalization done by http.Header.Set
+		for name, values := range preserved {
+			response.Header()[name] = values
+		}
+
 		next.ServeHTTP(response, request)
 	})
 }
@@ -2,6 +2,9 @@ package xhttpserver
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -62,7 +65,45 @@ func testResponseHeadersThenFunc(t *testing.T, responseHeaders ResponseHeaders)
 	}
 }
 
+func testResponseHeadersPreserveCase(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = ResponseHeaders{
+			Header: http.Header{
+				"Www-Authenticate": []string{"Basic"},
+				"x-canonical":      []string{"value"},
+			},
+			PreserveCase: []string{"WWW-authenticate", "X-Not-Configured"},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		server = httptest.NewServer(decorated)
+	)
+
+	defer server.Close()
+	require.NotNil(decorated)
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+	require.NoError(err)
+
+	raw, err := ioutil.ReadAll(conn)
+	require.NoError(err)
+	assert.Contains(string(raw), "\r\nWWW-authenticate: Basic\r\n")
+	assert.Contains(string(raw), "\r\nX-Canonical: value\r\n")
+	assert.NotContains(string(raw), "Www-Authenticate")
+	assert.NotContains(string(raw), "X-Not-Configured")
+}
+
 func TestResponseHeaders(t *testing.T) {
+	t.Run("PreserveCase", testResponseHeadersPreserveCase)
+
 	testData := []ResponseHeaders{
 		ResponseHeaders{},
 		ResponseHeaders{
@@ -2,11 +2,16 @@ package xhttpserver
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
+	"os"
+	"syscall"
 	"time"
 
+	"github.com/xmidt-org/themis/xlog"
 	"github.com/xmidt-org/themis/xlog/xloghttp"
+	"github.com/xmidt-org/themis/xmetrics"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -28,11 +33,62 @@ type Interface interface {
 type Options struct {
 	Address string
 	Network string
-	Tls     *Tls
+
+	// BindRetry optionally retries binding Address when a server starts, which helps during fast restarts when the
+	// previous instance's socket lingers.  If unset, a failure to bind is returned immediately.  See BindRetry.
+	BindRetry *BindRetry
+
+	// SocketMode, SocketUID, and SocketGID control access to the socket file when Network is unix, so that only
+	// particular processes can connect.  SocketMode is the file mode, e.g. 0660, and SocketUID and SocketGID are
+	// the numeric owner and group, which may be 0 for root.  If SocketUID or SocketGID is unset, the corresponding
+	// ownership is left unchanged.  When any of these are set, the socket is bound to a temporary path, its
+	// permissions are applied, and it is then renamed to Address.  This means clients never see the socket with
+	// the wrong permissions, and any stale socket file at Address is replaced.  A socket at Address that still
+	// accepts connections is never replaced; binding fails as if the address were in use.  These options have no
+	// effect when a ListenerFactory is used.
+	SocketMode os.FileMode
+	SocketUID  *int
+	SocketGID  *int
+
+	// Interface is the optional name of a network interface, e.g. eth1, whose address this server binds to.
+	// When set, only the port of Address is used.  IPv4 addresses are preferred unless Network is tcp6.
+	// This is useful on multi-homed hosts whose addresses are assigned dynamically.
+	Interface string
+
+	Tls *Tls
 
 	LogConnectionState    bool
 	DisableHTTPKeepAlives bool
-	MaxHeaderBytes        int
+
+	// MaxHeaderBytes bounds the size of a request's header, including the request line, and defaults to
+	// http.DefaultMaxHeaderBytes.  Note that this is only a limit.  net/http reads every connection through a
+	// fixed 4KB buffer that cannot be configured, so raising this value does not change how headers are buffered.
+	MaxHeaderBytes int
+
+	// ReadBufferSize and WriteBufferSize set the operating system's receive and send buffer sizes, i.e. SO_RCVBUF and
+	// SO_SNDBUF, for each accepted connection.  If unset, the operating system defaults are used, which is the
+	// recommended setting.  BenchmarkSocketBufferSizes shows that buffer size has no measurable effect on small
+	// requests, while buffers that are too small for large request bodies reduce throughput dramatically.  Run
+	// that benchmark on representative hardware before choosing an explicit size.  Connections from a custom
+	// ListenerFactory that do not support socket buffers are unaffected.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// CloseIdleOnShutdown enables connection tracking so that, on shutdown, idle keep-alive connections
+	// are closed immediately and only connections with in-flight requests are waited on.
+	CloseIdleOnShutdown bool
+
+	// ShutdownTimeout bounds how long this server waits for in-flight requests to complete when stopped, in
+	// addition to any deadline imposed by the application.  ShutdownDeadline is an absolute cap on stopping,
+	// after which the server is forcibly closed, abandoning any remaining connections.  The server is also forcibly
+	// closed if ShutdownTimeout expires first.  If ShutdownDeadline is unset, the server is never forcibly closed.
+	// See Shutdown.
+	ShutdownTimeout  time.Duration
+	ShutdownDeadline time.Duration
+
+	// ShutdownOrder is this server's position in a ShutdownSequence, if one is present in the application.
+	// Servers with lower values are stopped first.  The default is zero.
+	ShutdownOrder int
 
 	IdleTimeout           time.Duration
 	ReadHeaderTimeout     time.Duration
@@ -40,37 +96,641 @@ type Options struct {
 	WriteTimeout          time.Duration
 	MaxConcurrentRequests int
 
+	// HTTP2MaxConcurrentStreams bounds the number of streams each HTTP/2 client may have open at once.  If unset,
+	// the default of 250 is used.  HTTP2IdleTimeout is how long an HTTP/2 connection may have no open streams
+	// before the server sends a GOAWAY and closes it.  If unset, IdleTimeout is used, or ReadTimeout if IdleTimeout
+	// is also unset.
+	//
+	// When a server is stopped, Shutdown immediately sends a GOAWAY to each HTTP/2 connection, so that clients stop
+	// opening new streams, and then waits for the streams already open to finish.  Once they have, the server waits
+	// one second for the client to close the connection before closing it itself.  That timing cannot be changed,
+	// but a lower stream limit bounds how much work each connection can have in flight when draining begins, and
+	// a shorter HTTP2IdleTimeout closes connections that would otherwise sit idle until Shutdown.  ShutdownTimeout
+	// and ShutdownDeadline bound how long draining may take.
+	//
+	// Setting any HTTP/2 option configures the server with golang.org/x/net/http2 rather than net/http's implicit
+	// HTTP/2 support.  In either case, HTTP/2 is only served over TLS when "h2" is among Tls.NextProtos, and is
+	// disabled if TLSNextProto is set, in which case the HTTP/2 options are ignored.
+	HTTP2MaxConcurrentStreams int
+	HTTP2IdleTimeout          time.Duration
+
+	// HTTP2MaxReadFrameSize is the largest HTTP/2 frame this server reads, between 16KiB and 16MiB.
+	// HTTP2MaxUploadBufferPerConnection and HTTP2MaxUploadBufferPerStream are the flow control windows for request
+	// data received on each connection, at least 64KiB, and on each stream, respectively.  Both windows must be
+	// less than 2GiB.  If unset, the defaults of 1MiB are used for the frame size and each window.
+	//
+	// A client can only send as much request data as the flow control windows allow before waiting a round trip for
+	// the server to acknowledge it, so larger windows improve upload throughput on high latency links.  The cost is
+	// memory:  each connection may buffer up to its window of unread request data, so the worst case is roughly
+	// the per-connection window times the number of connections.  The per-stream window stops one large upload from
+	// consuming a connection's entire window.  Raise these only for servers that receive large, streamed request
+	// bodies, and keep MaxConcurrentRequests or ConcurrencyLimit in place to bound the total.
+	HTTP2MaxReadFrameSize             int
+	HTTP2MaxUploadBufferPerConnection int
+	HTTP2MaxUploadBufferPerStream     int
+
+	// MaxConnectionAge and HTTP2MaxConnectionAge are the maximum ages of HTTP/1.x and HTTP/2 connections,
+	// respectively.  HTTP2MaxConnectionRequests caps the total number of streams served over each HTTP/2
+	// connection.  Typically, HTTP/2 connections are allowed to live longer, since each one replaces many
+	// HTTP/1.x connections.  Connections past these limits are closed gracefully after their next response, and
+	// idle connections are closed as soon as they reach their maximum age.  This allows connection-based load
+	// balancers to rebalance clients.  If unset, connections have no maximum age.  See MaxConnectionAge.
+	MaxConnectionAge           time.Duration
+	HTTP2MaxConnectionAge      time.Duration
+	HTTP2MaxConnectionRequests int
+
+	// ConcurrencyLimit bounds the number of requests executing concurrently, with up to ConcurrencyQueue requests
+	// waiting for at most ConcurrencyQueueTimeout.  Requests beyond these bounds receive a 503.  Unlike
+	// MaxConcurrentRequests, excess requests are queued rather than immediately rejected.  See ConcurrencyLimiter.
+	ConcurrencyLimit        int
+	ConcurrencyQueue        int
+	ConcurrencyQueueTimeout time.Duration
+
+	// ConcurrencyMetrics are the optional gauges updated by the ConcurrencyLimit stage.  Any labels must already
+	// be applied.  This field cannot be unmarshalled and must be set in code.
+	ConcurrencyMetrics ConcurrencyMetrics `json:"-"`
+
+	// RequestTimeoutHeader is the optional name of the request header through which clients send how long they
+	// will wait for a response, e.g. X-Request-Timeout or Grpc-Timeout.  That timeout, bounded by MaxRequestTimeout
+	// when set, becomes the deadline of the request's context.  See RequestTimeout.
+	RequestTimeoutHeader string
+	MaxRequestTimeout    time.Duration
+
+	// HandlerTimeout is the maximum time a handler has to produce its response.  Requests that exceed it receive
+	// a 503, and anything the handler writes afterward is discarded.  See HandlerTimeout.
+	HandlerTimeout time.Duration
+
+	// BodyReadTimeout is the maximum time allowed to read a request's entire body.  Requests whose bodies are not
+	// read in time receive a 408.  When BodyReadMinBytesPerSecond is also set, BodyReadTimeout is instead a grace
+	// period, and bodies must then arrive at least that fast.  See BodyTimeout.
+	BodyReadTimeout           time.Duration
+	BodyReadMinBytesPerSecond int64
+
+	// MaxConcurrentHandshakes limits the number of TLS handshakes in progress at any time, which protects
+	// existing requests from being starved of CPU by a flood of new connections.  New connections wait up to
+	// HandshakeWaitTimeout, by default DefaultHandshakeWaitTimeout, for a handshake to begin, after which they are
+	// closed.  If HandshakeWaitTimeout is negative, connections wait indefinitely.  At most MaxPendingHandshakes,
+	// by default DefaultMaxPendingHandshakes, connections wait at once, and further connections are closed as soon
+	// as they are accepted.  HandshakeTimeout bounds each handshake and defaults to DefaultHandshakeTimeout.  These
+	// options have no effect for non-TLS servers.
+	MaxConcurrentHandshakes int
+	MaxPendingHandshakes    int
+	HandshakeWaitTimeout    time.Duration
+	HandshakeTimeout        time.Duration
+
+	// HandshakeWait is an optional metric that observes how long, in milliseconds, each new TLS connection
+	// waited for a handshake to begin.  This field cannot be unmarshalled and must be set in code or
+	// supplied via a HandshakeWaitFactory.
+	HandshakeWait xmetrics.Observer `json:"-"`
+
+	// SlowHandshakeThreshold causes a warning to be logged, with the client address and negotiated TLS version,
+	// for each handshake that takes at least this long.  Handshakes are timed from the client's hello until the
+	// connection is verified.  If unset, handshakes are not timed.  This option has no effect for non-TLS servers.
+	SlowHandshakeThreshold time.Duration
+
 	DisableTCPKeepAlives bool
 	TCPKeepAlivePeriod   time.Duration
 
-	Header               http.Header
+	// TCPKeepAliveJitter is the fraction of TCPKeepAlivePeriod by which each connection's keep-alive period
+	// is randomly varied.  This avoids synchronized keep-alive probes across many connections.  For example,
+	// a value of 0.1 with a period of 3 minutes results in periods between 2m42s and 3m18s.  Values outside
+	// of the interval (0, 1) disable jitter, which is the default.
+	TCPKeepAliveJitter float64
+
+	// TCPNoDelay controls TCP_NODELAY on each accepted connection.  Go enables it by default, which disables
+	// Nagle's algorithm and favors latency for small writes.  Setting this to false enables Nagle's algorithm,
+	// which can improve throughput for bulk transfers.  If unset, Go's default is left in place.  Connections
+	// from a custom ListenerFactory that do not support this option are unaffected.
+	TCPNoDelay *bool
+
+	// Clock is the optional source of time for this server's deadlines and timeouts, which is primarily useful
+	// for tests.  If unset, SystemClock is used.  This field cannot be unmarshalled and must be set in code.
+	Clock Clock `json:"-"`
+
+	// ListenBacklog is the size of the kernel's queue of connections waiting to be accepted.  Raising this value
+	// reduces dropped connections during connection storms.  If unset, the Go runtime's default is used, which on
+	// Linux is /proc/sys/net/core/somaxconn.  The kernel silently caps this value, e.g. at somaxconn on Linux, so that
+	// setting may also need to be raised.  This option is only supported on Unix platforms and is ignored when a
+	// ListenerFactory is set.
+	ListenBacklog int
+
+	// ResponsiveAccept makes the listener wait for connections in short intervals, checking between each one
+	// whether it has been closed.  On some platforms, closing a listener does not interrupt a pending accept, which
+	// delays shutdown.  This option makes shutdown prompt everywhere at the cost of periodically waking the accept
+	// loop.  Listeners from a ListenerFactory that do not support deadlines, e.g. via SetDeadline, are unaffected.
+	ResponsiveAccept bool
+
+	// ProxyProtocolTrusted lists the IP addresses and CIDRs of load balancers that may begin each connection with a
+	// PROXY protocol header, either the text version 1 or the binary version 2, which carries the original client's
+	// address.  Only peers in these networks have their headers honored.  Any other peer is treated as a direct
+	// client, and a PROXY header it sends is logged and its connection closed, so that clients cannot forge their
+	// address.  If empty, PROXY headers are not processed.
+	//
+	// The header is read along with the first request, so the RemoteAddr of a new connection waits for it.  Hooks
+	// passed to New, which run as connections are accepted, must not call RemoteAddr for new connections.
+	ProxyProtocolTrusted []string
+
+	// ControlFunc is an optional function that is invoked on the raw network connection prior to binding.
+	// This allows callers to set arbitrary socket options, e.g. TCP_FASTOPEN.  This function is composed
+	// with any control function on the net.ListenConfig passed to NewListener.  This field cannot be
+	// unmarshalled and must be set in code.
+	ControlFunc func(network, address string, c syscall.RawConn) error `json:"-"`
+
+	// ListenerFactory is an optional strategy for creating the server's net.Listener, e.g. an in-memory
+	// listener for tests or a custom transport.  When set, it is used instead of binding a TCP socket, and
+	// ControlFunc is ignored.  TLS and TCP keep-alive options still apply to accepted connections.  This field
+	// cannot be unmarshalled and must be set in code.
+	ListenerFactory func(ctx context.Context, network, address string) (net.Listener, error) `json:"-"`
+
+	// StartupGate causes requests to be rejected with a 503 until Gate is opened, usually by OpenOnStart.
+	// StartupGateExempt lists URI paths, e.g. health checks, that are never rejected.  Like all exempt paths below,
+	// these are matched against the full request path, before any StripPrefix is applied.  This option has no
+	// effect unless a Gate is supplied.  See StartupGate.
+	StartupGate           bool
+	StartupGateRetryAfter time.Duration
+	StartupGateExempt     []string
+
+	// Drain causes requests to be rejected with a 503 once ShutdownSignal is triggered, usually by CancelOnStop.
+	// DrainExempt lists URI paths, e.g. health checks, that are never rejected.  This option has no effect unless
+	// a ShutdownSignal is supplied.  See Drain.
+	Drain           bool
+	DrainRetryAfter time.Duration
+	DrainExempt     []string
+
+	// Maintenance causes requests to be rejected with a 503 while MaintenanceSwitch is enabled.  MaintenanceExempt
+	// lists URI paths, e.g. health checks, and MaintenanceTrusted lists the IP addresses and CIDRs of clients, e.g.
+	// administrators, that are never rejected.  MaintenanceMessage is the optional body of rejected responses, served
+	// as MaintenanceContentType, which defaults to text/plain.  This option has no effect unless a MaintenanceSwitch
+	// is supplied.  See MaintenanceMode.
+	Maintenance            bool
+	MaintenanceRetryAfter  time.Duration
+	MaintenanceExempt      []string
+	MaintenanceTrusted     []string
+	MaintenanceMessage     string
+	MaintenanceContentType string
+
+	// Gate, ShutdownSignal, and MaintenanceSwitch are the application's components that control the StartupGate,
+	// Drain, and Maintenance options.  Unmarshal supplies them from the enclosing application.  These fields cannot
+	// be unmarshalled and must be set in code.
+	Gate              *Gate           `json:"-"`
+	ShutdownSignal    *ShutdownSignal `json:"-"`
+	MaintenanceSwitch *Maintenance    `json:"-"`
+
+	// TLSNextProto optionally maps ALPN protocol names to connection handlers, exactly like http.Server.TLSNextProto.
+	// This allows different protocols to be served on the same TLS port.  Any protocol names not already in
+	// Tls.NextProtos are advertised after the configured ones.  Note that, as with net/http, setting this field
+	// disables automatic HTTP/2 support.  This field cannot be unmarshalled and must be set in code.
+	TLSNextProto map[string]func(*http.Server, *tls.Conn, http.Handler) `json:"-"`
+
+	// Favicon, when set, answers requests for /favicon.ico before any other part of the server chain, so that
+	// browser requests for an icon produce neither 404s nor log entries.  An empty Favicon results in a 204.
+	// See Favicon.
+	Favicon *Favicon
+
+	// OptionsPath is the optional URI path at which these Options are served as JSON, with sensitive values
+	// redacted by RedactOptions.  This is useful to verify the effective configuration of a running server.  If
+	// unset, no such endpoint is created.
+	OptionsPath string
+
+	Header http.Header
+
+	// Charset ensures that textual responses declare charset=utf-8 in their Content-Type.  CharsetTypes are
+	// the media type prefixes considered textual, defaulting to DefaultCharsetTypes.  See Charset.
+	Charset      bool
+	CharsetTypes []string
+
+	// AutoFlush flushes streaming responses after each write, so that handlers need not flush explicitly.
+	// AutoFlushTypes are the media types considered streaming, defaulting to DefaultAutoFlushTypes.  See AutoFlush.
+	AutoFlush      bool
+	AutoFlushTypes []string
+
+	// ServerHeader is the value of the Server header sent with every response, overriding any Server header
+	// in Header.  Handlers may still set their own value.  If unset, no Server header is sent, which avoids
+	// leaking implementation or version information.
+	ServerHeader string
+
+	// TrustedProxies lists the IP addresses and CIDRs of proxies whose Forwarded and X-Forwarded-Proto headers
+	// determine the scheme of each request.  This is necessary when TLS is terminated by a proxy.  If empty,
+	// those headers are ignored.  See ForwardedScheme.
+	TrustedProxies []string
+
+	// CanonicalHost is the host to which requests for any of the CanonicalHostFrom hostnames, e.g. www.example.com
+	// or *.example.com, are permanently redirected.  The redirect uses the scheme determined via TrustedProxies.
+	// If either field is unset, no redirects are done.  See CanonicalHost.
+	CanonicalHost     string
+	CanonicalHostFrom []string
+
+	// StripPrefix is the optional path prefix, e.g. /serviceA, removed from each request before it is routed.
+	// Requests not under this prefix receive a 404.  Every other stage of the standard chain, including access
+	// logging, sees the full path the client requested.  See StripPrefix.
+	StripPrefix string
+
+	// ClientCertificatePaths are the URI path prefixes that require a verified TLS client certificate, which allows
+	// mutual TLS to be enforced per route on a single listener.  See RequireClientCertificate and
+	// Tls.ClientCertificateOptional.
+	ClientCertificatePaths []string
+
+	// BaseContext is the optional source of the context from which every request's context on this server
+	// derives, which allows values such as a deployment region to be seeded once rather than by middleware.
+	// It is invoked each time the server starts listening and must not return nil.  This field cannot be
+	// unmarshalled and must be set in code.
+	BaseContext func() context.Context `json:"-"`
+
+	// ServerInfo seeds a ServerInfo, with this server's name and listening address, into the base context of
+	// every request.  Handlers retrieve it with ServerInfoFromContext.
+	ServerInfo bool
+
+	// ConnectionPolicy is evaluated once for each connection, after any TLS handshake, and decides whether requests
+	// on that connection are allowed, e.g. based on the negotiated cipher suite.  Every request on a rejected connection,
+	// including each request on a keep-alive connection, receives the same error response.  This field cannot be
+	// unmarshalled and must be set in code.  See ConnectionPolicyCheck.
+	ConnectionPolicy ConnectionPolicy `json:"-"`
+
+	// ExpectCT is the optional Certificate Transparency policy advertised via the Expect-CT header on
+	// TLS requests.  See ExpectCT.
+	ExpectCT *ExpectCT
+
+	// DisableTracking omits the TrackingWriter from the chain.  Options that depend on the response status,
+	// e.g. LogMinStatusCode, override this and still install it, and a warning is logged at startup.  The server
+	// decorators in xmetricshttp capture the status themselves, so metrics remain accurate either way.
 	DisableTracking      bool
 	DisableHandlerLogger bool
+
+	// LogParameterSets names the groups of logging fields, from the ParameterBuilderSets component, that are added
+	// to each request's contextual logger.  This allows the logged fields to differ by environment via configuration.
+	// It is an error to name a set that does not exist.
+	LogParameterSets []string
+
+	// LogMinStatusCode is the optional response status below which entries logged with a request's contextual
+	// logger are discarded, e.g. 400 to only log failed requests on high-traffic services.  Unlike sampling, this
+	// is deterministic.  The status is taken from the tracking writer, which is installed for this option even if
+	// DisableTracking is set.  Metrics are unaffected.  See xloghttp.Logging.
+	LogMinStatusCode int
+
+	// LogRejections logs an entry for each request rejected by middleware of the standard chain, naming that
+	// middleware under RejectedByKey.  Rejections by middleware that follows the logging stage, e.g. Drain, are
+	// logged with the request's contextual logger.  This option has no effect if DisableHandlerLogger is set.
+	// See LogRejections.
+	LogRejections bool
+
+	// DebugTrusted lists the IP addresses and CIDRs of clients that may ask, via the DebugHeader, for verbose
+	// logging of individual requests.  DebugMaxBodyBytes limits how much of each body is logged, and the values
+	// of the DebugRedactHeaders, by default DefaultDebugRedactHeaders, are never logged.  If DebugTrusted is
+	// empty, requests are never debugged.  This option has no effect if DisableHandlerLogger is set.
+	//
+	// DebugTrusted is matched against the immediate peer of each connection, never a forwarded address, so it
+	// must not contain the addresses of proxies or load balancers.  See DebugRequest.
+	DebugHeader        string
+	DebugTrusted       []string
+	DebugMaxBodyBytes  int
+	DebugRedactHeaders []string
+
+	// MethodOverride allows POST requests to be handled as another method named in the MethodOverrideHeader,
+	// for clients behind proxies that only pass GET and POST.  MethodOverrideMethods is the allowlist of methods
+	// a POST may become.  If unset, DefaultMethodOverrideHeader and DefaultMethodOverrideMethods are used.
+	// See MethodOverride.
+	MethodOverride        bool
+	MethodOverrideHeader  string
+	MethodOverrideMethods []string
+
+	// RequestIO enables tracking of each request's body I/O for logging and metrics.  MaxRequestIOBytes, which
+	// implies RequestIO, limits the combined bytes read and written by each request.  See IOAccounting.
+	RequestIO         bool
+	MaxRequestIOBytes int64
+
+	// RequestIOMetrics are the optional observers of each request's I/O when RequestIO is enabled.  Any labels
+	// must already be applied.  This field cannot be unmarshalled and must be set in code.
+	RequestIOMetrics IOMetrics `json:"-"`
+
+	// PreserveHeaderCase is the opt-in list of Header names that are written exactly as given in this list,
+	// rather than in canonical form, e.g. WWW-authenticate.  This exists for legacy clients that mishandle
+	// canonical header names.  Headers not in this list are always canonical.
+	PreserveHeaderCase []string
+
+	// Tracing enables an OpenTelemetry server span for each request.  See Trace.  Spans are no-ops unless
+	// the application registers a global tracer provider.  The response status is taken from the tracking writer,
+	// which is installed for this option even if DisableTracking is set.
+	Tracing bool
+
+	// AllowedContentTypes is the allowlist of request media types enforced for ContentTypeMethods.
+	// If unset, no Content-Type enforcement is done.
+	AllowedContentTypes []string
+
+	// ContentTypeMethods are the HTTP methods for which AllowedContentTypes is enforced.
+	// If unset, DefaultContentTypeMethods is used.
+	ContentTypeMethods []string
+
+	// MaxFormParams limits the combined number of query and URL-encoded form parameters of each request.  Requests
+	// whose query exceeds this limit receive a 400, while URL-encoded bodies that exceed it cause ParseForm to fail
+	// with a FormParamsError.  If unset, parameters are not limited.  See FormParamsLimit.
+	MaxFormParams int
+
+	// StrictFormParsing parses each request's form before its handler executes, rejecting requests whose form is
+	// malformed with a 400 and logging the parse error.  By default, form parsing is left to handlers, which may
+	// ignore ParseForm errors.  See StrictForm.
+	StrictFormParsing bool
+
+	// HeaderHardening rejects requests with suspicious or malformed headers with a 400, logging the reason.
+	// MaxHeaderValueBytes, which implies HeaderHardening, limits the length of each header value, and UniqueHeaders
+	// names the headers that may appear at most once.  If unset, DefaultMaxHeaderValueBytes and DefaultUniqueHeaders
+	// are used.  See HeaderHardening.
+	HeaderHardening     bool
+	MaxHeaderValueBytes int
+	UniqueHeaders       []string
+
+	// ErrorEncoder is the optional strategy used by the standard server chain to render error responses,
+	// e.g. when too many requests are in flight.  If unset, DefaultErrorEncoder is used, which writes the standard
+	// status text as a plain text body.  This field cannot be unmarshalled and must be set in code.
+	ErrorEncoder ErrorEncoder `json:"-"`
+
+	// RecoverPanics installs a Recovery stage after the logging stage, so panics are logged with the request's
+	// contextual logger and answered with a 500.  Panics with an HTTPError are rendered with that error's status
+	// code and message, which requires this stage.  If neither this nor OnPanic is set, panics are left to
+	// net/http, which logs them and closes the connection.
+	RecoverPanics bool
+
+	// OnPanic is the optional PanicReporter invoked when a handler panics, e.g. to alert an external service, before
+	// the 500 response is written.  Setting this implies RecoverPanics.  This field cannot be unmarshalled and must
+	// be set in code.
+	OnPanic PanicReporter `json:"-"`
+
+	// AccessLogger is the optional logger from which the contextual request loggers of the logging stage are
+	// derived.  This allows access logs to be routed separately from the server's own logging.  If unset, the
+	// server logger is used.  This field cannot be unmarshalled and must be set in code.
+	AccessLogger log.Logger `json:"-"`
+
+	// ListenerLogger is the optional logger for events of the Listener that are not tied to any request, e.g.
+	// rejected PROXY headers.  If unset, OnStart uses the server's logger, while NewListener discards these
+	// events.  This field cannot be unmarshalled and must be set in code.
+	ListenerLogger log.Logger `json:"-"`
+}
+
+// statusDependents returns the names of the enabled options that depend on the response status captured
+// by the tracking writer
+func (o Options) statusDependents() (names []string) {
+	if o.LogMinStatusCode > 0 && !o.DisableHandlerLogger {
+		names = append(names, "LogMinStatusCode")
+	}
+
+	if o.Tracing {
+		names = append(names, "Tracing")
+	}
+
+	return
 }
 
 // NewServerChain produces the standard constructor chain for a server, primarily using configuration.
+// The individual stages are available as exported functions, e.g. TrackingStage, for custom chains.
+//
+// The trusted network options, e.g. TrustedProxies and DebugTrusted, are not validated here.  Unmarshal.Provide
+// rejects any configuration with invalid networks, but when Options are built in code any invalid networks are
+// simply never trusted.
 func NewServerChain(o Options, l log.Logger, pb ...xloghttp.ParameterBuilder) alice.Chain {
+	header := o.Header
+	if len(o.ServerHeader) > 0 {
+		header = make(http.Header, len(o.Header)+1)
+		for name, values := range o.Header {
+			header[name] = values
+		}
+
+		header.Set("Server", o.ServerHeader)
+	}
+
 	chain := alice.New(
-		ResponseHeaders{Header: o.Header}.Then,
-		Busy{MaxConcurrentRequests: o.MaxConcurrentRequests}.Then,
+		HeaderStage(header, o.PreserveHeaderCase...),
 	)
 
-	if !o.DisableTracking {
-		chain = chain.Append(UseTrackingWriter)
+	// this precedes every stage that may reject requests
+	if o.LogRejections && !o.DisableHandlerLogger {
+		accessLogger := o.AccessLogger
+		if accessLogger == nil {
+			accessLogger = l
+		}
+
+		chain = chain.Append(LogRejections{
+			Logger:   accessLogger,
+			Builders: pb,
+		}.Then)
+	}
+
+	// this precedes every stage that reads request headers, so that malformed headers are rejected first
+	if o.HeaderHardening || o.MaxHeaderValueBytes > 0 {
+		chain = chain.Append(HeaderHardening{
+			MaxValueBytes: o.MaxHeaderValueBytes,
+			UniqueHeaders: o.UniqueHeaders,
+			ErrorEncoder:  o.ErrorEncoder,
+			Logger:        l,
+		}.Then)
+	}
+
+	// Unmarshal validates these networks, so any invalid entries are simply never trusted
+	if trusted, err := ParseNetworks(o.TrustedProxies); err == nil && len(trusted) > 0 {
+		chain = chain.Append(ForwardedScheme{Trusted: trusted}.Then)
+	}
+
+	chain = chain.Append(CanonicalHost{
+		Host: o.CanonicalHost,
+		From: o.CanonicalHostFrom,
+	}.Then)
+
+	if o.ExpectCT != nil {
+		chain = chain.Append(o.ExpectCT.Then)
+	}
+
+	if o.ConnectionPolicy != nil {
+		chain = chain.Append(ConnectionPolicyCheck{ErrorEncoder: o.ErrorEncoder}.Then)
+	}
+
+	if o.MaxConnectionAge > 0 || o.HTTP2MaxConnectionAge > 0 || o.HTTP2MaxConnectionRequests > 0 {
+		chain = chain.Append(MaxConnectionAge{
+			HTTP1:            o.MaxConnectionAge,
+			HTTP2:            o.HTTP2MaxConnectionAge,
+			HTTP2MaxRequests: o.HTTP2MaxConnectionRequests,
+			Clock:            o.Clock,
+		}.Then)
+	}
+
+	if len(o.ClientCertificatePaths) > 0 {
+		chain = chain.Append(RequireClientCertificate{
+			Paths:        o.ClientCertificatePaths,
+			OnMissing:    NewErrorHandler(o.ErrorEncoder, http.StatusUnauthorized),
+			OnUnverified: NewErrorHandler(o.ErrorEncoder, http.StatusForbidden),
+		}.Then)
+	}
+
+	if o.Favicon != nil {
+		chain = chain.Append(o.Favicon.Then)
+	}
+
+	chain = chain.Append(
+		BusyStage(o.MaxConcurrentRequests, o.ErrorEncoder),
+		ContentTypeStage(o.AllowedContentTypes, o.ContentTypeMethods, o.ErrorEncoder),
+		FormParamsLimit{
+			Max:        o.MaxFormParams,
+			OnExceeded: NewErrorHandler(o.ErrorEncoder, http.StatusBadRequest),
+		}.Then,
+		BodyTimeoutStage(o.BodyReadTimeout, o.BodyReadMinBytesPerSecond, o.ReadTimeout, o.ErrorEncoder, o.Clock),
+		RequestTimeout{
+			Header:    o.RequestTimeoutHeader,
+			Max:       o.MaxRequestTimeout,
+			OnExpired: NewErrorHandler(o.ErrorEncoder, http.StatusRequestTimeout),
+		}.Then,
+		ProtocolStage(),
+	)
+
+	// these must precede tracking, so that handlers still receive a TrackingWriter
+	if o.Charset {
+		chain = chain.Append(CharsetStage(o.CharsetTypes...))
+	}
+
+	if o.AutoFlush {
+		chain = chain.Append(AutoFlush{Types: o.AutoFlushTypes}.Then)
+	}
+
+	// features that depend on the response status still get a tracking writer, even if DisableTracking is set
+	if !o.DisableTracking || len(o.statusDependents()) > 0 {
+		chain = chain.Append(TrackingStage())
+	}
+
+	if o.Tracing {
+		chain = chain.Append(TracingStage())
 	}
 
 	if !o.DisableHandlerLogger {
-		chain = chain.Append(
-			xloghttp.Logging{Base: l, Builders: pb}.Then,
-		)
+		accessLogger := o.AccessLogger
+		if accessLogger == nil {
+			accessLogger = l
+		}
+
+		chain = chain.Append(xloghttp.Logging{
+			Base:          accessLogger,
+			Builders:      pb,
+			MinStatusCode: o.LogMinStatusCode,
+		}.Then)
+
+		// Unmarshal validates these networks, so any invalid entries are simply never trusted
+		if trusted, err := ParseNetworks(o.DebugTrusted); err == nil && len(trusted) > 0 {
+			chain = chain.Append(DebugRequest{
+				Header:       o.DebugHeader,
+				Trusted:      trusted,
+				MaxBodyBytes: o.DebugMaxBodyBytes,
+				Redact:       o.DebugRedactHeaders,
+				Clock:        o.Clock,
+			}.Then)
+		}
+	}
+
+	// the remaining stages follow the logging stage, so that panics, I/O totals, rejections, and method
+	// overrides are logged with the request's contextual logger
+	if o.RecoverPanics || o.OnPanic != nil {
+		chain = chain.Append(Recovery{
+			OnPanic:      NewErrorHandler(o.ErrorEncoder, http.StatusInternalServerError),
+			ErrorEncoder: o.ErrorEncoder,
+			Reporter:     o.OnPanic,
+		}.Then)
 	}
 
+	if o.RequestIO || o.MaxRequestIOBytes > 0 {
+		chain = chain.Append(IOAccounting{
+			MaxBytes:   o.MaxRequestIOBytes,
+			OnExceeded: NewErrorHandler(o.ErrorEncoder, http.StatusRequestEntityTooLarge),
+			Metrics:    o.RequestIOMetrics,
+		}.Then)
+	}
+
+	if o.StrictFormParsing {
+		chain = chain.Append(StrictForm{ErrorEncoder: o.ErrorEncoder}.Then)
+	}
+
+	if o.MethodOverride {
+		chain = chain.Append(MethodOverride{
+			Header:  o.MethodOverrideHeader,
+			Methods: o.MethodOverrideMethods,
+		}.Then)
+	}
+
+	// the gates precede HandlerTimeout and StripPrefix, so that their rejections are written directly and
+	// exempt paths are matched against the full request path
+	if o.StartupGate {
+		chain = chain.Append(StartupGate{
+			Gate:       o.Gate,
+			RetryAfter: o.StartupGateRetryAfter,
+			Exempt:     o.StartupGateExempt,
+			OnClosed:   NewErrorHandler(o.ErrorEncoder, http.StatusServiceUnavailable),
+		}.Then)
+	}
+
+	if o.Drain {
+		chain = chain.Append(Drain{
+			ShutdownSignal: o.ShutdownSignal,
+			RetryAfter:     o.DrainRetryAfter,
+			Exempt:         o.DrainExempt,
+			OnDraining:     NewErrorHandler(o.ErrorEncoder, http.StatusServiceUnavailable),
+		}.Then)
+	}
+
+	if o.Maintenance {
+		onMaintenance := NewErrorHandler(o.ErrorEncoder, http.StatusServiceUnavailable)
+		if len(o.MaintenanceMessage) > 0 {
+			contentType := o.MaintenanceContentType
+			if len(contentType) == 0 {
+				contentType = "text/plain; charset=utf-8"
+			}
+
+			onMaintenance = Constant{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       []byte(o.MaintenanceMessage),
+			}.NewHandler()
+		}
+
+		// Unmarshal validates these networks, so any invalid entries are simply never trusted
+		trusted, _ := ParseNetworks(o.MaintenanceTrusted)
+		chain = chain.Append(MaintenanceMode{
+			Maintenance:   o.MaintenanceSwitch,
+			RetryAfter:    o.MaintenanceRetryAfter,
+			Exempt:        o.MaintenanceExempt,
+			Trusted:       trusted,
+			OnMaintenance: onMaintenance,
+		}.Then)
+	}
+
+	// this precedes HandlerTimeout, so that time spent waiting for a slot does not count against the handler
+	if o.ConcurrencyLimit > 0 {
+		chain = chain.Append(ConcurrencyLimiter{
+			MaxConcurrent: o.ConcurrencyLimit,
+			MaxQueue:      o.ConcurrencyQueue,
+			MaxWait:       o.ConcurrencyQueueTimeout,
+			OnRejected:    NewErrorHandler(o.ErrorEncoder, http.StatusServiceUnavailable),
+			Metrics:       o.ConcurrencyMetrics,
+			Clock:         o.Clock,
+		}.Then)
+	}
+
+	// this follows Recovery, so that a panic re-raised from the handler's goroutine is still recovered.  It precedes
+	// StripPrefix, which must remain the last stage.
+	if o.HandlerTimeout > 0 {
+		chain = chain.Append(HandlerTimeout{
+			Timeout:   o.HandlerTimeout,
+			OnTimeout: NewErrorHandler(o.ErrorEncoder, http.StatusServiceUnavailable),
+		}.Then)
+	}
+
+	// this is the last stage, so that only routing sees the stripped path
+	chain = chain.Append(StripPrefix{
+		Prefix:   o.StripPrefix,
+		NotFound: NewErrorHandler(o.ErrorEncoder, http.StatusNotFound),
+	}.Then)
+
 	return chain
 }
 
 // New constructs a basic HTTP server instance.  The supplied logger is enriched with information
 // about the server and returned for use by higher-level code.
-func New(o Options, l log.Logger, h http.Handler) Interface {
+//
+// Any supplied ConnState callbacks are invoked for each connection state transition, after those
+// configured via the options.
+func New(o Options, l log.Logger, h http.Handler, cs ...func(net.Conn, http.ConnState)) Interface {
 	s := &http.Server{
 		// we don't need this technically, because we create a listener
 		// it's here for other code to inspect
@@ -78,10 +738,13 @@ func New(o Options, l log.Logger, h http.Handler) Interface {
 		Handler: h,
 
 		MaxHeaderBytes:    o.MaxHeaderBytes,
+		TLSNextProto:      o.TLSNextProto,
 		IdleTimeout:       o.IdleTimeout,
 		ReadHeaderTimeout: o.ReadHeaderTimeout,
 		ReadTimeout:       o.ReadTimeout,
 		WriteTimeout:      o.WriteTimeout,
+		BaseContext:       newBaseContext(o),
+		ConnContext:       newConnContext(o),
 
 		ErrorLog: xloghttp.NewErrorLog(
 			o.Address,
@@ -89,11 +752,52 @@ func New(o Options, l log.Logger, h http.Handler) Interface {
 		),
 	}
 
+	var (
+		connStates []func(net.Conn, http.ConnState)
+		tracker    *ConnectionTracker
+	)
+
 	if o.LogConnectionState {
-		s.ConnState = xloghttp.NewConnStateLogger(
-			l,
-			"connState",
-			level.DebugValue(),
+		connStates = append(connStates,
+			xloghttp.NewConnStateLogger(
+				l,
+				"connState",
+				level.DebugValue(),
+			),
+		)
+	}
+
+	if ager := newIdleConnectionAger(o); ager != nil {
+		connStates = append(connStates, ager.ConnState)
+	}
+
+	if o.CloseIdleOnShutdown || o.ShutdownDeadline > 0 {
+		tracker = NewConnectionTracker()
+		connStates = append(connStates, tracker.ConnState)
+	}
+
+	connStates = append(connStates, cs...)
+
+	switch len(connStates) {
+	case 0:
+		// leave ConnState unset
+
+	case 1:
+		s.ConnState = connStates[0]
+
+	default:
+		s.ConnState = func(c net.Conn, cs http.ConnState) {
+			for _, f := range connStates {
+				f(c, cs)
+			}
+		}
+	}
+
+	if err := configureHTTP2(s, o); err != nil {
+		l.Log(
+			level.Key(), level.ErrorValue(),
+			xlog.MessageKey(), "unable to configure HTTP/2",
+			xlog.ErrorKey(), err,
 		)
 	}
 
@@ -101,5 +805,9 @@ func New(o Options, l log.Logger, h http.Handler) Interface {
 		s.SetKeepAlivesEnabled(false)
 	}
 
+	if tracker != nil {
+		return trackedServer{Server: s, tracker: tracker, closeIdle: o.CloseIdleOnShutdown}
+	}
+
 	return s
 }
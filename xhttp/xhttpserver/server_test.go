@@ -2,9 +2,12 @@ package xhttpserver
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -89,6 +92,122 @@ func testNewServerChainHeaders(t *testing.T) {
 	assert.Equal(299, response.Code)
 }
 
+func testNewServerChainServerHeader(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		base   = log.NewJSONLogger(&output)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Equal("value", response.Header().Get("X-From-Configuration"))
+			assert.Equal("themis", response.Header().Get("Server"))
+			response.WriteHeader(299)
+		})
+
+		header = http.Header{
+			"X-From-Configuration": []string{"value"},
+			"Server":               []string{"overridden"},
+		}
+
+		chain = NewServerChain(
+			Options{
+				Header:               header,
+				ServerHeader:         "themis",
+				DisableTracking:      true,
+				DisableHandlerLogger: true,
+			},
+			base,
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", nil)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+	assert.Equal([]string{"overridden"}, header["Server"])
+}
+
+func testNewServerChainRequestTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		base   = log.NewJSONLogger(&output)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			deadline, ok := request.Context().Deadline()
+			assert.True(ok)
+			assert.True(time.Until(deadline) <= time.Second)
+			response.WriteHeader(299)
+		})
+
+		chain = NewServerChain(
+			Options{
+				RequestTimeoutHeader: "X-Timeout",
+				MaxRequestTimeout:    time.Second,
+				DisableTracking:      true,
+				DisableHandlerLogger: true,
+			},
+			base,
+		)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/foo", nil)
+	request.Header.Set("X-Timeout", "1h")
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+
+	// a request whose client has already gone away is rejected
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest("GET", "/foo", nil).WithContext(ctx)
+	request.Header.Set("X-Timeout", "1s")
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusRequestTimeout, response.Code)
+}
+
+func testNewServerChainFavicon(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		base   = log.NewJSONLogger(&output)
+
+		next = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		chain = NewServerChain(
+			Options{
+				Favicon:      &Favicon{},
+				ServerHeader: "themis",
+			},
+			base,
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/favicon.ico", nil))
+	assert.Equal(http.StatusNoContent, response.Code)
+	assert.Equal("themis", response.Header().Get("Server"))
+	assert.Zero(output.Len())
+}
+
 func testNewServerChainTracking(t *testing.T) {
 	var (
 		assert  = assert.New(t)
@@ -166,11 +285,560 @@ func testNewServerChainFull(t *testing.T) {
 	assert.Contains(output.String(), "/foo")
 }
 
+func testNewServerChainContentType(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Fail("The next handler should not have been called")
+		})
+
+		chain = NewServerChain(
+			Options{
+				AllowedContentTypes: []string{"application/json"},
+			},
+			log.NewNopLogger(),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", nil)
+	)
+
+	request.Header.Set("Content-Type", "text/plain")
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusUnsupportedMediaType, response.Code)
+
+	// without an ErrorEncoder, rejections have a plain text body
+	assert.Equal("text/plain; charset=utf-8", response.Header().Get("Content-Type"))
+	assert.Equal("Unsupported Media Type\n", response.Body.String())
+}
+
+func testNewServerChainErrorEncoder(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Fail("The next handler should not have been called")
+		})
+
+		chain = NewServerChain(
+			Options{
+				AllowedContentTypes: []string{"application/json"},
+				ErrorEncoder:        JSONErrorEncoder,
+			},
+			log.NewNopLogger(),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", nil)
+	)
+
+	request.Header.Set("Content-Type", "text/plain")
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusUnsupportedMediaType, response.Code)
+	assert.JSONEq(`{"error": {"code": 415, "message": "Unsupported Media Type"}}`, response.Body.String())
+}
+
+func testNewServerChainAccessLogger(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		serverOutput bytes.Buffer
+		accessOutput bytes.Buffer
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			xloghttp.LoggerFromContext(request.Context()).Log(xlog.MessageKey(), "access")
+			response.WriteHeader(299)
+		})
+
+		chain = NewServerChain(
+			Options{
+				AccessLogger: log.NewJSONLogger(&accessOutput),
+			},
+			log.NewJSONLogger(&serverOutput),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", nil)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+	assert.Zero(serverOutput.Len())
+	assert.Contains(accessOutput.String(), "access")
+}
+
+func testNewServerChainMethodOverride(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		actual string
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			actual = request.Method
+		})
+
+		chain = NewServerChain(
+			Options{
+				MethodOverride: true,
+			},
+			log.NewJSONLogger(&output),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", nil)
+	)
+
+	request.Header.Set(DefaultMethodOverrideHeader, "DELETE")
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal("DELETE", actual)
+	assert.Contains(output.String(), "method overridden")
+}
+
+func testNewServerChainLogMinStatusCode(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			xloghttp.LoggerFromContext(request.Context()).Log(xlog.MessageKey(), request.URL.Path)
+			if request.URL.Path == "/fail" {
+				response.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+
+		chain = NewServerChain(
+			Options{
+				LogMinStatusCode: 400,
+			},
+			log.NewJSONLogger(&output),
+		)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/ok", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Zero(output.Len())
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/fail", nil))
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Contains(output.String(), "/fail")
+}
+
+func testNewServerChainDisableTracking(t *testing.T) {
+	testData := []struct {
+		options  Options
+		expected bool
+	}{
+		{
+			options:  Options{DisableTracking: true},
+			expected: false,
+		},
+		{
+			options:  Options{DisableTracking: true, LogMinStatusCode: 400},
+			expected: true,
+		},
+		{
+			options:  Options{DisableTracking: true, LogMinStatusCode: 400, DisableHandlerLogger: true},
+			expected: false,
+		},
+		{
+			options:  Options{DisableTracking: true, Tracing: true},
+			expected: true,
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				tracked bool
+				next    = http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+					_, tracked = response.(TrackingWriter)
+				})
+
+				decorated = NewServerChain(record.options, log.NewNopLogger()).Then(next)
+			)
+
+			require.NotNil(decorated)
+			decorated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			assert.Equal(record.expected, tracked)
+		})
+	}
+}
+
+func testNewServerChainStripPrefix(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		actual string
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			actual = request.URL.Path
+			xloghttp.LoggerFromContext(request.Context()).Log(xlog.MessageKey(), "routed")
+		})
+
+		chain = NewServerChain(
+			Options{
+				StripPrefix: "/service",
+			},
+			log.NewJSONLogger(&output),
+			xloghttp.URI("uri"),
+		)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/service/foo", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("/foo", actual)
+	assert.Contains(output.String(), `"uri":"/service/foo"`)
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/other/foo", nil))
+	assert.Equal(http.StatusNotFound, response.Code)
+}
+
+func testNewServerChainAutoFlush(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Implements((*TrackingWriter)(nil), response)
+			response.Header().Set("Content-Type", "text/event-stream")
+			response.Write([]byte("data: event\n\n"))
+		})
+
+		chain = NewServerChain(
+			Options{
+				AutoFlush: true,
+			},
+			log.NewNopLogger(),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/events", nil))
+	assert.Equal("data: event\n\n", response.Body.String())
+	assert.True(response.Flushed)
+}
+
+func testNewServerChainLogRejections(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Fail("the handler should not have been called")
+		})
+
+		chain = NewServerChain(
+			Options{
+				LogRejections:       true,
+				AllowedContentTypes: []string{"application/json"},
+			},
+			log.NewJSONLogger(&output),
+			xloghttp.URI("uri"),
+		)
+
+		request = httptest.NewRequest("POST", "/test", bytes.NewBufferString("body"))
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+
+	request.Header.Set("Content-Type", "text/plain")
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusUnsupportedMediaType, response.Code)
+	assert.Contains(output.String(), `"rejectedBy":"contentType"`)
+	assert.Contains(output.String(), `"uri":"/test"`)
+}
+
+func testNewServerChainOnPanic(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output    bytes.Buffer
+		recovered interface{}
+
+		next = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("expected panic")
+		})
+
+		chain = NewServerChain(
+			Options{
+				ErrorEncoder: JSONErrorEncoder,
+				OnPanic: func(_ *http.Request, v interface{}, _ []byte) {
+					recovered = v
+				},
+			},
+			log.NewJSONLogger(&output),
+			xloghttp.URI("uri"),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/foo", nil))
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+	assert.Equal("expected panic", recovered)
+	assert.Contains(output.String(), `"uri":"/foo"`)
+}
+
+func testNewServerChainRecoverPanics(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+
+		next = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(testHTTPError{statusCode: http.StatusConflict})
+		})
+
+		chain = NewServerChain(
+			Options{
+				RecoverPanics: true,
+				ErrorEncoder:  JSONErrorEncoder,
+			},
+			log.NewJSONLogger(&output),
+			xloghttp.URI("uri"),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/foo", nil))
+	assert.Equal(http.StatusConflict, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+	assert.Contains(response.Body.String(), "expected HTTP error")
+	assert.Contains(output.String(), `"uri":"/foo"`)
+
+	// without RecoverPanics or OnPanic, panics are left to net/http
+	decorated = NewServerChain(Options{}, log.NewNopLogger()).Then(next)
+	assert.Panics(func() {
+		decorated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+	})
+}
+
+func testNewServerChainConcurrencyLimit(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		queued  = new(testGauge)
+		entered = make(chan struct{}, 2)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			entered <- struct{}{}
+			time.Sleep(150 * time.Millisecond)
+			response.WriteHeader(299)
+		})
+
+		chain = NewServerChain(
+			Options{
+				ConcurrencyLimit: 1,
+				ConcurrencyQueue: 1,
+				HandlerTimeout:   250 * time.Millisecond,
+				ErrorEncoder:     JSONErrorEncoder,
+				ConcurrencyMetrics: ConcurrencyMetrics{
+					Queued: queued,
+				},
+			},
+			log.NewNopLogger(),
+		)
+
+		responses = [2]*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder()}
+		finished  = make(chan struct{}, 2)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+
+	go func() {
+		decorated.ServeHTTP(responses[0], httptest.NewRequest("GET", "/", nil))
+		finished <- struct{}{}
+	}()
+
+	<-entered
+	go func() {
+		decorated.ServeHTTP(responses[1], httptest.NewRequest("GET", "/", nil))
+		finished <- struct{}{}
+	}()
+
+	require.Eventually(func() bool { return queued.Value() == 1.0 }, 5*time.Second, 10*time.Millisecond)
+
+	// the limiter, not HandlerTimeout, rejects requests beyond the queue
+	rejected := httptest.NewRecorder()
+	decorated.ServeHTTP(rejected, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusServiceUnavailable, rejected.Code)
+	assert.Equal("application/json", rejected.Header().Get("Content-Type"))
+
+	<-finished
+	<-finished
+
+	// the second request waited for the first, which does not count against its HandlerTimeout
+	assert.Equal(299, responses[0].Code)
+	assert.Equal(299, responses[1].Code)
+}
+
+func testNewServerChainGatesWithStripPrefix(t *testing.T) {
+	shutdownSignal := NewShutdownSignal()
+	shutdownSignal.Cancel()
+
+	maintenance := NewMaintenance(log.NewNopLogger())
+	maintenance.Set(true, "test")
+
+	testData := []struct {
+		name    string
+		options Options
+	}{
+		{
+			name: "StartupGate",
+			options: Options{
+				StartupGate:       true,
+				StartupGateExempt: []string{"/service/health"},
+				Gate:              ProvideGate(),
+			},
+		},
+		{
+			name: "Drain",
+			options: Options{
+				Drain:          true,
+				DrainExempt:    []string{"/service/health"},
+				ShutdownSignal: shutdownSignal,
+			},
+		},
+		{
+			name: "Maintenance",
+			options: Options{
+				Maintenance:       true,
+				MaintenanceExempt: []string{"/service/health"},
+				MaintenanceSwitch: maintenance,
+			},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				actual string
+				next   = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					actual = request.URL.Path
+				})
+			)
+
+			record.options.StripPrefix = "/service"
+			record.options.HandlerTimeout = time.Minute
+			decorated := NewServerChain(record.options, log.NewNopLogger()).Then(next)
+			require.NotNil(decorated)
+
+			response := httptest.NewRecorder()
+			decorated.ServeHTTP(response, httptest.NewRequest("GET", "/service/health", nil))
+			assert.Equal(http.StatusOK, response.Code)
+			assert.Equal("/health", actual)
+
+			actual = ""
+			response = httptest.NewRecorder()
+			decorated.ServeHTTP(response, httptest.NewRequest("GET", "/service/foo", nil))
+			assert.Equal(http.StatusServiceUnavailable, response.Code)
+			assert.Empty(actual)
+		})
+	}
+}
+
+func testNewServerChainHeaderHardening(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		chain  = NewServerChain(
+			Options{
+				HeaderHardening:   true,
+				CanonicalHost:     "example.com",
+				CanonicalHostFrom: []string{"www.example.com"},
+			},
+			log.NewJSONLogger(&output),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "http://www.example.com/foo", nil)
+	)
+
+	// a malformed header is rejected before CanonicalHost gets the chance to redirect
+	request.Header.Set("X-Null", "a\x00b")
+	decorated := chain.Then(Constant{StatusCode: 299}.NewHandler())
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+	assert.Empty(response.Header().Get("Location"))
+	assert.Contains(output.String(), "suspicious request header")
+}
+
 func TestNewServerChain(t *testing.T) {
 	t.Run("None", testNewServerChainNone)
 	t.Run("Headers", testNewServerChainHeaders)
+	t.Run("ServerHeader", testNewServerChainServerHeader)
+	t.Run("RequestTimeout", testNewServerChainRequestTimeout)
+	t.Run("Favicon", testNewServerChainFavicon)
 	t.Run("Tracking", testNewServerChainTracking)
 	t.Run("Full", testNewServerChainFull)
+	t.Run("ContentType", testNewServerChainContentType)
+	t.Run("ErrorEncoder", testNewServerChainErrorEncoder)
+	t.Run("AccessLogger", testNewServerChainAccessLogger)
+	t.Run("MethodOverride", testNewServerChainMethodOverride)
+	t.Run("LogMinStatusCode", testNewServerChainLogMinStatusCode)
+	t.Run("DisableTracking", testNewServerChainDisableTracking)
+	t.Run("StripPrefix", testNewServerChainStripPrefix)
+	t.Run("AutoFlush", testNewServerChainAutoFlush)
+	t.Run("LogRejections", testNewServerChainLogRejections)
+	t.Run("OnPanic", testNewServerChainOnPanic)
+	t.Run("RecoverPanics", testNewServerChainRecoverPanics)
+	t.Run("ConcurrencyLimit", testNewServerChainConcurrencyLimit)
+	t.Run("GatesWithStripPrefix", testNewServerChainGatesWithStripPrefix)
+	t.Run("HeaderHardening", testNewServerChainHeaderHardening)
 }
 
 func testNewSimple(t *testing.T) {
@@ -236,6 +904,9 @@ func testNewFull(t *testing.T) {
 				ReadTimeout:        99 * time.Second,
 				WriteTimeout:       8456 * time.Nanosecond,
 				LogConnectionState: true,
+				TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){
+					"custom": func(*http.Server, *tls.Conn, http.Handler) {},
+				},
 			},
 			base,
 			router,
@@ -254,6 +925,7 @@ func testNewFull(t *testing.T) {
 	assert.Equal(113*time.Minute, s.(*http.Server).ReadHeaderTimeout)
 	assert.Equal(99*time.Second, s.(*http.Server).ReadTimeout)
 	assert.Equal(8456*time.Nanosecond, s.(*http.Server).WriteTimeout)
+	assert.Contains(s.(*http.Server).TLSNextProto, "custom")
 
 	require.NotNil(s.(*http.Server).ErrorLog)
 	s.(*http.Server).ErrorLog.Print("foo", "bar")
@@ -265,7 +937,70 @@ func testNewFull(t *testing.T) {
 	assert.Greater(output.Len(), 0)
 }
 
+func testNewCloseIdleOnShutdown(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		base   = log.NewJSONLogger(&output)
+
+		s = New(
+			Options{
+				Address:             ":12000",
+				LogConnectionState:  true,
+				CloseIdleOnShutdown: true,
+			},
+			base,
+			mux.NewRouter(),
+		)
+	)
+
+	require.NotNil(s)
+	require.IsType(trackedServer{}, s)
+	assert.Equal(":12000", s.(trackedServer).Addr)
+
+	// both the logger and the tracker should receive connection state
+	require.NotNil(s.(trackedServer).ConnState)
+	s.(trackedServer).ConnState(new(net.IPConn), http.StateNew)
+	assert.Greater(output.Len(), 0)
+	assert.Equal(1, s.(trackedServer).tracker.Len())
+}
+
+func testNewConnState(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		base   = log.NewJSONLogger(&output)
+
+		states []http.ConnState
+		s      = New(
+			Options{
+				Address:            ":13000",
+				LogConnectionState: true,
+			},
+			base,
+			mux.NewRouter(),
+			func(c net.Conn, cs http.ConnState) {
+				states = append(states, cs)
+			},
+		)
+	)
+
+	require.NotNil(s)
+	require.IsType((*http.Server)(nil), s)
+	require.NotNil(s.(*http.Server).ConnState)
+	s.(*http.Server).ConnState(new(net.IPConn), http.StateNew)
+	s.(*http.Server).ConnState(new(net.IPConn), http.StateClosed)
+	assert.Greater(output.Len(), 0)
+	assert.Equal([]http.ConnState{http.StateNew, http.StateClosed}, states)
+}
+
 func TestNew(t *testing.T) {
 	t.Run("Simple", testNewSimple)
 	t.Run("Full", testNewFull)
+	t.Run("CloseIdleOnShutdown", testNewCloseIdleOnShutdown)
+	t.Run("ConnState", testNewConnState)
 }
@@ -0,0 +1,87 @@
+package xhttpserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	abandonedKey = "abandoned"
+)
+
+var (
+	ErrShutdownDeadline = errors.New("The server did not shut down before its deadline")
+)
+
+// AbandonedKey is the logging key for the number of connections abandoned when a server is forcibly closed
+func AbandonedKey() interface{} {
+	return abandonedKey
+}
+
+// forceCloser is implemented by servers, such as *http.Server, that can be closed immediately
+type forceCloser interface {
+	Close() error
+}
+
+// connectionCounter is implemented by servers that know how many connections they have open
+type connectionCounter interface {
+	Connections() int
+}
+
+// Shutdown gracefully shuts down a server, waiting at most drain for in-flight requests to complete.  The given
+// context may impose its own, shorter deadline.  If drain is nonpositive, only the context bounds the shutdown.
+//
+// If deadline is positive, it is an absolute cap on the shutdown.  Should the graceful shutdown fail or not
+// complete within that time, the server is forcibly closed and the forced termination is logged, along with the
+// number of abandoned connections if the server tracks them.  In that case, only an error from closing the server
+// is returned.  Servers that cannot be closed, i.e. that do not have a Close method, are never forcibly closed.
+func Shutdown(ctx context.Context, s Interface, logger log.Logger, drain, deadline time.Duration) error {
+	if drain > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, drain)
+		defer cancel()
+	}
+
+	fc, ok := s.(forceCloser)
+	if deadline <= 0 || !ok {
+		return s.Shutdown(ctx)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Shutdown(ctx)
+	}()
+
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case err = <-result:
+		if err == nil {
+			return nil
+		}
+
+	case <-timer.C:
+		err = ErrShutdownDeadline
+	}
+
+	keyvals := []interface{}{
+		level.Key(), level.WarnValue(),
+		xlog.MessageKey(), "forcibly closing server",
+		xlog.ErrorKey(), err,
+	}
+
+	if cc, ok := s.(connectionCounter); ok {
+		keyvals = append(keyvals, AbandonedKey(), cc.Connections())
+	}
+
+	logger.Log(keyvals...)
+	return fc.Close()
+}
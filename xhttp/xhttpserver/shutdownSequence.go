@@ -0,0 +1,121 @@
+package xhttpserver
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"go.uber.org/fx"
+)
+
+// shutdownStep is a single server's shutdown closure, along with its position in the sequence
+type shutdownStep struct {
+	order int
+	index int
+	stop  func(context.Context) error
+}
+
+// ErrShutdownSequenceNotStopped is returned on startup when an application includes a ShutdownSequence but not
+// StopInSequence.  Without StopInSequence, the servers registered with the sequence would never be stopped.
+var ErrShutdownSequenceNotStopped = errors.New("A ShutdownSequence requires StopInSequence to be invoked")
+
+// ShutdownSequence controls the order in which servers are shut down.  Without a ShutdownSequence,
+// each server appends its own OnStop hook, and uber/fx stops servers in the reverse order in which
+// they were constructed.  That order depends on the dependency graph and is usually not what's desired.
+//
+// When a ShutdownSequence is present in the application, servers register their shutdown closures with it
+// instead.  A single lifecycle hook, appended by StopInSequence, then stops the servers in ascending order of
+// Options.ShutdownOrder.  Servers with the same order, including the default of zero, are stopped in reverse order
+// of construction.  For example, to keep a metrics server available while the servers that handle traffic are
+// draining, give the metrics server a higher shutdownOrder in its configuration.
+type ShutdownSequence struct {
+	lock    sync.Mutex
+	stopped bool
+	steps   []shutdownStep
+}
+
+// NewShutdownSequence creates an empty ShutdownSequence.  The application is responsible for calling Stop,
+// which StopInSequence arranges for uber/fx applications.
+func NewShutdownSequence() *ShutdownSequence {
+	return new(ShutdownSequence)
+}
+
+// ProvideShutdownSequence is an uber/fx provider for a ShutdownSequence.  Including this provider in an
+// application opts all servers created via Unmarshal into ordered shutdown.  The application must also invoke
+// StopInSequence, or it fails to start with ErrShutdownSequenceNotStopped.
+func ProvideShutdownSequence(lc fx.Lifecycle) *ShutdownSequence {
+	ss := NewShutdownSequence()
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ss.lock.Lock()
+			defer ss.lock.Unlock()
+			if !ss.stopped {
+				return ErrShutdownSequenceNotStopped
+			}
+
+			return nil
+		},
+	})
+
+	return ss
+}
+
+// StopInSequenceIn defines the dependencies for StopInSequence
+type StopInSequenceIn struct {
+	fx.In
+
+	Lifecycle        fx.Lifecycle
+	ShutdownSequence *ShutdownSequence
+}
+
+// StopInSequence is an uber/fx Invoke function that appends the lifecycle hook which stops the servers registered
+// with the ShutdownSequence.  uber/fx runs OnStop hooks in the reverse order they were appended, so this function
+// must follow the Invoke functions that create servers.  The servers are then stopped before any component
+// that was created along with them, e.g. a database used by their handlers.
+func StopInSequence(in StopInSequenceIn) {
+	in.ShutdownSequence.lock.Lock()
+	in.ShutdownSequence.stopped = true
+	in.ShutdownSequence.lock.Unlock()
+
+	in.Lifecycle.Append(fx.Hook{
+		OnStop: in.ShutdownSequence.Stop,
+	})
+}
+
+// Add registers a server's shutdown closure at the given order
+func (ss *ShutdownSequence) Add(order int, stop func(context.Context) error) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	ss.steps = append(ss.steps, shutdownStep{
+		order: order,
+		index: len(ss.steps),
+		stop:  stop,
+	})
+}
+
+// Stop executes each registered shutdown closure in sequence.  Every closure is executed, even if an
+// earlier one fails.  The first error encountered is returned.
+func (ss *ShutdownSequence) Stop(ctx context.Context) error {
+	ss.lock.Lock()
+	steps := append([]shutdownStep{}, ss.steps...)
+	ss.lock.Unlock()
+
+	sort.Slice(steps, func(i, j int) bool {
+		if steps[i].order == steps[j].order {
+			return steps[i].index > steps[j].index
+		}
+
+		return steps[i].order < steps[j].order
+	})
+
+	var firstErr error
+	for _, s := range steps {
+		if err := s.stop(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,113 @@
+package xhttpserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func testShutdownSequenceOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		lc      = fxtest.NewLifecycle(t)
+		ss      = ProvideShutdownSequence(lc)
+		stopped []string
+
+		stop = func(name string) func(context.Context) error {
+			return func(context.Context) error {
+				stopped = append(stopped, name)
+				return nil
+			}
+		}
+	)
+
+	ss.Add(1, stop("metrics"))
+	ss.Add(0, stop("first"))
+	ss.Add(0, stop("second"))
+	ss.Add(-1, stop("early"))
+
+	StopInSequence(StopInSequenceIn{Lifecycle: lc, ShutdownSequence: ss})
+	lc.RequireStart()
+	assert.Empty(stopped)
+	lc.RequireStop()
+	assert.Equal([]string{"early", "second", "first", "metrics"}, stopped)
+}
+
+func testShutdownSequenceError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		expectedErr = errors.New("expected")
+
+		ss      = NewShutdownSequence()
+		stopped []int
+	)
+
+	ss.Add(0, func(context.Context) error {
+		stopped = append(stopped, 0)
+		return expectedErr
+	})
+
+	ss.Add(1, func(context.Context) error {
+		stopped = append(stopped, 1)
+		return errors.New("should not be returned")
+	})
+
+	assert.Equal(expectedErr, ss.Stop(context.Background()))
+	assert.Equal([]int{0, 1}, stopped)
+}
+
+func testShutdownSequenceLifecycleOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		lc      = fxtest.NewLifecycle(t)
+		ss      = ProvideShutdownSequence(lc)
+		stopped []string
+	)
+
+	ss.Add(0, func(context.Context) error {
+		stopped = append(stopped, "server")
+		return nil
+	})
+
+	// a component created after the server, e.g. one of its handlers' dependencies, must outlive the server
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			stopped = append(stopped, "dependency")
+			return nil
+		},
+	})
+
+	StopInSequence(StopInSequenceIn{Lifecycle: lc, ShutdownSequence: ss})
+	lc.RequireStart()
+	lc.RequireStop()
+	assert.Equal([]string{"server", "dependency"}, stopped)
+}
+
+func testShutdownSequenceNotStopped(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		lc = fxtest.NewLifecycle(t)
+		ss = ProvideShutdownSequence(lc)
+	)
+
+	ss.Add(0, func(context.Context) error {
+		assert.Fail("The server should not have been stopped")
+		return nil
+	})
+
+	assert.Equal(ErrShutdownSequenceNotStopped, lc.Start(context.Background()))
+}
+
+func TestShutdownSequence(t *testing.T) {
+	t.Run("Order", testShutdownSequenceOrder)
+	t.Run("LifecycleOrder", testShutdownSequenceLifecycleOrder)
+	t.Run("NotStopped", testShutdownSequenceNotStopped)
+	t.Run("Error", testShutdownSequenceError)
+}
@@ -0,0 +1,97 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/fx"
+)
+
+// ShutdownSignal is a shared signal that the application has begun shutting down.  Handlers and background
+// goroutines can observe this signal, via Context or Done, in order to abort long-running work promptly.
+type ShutdownSignal struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewShutdownSignal creates a ShutdownSignal that has not been triggered
+func NewShutdownSignal() *ShutdownSignal {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ShutdownSignal{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// ProvideShutdownSignal is an uber/fx provider that creates a ShutdownSignal.  Use CancelOnStop to trigger
+// the signal when the application stops.
+func ProvideShutdownSignal() *ShutdownSignal {
+	return NewShutdownSignal()
+}
+
+// Context returns a context that is canceled once shutdown begins
+func (ss *ShutdownSignal) Context() context.Context {
+	return ss.ctx
+}
+
+// Done returns a channel that is closed once shutdown begins
+func (ss *ShutdownSignal) Done() <-chan struct{} {
+	return ss.ctx.Done()
+}
+
+// Cancel triggers this signal.  This method is idempotent.
+func (ss *ShutdownSignal) Cancel() {
+	ss.cancel()
+}
+
+// CancelOnStopIn defines the dependencies for CancelOnStop
+type CancelOnStopIn struct {
+	fx.In
+
+	Lifecycle      fx.Lifecycle
+	ShutdownSignal *ShutdownSignal
+}
+
+// CancelOnStop is an uber/fx Invoke function that triggers the ShutdownSignal when the application stops.
+// Since uber/fx runs OnStop hooks in the reverse order they were appended, this function must be one of the last
+// Invoke functions for an application.  That way, the signal is triggered before servers begin draining requests.
+func CancelOnStop(in CancelOnStopIn) {
+	in.Lifecycle.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			in.ShutdownSignal.Cancel()
+			return nil
+		},
+	})
+}
+
+// ShutdownContext is an Alice-style decorator that cancels each request's context once a ShutdownSignal
+// is triggered.  This allows handlers that already honor request cancellation to abort during shutdown.
+type ShutdownContext struct {
+	// ShutdownSignal is the signal that cancels requests.  If unset, no decoration is done.
+	ShutdownSignal *ShutdownSignal
+}
+
+func (sc ShutdownContext) Then(next http.Handler) http.Handler {
+	if sc.ShutdownSignal == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ctx, cancel := context.WithCancel(request.Context())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-sc.ShutdownSignal.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		next.ServeHTTP(response, request.WithContext(ctx))
+	})
+}
+
+func (sc ShutdownContext) ThenFunc(next http.HandlerFunc) http.Handler {
+	return sc.Then(next)
+}
@@ -0,0 +1,119 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestShutdownSignal(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ss     = ProvideShutdownSignal()
+	)
+
+	assert.NoError(ss.Context().Err())
+	select {
+	case <-ss.Done():
+		assert.Fail("The signal should not have been triggered")
+	default:
+	}
+
+	ss.Cancel()
+	ss.Cancel()
+	assert.Equal(context.Canceled, ss.Context().Err())
+
+	select {
+	case <-ss.Done():
+	default:
+		assert.Fail("The signal should have been triggered")
+	}
+}
+
+func TestCancelOnStop(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		lc     = fxtest.NewLifecycle(t)
+		ss     = NewShutdownSignal()
+	)
+
+	CancelOnStop(CancelOnStopIn{Lifecycle: lc, ShutdownSignal: ss})
+	lc.RequireStart()
+	assert.NoError(ss.Context().Err())
+	lc.RequireStop()
+	assert.Equal(context.Canceled, ss.Context().Err())
+}
+
+func testShutdownContextNoSignal(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = ShutdownContext{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testShutdownContextCanceled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ss        = NewShutdownSignal()
+		entered   = make(chan struct{})
+		decorated = ShutdownContext{ShutdownSignal: ss}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			close(entered)
+			select {
+			case <-request.Context().Done():
+				response.WriteHeader(299)
+			case <-time.After(5 * time.Second):
+				assert.Fail("The request context was not canceled")
+			}
+		})
+
+		response = httptest.NewRecorder()
+		done     = make(chan struct{})
+	)
+
+	require.NotNil(decorated)
+	go func() {
+		defer close(done)
+		decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	}()
+
+	<-entered
+	ss.Cancel()
+	<-done
+	assert.Equal(299, response.Code)
+}
+
+func testShutdownContextNotCanceled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = ShutdownContext{ShutdownSignal: NewShutdownSignal()}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.NoError(request.Context().Err())
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+}
+
+func TestShutdownContext(t *testing.T) {
+	t.Run("NoSignal", testShutdownContextNoSignal)
+	t.Run("Canceled", testShutdownContextCanceled)
+	t.Run("NotCanceled", testShutdownContextNotCanceled)
+}
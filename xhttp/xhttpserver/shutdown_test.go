@@ -0,0 +1,160 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingServer is an Interface whose Shutdown blocks until the context is canceled or the server is closed
+type blockingServer struct {
+	closed   chan struct{}
+	closeErr error
+}
+
+func (bs *blockingServer) Serve(net.Listener) error {
+	return nil
+}
+
+func (bs *blockingServer) Shutdown(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-bs.closed:
+		return http.ErrServerClosed
+	}
+}
+
+func (bs *blockingServer) Close() error {
+	close(bs.closed)
+	return bs.closeErr
+}
+
+func testShutdownGraceful(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output bytes.Buffer
+		s      = New(Options{ShutdownDeadline: time.Minute}, log.NewNopLogger(), http.NotFoundHandler())
+	)
+
+	assert.NoError(Shutdown(context.Background(), s, log.NewJSONLogger(&output), time.Minute, time.Minute))
+	assert.Zero(output.Len())
+}
+
+func testShutdownNoDeadline(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output bytes.Buffer
+		s      = &blockingServer{closed: make(chan struct{})}
+	)
+
+	err := Shutdown(context.Background(), s, log.NewJSONLogger(&output), 10*time.Millisecond, 0)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Zero(output.Len())
+}
+
+func testShutdownDeadline(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		s      = &blockingServer{closed: make(chan struct{})}
+	)
+
+	assert.NoError(Shutdown(context.Background(), s, log.NewJSONLogger(&output), 0, 10*time.Millisecond))
+
+	var record map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &record))
+	assert.Equal("forcibly closing server", record["msg"])
+	assert.Equal(ErrShutdownDeadline.Error(), record["error"])
+	assert.NotContains(record, abandonedKey)
+}
+
+func testShutdownCloseError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		expectedErr = errors.New("expected")
+
+		s = &blockingServer{closed: make(chan struct{}), closeErr: expectedErr}
+	)
+
+	assert.Equal(expectedErr, Shutdown(context.Background(), s, log.NewNopLogger(), 0, 10*time.Millisecond))
+}
+
+func testShutdownAbandoned(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output  bytes.Buffer
+		entered = make(chan struct{})
+		release = make(chan struct{})
+
+		s = New(
+			Options{ShutdownDeadline: time.Minute},
+			log.NewNopLogger(),
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				close(entered)
+				<-release
+			}),
+		)
+	)
+
+	defer close(release)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	go s.Serve(l)
+
+	clientErr := make(chan error, 1)
+	go func() {
+		response, err := http.Get("http://" + l.Addr().String())
+		if err == nil {
+			response.Body.Close()
+		}
+
+		clientErr <- err
+	}()
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		require.Fail("The handler was not invoked")
+	}
+
+	// the drain timeout expires first, which forces the server closed before the deadline
+	assert.NoError(Shutdown(context.Background(), s, log.NewJSONLogger(&output), 50*time.Millisecond, time.Minute))
+
+	var record map[string]interface{}
+	require.NoError(json.Unmarshal(output.Bytes(), &record))
+	assert.Equal("forcibly closing server", record["msg"])
+	assert.Equal(context.DeadlineExceeded.Error(), record["error"])
+	assert.Equal(1.0, record[abandonedKey])
+
+	select {
+	case err := <-clientErr:
+		assert.Error(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The client request did not fail after the server was closed")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("Graceful", testShutdownGraceful)
+	t.Run("NoDeadline", testShutdownNoDeadline)
+	t.Run("Deadline", testShutdownDeadline)
+	t.Run("CloseError", testShutdownCloseError)
+	t.Run("Abandoned", testShutdownAbandoned)
+}
@@ -0,0 +1,310 @@
+package xhttpserver
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultSingleflightMaxBytes is the default limit on the size of a response body that is shared
+	// with duplicate requests
+	DefaultSingleflightMaxBytes = 1024 * 1024
+)
+
+// SingleflightKeyHeaders returns the request headers that SingleflightURIKey includes in its keys.  These are the
+// credentials that identify a client and the headers that commonly select among variants of a response.
+func SingleflightKeyHeaders() []string {
+	return []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Accept",
+		"Accept-Charset",
+		"Accept-Encoding",
+		"Accept-Language",
+		"Origin",
+	}
+}
+
+// SingleflightURIKey is a Singleflight key function that treats GET and HEAD requests for the same request URI
+// as duplicates, as long as they also agree on every header in SingleflightKeyHeaders.  This keeps one client's
+// response from being served to another client with different credentials.  Requests using any other method are
+// never collapsed.
+//
+// Handlers whose responses vary on anything else, e.g. a custom header named in Vary, need their own key function.
+func SingleflightURIKey(request *http.Request) (string, bool) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		var key strings.Builder
+		key.WriteString(request.Method)
+		key.WriteByte(' ')
+		key.WriteString(request.URL.RequestURI())
+		for _, name := range SingleflightKeyHeaders() {
+			if values, ok := request.Header[name]; ok {
+				key.WriteByte('\n')
+				key.WriteString(name)
+				key.WriteString(": ")
+				key.WriteString(strings.Join(values, ", "))
+			}
+		}
+
+		return key.String(), true
+
+	default:
+		return "", false
+	}
+}
+
+// singleflightSkipHeaders are the response headers that are never replayed to waiters.  Cookies belong to the
+// leader's client, and hop-by-hop headers describe the leader's connection.
+var singleflightSkipHeaders = map[string]bool{
+	"Set-Cookie":         true,
+	"Connection":         true,
+	"Keep-Alive":         true,
+	"Proxy-Connection":   true,
+	"Proxy-Authenticate": true,
+	"Te":                 true,
+	"Trailer":            true,
+	"Transfer-Encoding":  true,
+	"Upgrade":            true,
+}
+
+// cloneHeader makes a deep copy of an http.Header
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for name, values := range h {
+		clone[name] = append([]string{}, values...)
+	}
+
+	return clone
+}
+
+// singleflightResult is the response produced by the request that did the work
+type singleflightResult struct {
+	// shared indicates whether this result can be replayed.  This is false if the body was too large,
+	// the connection was hijacked, or the handler panicked.
+	shared bool
+
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// singleflightCall is an in-flight execution of the decorated handler
+type singleflightCall struct {
+	done   chan struct{}
+	result singleflightResult
+}
+
+// singleflightWriter writes through to the leader's response while capturing a copy for waiters
+type singleflightWriter struct {
+	next     http.ResponseWriter
+	maxBytes int
+
+	statusCode int
+	header     http.Header
+	body       bytes.Buffer
+	overflow   bool
+	hijacked   bool
+}
+
+// Unwrap returns the decorated http.ResponseWriter
+func (sw *singleflightWriter) Unwrap() http.ResponseWriter {
+	return sw.next
+}
+
+func (sw *singleflightWriter) Header() http.Header {
+	return sw.next.Header()
+}
+
+func (sw *singleflightWriter) WriteHeader(statusCode int) {
+	if sw.statusCode == 0 {
+		sw.statusCode = statusCode
+		sw.header = cloneHeader(sw.next.Header())
+	}
+
+	sw.next.WriteHeader(statusCode)
+}
+
+func (sw *singleflightWriter) Write(b []byte) (int, error) {
+	if sw.statusCode == 0 {
+		sw.WriteHeader(http.StatusOK)
+	}
+
+	if !sw.overflow {
+		if sw.body.Len()+len(b) > sw.maxBytes {
+			sw.overflow = true
+			sw.body = bytes.Buffer{}
+		} else {
+			sw.body.Write(b)
+		}
+	}
+
+	return sw.next.Write(b)
+}
+
+func (sw *singleflightWriter) Flush() {
+	if f, ok := sw.next.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *singleflightWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := sw.next.(http.Hijacker); ok {
+		sw.hijacked = true
+		return h.Hijack()
+	}
+
+	return nil, nil, ErrHijackerNotSupported
+}
+
+func (sw *singleflightWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := sw.next.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// result produces the shareable outcome of the leader's request
+func (sw *singleflightWriter) result() singleflightResult {
+	if sw.overflow || sw.hijacked {
+		return singleflightResult{}
+	}
+
+	r := singleflightResult{
+		shared:     true,
+		statusCode: sw.statusCode,
+		header:     sw.header,
+		body:       sw.body.Bytes(),
+	}
+
+	if r.statusCode == 0 {
+		// the handler wrote nothing, which net/http treats as an empty 200
+		r.statusCode = http.StatusOK
+		r.header = cloneHeader(sw.next.Header())
+	}
+
+	return r
+}
+
+// singleflightHandler is the internal http.Handler that collapses duplicate requests
+type singleflightHandler struct {
+	next     http.Handler
+	key      func(*http.Request) (string, bool)
+	maxBytes int
+
+	lock  sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+// lead executes the decorated handler on behalf of all duplicates of the given key
+func (sh *singleflightHandler) lead(key string, c *singleflightCall, response http.ResponseWriter, request *http.Request) {
+	sw := &singleflightWriter{
+		next:     response,
+		maxBytes: sh.maxBytes,
+	}
+
+	defer func() {
+		// if the handler panics, the result is left unshared and waiters execute the handler themselves
+		sh.lock.Lock()
+		delete(sh.calls, key)
+		sh.lock.Unlock()
+		close(c.done)
+	}()
+
+	sh.next.ServeHTTP(sw, request)
+	c.result = sw.result()
+}
+
+func (sh *singleflightHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	key, ok := sh.key(request)
+	if !ok {
+		sh.next.ServeHTTP(response, request)
+		return
+	}
+
+	sh.lock.Lock()
+	c, waiting := sh.calls[key]
+	if !waiting {
+		c = &singleflightCall{done: make(chan struct{})}
+		sh.calls[key] = c
+	}
+
+	sh.lock.Unlock()
+	if !waiting {
+		sh.lead(key, c, response, request)
+		return
+	}
+
+	select {
+	case <-c.done:
+	case <-request.Context().Done():
+		// the client went away, so there is nobody to respond to
+		return
+	}
+
+	if !c.result.shared {
+		sh.next.ServeHTTP(response, request)
+		return
+	}
+
+	// headers already set for this waiter, e.g. by outer middleware, take precedence over the leader's
+	header := response.Header()
+	for name, values := range c.result.header {
+		if _, exists := header[name]; !exists && !singleflightSkipHeaders[name] {
+			header[name] = append([]string{}, values...)
+		}
+	}
+
+	response.WriteHeader(c.result.statusCode)
+	response.Write(c.result.body)
+}
+
+// Singleflight is an Alice-style decorator that collapses concurrent duplicate requests.  The first request
+// for a given key executes the decorated handler, and duplicates that arrive while it is in flight wait for it
+// to finish.  The status code, headers, and body it produced are then replayed to each waiter.
+//
+// The leader's response is streamed to its client as usual, while a copy of the body is buffered for waiters.
+// Once that copy would exceed MaxBytes, buffering stops and each waiter executes the decorated handler itself.
+// The same happens if the leader panics or hijacks its connection.  Trailers, cookies, and hop-by-hop headers are
+// not replayed, nor are headers that were already set on a waiter's response.
+//
+// The key function must distinguish every request attribute that affects the response, e.g. credentials or
+// negotiated content types.  Otherwise, one client's response may be served to another.
+type Singleflight struct {
+	// Key computes the deduplication key for a request.  If the second return value is false, the request
+	// is never collapsed.  If unset, no decoration is done.  See SingleflightURIKey.
+	Key func(*http.Request) (string, bool)
+
+	// MaxBytes is the largest response body that is shared with waiters.  If nonpositive,
+	// DefaultSingleflightMaxBytes is used.
+	MaxBytes int
+}
+
+func (sf Singleflight) Then(next http.Handler) http.Handler {
+	if sf.Key == nil {
+		return next
+	}
+
+	sh := &singleflightHandler{
+		next:     next,
+		key:      sf.Key,
+		maxBytes: sf.MaxBytes,
+		calls:    make(map[string]*singleflightCall),
+	}
+
+	if sh.maxBytes < 1 {
+		sh.maxBytes = DefaultSingleflightMaxBytes
+	}
+
+	return sh
+}
+
+func (sf Singleflight) ThenFunc(next http.HandlerFunc) http.Handler {
+	return sf.Then(next)
+}
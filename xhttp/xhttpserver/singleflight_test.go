@@ -0,0 +1,323 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleflightURIKey(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		key, ok = SingleflightURIKey(httptest.NewRequest("GET", "/test?a=1", nil))
+	)
+
+	assert.Equal("GET /test?a=1", key)
+	assert.True(ok)
+
+	key, ok = SingleflightURIKey(httptest.NewRequest("HEAD", "/test", nil))
+	assert.Equal("HEAD /test", key)
+	assert.True(ok)
+
+	_, ok = SingleflightURIKey(httptest.NewRequest("POST", "/test", nil))
+	assert.False(ok)
+
+	// requests with different credentials or negotiated content are never duplicates
+	keys := make(map[string]bool)
+	for _, name := range append(SingleflightKeyHeaders(), "") {
+		request := httptest.NewRequest("GET", "/test", nil)
+		if len(name) > 0 {
+			request.Header.Set(name, "value")
+		}
+
+		key, ok := SingleflightURIKey(request)
+		assert.True(ok)
+		assert.False(keys[key], "duplicate key for header %s", name)
+		keys[key] = true
+	}
+
+	first := httptest.NewRequest("GET", "/test", nil)
+	first.Header.Set("Authorization", "Bearer first")
+	second := httptest.NewRequest("GET", "/test", nil)
+	second.Header.Set("Authorization", "Bearer second")
+
+	firstKey, _ := SingleflightURIKey(first)
+	secondKey, _ := SingleflightURIKey(second)
+	assert.NotEqual(firstKey, secondKey)
+}
+
+func testSingleflightNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = Singleflight{MaxBytes: 100}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+// testSingleflightRun starts the given number of identical requests against a decorated handler that blocks
+// until all of them have entered the decorator, then returns the responses along with how many times the
+// decorated handler actually executed
+func testSingleflightRun(t *testing.T, sf Singleflight, count int, next http.HandlerFunc) ([]*httptest.ResponseRecorder, int32) {
+	var (
+		executions int32
+		release    = make(chan struct{})
+		decorated  = sf.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if atomic.AddInt32(&executions, 1) == 1 {
+				<-release
+			}
+
+			next(response, request)
+		}))
+
+		responses = make([]*httptest.ResponseRecorder, count)
+		wg        sync.WaitGroup
+	)
+
+	sh := decorated.(*singleflightHandler)
+	for i := 0; i < count; i++ {
+		responses[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(response *httptest.ResponseRecorder) {
+			defer wg.Done()
+			decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+		}(responses[i])
+	}
+
+	// wait for the leader to register its call, then give the duplicates time to start waiting
+	require.Eventually(t, func() bool {
+		sh.lock.Lock()
+		defer sh.lock.Unlock()
+		return len(sh.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	return responses, atomic.LoadInt32(&executions)
+}
+
+func testSingleflightShared(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		responses, executions = testSingleflightRun(t, Singleflight{Key: SingleflightURIKey}, 5,
+			func(response http.ResponseWriter, request *http.Request) {
+				response.Header().Set("Content-Type", "text/plain")
+				response.Header().Add("X-Test", "a")
+				response.Header().Add("X-Test", "b")
+				response.WriteHeader(299)
+				response.Write([]byte("hello, "))
+				response.Write([]byte("world"))
+			},
+		)
+	)
+
+	assert.Equal(int32(1), executions)
+	for _, response := range responses {
+		assert.Equal(299, response.Code)
+		assert.Equal("text/plain", response.HeaderMap.Get("Content-Type"))
+		assert.Equal([]string{"a", "b"}, response.HeaderMap["X-Test"])
+		assert.Equal("hello, world", response.Body.String())
+	}
+}
+
+func testSingleflightEmpty(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		responses, executions = testSingleflightRun(t, Singleflight{Key: SingleflightURIKey}, 3,
+			func(response http.ResponseWriter, request *http.Request) {
+				response.Header().Set("X-Test", "value")
+			},
+		)
+	)
+
+	assert.Equal(int32(1), executions)
+	for _, response := range responses {
+		assert.Equal(http.StatusOK, response.Code)
+		assert.Equal("value", response.HeaderMap.Get("X-Test"))
+		assert.Zero(response.Body.Len())
+	}
+}
+
+func testSingleflightTooLarge(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		body = strings.Repeat("x", 100)
+
+		responses, executions = testSingleflightRun(t, Singleflight{Key: SingleflightURIKey, MaxBytes: 64}, 3,
+			func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(299)
+				response.Write([]byte(body[:50]))
+				response.Write([]byte(body[50:]))
+			},
+		)
+	)
+
+	assert.Equal(int32(3), executions)
+	for _, response := range responses {
+		assert.Equal(299, response.Code)
+		assert.Equal(body, response.Body.String())
+	}
+}
+
+func testSingleflightNotCollapsed(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		executions int
+		decorated  = Singleflight{Key: SingleflightURIKey}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			executions++
+			response.WriteHeader(299)
+		})
+	)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("POST", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Equal(1, executions)
+
+	// sequential requests each execute the handler
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Equal(3, executions)
+}
+
+func testSingleflightPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		calls     int32
+		release   = make(chan struct{})
+		decorated = Singleflight{Key: SingleflightURIKey}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-release
+				panic("expected")
+			}
+
+			response.WriteHeader(299)
+		})
+
+		leaderDone = make(chan struct{})
+		waiter     = httptest.NewRecorder()
+		waiterDone = make(chan struct{})
+	)
+
+	go func() {
+		defer close(leaderDone)
+		defer func() { recover() }()
+		decorated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	}()
+
+	sh := decorated.(*singleflightHandler)
+	require.Eventually(t, func() bool {
+		sh.lock.Lock()
+		defer sh.lock.Unlock()
+		return len(sh.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	go func() {
+		defer close(waiterDone)
+		decorated.ServeHTTP(waiter, httptest.NewRequest("GET", "/test", nil))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-leaderDone
+	<-waiterDone
+
+	assert.Equal(299, waiter.Code)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func testSingleflightReplayHeaders(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		executions int32
+		release    = make(chan struct{})
+		decorated  = Singleflight{Key: SingleflightURIKey}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			if atomic.AddInt32(&executions, 1) == 1 {
+				<-release
+			}
+
+			response.Header().Set("Content-Type", "text/plain")
+			response.Header().Set("Set-Cookie", "session=leader")
+			response.Header().Set("Connection", "close")
+			response.Header().Set("X-Outer", "leader")
+			response.Write([]byte("body"))
+		})
+
+		// outer middleware sets a header before the decorated handler runs
+		outer = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Header().Set("X-Outer", "outer")
+			decorated.ServeHTTP(response, request)
+		})
+
+		leader = httptest.NewRecorder()
+		waiter = httptest.NewRecorder()
+		done   = make(chan struct{})
+	)
+
+	go func() {
+		defer close(done)
+		outer.ServeHTTP(leader, httptest.NewRequest("GET", "/test", nil))
+	}()
+
+	sh := decorated.(*singleflightHandler)
+	require.Eventually(func() bool {
+		sh.lock.Lock()
+		defer sh.lock.Unlock()
+		return len(sh.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	waited := make(chan struct{})
+	go func() {
+		defer close(waited)
+		outer.ServeHTTP(waiter, httptest.NewRequest("GET", "/test", nil))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-done
+	<-waited
+
+	assert.Equal(int32(1), atomic.LoadInt32(&executions))
+	assert.Equal("session=leader", leader.Header().Get("Set-Cookie"))
+
+	assert.Equal(http.StatusOK, waiter.Code)
+	assert.Equal("body", waiter.Body.String())
+	assert.Equal("text/plain", waiter.Header().Get("Content-Type"))
+	assert.Equal([]string{"outer"}, waiter.Header()["X-Outer"])
+	assert.NotContains(waiter.Header(), "Set-Cookie")
+	assert.NotContains(waiter.Header(), "Connection")
+}
+
+func TestSingleflight(t *testing.T) {
+	t.Run("NoDecoration", testSingleflightNoDecoration)
+	t.Run("Shared", testSingleflightShared)
+	t.Run("Empty", testSingleflightEmpty)
+	t.Run("TooLarge", testSingleflightTooLarge)
+	t.Run("NotCollapsed", testSingleflightNotCollapsed)
+	t.Run("Panic", testSingleflightPanic)
+	t.Run("ReplayHeaders", testSingleflightReplayHeaders)
+}
@@ -0,0 +1,94 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	remoteAddressKey = "remoteAddr"
+	tlsVersionKey    = "tlsVersion"
+	handshakeKey     = "handshake_ms"
+)
+
+// RemoteAddressKey is the logging key for the network address of a client
+func RemoteAddressKey() interface{} {
+	return remoteAddressKey
+}
+
+// TlsVersionKey is the logging key for the negotiated TLS version of a connection
+func TlsVersionKey() interface{} {
+	return tlsVersionKey
+}
+
+// HandshakeKey is the logging key for the time, in milliseconds, taken by a TLS handshake
+func HandshakeKey() interface{} {
+	return handshakeKey
+}
+
+// logSlowHandshakes produces a *tls.Config that logs a warning for each handshake that takes at least the given
+// threshold.  A handshake is timed from the arrival of the client's hello until the connection is verified, so
+// the time a client takes to send its hello is not included.  Handshakes that fail are not logged.
+//
+// The returned configuration delegates to tcfg, including any GetConfigForClient that tcfg has.  Each handshake
+// uses a clone of the delegate configuration.  If threshold is nonpositive or tcfg is nil, tcfg is returned as is.
+func logSlowHandshakes(tcfg *tls.Config, threshold time.Duration, logger log.Logger, clock Clock) *tls.Config {
+	if tcfg == nil || threshold <= 0 {
+		return tcfg
+	}
+
+	clock = clockOrSystem(clock)
+	return &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			var (
+				start = clock.Now()
+				base  = tcfg
+			)
+
+			if tcfg.GetConfigForClient != nil {
+				tc, err := tcfg.GetConfigForClient(hello)
+				if err != nil {
+					return nil, err
+				} else if tc != nil {
+					base = tc
+				}
+			}
+
+			var (
+				clone = base.Clone()
+				next  = base.VerifyConnection
+			)
+
+			clone.GetConfigForClient = nil
+			clone.VerifyConnection = func(cs tls.ConnectionState) error {
+				if elapsed := clock.Since(start); elapsed >= threshold {
+					var remoteAddress string
+					if hello.Conn != nil {
+						remoteAddress = hello.Conn.RemoteAddr().String()
+					}
+
+					logger.Log(
+						level.Key(), level.WarnValue(),
+						xlog.MessageKey(), "slow TLS handshake",
+						RemoteAddressKey(), remoteAddress,
+						TlsVersionKey(), tlsVersionName(cs.Version),
+						HandshakeKey(), int64(elapsed/time.Millisecond),
+					)
+				}
+
+				if next != nil {
+					return next(cs)
+				}
+
+				return nil
+			}
+
+			return clone, nil
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandshakeLog captures the key/value pairs of each entry logged
+type testHandshakeLog struct {
+	lock    sync.Mutex
+	entries []map[interface{}]interface{}
+}
+
+func (thl *testHandshakeLog) Log(keyvals ...interface{}) error {
+	entry := make(map[interface{}]interface{})
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		entry[keyvals[i]] = keyvals[i+1]
+	}
+
+	thl.lock.Lock()
+	thl.entries = append(thl.entries, entry)
+	thl.lock.Unlock()
+	return nil
+}
+
+func (thl *testHandshakeLog) Entries() []map[interface{}]interface{} {
+	thl.lock.Lock()
+	defer thl.lock.Unlock()
+	return append([]map[interface{}]interface{}{}, thl.entries...)
+}
+
+// testSlowHandshake performs a single handshake against the given server configuration, returning the
+// server's handshake error
+func testSlowHandshake(t *testing.T, tcfg *tls.Config) error {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tcfg)
+	require.NoError(t, err)
+	defer l.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			result <- err
+			return
+		}
+
+		defer c.Close()
+		result <- c.(*tls.Conn).Handshake()
+	}()
+
+	c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+	})
+
+	if err == nil {
+		c.Close()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("The server handshake did not complete")
+		return nil
+	}
+}
+
+func testLogSlowHandshakesNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		tcfg   = addServerCertificate(t, nil)
+	)
+
+	assert.Nil(logSlowHandshakes(nil, time.Second, log.NewNopLogger(), nil))
+	assert.Equal(tcfg, logSlowHandshakes(tcfg, 0, log.NewNopLogger(), nil))
+	assert.Equal(tcfg, logSlowHandshakes(tcfg, -1, log.NewNopLogger(), nil))
+}
+
+func testLogSlowHandshakesFast(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output   = new(testHandshakeLog)
+		clock    = newTestClock()
+		verified bool
+
+		base = addServerCertificate(t, &tls.Config{
+			VerifyConnection: func(tls.ConnectionState) error {
+				verified = true
+				return nil
+			},
+		})
+	)
+
+	assert.NoError(testSlowHandshake(t, logSlowHandshakes(base, time.Second, output, clock)))
+	assert.True(verified)
+	assert.Empty(output.Entries())
+}
+
+func testLogSlowHandshakesSlow(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output      = new(testHandshakeLog)
+		clock       = newTestClock()
+		expectedErr = errors.New("expected")
+
+		base = &tls.Config{
+			// simulates a slow handshake, since the handshake is timed starting just before this closure
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				clock.Add(1500 * time.Millisecond)
+				return addServerCertificate(t, &tls.Config{
+					VerifyConnection: func(tls.ConnectionState) error {
+						return expectedErr
+					},
+				}), nil
+			},
+		}
+	)
+
+	// the delegate's verification still applies
+	assert.Equal(expectedErr, testSlowHandshake(t, logSlowHandshakes(base, time.Second, output, clock)))
+
+	entries := output.Entries()
+	require.Len(entries, 1)
+	assert.Equal(level.WarnValue(), entries[0][level.Key()])
+	assert.Equal("TLS1.2", entries[0][TlsVersionKey()])
+	assert.Equal(int64(1500), entries[0][HandshakeKey()])
+
+	host, _, err := net.SplitHostPort(entries[0][RemoteAddressKey()].(string))
+	require.NoError(err)
+	assert.Equal("127.0.0.1", host)
+}
+
+func testLogSlowHandshakesGetConfigForClientError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output = new(testHandshakeLog)
+		base   = &tls.Config{
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return nil, errors.New("expected")
+			},
+		}
+	)
+
+	assert.Error(testSlowHandshake(t, logSlowHandshakes(base, time.Nanosecond, output, nil)))
+	assert.Empty(output.Entries())
+}
+
+func TestLogSlowHandshakes(t *testing.T) {
+	t.Run("NoDecoration", testLogSlowHandshakesNoDecoration)
+	t.Run("Fast", testLogSlowHandshakesFast)
+	t.Run("Slow", testLogSlowHandshakesSlow)
+	t.Run("GetConfigForClientError", testLogSlowHandshakesGetConfigForClientError)
+}
@@ -0,0 +1,87 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/justinas/alice"
+)
+
+// The functions in this file are the individual stages used by NewServerChain.  Each returns an
+// alice.Constructor, which allows callers to compose custom chains from just the stages they need
+// while keeping the same behavior as the standard server chain.
+
+// HeaderStage returns a constructor that sets the given headers on every response.  Any header names in
+// preserveCase are written with their casing intact.  See ResponseHeaders.
+func HeaderStage(h http.Header, preserveCase ...string) alice.Constructor {
+	return ResponseHeaders{Header: h, PreserveCase: preserveCase}.Then
+}
+
+// BusyStage returns a constructor that enforces a maximum number of concurrent requests.  Rejections are rendered
+// with the given ErrorEncoder, or DefaultErrorEncoder if it is nil.  See Busy.
+func BusyStage(maxConcurrentRequests int, ee ErrorEncoder) alice.Constructor {
+	return Busy{
+		MaxConcurrentRequests: maxConcurrentRequests,
+		OnBusy:                NewErrorHandler(ee, http.StatusTooManyRequests),
+	}.Then
+}
+
+// ContentTypeStage returns a constructor that enforces an allowlist of request media types for the given methods.
+// Rejections are rendered with the given ErrorEncoder, or DefaultErrorEncoder if it is nil.  See ContentType.
+func ContentTypeStage(allowed, methods []string, ee ErrorEncoder) alice.Constructor {
+	return ContentType{
+		Allowed:       allowed,
+		Methods:       methods,
+		OnUnsupported: NewErrorHandler(ee, http.StatusUnsupportedMediaType),
+	}.Then
+}
+
+// BodyTimeoutStage returns a constructor that enforces a deadline, and optionally a minimum rate, for reading
+// request bodies.  The readTimeout is the server's ReadTimeout, if any.  Rejections are rendered with the given
+// ErrorEncoder, or DefaultErrorEncoder if it is nil.  The Clock is optional.  See BodyTimeout.
+func BodyTimeoutStage(timeout time.Duration, minBytesPerSecond int64, readTimeout time.Duration, ee ErrorEncoder, c Clock) alice.Constructor {
+	return BodyTimeout{
+		Timeout:           timeout,
+		MinBytesPerSecond: minBytesPerSecond,
+		ReadTimeout:       readTimeout,
+		OnTimeout:         NewErrorHandler(ee, http.StatusRequestTimeout),
+		Clock:             c,
+	}.Then
+}
+
+// ProtocolStage returns a constructor that stores the normalized protocol in each request's context.
+// See UseProtocol.
+func ProtocolStage() alice.Constructor {
+	return UseProtocol
+}
+
+// CharsetStage returns a constructor that adds charset=utf-8 to textual response Content-Types.  See Charset.
+func CharsetStage(types ...string) alice.Constructor {
+	return Charset{Types: types}.Then
+}
+
+// TrackingStage returns a constructor that decorates each response as a TrackingWriter.  See UseTrackingWriter.
+func TrackingStage() alice.Constructor {
+	return UseTrackingWriter
+}
+
+// TracingStage returns a constructor that starts an OpenTelemetry server span for each request using the
+// global tracer provider.  See Trace.
+func TracingStage() alice.Constructor {
+	return Trace{}.Then
+}
+
+// LoggingStage returns a constructor that binds a contextual request logger, derived from the given
+// base logger and parameter builders, to each request.  See xloghttp.Logging.
+func LoggingStage(l log.Logger, pb ...xloghttp.ParameterBuilder) alice.Constructor {
+	return xloghttp.Logging{Base: l, Builders: pb}.Then
+}
+
+// RecoveryStage returns a constructor that recovers from handler panics, logging them to the given logger.
+// If the logger is nil, the request's contextual logger is used.  See Recovery.
+func RecoveryStage(l log.Logger) alice.Constructor {
+	return Recovery{Logger: l}.Then
+}
@@ -0,0 +1,80 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStages(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		base   = log.NewJSONLogger(&output)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Implements((*TrackingWriter)(nil), response)
+			assert.Equal("value", response.Header().Get("X-Stage"))
+			assert.Equal(ProtocolHTTP11, ProtocolFromContext(request.Context()))
+			xlog.Get(request.Context()).Log("foo", "bar")
+			panic("expected panic")
+		})
+
+		chain = alice.New(
+			RecoveryStage(base),
+			HeaderStage(http.Header{"X-Stage": []string{"value"}}),
+			BusyStage(10, nil),
+			ContentTypeStage([]string{"application/json"}, nil, JSONErrorEncoder),
+			BodyTimeoutStage(time.Minute, 0, 0, nil, nil),
+			ProtocolStage(),
+			CharsetStage(),
+			TrackingStage(),
+			LoggingStage(base, xloghttp.Method("requestMethod")),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/foo", nil)
+	)
+
+	decorated := chain.Then(next)
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Contains(output.String(), "requestMethod")
+	assert.Contains(output.String(), "expected panic")
+}
+
+func TestStagesErrorEncoder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Fail("the handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", bytes.NewBufferString("body"))
+	)
+
+	request.Header.Set("Content-Type", "text/plain")
+	ContentTypeStage([]string{"application/json"}, nil, JSONErrorEncoder)(next).ServeHTTP(response, request)
+	assert.Equal(http.StatusUnsupportedMediaType, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	response = httptest.NewRecorder()
+	ContentTypeStage([]string{"application/json"}, nil, nil)(next).ServeHTTP(response, request)
+	assert.Equal(http.StatusUnsupportedMediaType, response.Code)
+	assert.Equal("Unsupported Media Type\n", response.Body.String())
+}
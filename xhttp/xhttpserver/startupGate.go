@@ -0,0 +1,117 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// DefaultStartupRetryAfter is the Retry-After interval used by StartupGate when none is configured
+const DefaultStartupRetryAfter = 5 * time.Second
+
+// Gate is a concurrency-safe switch that controls whether a StartupGate passes requests through.
+// The zero value is an open Gate.
+type Gate struct {
+	closed int32
+}
+
+// ProvideGate is an uber/fx provider that creates a closed Gate.  Use OpenOnStart to open the Gate
+// once the application has started.
+func ProvideGate() *Gate {
+	g := new(Gate)
+	g.Close()
+	return g
+}
+
+// IsOpen tests if requests should be passed through
+func (g *Gate) IsOpen() bool {
+	return atomic.LoadInt32(&g.closed) == 0
+}
+
+// Open allows requests to pass through
+func (g *Gate) Open() {
+	atomic.StoreInt32(&g.closed, 0)
+}
+
+// Close causes requests to be rejected
+func (g *Gate) Close() {
+	atomic.StoreInt32(&g.closed, 1)
+}
+
+// OpenOnStartIn defines the dependencies for OpenOnStart
+type OpenOnStartIn struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Gate      *Gate
+}
+
+// OpenOnStart is an uber/fx Invoke function that opens the Gate once every OnStart hook has completed.
+// Since uber/fx runs OnStart hooks in the order they were appended, this function must be one of the last
+// Invoke functions for an application.  The Gate is not closed on stop, so that servers can drain normally.
+func OpenOnStart(in OpenOnStartIn) {
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			in.Gate.Open()
+			return nil
+		},
+	})
+}
+
+// StartupGate is an Alice-style decorator that rejects requests while a Gate is closed.  This prevents
+// requests from reaching handlers whose dependencies have not finished initializing, even though the
+// server's listener is already accepting connections.
+type StartupGate struct {
+	// Gate is the switch that controls this decorator.  If unset, no decoration is done.
+	Gate *Gate
+
+	// RetryAfter is the interval sent in the Retry-After header of rejected requests.  If unset,
+	// DefaultStartupRetryAfter is used.
+	RetryAfter time.Duration
+
+	// Exempt is the optional set of URI paths, e.g. health and readiness endpoints, that are never rejected
+	Exempt []string
+
+	// OnClosed is the optional handler for rejected requests.  If unset, a 503 is returned.  In either case,
+	// the Retry-After header is set prior to invoking this handler.
+	OnClosed http.Handler
+}
+
+func (sg StartupGate) Then(next http.Handler) http.Handler {
+	if sg.Gate == nil {
+		return next
+	}
+
+	retryAfter := sg.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = DefaultStartupRetryAfter
+	}
+
+	var (
+		retryAfterHeader = retryAfterValue(retryAfter)
+		exempt           = newExemptPaths(sg.Exempt)
+	)
+
+	onClosed := sg.OnClosed
+	if onClosed == nil {
+		onClosed = Constant{StatusCode: http.StatusServiceUnavailable}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if sg.Gate.IsOpen() || exempt.contains(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		response.Header().Set("Retry-After", retryAfterHeader)
+		MarkRejected(request.Context(), "startupGate")
+		onClosed.ServeHTTP(response, request)
+	})
+}
+
+func (sg StartupGate) ThenFunc(next http.HandlerFunc) http.Handler {
+	return sg.Then(next)
+}
@@ -0,0 +1,122 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestGate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		g      Gate
+	)
+
+	assert.True(g.IsOpen())
+	g.Close()
+	assert.False(g.IsOpen())
+	g.Open()
+	assert.True(g.IsOpen())
+
+	assert.False(ProvideGate().IsOpen())
+}
+
+func TestOpenOnStart(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		lc     = fxtest.NewLifecycle(t)
+		g      = ProvideGate()
+	)
+
+	OpenOnStart(OpenOnStartIn{Lifecycle: lc, Gate: g})
+	assert.False(g.IsOpen())
+	lc.RequireStart()
+	assert.True(g.IsOpen())
+	lc.RequireStop()
+	assert.True(g.IsOpen())
+}
+
+func testStartupGateNoGate(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = StartupGate{}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Empty(response.Header().Get("Retry-After"))
+}
+
+func testStartupGateDefaults(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		g         = ProvideGate()
+		decorated = StartupGate{
+			Gate:   g,
+			Exempt: []string{"/health"},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+	)
+
+	require.NotNil(decorated)
+
+	response := httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal("5", response.Header().Get("Retry-After"))
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(299, response.Code)
+
+	g.Open()
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Empty(response.Header().Get("Retry-After"))
+}
+
+func testStartupGateCustom(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = StartupGate{
+			Gate:       ProvideGate(),
+			RetryAfter: 1500 * time.Millisecond,
+			OnClosed: http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(599)
+			}),
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Fail("The next handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(599, response.Code)
+	assert.Equal("2", response.Header().Get("Retry-After"))
+}
+
+func TestStartupGate(t *testing.T) {
+	t.Run("NoGate", testStartupGateNoGate)
+	t.Run("Defaults", testStartupGateDefaults)
+	t.Run("Custom", testStartupGateCustom)
+}
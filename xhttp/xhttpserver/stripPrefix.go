@@ -0,0 +1,99 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type originalPathContextKey struct{}
+
+// WithOriginalPath returns a new context with the given original request path
+func WithOriginalPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, originalPathContextKey{}, path)
+}
+
+// OriginalPathFromContext returns the request path as sent by the client, prior to any prefix being stripped
+// by StripPrefix.  If no original path is present, this function returns the empty string.
+func OriginalPathFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(originalPathContextKey{}).(string)
+	return p
+}
+
+// StripPrefix is an Alice-style decorator that removes a path prefix from each request before it is routed,
+// which is useful behind a path-based router that forwards /serviceA/... to this server.  Unlike http.StripPrefix,
+// the prefix only matches whole path segments, so /serviceA matches /serviceA and /serviceA/foo but not
+// /serviceAfoo.  The stripped path always begins with a slash.  Requests that are not under the prefix are
+// rejected.
+//
+// Only the request's URL is changed.  RequestURI is left as the client sent it, and the original path is
+// available via OriginalPathFromContext.  Decorators that precede this one, e.g. access logging, see the full path.
+type StripPrefix struct {
+	// Prefix is the path prefix to remove.  Any trailing slash is ignored.  If unset, no decoration is done.
+	Prefix string
+
+	// NotFound is the optional handler for requests that are not under the prefix.  If unset, a 404 is returned.
+	NotFound http.Handler
+}
+
+// stripSegments removes a prefix from a path at a segment boundary
+func stripSegments(prefix, path string) (string, bool) {
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+
+	rest := path[len(prefix):]
+	switch {
+	case len(rest) == 0:
+		return "/", true
+
+	case rest[0] == '/':
+		return rest, true
+
+	default:
+		return "", false
+	}
+}
+
+func (sp StripPrefix) Then(next http.Handler) http.Handler {
+	prefix := strings.TrimSuffix(sp.Prefix, "/")
+	if len(prefix) == 0 {
+		return next
+	}
+
+	notFound := sp.NotFound
+	if notFound == nil {
+		notFound = Constant{StatusCode: http.StatusNotFound}.NewHandler()
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		path, ok := stripSegments(prefix, request.URL.Path)
+		if !ok {
+			MarkRejected(request.Context(), "stripPrefix")
+			notFound.ServeHTTP(response, request)
+			return
+		}
+
+		var rawPath string
+		if len(request.URL.RawPath) > 0 {
+			if rawPath, ok = stripSegments(prefix, request.URL.RawPath); !ok {
+				// the prefix was only present once the path was decoded
+				MarkRejected(request.Context(), "stripPrefix")
+				notFound.ServeHTTP(response, request)
+				return
+			}
+		}
+
+		stripped := request.WithContext(WithOriginalPath(request.Context(), request.URL.Path))
+		stripped.URL = new(url.URL)
+		*stripped.URL = *request.URL
+		stripped.URL.Path = path
+		stripped.URL.RawPath = rawPath
+		next.ServeHTTP(response, stripped)
+	})
+}
+
+func (sp StripPrefix) ThenFunc(next http.HandlerFunc) http.Handler {
+	return sp.Then(next)
+}
@@ -0,0 +1,106 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginalPathFromContext(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(OriginalPathFromContext(context.Background()))
+	assert.Equal("/test", OriginalPathFromContext(WithOriginalPath(context.Background(), "/test")))
+}
+
+func testStripPrefixNoDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = Constant{StatusCode: 299}.NewHandler()
+	)
+
+	assert.Equal(next, StripPrefix{}.Then(next))
+	assert.Equal(next, StripPrefix{Prefix: "/"}.Then(next))
+}
+
+func testStripPrefixStrip(t *testing.T, prefix string) {
+	testData := []struct {
+		target             string
+		expectedStatusCode int
+		expectedPath       string
+		expectedRawPath    string
+	}{
+		{"/service", 299, "/", ""},
+		{"/service/", 299, "/", ""},
+		{"/service/foo/bar?x=1", 299, "/foo/bar", ""},
+		{"/service/a%2Fb", 299, "/a/b", "/a%2Fb"},
+		{"/servicefoo", http.StatusNotFound, "", ""},
+		{"/other/service", http.StatusNotFound, "", ""},
+		{"/", http.StatusNotFound, "", ""},
+	}
+
+	for _, record := range testData {
+		t.Run(record.target, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				actualPath, actualRawPath, originalPath, requestURI string
+
+				decorated = StripPrefix{Prefix: prefix}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+					actualPath = request.URL.Path
+					actualRawPath = request.URL.RawPath
+					originalPath = OriginalPathFromContext(request.Context())
+					requestURI = request.RequestURI
+					response.WriteHeader(299)
+				})
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", record.target, nil)
+				original = request.URL.Path
+			)
+
+			decorated.ServeHTTP(response, request)
+			assert.Equal(record.expectedStatusCode, response.Code)
+			assert.Equal(original, request.URL.Path, "the original request should be unchanged")
+			if record.expectedStatusCode == 299 {
+				assert.Equal(record.expectedPath, actualPath)
+				assert.Equal(record.expectedRawPath, actualRawPath)
+				assert.Equal(original, originalPath)
+				assert.Equal(record.target, requestURI)
+			}
+		})
+	}
+}
+
+func testStripPrefixCustomNotFound(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = StripPrefix{
+			Prefix:   "/service",
+			NotFound: Constant{StatusCode: 499}.NewHandler(),
+		}.ThenFunc(func(http.ResponseWriter, *http.Request) {
+			assert.Fail("The decorated handler should not have been called")
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/other", nil))
+	assert.Equal(499, response.Code)
+}
+
+func TestStripPrefix(t *testing.T) {
+	t.Run("NoDecoration", testStripPrefixNoDecoration)
+	t.Run("Strip", func(t *testing.T) {
+		testStripPrefixStrip(t, "/service")
+	})
+
+	t.Run("StripTrailingSlash", func(t *testing.T) {
+		testStripPrefixStrip(t, "/service/")
+	})
+
+	t.Run("CustomNotFound", testStripPrefixCustomNotFound)
+}
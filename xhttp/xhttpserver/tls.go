@@ -1,18 +1,67 @@
 package xhttpserver
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/xmidt-org/themis/config"
 )
 
 var (
 	ErrTlsCertificateRequired         = errors.New("Both a certificateFile and keyFile are required")
 	ErrUnableToAddClientCACertificate = errors.New("Unable to add client CA certificate")
+	ErrTlsFileEmpty                   = errors.New("File is empty")
+	ErrTlsVersionFloorUnreachable     = errors.New("A versionFloor has no effect unless minVersion is below it")
 )
 
+// TlsFileError indicates that one of the files referenced by the Tls options is missing, empty,
+// or otherwise cannot be used.
+type TlsFileError struct {
+	// Field is the name of the Tls configuration field, e.g. certificateFile
+	Field string
+
+	// File is the file system path that was configured for the Field
+	File string
+
+	// Err is the underlying cause
+	Err error
+}
+
+func (tfe TlsFileError) Error() string {
+	return fmt.Sprintf("Invalid %s [%s]: %s", tfe.Field, tfe.File, tfe.Err)
+}
+
+// checkTlsFile verifies that a given file exists, is a regular file, and is not empty.  This allows more
+// descriptive errors than what crypto/tls returns, which omit which file had a problem.
+func checkTlsFile(field, file string) error {
+	fi, err := os.Stat(file)
+	switch {
+	case err != nil:
+		return TlsFileError{Field: field, File: file, Err: err}
+
+	case fi.IsDir():
+		return TlsFileError{Field: field, File: file, Err: errors.New("File is a directory")}
+
+	case fi.Size() == 0:
+		return TlsFileError{Field: field, File: file, Err: ErrTlsFileEmpty}
+
+	default:
+		return nil
+	}
+}
+
 // PeerVerifyError represents a verification error for a particular certificate
 type PeerVerifyError struct {
 	Certificate *x509.Certificate
@@ -23,6 +72,81 @@ func (pve PeerVerifyError) Error() string {
 	return pve.Reason
 }
 
+// TlsVersionError is returned during a TLS handshake when the negotiated version is below the
+// configured VersionFloor.  Since net/http logs handshake errors along with the remote address,
+// this error gives operators visibility into which clients are still using legacy TLS versions.
+type TlsVersionError struct {
+	Version uint16
+	Floor   uint16
+}
+
+func (tve TlsVersionError) Error() string {
+	return fmt.Sprintf(
+		"Rejected TLS version %s, which is below the minimum allowed version %s",
+		tlsVersionName(tve.Version),
+		tlsVersionName(tve.Floor),
+	)
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionSSL30:
+		return "SSLv3"
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	default:
+		return fmt.Sprintf("0x%04x", v)
+	}
+}
+
+// NewVersionFloorVerifier produces a closure for crypto/tls.Config.VerifyConnection that rejects
+// any connection whose negotiated version is below the given floor with a TlsVersionError.
+func NewVersionFloorVerifier(floor uint16) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if cs.Version < floor {
+			return TlsVersionError{Version: cs.Version, Floor: floor}
+		}
+
+		return nil
+	}
+}
+
+// composeVerifyConnection produces a closure for crypto/tls.Config.VerifyConnection that executes each
+// non-nil verifier in order, stopping at the first error.  If there are no verifiers, this function returns nil.
+func composeVerifyConnection(verifiers ...func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	var composed []func(tls.ConnectionState) error
+	for _, v := range verifiers {
+		if v != nil {
+			composed = append(composed, v)
+		}
+	}
+
+	switch len(composed) {
+	case 0:
+		return nil
+
+	case 1:
+		return composed[0]
+
+	default:
+		return func(cs tls.ConnectionState) error {
+			for _, v := range composed {
+				if err := v(cs); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+}
+
 // PeerVerifyOptions allows common checks against a client-side certificate to be configured externally.  Any constraint that matches
 // will result in a valid peer cert.
 type PeerVerifyOptions struct {
@@ -106,6 +230,65 @@ func NewConfiguredPeerVerifier(pvo PeerVerifyOptions) *ConfiguredPeerVerifier {
 	return cpv
 }
 
+const (
+	// DefaultMinRSAKeyBits is the smallest RSA client key allowed by a ClientKeyStrength, per NIST SP 800-131A
+	DefaultMinRSAKeyBits = 2048
+
+	// DefaultMinECKeyBits is the smallest elliptic curve, by bit size, allowed by a ClientKeyStrength, i.e. P-256
+	DefaultMinECKeyBits = 256
+)
+
+// ClientKeyStrength is a PeerVerifier that rejects client certificates with weak public keys.  This allows weak
+// certificates to be phased out without rotating the CA that issued them.  Ed25519 keys are always allowed, and keys
+// of any other type are rejected.  Each rejection is a PeerVerifyError that describes the key, which net/http logs
+// along with the client's address.
+type ClientKeyStrength struct {
+	// MinRSABits is the smallest RSA modulus allowed.  If unset, DefaultMinRSAKeyBits is used.
+	MinRSABits int
+
+	// MinECBits is the smallest ECDSA curve size allowed.  If unset, DefaultMinECKeyBits is used.
+	MinECBits int
+}
+
+func (cks ClientKeyStrength) Verify(peerCert *x509.Certificate, _ [][]*x509.Certificate) error {
+	minRSABits := cks.MinRSABits
+	if minRSABits <= 0 {
+		minRSABits = DefaultMinRSAKeyBits
+	}
+
+	minECBits := cks.MinECBits
+	if minECBits <= 0 {
+		minECBits = DefaultMinECKeyBits
+	}
+
+	var reason string
+	switch key := peerCert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < minRSABits {
+			reason = fmt.Sprintf("RSA key of %d bits is below the minimum of %d bits", bits, minRSABits)
+		}
+
+	case *ecdsa.PublicKey:
+		if bits := key.Curve.Params().BitSize; bits < minECBits {
+			reason = fmt.Sprintf("EC key on curve %s is below the minimum of %d bits", key.Curve.Params().Name, minECBits)
+		}
+
+	case ed25519.PublicKey:
+
+	default:
+		reason = fmt.Sprintf("Unsupported public key algorithm %s", peerCert.PublicKeyAlgorithm)
+	}
+
+	if len(reason) > 0 {
+		return PeerVerifyError{
+			Certificate: peerCert,
+			Reason:      fmt.Sprintf("Weak key in certificate for %s: %s", peerCert.Subject, reason),
+		}
+	}
+
+	return nil
+}
+
 // PeerVerifiers is a sequence of verification strategies.  All of the verifiers must return nil errors for
 // a given peer cert to be considered valid.
 type PeerVerifiers []PeerVerifier
@@ -161,16 +344,69 @@ type Tls struct {
 	ClientCACertificateFile string
 	ServerName              string
 	NextProtos              []string
-	MinVersion              uint16
-	MaxVersion              uint16
+	MinVersion              config.TlsVersion
+	MaxVersion              config.TlsVersion
 	PeerVerify              PeerVerifyOptions
+
+	// ClientKeyStrength, if set, rejects client certificates whose public keys are weaker than its minimums.  Its
+	// zero value enforces DefaultMinRSAKeyBits and DefaultMinECKeyBits.
+	ClientKeyStrength *ClientKeyStrength
+
+	// ClientCertificateOptional verifies client certificates against ClientCACertificateFile only when clients
+	// present them, i.e. tls.VerifyClientCertIfGiven, rather than requiring them for every connection.  This is
+	// useful along with RequireClientCertificate to require certificates only for some paths.
+	ClientCertificateOptional bool
+
+	// VersionFloor is the minimum TLS version that is allowed to complete a handshake.  Unlike MinVersion,
+	// which silently closes connections from legacy clients, connections below this floor are rejected
+	// with a TlsVersionError that is logged along with the client's address.  This is useful to discover
+	// legacy clients prior to raising MinVersion.  If unset, no floor is enforced.
+	//
+	// Connections below MinVersion never reach this check, so MinVersion must be set below VersionFloor for the
+	// floor to have any effect.  NewTlsConfig returns ErrTlsVersionFloorUnreachable if MinVersion is set at or
+	// above VersionFloor.  If MinVersion is unset, crypto/tls applies its own default minimum, TLS 1.2 as of Go
+	// 1.22, so a floor at or below that default also has no effect.
+	VersionFloor config.TlsVersion
+
+	// VerifyConnection is an optional, application-defined policy applied to each connection after its handshake,
+	// e.g. to require particular SANs or reject certain issuers.  It runs after this package's own checks, such as
+	// VersionFloor.  Any error fails the handshake and, like a TlsVersionError, is logged along with the client's
+	// address.  This field cannot be unmarshalled and must be set in code.
+	VerifyConnection func(tls.ConnectionState) error `json:"-"`
+
+	// ValidateOnStart causes the server's TLS configuration to be checked with ValidateTlsConfig when the server
+	// starts.  Any problem then fails startup rather than the first client handshake.
+	ValidateOnStart bool
+}
+
+// appendNextProtos adds the names of each protocol in a TLSNextProto map to the tls.Config's NextProtos,
+// if not already present.  The new names are sorted, so that the ALPN preference order is deterministic.
+func appendNextProtos(tc *tls.Config, tlsNextProto map[string]func(*http.Server, *tls.Conn, http.Handler)) {
+	if tc == nil || len(tlsNextProto) == 0 {
+		return
+	}
+
+	existing := make(map[string]bool, len(tc.NextProtos))
+	for _, np := range tc.NextProtos {
+		existing[np] = true
+	}
+
+	var more []string
+	for np := range tlsNextProto {
+		if !existing[np] {
+			more = append(more, np)
+		}
+	}
+
+	sort.Strings(more)
+	tc.NextProtos = append(tc.NextProtos, more...)
 }
 
 // NewTlsConfig produces a *tls.Config from a set of configuration options.  If the supplied set of options
 // is nil, this function returns nil with no error.
 //
 // If supplied, the PeerVerifier strategies will be executed as part of peer verification.  This allows application-layer
-// logic to be injected.
+// logic to be injected.  Any ClientKeyStrength check runs after those strategies.
 func NewTlsConfig(t *Tls, extra ...PeerVerifier) (*tls.Config, error) {
 	if t == nil {
 		return nil, nil
@@ -191,16 +427,39 @@ func NewTlsConfig(t *Tls, extra ...PeerVerifier) (*tls.Config, error) {
 	}
 
 	tc := &tls.Config{
-		MinVersion: t.MinVersion,
-		MaxVersion: t.MaxVersion,
+		MinVersion: uint16(t.MinVersion),
+		MaxVersion: uint16(t.MaxVersion),
 		ServerName: t.ServerName,
 		NextProtos: nextProtos,
 	}
 
+	if t.ClientKeyStrength != nil {
+		extra = append(extra[:len(extra):len(extra)], *t.ClientKeyStrength)
+	}
+
 	if pvs := NewPeerVerifiers(t.PeerVerify, extra...); len(pvs) > 0 {
 		tc.VerifyPeerCertificate = pvs.VerifyPeerCertificate
 	}
 
+	var versionFloor func(tls.ConnectionState) error
+	if t.VersionFloor > 0 {
+		if t.MinVersion >= t.VersionFloor {
+			return nil, ErrTlsVersionFloorUnreachable
+		}
+
+		versionFloor = NewVersionFloorVerifier(uint16(t.VersionFloor))
+	}
+
+	tc.VerifyConnection = composeVerifyConnection(versionFloor, t.VerifyConnection)
+
+	if err := checkTlsFile("certificateFile", t.CertificateFile); err != nil {
+		return nil, err
+	}
+
+	if err := checkTlsFile("keyFile", t.KeyFile); err != nil {
+		return nil, err
+	}
+
 	if cert, err := tls.LoadX509KeyPair(t.CertificateFile, t.KeyFile); err != nil {
 		return nil, err
 	} else {
@@ -208,6 +467,10 @@ func NewTlsConfig(t *Tls, extra ...PeerVerifier) (*tls.Config, error) {
 	}
 
 	if len(t.ClientCACertificateFile) > 0 {
+		if err := checkTlsFile("clientCACertificateFile", t.ClientCACertificateFile); err != nil {
+			return nil, err
+		}
+
 		caCert, err := ioutil.ReadFile(t.ClientCACertificateFile)
 		if err != nil {
 			return nil, err
@@ -219,9 +482,103 @@ func NewTlsConfig(t *Tls, extra ...PeerVerifier) (*tls.Config, error) {
 		}
 
 		tc.ClientCAs = caCertPool
-		tc.ClientAuth = tls.RequireAndVerifyClientCert
+		if t.ClientCertificateOptional {
+			tc.ClientAuth = tls.VerifyClientCertIfGiven
+		} else {
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
 	}
 
 	tc.BuildNameToCertificate()
 	return tc, nil
 }
+
+// TlsValidationError indicates that a *tls.Config cannot be used to serve clients.  This error
+// is returned by ValidateTlsConfig.
+type TlsValidationError struct {
+	Err error
+}
+
+func (tve TlsValidationError) Error() string {
+	return fmt.Sprintf("Invalid TLS configuration: %s", tve.Err)
+}
+
+func (tve TlsValidationError) Unwrap() error {
+	return tve.Err
+}
+
+// ValidateTlsConfig checks that a serverside *tls.Config is usable.  Each certificate must be within its validity
+// period, as of the configuration's Time function if set, and the configuration must be able to complete an in-memory
+// handshake with itself.  That handshake exercises the certificates, keys, versions, and cipher suites, though not any
+// client certificate requirements or peer verification, since those depend on the client.  If tc is nil, this function
+// does nothing.
+//
+// Any problem is returned as a TlsValidationError.
+func ValidateTlsConfig(tc *tls.Config) error {
+	if tc == nil {
+		return nil
+	}
+
+	now := time.Now
+	if tc.Time != nil {
+		now = tc.Time
+	}
+
+	for _, cert := range tc.Certificates {
+		leaf := cert.Leaf
+		if leaf == nil && len(cert.Certificate) > 0 {
+			var err error
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return TlsValidationError{Err: err}
+			}
+		}
+
+		if leaf == nil {
+			continue
+		}
+
+		if t := now(); t.Before(leaf.NotBefore) {
+			return TlsValidationError{Err: fmt.Errorf("Certificate for %s is not valid until %s", leaf.Subject, leaf.NotBefore)}
+		} else if t.After(leaf.NotAfter) {
+			return TlsValidationError{Err: fmt.Errorf("Certificate for %s expired at %s", leaf.Subject, leaf.NotAfter)}
+		}
+	}
+
+	server := tc.Clone()
+	server.ClientAuth = tls.NoClientCert
+	server.VerifyPeerCertificate = nil
+	server.VerifyConnection = nil
+
+	client := &tls.Config{
+		// the server's certificates were checked above, and may not be trusted by this host
+		InsecureSkipVerify: true,
+		MinVersion:         tc.MinVersion,
+		MaxVersion:         tc.MaxVersion,
+		NextProtos:         tc.NextProtos,
+		Time:               tc.Time,
+	}
+
+	var (
+		serverConn, clientConn = net.Pipe()
+		deadline               = time.Now().Add(DefaultHandshakeTimeout)
+		serverErr              = make(chan error, 1)
+	)
+
+	serverConn.SetDeadline(deadline)
+	clientConn.SetDeadline(deadline)
+	go func() {
+		defer serverConn.Close()
+		serverErr <- tls.Server(serverConn, server).Handshake()
+	}()
+
+	clientErr := tls.Client(clientConn, client).Handshake()
+	clientConn.Close()
+
+	if err := <-serverErr; err != nil {
+		return TlsValidationError{Err: err}
+	} else if clientErr != nil {
+		return TlsValidationError{Err: clientErr}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrNoTlsPolicy = errors.New("No TLS policy has been installed")
+)
+
+// TlsPolicy holds a server's current *tls.Config, which can be atomically replaced while the server is running.
+// Each TLS handshake uses whichever configuration is current at the time, so changes to cipher suites, versions,
+// certificates, or client CAs take effect for new connections without a restart.  Existing connections are unaffected.
+//
+// The zero value of this type has no configuration, and handshakes fail until one is installed.
+type TlsPolicy struct {
+	current      atomic.Value
+	tlsNextProto map[string]func(*http.Server, *tls.Conn, http.Handler)
+}
+
+// Current returns the currently installed *tls.Config, or nil if none has been installed.  The returned
+// configuration must not be modified.
+func (tp *TlsPolicy) Current() *tls.Config {
+	tc, _ := tp.current.Load().(*tls.Config)
+	return tc
+}
+
+// Set installs a new *tls.Config, which will be used for all subsequent handshakes.  The given
+// configuration must not be modified after it has been passed to this method.
+func (tp *TlsPolicy) Set(tc *tls.Config) {
+	tp.current.Store(tc)
+}
+
+// Update builds a new *tls.Config from configuration options using NewTlsConfig and installs it.  Any protocols
+// from the server's Options.TLSNextProto are advertised as with the original configuration.  If an error occurs,
+// the current configuration is left in place.
+func (tp *TlsPolicy) Update(t *Tls, extra ...PeerVerifier) error {
+	if t == nil {
+		return ErrNoTlsPolicy
+	}
+
+	tc, err := NewTlsConfig(t, extra...)
+	if err != nil {
+		return err
+	}
+
+	appendNextProtos(tc, tp.tlsNextProto)
+	tp.Set(tc)
+	return nil
+}
+
+// GetConfigForClient may be used as the closure for crypto/tls.Config.GetConfigForClient.  It returns the
+// current configuration, or ErrNoTlsPolicy if none has been installed.
+func (tp *TlsPolicy) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	if tc := tp.Current(); tc != nil {
+		return tc, nil
+	}
+
+	return nil, ErrNoTlsPolicy
+}
+
+// Config returns a *tls.Config that delegates every handshake to this policy.  This is the configuration
+// to use with a listener.
+func (tp *TlsPolicy) Config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: tp.GetConfigForClient,
+	}
+}
+
+// TlsPolicies is a registry of the TlsPolicy for each TLS server in an application, keyed by server name.
+// When this component is present, servers created by Unmarshal register their policies here so that
+// application code can change TLS configuration at runtime.
+type TlsPolicies struct {
+	lock     sync.RWMutex
+	policies map[string]*TlsPolicy
+}
+
+// ProvideTlsPolicies is an uber/fx provider that creates an empty TlsPolicies
+func ProvideTlsPolicies() *TlsPolicies {
+	return &TlsPolicies{
+		policies: make(map[string]*TlsPolicy),
+	}
+}
+
+// Get returns the TlsPolicy for the given server name, or nil if no such server uses TLS
+func (tp *TlsPolicies) Get(name string) *TlsPolicy {
+	tp.lock.RLock()
+	p := tp.policies[name]
+	tp.lock.RUnlock()
+	return p
+}
+
+// Names returns the names of the servers that have registered policies, in no particular order
+func (tp *TlsPolicies) Names() []string {
+	tp.lock.RLock()
+	names := make([]string, 0, len(tp.policies))
+	for n := range tp.policies {
+		names = append(names, n)
+	}
+
+	tp.lock.RUnlock()
+	return names
+}
+
+// register creates and stores the TlsPolicy for the given server options
+func (tp *TlsPolicies) register(name string, o Options) *TlsPolicy {
+	p := &TlsPolicy{
+		tlsNextProto: o.TLSNextProto,
+	}
+
+	tp.lock.Lock()
+	if tp.policies == nil {
+		tp.policies = make(map[string]*TlsPolicy)
+	}
+
+	tp.policies[name] = p
+	tp.lock.Unlock()
+	return p
+}
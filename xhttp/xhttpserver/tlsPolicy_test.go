@@ -0,0 +1,141 @@
+package xhttpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTlsPolicyZeroValue(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		tp     TlsPolicy
+	)
+
+	assert.Nil(tp.Current())
+
+	tc, err := tp.GetConfigForClient(nil)
+	assert.Nil(tc)
+	assert.Equal(ErrNoTlsPolicy, err)
+
+	assert.Equal(ErrNoTlsPolicy, tp.Update(nil))
+	assert.Nil(tp.Current())
+}
+
+func testTlsPolicyUpdate(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		noop = func(*http.Server, *tls.Conn, http.Handler) {}
+		tp   = TlsPolicy{
+			tlsNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){"custom": noop},
+		}
+	)
+
+	require.NoError(tp.Update(&Tls{CertificateFile: certificateFile, KeyFile: keyFile}))
+	first := tp.Current()
+	require.NotNil(first)
+	assert.Equal([]string{"http/1.1", "custom"}, first.NextProtos)
+
+	actual, err := tp.Config().GetConfigForClient(nil)
+	assert.NoError(err)
+	assert.True(first == actual)
+
+	// a failed update must leave the current configuration in place
+	assert.Error(tp.Update(&Tls{CertificateFile: certificateFile}))
+	assert.True(first == tp.Current())
+
+	require.NoError(tp.Update(&Tls{CertificateFile: certificateFile, KeyFile: keyFile, MinVersion: tls.VersionTLS13}))
+	second := tp.Current()
+	require.NotNil(second)
+	assert.False(first == second)
+	assert.Equal(uint16(tls.VersionTLS13), second.MinVersion)
+}
+
+func testTlsPolicyHandshake(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tp TlsPolicy
+	)
+
+	require.NoError(tp.Update(&Tls{CertificateFile: certificateFile, KeyFile: keyFile}))
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tp.Config())
+	require.NoError(err)
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			c.(*tls.Conn).Handshake()
+			c.Close()
+		}
+	}()
+
+	handshake := func() error {
+		c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			MaxVersion:         tls.VersionTLS12,
+		})
+
+		if err == nil {
+			c.Close()
+		}
+
+		return err
+	}
+
+	assert.NoError(handshake())
+
+	require.NoError(tp.Update(&Tls{CertificateFile: certificateFile, KeyFile: keyFile, MinVersion: tls.VersionTLS13}))
+	assert.Error(handshake())
+}
+
+func TestTlsPolicy(t *testing.T) {
+	certificateFile, keyFile := createServerFiles(t)
+	defer os.Remove(certificateFile)
+	defer os.Remove(keyFile)
+
+	t.Run("ZeroValue", testTlsPolicyZeroValue)
+
+	t.Run("Update", func(t *testing.T) {
+		testTlsPolicyUpdate(t, certificateFile, keyFile)
+	})
+
+	t.Run("Handshake", func(t *testing.T) {
+		testTlsPolicyHandshake(t, certificateFile, keyFile)
+	})
+}
+
+func TestTlsPolicies(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tp = ProvideTlsPolicies()
+	)
+
+	require.NotNil(tp)
+	assert.Nil(tp.Get("test"))
+	assert.Empty(tp.Names())
+
+	p := tp.register("test", Options{})
+	require.NotNil(p)
+	assert.True(p == tp.Get("test"))
+	assert.Equal([]string{"test"}, tp.Names())
+
+	var zero TlsPolicies
+	assert.NotNil(zero.register("test", Options{}))
+	assert.NotNil(zero.Get("test"))
+}
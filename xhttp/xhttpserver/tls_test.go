@@ -1,17 +1,26 @@
 package xhttpserver
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"math/rand"
+	"net/http"
 	"os"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/config"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,6 +35,81 @@ func TestPeerVerifyError(t *testing.T) {
 	assert.Equal("expected", err.Error())
 }
 
+func TestTlsVersionError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		err    = TlsVersionError{Version: tls.VersionTLS11, Floor: tls.VersionTLS12}
+	)
+
+	assert.Contains(err.Error(), "TLS1.1")
+	assert.Contains(err.Error(), "TLS1.2")
+	assert.Contains(TlsVersionError{Version: 0x1234}.Error(), "0x1234")
+}
+
+func TestNewVersionFloorVerifier(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		verifier = NewVersionFloorVerifier(tls.VersionTLS12)
+	)
+
+	assert.Equal(
+		TlsVersionError{Version: tls.VersionTLS10, Floor: tls.VersionTLS12},
+		verifier(tls.ConnectionState{Version: tls.VersionTLS10}),
+	)
+
+	assert.Equal(
+		TlsVersionError{Version: tls.VersionTLS11, Floor: tls.VersionTLS12},
+		verifier(tls.ConnectionState{Version: tls.VersionTLS11}),
+	)
+
+	assert.NoError(verifier(tls.ConnectionState{Version: tls.VersionTLS12}))
+	assert.NoError(verifier(tls.ConnectionState{Version: tls.VersionTLS13}))
+}
+
+func TestComposeVerifyConnection(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Nil(composeVerifyConnection())
+		assert.Nil(composeVerifyConnection(nil, nil))
+	})
+
+	t.Run("Order", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			calls       []int
+			expectedErr = errors.New("expected")
+
+			composed = composeVerifyConnection(
+				func(tls.ConnectionState) error {
+					calls = append(calls, 1)
+					return nil
+				},
+				nil,
+				func(cs tls.ConnectionState) error {
+					calls = append(calls, 2)
+					if cs.ServerName == "reject" {
+						return expectedErr
+					}
+
+					return nil
+				},
+				func(tls.ConnectionState) error {
+					calls = append(calls, 3)
+					return nil
+				},
+			)
+		)
+
+		assert.NoError(composed(tls.ConnectionState{}))
+		assert.Equal([]int{1, 2, 3}, calls)
+
+		calls = nil
+		assert.Equal(expectedErr, composed(tls.ConnectionState{ServerName: "reject"}))
+		assert.Equal([]int{1, 2}, calls)
+	})
+}
+
 func testConfiguredPeerVerifierSuccess(t *testing.T) {
 	testData := []struct {
 		peerCert x509.Certificate
@@ -392,6 +476,49 @@ func TestPeerVerifiers(t *testing.T) {
 	t.Run("Verify", testPeerVerifiersVerify)
 }
 
+func TestClientKeyStrength(t *testing.T) {
+	rsaKey := func(bits uint) *rsa.PublicKey {
+		return &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), bits-1), E: 65537}
+	}
+
+	testData := []struct {
+		name      string
+		strength  ClientKeyStrength
+		publicKey interface{}
+		expectErr bool
+	}{
+		{"RSA2048", ClientKeyStrength{}, rsaKey(2048), false},
+		{"RSA1024", ClientKeyStrength{}, rsaKey(1024), true},
+		{"RSACustom", ClientKeyStrength{MinRSABits: 3072}, rsaKey(2048), true},
+		{"P256", ClientKeyStrength{}, &ecdsa.PublicKey{Curve: elliptic.P256()}, false},
+		{"P384", ClientKeyStrength{}, &ecdsa.PublicKey{Curve: elliptic.P384()}, false},
+		{"P224", ClientKeyStrength{}, &ecdsa.PublicKey{Curve: elliptic.P224()}, true},
+		{"ECCustom", ClientKeyStrength{MinECBits: 384}, &ecdsa.PublicKey{Curve: elliptic.P256()}, true},
+		{"Ed25519", ClientKeyStrength{}, make(ed25519.PublicKey, ed25519.PublicKeySize), false},
+		{"Unsupported", ClientKeyStrength{}, "unsupported", true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+				cert   = &x509.Certificate{
+					Subject:   pkix.Name{CommonName: "test"},
+					PublicKey: record.publicKey,
+				}
+			)
+
+			err := record.strength.Verify(cert, nil)
+			if record.expectErr {
+				assert.IsType(PeerVerifyError{}, err)
+				assert.Contains(err.Error(), "CN=test")
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
 func TestNewPeerVerifiers(t *testing.T) {
 	t.Run("Empty", func(t *testing.T) {
 		var (
@@ -462,6 +589,70 @@ func testNewTlsConfigLoadCertificateError(t *testing.T) {
 	assert.Error(err)
 }
 
+func testNewTlsConfigMissingFile(t *testing.T, certificateFile, keyFile string) {
+	testData := []struct {
+		tls           Tls
+		expectedField string
+		expectedFile  string
+	}{
+		{
+			tls:           Tls{CertificateFile: "nosuch.cert", KeyFile: keyFile},
+			expectedField: "certificateFile",
+			expectedFile:  "nosuch.cert",
+		},
+		{
+			tls:           Tls{CertificateFile: certificateFile, KeyFile: "nosuch.key"},
+			expectedField: "keyFile",
+			expectedFile:  "nosuch.key",
+		},
+		{
+			tls:           Tls{CertificateFile: certificateFile, KeyFile: keyFile, ClientCACertificateFile: "nosuch.ca"},
+			expectedField: "clientCACertificateFile",
+			expectedFile:  "nosuch.ca",
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			tc, err := NewTlsConfig(&record.tls)
+			assert.Nil(tc)
+			require.IsType(TlsFileError{}, err)
+			assert.Equal(record.expectedField, err.(TlsFileError).Field)
+			assert.Equal(record.expectedFile, err.(TlsFileError).File)
+			assert.Contains(err.Error(), record.expectedField)
+			assert.Contains(err.Error(), record.expectedFile)
+		})
+	}
+}
+
+func testNewTlsConfigEmptyFile(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	empty, err := ioutil.TempFile("", "empty.*.pem")
+	require.NoError(err)
+	empty.Close()
+	defer os.Remove(empty.Name())
+
+	tc, err := NewTlsConfig(&Tls{
+		CertificateFile: certificateFile,
+		KeyFile:         empty.Name(),
+	})
+
+	assert.Nil(tc)
+	assert.Equal(
+		TlsFileError{Field: "keyFile", File: empty.Name(), Err: ErrTlsFileEmpty},
+		err,
+	)
+}
+
 func testNewTlsConfigSimple(t *testing.T, certificateFile, keyFile string) {
 	var (
 		assert  = assert.New(t)
@@ -481,6 +672,76 @@ func testNewTlsConfigSimple(t *testing.T, certificateFile, keyFile string) {
 	assert.Empty(tc.ServerName)
 	assert.Equal([]string{"http/1.1"}, tc.NextProtos)
 	assert.NotEmpty(tc.NameToCertificate)
+	assert.Nil(tc.VerifyConnection)
+}
+
+func testNewTlsConfigVersionFloor(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tc, err = NewTlsConfig(&Tls{
+			CertificateFile: certificateFile,
+			KeyFile:         keyFile,
+			MinVersion:      tls.VersionTLS10,
+			VersionFloor:    tls.VersionTLS12,
+		})
+	)
+
+	require.NoError(err)
+	require.NotNil(tc)
+
+	assert.Equal(uint16(tls.VersionTLS10), tc.MinVersion)
+	require.NotNil(tc.VerifyConnection)
+	assert.Error(tc.VerifyConnection(tls.ConnectionState{Version: tls.VersionTLS11}))
+	assert.NoError(tc.VerifyConnection(tls.ConnectionState{Version: tls.VersionTLS12}))
+}
+
+func testNewTlsConfigVersionFloorUnreachable(t *testing.T, certificateFile, keyFile string) {
+	for _, minVersion := range []config.TlsVersion{tls.VersionTLS12, tls.VersionTLS13} {
+		t.Run(tlsVersionName(uint16(minVersion)), func(t *testing.T) {
+			assert := assert.New(t)
+			tc, err := NewTlsConfig(&Tls{
+				CertificateFile: certificateFile,
+				KeyFile:         keyFile,
+				MinVersion:      minVersion,
+				VersionFloor:    tls.VersionTLS12,
+			})
+
+			assert.Nil(tc)
+			assert.Equal(ErrTlsVersionFloorUnreachable, err)
+		})
+	}
+}
+
+func testNewTlsConfigVerifyConnection(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedErr = errors.New("expected")
+		tc, err     = NewTlsConfig(&Tls{
+			CertificateFile: certificateFile,
+			KeyFile:         keyFile,
+			VersionFloor:    tls.VersionTLS12,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				if cs.ServerName == "reject" {
+					return expectedErr
+				}
+
+				return nil
+			},
+		})
+	)
+
+	require.NoError(err)
+	require.NotNil(tc)
+	require.NotNil(tc.VerifyConnection)
+
+	// the version floor is checked before the application's policy
+	assert.IsType(TlsVersionError{}, tc.VerifyConnection(tls.ConnectionState{Version: tls.VersionTLS11, ServerName: "reject"}))
+	assert.Equal(expectedErr, tc.VerifyConnection(tls.ConnectionState{Version: tls.VersionTLS12, ServerName: "reject"}))
+	assert.NoError(tc.VerifyConnection(tls.ConnectionState{Version: tls.VersionTLS12}))
 }
 
 func testNewTlsConfigWithoutClientCACertificateFile(t *testing.T, certificateFile, keyFile string) {
@@ -535,6 +796,59 @@ func testNewTlsConfigWithClientCACertificateFile(t *testing.T, certificateFile,
 	assert.Equal(tls.RequireAndVerifyClientCert, tc.ClientAuth)
 }
 
+func testNewTlsConfigClientKeyStrength(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		block, _ = pem.Decode(serverCertificate)
+	)
+
+	require.NotNil(block)
+
+	tc, err := NewTlsConfig(&Tls{
+		CertificateFile:   certificateFile,
+		KeyFile:           keyFile,
+		ClientKeyStrength: &ClientKeyStrength{},
+	})
+
+	require.NoError(err)
+	require.NotNil(tc)
+	require.NotNil(tc.VerifyPeerCertificate)
+	assert.NoError(tc.VerifyPeerCertificate([][]byte{block.Bytes}, nil))
+
+	// the test certificate has a 4096-bit key
+	tc, err = NewTlsConfig(&Tls{
+		CertificateFile:   certificateFile,
+		KeyFile:           keyFile,
+		ClientKeyStrength: &ClientKeyStrength{MinRSABits: 8192},
+	})
+
+	require.NoError(err)
+	require.NotNil(tc)
+	require.NotNil(tc.VerifyPeerCertificate)
+	assert.IsType(PeerVerifyError{}, tc.VerifyPeerCertificate([][]byte{block.Bytes}, nil))
+}
+
+func testNewTlsConfigClientCertificateOptional(t *testing.T, certificateFile, keyFile string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tc, err = NewTlsConfig(&Tls{
+			CertificateFile:           certificateFile,
+			KeyFile:                   keyFile,
+			ClientCACertificateFile:   certificateFile,
+			ClientCertificateOptional: true,
+		})
+	)
+
+	require.NoError(err)
+	require.NotNil(tc)
+	assert.NotNil(tc.ClientCAs)
+	assert.Equal(tls.VerifyClientCertIfGiven, tc.ClientAuth)
+}
+
 func testNewTlsConfigLoadClientCACertificateError(t *testing.T, certificateFile, keyFile string) {
 	var (
 		assert = assert.New(t)
@@ -565,6 +879,32 @@ func testNewTlsConfigAppendClientCACertificateError(t *testing.T, certificateFil
 	assert.Equal(ErrUnableToAddClientCACertificate, err)
 }
 
+func TestAppendNextProtos(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		noop   = func(*http.Server, *tls.Conn, http.Handler) {}
+	)
+
+	assert.NotPanics(func() {
+		appendNextProtos(nil, map[string]func(*http.Server, *tls.Conn, http.Handler){"custom": noop})
+	})
+
+	tc := &tls.Config{NextProtos: []string{"http/1.1"}}
+	appendNextProtos(tc, nil)
+	assert.Equal([]string{"http/1.1"}, tc.NextProtos)
+
+	appendNextProtos(
+		tc,
+		map[string]func(*http.Server, *tls.Conn, http.Handler){
+			"http/1.1": noop,
+			"zeta":     noop,
+			"alpha":    noop,
+		},
+	)
+
+	assert.Equal([]string{"http/1.1", "alpha", "zeta"}, tc.NextProtos)
+}
+
 func TestNewTlsConfig(t *testing.T) {
 	certificateFile, keyFile := createServerFiles(t)
 	defer os.Remove(certificateFile)
@@ -577,10 +917,30 @@ func TestNewTlsConfig(t *testing.T) {
 	t.Run("NoKeyFile", testNewTlsConfigNoKeyFile)
 	t.Run("LoadCertificateError", testNewTlsConfigLoadCertificateError)
 
+	t.Run("MissingFile", func(t *testing.T) {
+		testNewTlsConfigMissingFile(t, certificateFile, keyFile)
+	})
+
+	t.Run("EmptyFile", func(t *testing.T) {
+		testNewTlsConfigEmptyFile(t, certificateFile, keyFile)
+	})
+
 	t.Run("Simple", func(t *testing.T) {
 		testNewTlsConfigSimple(t, certificateFile, keyFile)
 	})
 
+	t.Run("VersionFloor", func(t *testing.T) {
+		testNewTlsConfigVersionFloor(t, certificateFile, keyFile)
+	})
+
+	t.Run("VersionFloorUnreachable", func(t *testing.T) {
+		testNewTlsConfigVersionFloorUnreachable(t, certificateFile, keyFile)
+	})
+
+	t.Run("VerifyConnection", func(t *testing.T) {
+		testNewTlsConfigVerifyConnection(t, certificateFile, keyFile)
+	})
+
 	t.Run("WithoutClientCACertificateFile", func(t *testing.T) {
 		testNewTlsConfigWithoutClientCACertificateFile(t, certificateFile, keyFile)
 	})
@@ -589,6 +949,14 @@ func TestNewTlsConfig(t *testing.T) {
 		testNewTlsConfigWithClientCACertificateFile(t, certificateFile, keyFile)
 	})
 
+	t.Run("ClientKeyStrength", func(t *testing.T) {
+		testNewTlsConfigClientKeyStrength(t, certificateFile, keyFile)
+	})
+
+	t.Run("ClientCertificateOptional", func(t *testing.T) {
+		testNewTlsConfigClientCertificateOptional(t, certificateFile, keyFile)
+	})
+
 	t.Run("LoadClientCACertificateError", func(t *testing.T) {
 		testNewTlsConfigLoadClientCACertificateError(t, certificateFile, keyFile)
 	})
@@ -597,3 +965,66 @@ func TestNewTlsConfig(t *testing.T) {
 		testNewTlsConfigAppendClientCACertificateError(t, certificateFile, keyFile)
 	})
 }
+
+func TestValidateTlsConfig(t *testing.T) {
+	// the prebaked server certificate is valid from 2019-11-14 until 2019-12-14
+	var (
+		valid      = func() time.Time { return time.Date(2019, 11, 20, 0, 0, 0, 0, time.UTC) }
+		expired    = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+		notYet     = func() time.Time { return time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC) }
+		validation TlsValidationError
+	)
+
+	t.Run("Nil", func(t *testing.T) {
+		assert.NoError(t, ValidateTlsConfig(nil))
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, ValidateTlsConfig(addServerCertificate(t, &tls.Config{Time: valid})))
+	})
+
+	t.Run("ClientCertificateRequired", func(t *testing.T) {
+		tc := addServerCertificate(t, &tls.Config{
+			Time:       valid,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  x509.NewCertPool(),
+			VerifyConnection: func(tls.ConnectionState) error {
+				return errors.New("should not have been called")
+			},
+		})
+
+		assert.NoError(t, ValidateTlsConfig(tc))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		err := ValidateTlsConfig(addServerCertificate(t, &tls.Config{Time: expired}))
+		assert.Error(t, err)
+		assert.True(t, errors.As(err, &validation))
+		assert.Contains(t, err.Error(), "expired")
+	})
+
+	t.Run("NotYetValid", func(t *testing.T) {
+		err := ValidateTlsConfig(addServerCertificate(t, &tls.Config{Time: notYet}))
+		assert.Error(t, err)
+		assert.True(t, errors.As(err, &validation))
+		assert.Contains(t, err.Error(), "not valid until")
+	})
+
+	t.Run("NoCertificate", func(t *testing.T) {
+		err := ValidateTlsConfig(&tls.Config{Time: valid})
+		assert.Error(t, err)
+		assert.True(t, errors.As(err, &validation))
+		assert.NotNil(t, validation.Err)
+	})
+
+	t.Run("NoCommonVersion", func(t *testing.T) {
+		err := ValidateTlsConfig(addServerCertificate(t, &tls.Config{
+			Time:       valid,
+			MinVersion: tls.VersionTLS13,
+			MaxVersion: tls.VersionTLS12,
+		}))
+
+		assert.Error(t, err)
+		assert.True(t, errors.As(err, &validation))
+	})
+}
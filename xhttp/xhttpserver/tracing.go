@@ -0,0 +1,80 @@
+package xhttpserver
+
+import (
+	"net/http"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name used when obtaining a tracer for server spans
+const TracerName = "github.com/xmidt-org/themis/xhttp/xhttpserver"
+
+const (
+	// MethodAttribute is the span attribute holding the request method
+	MethodAttribute = attribute.Key("http.method")
+
+	// RouteAttribute is the span attribute holding the matched route, when known
+	RouteAttribute = attribute.Key("http.route")
+
+	// StatusCodeAttribute is the span attribute holding the response status code
+	StatusCodeAttribute = attribute.Key("http.status_code")
+)
+
+// Trace is an Alice-style decorator that starts an OpenTelemetry server span for each request.  The parent span,
+// if any, is extracted from the request headers, e.g. traceparent.  The new span is stored in the request context,
+// where handlers can enrich it via trace.SpanFromContext and where xhttpclient.TracePropagation can inject it into
+// outgoing requests.
+//
+// The response status is recorded only if the response is a TrackingWriter, so this decorator should be placed after
+// TrackingStage in a chain.  The route is recorded by MethodMux, if used.
+type Trace struct {
+	// TracerProvider is the source of tracers.  If unset, otel.GetTracerProvider is used, which produces
+	// no-op spans unless the application has registered a global provider.
+	TracerProvider trace.TracerProvider
+
+	// Propagator is used to extract the parent span from request headers.  If unset, the W3C trace context
+	// propagator is used.
+	Propagator propagation.TextMapPropagator
+}
+
+func (t Trace) Then(next http.Handler) http.Handler {
+	tp := t.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	propagator := t.Propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+
+	tracer := tp.Tracer(TracerName)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ctx, span := tracer.Start(
+			propagator.Extract(request.Context(), propagation.HeaderCarrier(request.Header)),
+			request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(MethodAttribute.String(request.Method)),
+		)
+
+		defer span.End()
+		next.ServeHTTP(response, request.WithContext(ctx))
+
+		if sc, ok := response.(kithttp.StatusCoder); ok {
+			statusCode := sc.StatusCode()
+			span.SetAttributes(StatusCodeAttribute.Int(statusCode))
+			if statusCode >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(statusCode))
+			}
+		}
+	})
+}
+
+func (t Trace) ThenFunc(next http.HandlerFunc) http.Handler {
+	return t.Then(next)
+}
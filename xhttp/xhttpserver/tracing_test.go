@@ -0,0 +1,141 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceParentHeader = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// testSpan is a trace.Span that records attributes and status
+type testSpan struct {
+	trace.Span
+	name       string
+	attributes []attribute.KeyValue
+	code       codes.Code
+	ended      bool
+}
+
+func (ts *testSpan) SetAttributes(kv ...attribute.KeyValue) {
+	ts.attributes = append(ts.attributes, kv...)
+}
+
+func (ts *testSpan) SetStatus(code codes.Code, _ string) {
+	ts.code = code
+}
+
+func (ts *testSpan) End(...trace.SpanEndOption) {
+	ts.ended = true
+}
+
+// testTracerProvider is a trace.TracerProvider that captures the last span started
+type testTracerProvider struct {
+	span *testSpan
+}
+
+func (ttp *testTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return ttp
+}
+
+func (ttp *testTracerProvider) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	ttp.span = &testSpan{
+		Span:       trace.SpanFromContext(ctx),
+		name:       name,
+		attributes: config.Attributes(),
+	}
+
+	return trace.ContextWithSpan(ctx, ttp.span), ttp.span
+}
+
+func testTraceParent(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			sc := trace.SpanContextFromContext(request.Context())
+			assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+			response.WriteHeader(299)
+		})
+
+		decorated = Trace{}.Then(next)
+		response  = httptest.NewRecorder()
+		request   = httptest.NewRequest("GET", "/", nil)
+	)
+
+	require.NotNil(decorated)
+	request.Header.Set("traceparent", testTraceParentHeader)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func testTraceAttributes(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tp  = new(testTracerProvider)
+		mux = new(MethodMux)
+
+		decorated = UseTrackingWriter(
+			Trace{TracerProvider: tp}.Then(mux),
+		)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/test", nil)
+	)
+
+	mux.HandleFunc("POST", "/test", func(response http.ResponseWriter, request *http.Request) {
+		response.WriteHeader(503)
+	})
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(503, response.Code)
+
+	require.NotNil(tp.span)
+	assert.Equal("POST", tp.span.name)
+	assert.True(tp.span.ended)
+	assert.Equal(codes.Error, tp.span.code)
+	assert.ElementsMatch(
+		[]attribute.KeyValue{
+			MethodAttribute.String("POST"),
+			RouteAttribute.String("/test"),
+			StatusCodeAttribute.Int(503),
+		},
+		tp.span.attributes,
+	)
+}
+
+func testTraceThenFunc(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decorated = Trace{}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+}
+
+func TestTrace(t *testing.T) {
+	t.Run("TraceParent", testTraceParent)
+	t.Run("Attributes", testTraceAttributes)
+	t.Run("ThenFunc", testTraceThenFunc)
+}
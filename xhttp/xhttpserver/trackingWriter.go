@@ -24,6 +24,11 @@ var (
 //
 // This interface implements go-kit's StatusCoder to allow client code to narrowly cast to
 // the desired type.
+//
+// HTTP trailers are fully supported.  Header() always returns the underlying writer's header map,
+// so both declared trailers (via the Trailer header, set prior to WriteHeader) and trailers set
+// after the body using http.TrailerPrefix are passed through untouched.  Neither Write nor Flush
+// finalizes the response beyond what the decorated http.ResponseWriter itself does.
 type TrackingWriter interface {
 	http.ResponseWriter
 	http.Hijacker
@@ -34,7 +39,8 @@ type TrackingWriter interface {
 	// Hijacked returns true if the underlying network connection has been hijacked
 	Hijacked() bool
 
-	// BytesWritten returns the total bytes written to the response body via Write.
+	// BytesWritten returns the total bytes written to the response body via Write.  For HEAD requests
+	// decorated via UseTrackingWriter, this is always zero since net/http discards the body.
 	BytesWritten() int
 }
 
@@ -50,8 +56,22 @@ func NewTrackingWriter(next http.ResponseWriter) TrackingWriter {
 	}
 }
 
+// newRequestTrackingWriter is like NewTrackingWriter, but takes the request into account.  In particular,
+// body bytes for HEAD requests are not counted, as net/http discards them.
+func newRequestTrackingWriter(next http.ResponseWriter, request *http.Request) TrackingWriter {
+	if tr, ok := next.(TrackingWriter); ok {
+		return tr
+	}
+
+	return &trackingWriter{
+		next: next,
+		head: request.Method == http.MethodHead,
+	}
+}
+
 type trackingWriter struct {
 	next http.ResponseWriter
+	head bool
 
 	hijacked     bool
 	statusCode   int
@@ -74,13 +94,19 @@ func (dw *trackingWriter) BytesWritten() int {
 	return dw.bytesWritten
 }
 
+// Unwrap returns the decorated http.ResponseWriter.  This allows http.ResponseController to access
+// optional behavior, such as read and write deadlines, of the underlying writer.
+func (dw *trackingWriter) Unwrap() http.ResponseWriter {
+	return dw.next
+}
+
 func (dw *trackingWriter) Header() http.Header {
 	return dw.next.Header()
 }
 
 func (dw *trackingWriter) Write(b []byte) (int, error) {
 	c, err := dw.next.Write(b)
-	if c > 0 {
+	if c > 0 && !dw.head {
 		dw.bytesWritten += c
 	}
 
@@ -126,7 +152,7 @@ func (dw *trackingWriter) Push(target string, opts *http.PushOptions) error {
 func UseTrackingWriter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(original http.ResponseWriter, request *http.Request) {
 		next.ServeHTTP(
-			NewTrackingWriter(original),
+			newRequestTrackingWriter(original, request),
 			request,
 		)
 	})
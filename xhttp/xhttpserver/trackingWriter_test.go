@@ -3,6 +3,7 @@ package xhttpserver
 import (
 	"bufio"
 	"errors"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -195,11 +196,91 @@ func testTrackingWriterFlush(t *testing.T) {
 	})
 }
 
+func testTrackingWriterTrailers(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedBody = []byte("streamed body")
+
+		server = httptest.NewServer(
+			UseTrackingWriter(
+				http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					response.Header().Set("Trailer", "X-Declared")
+					response.WriteHeader(http.StatusOK)
+					response.Write(expectedBody)
+					response.(http.Flusher).Flush()
+
+					response.Header().Set("X-Declared", "declared value")
+					response.Header().Set(http.TrailerPrefix+"X-Undeclared", "undeclared value")
+
+					tw := response.(TrackingWriter)
+					assert.Equal(http.StatusOK, tw.StatusCode())
+					assert.Equal(len(expectedBody), tw.BytesWritten())
+				}),
+			),
+		)
+	)
+
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	require.NoError(err)
+	require.NotNil(response)
+
+	defer response.Body.Close()
+	actualBody, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal(expectedBody, actualBody)
+
+	// trailers are only available after the body has been fully read
+	assert.Equal("declared value", response.Trailer.Get("X-Declared"))
+	assert.Equal("undeclared value", response.Trailer.Get("X-Undeclared"))
+}
+
+func testTrackingWriterHead(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		statusCodes  = make(chan int, 1)
+		bytesWritten = make(chan int, 1)
+
+		server = httptest.NewServer(
+			UseTrackingWriter(
+				http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					response.Header().Set("X-Test", "value")
+					response.WriteHeader(299)
+					c, err := response.Write([]byte("discarded body"))
+					assert.Equal(len("discarded body"), c)
+					assert.NoError(err)
+
+					tw := response.(TrackingWriter)
+					statusCodes <- tw.StatusCode()
+					bytesWritten <- tw.BytesWritten()
+				}),
+			),
+		)
+	)
+
+	defer server.Close()
+	response, err := http.Head(server.URL)
+	require.NoError(err)
+	require.NotNil(response)
+	response.Body.Close()
+
+	assert.Equal(299, response.StatusCode)
+	assert.Equal("value", response.Header.Get("X-Test"))
+	assert.Equal(299, <-statusCodes)
+	assert.Zero(<-bytesWritten)
+}
+
 func TestTrackingWriter(t *testing.T) {
 	t.Run("Basic", testTrackingWriterBasic)
 	t.Run("Hijack", testTrackingWriterHijack)
 	t.Run("Push", testTrackingWriterPush)
 	t.Run("Flush", testTrackingWriterFlush)
+	t.Run("Trailers", testTrackingWriterTrailers)
+	t.Run("Head", testTrackingWriterHead)
 }
 
 func TestNewTrackingWriter(t *testing.T) {
@@ -219,6 +300,10 @@ func TestNewTrackingWriter(t *testing.T) {
 	)
 
 	assert.Equal(tr, NewTrackingWriter(tr))
+
+	unwrapper, ok := tr.(interface{ Unwrap() http.ResponseWriter })
+	require.True(ok)
+	assert.Equal(next, unwrapper.Unwrap())
 	next.AssertExpectations(t)
 }
 
@@ -0,0 +1,135 @@
+package xhttpserver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// socketProbeTimeout is how long listenUnix waits to connect to an existing socket file, to determine whether
+// another process is still serving on it
+const socketProbeTimeout = time.Second
+
+// unixSocketListener is a *net.UnixListener that was bound to a temporary path and then renamed.  Since the
+// net package only knows the temporary path, this type removes the final socket file when closed.
+type unixSocketListener struct {
+	*net.UnixListener
+	path      string
+	closeOnce sync.Once
+}
+
+func (usl *unixSocketListener) Close() error {
+	err := usl.UnixListener.Close()
+	usl.closeOnce.Do(func() {
+		os.Remove(usl.path)
+	})
+
+	return err
+}
+
+// hasSocketPermissions tests if any of the unix socket permissions options are set
+func hasSocketPermissions(o Options) bool {
+	return o.SocketMode != 0 || o.SocketUID != nil || o.SocketGID != nil
+}
+
+// setSocketPermissions applies the configured mode and ownership to a socket file
+func setSocketPermissions(path string, o Options) error {
+	if o.SocketMode != 0 {
+		if err := os.Chmod(path, o.SocketMode); err != nil {
+			return err
+		}
+	}
+
+	if o.SocketUID != nil || o.SocketGID != nil {
+		// -1 leaves the corresponding id unchanged
+		uid, gid := -1, -1
+		if o.SocketUID != nil {
+			uid = *o.SocketUID
+		}
+
+		if o.SocketGID != nil {
+			gid = *o.SocketGID
+		}
+
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// socketInUse tests if another process is accepting connections on the unix socket at the given address.  A
+// stale socket file, or a file that is not a socket, is not in use.
+func socketInUse(address string) bool {
+	conn, err := net.DialTimeout("unix", address, socketProbeTimeout)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
+
+// listenUnix binds a unix socket.  If any permissions are configured, the socket is first bound to a temporary,
+// randomly named path in the same directory.  The permissions are applied to that path, and only then is it
+// renamed to the configured address.  That way, clients can never connect to the configured address before
+// the permissions are in place.
+//
+// The rename replaces any existing file at the address.  So that a live server is never silently displaced, the
+// existing socket is probed first, and a BindError for syscall.EADDRINUSE is returned if anything accepts the
+// connection.  This mirrors what binding the address directly would do.
+func listenUnix(ctx context.Context, o Options, lcfg net.ListenConfig, address string) (net.Listener, error) {
+	if !hasSocketPermissions(o) {
+		l, err := lcfg.Listen(ctx, "unix", address)
+		if err != nil {
+			return nil, BindError{Network: "unix", Address: address, Err: err}
+		}
+
+		return l, nil
+	}
+
+	temporary := filepath.Join(
+		filepath.Dir(address),
+		"."+filepath.Base(address)+"."+strconv.FormatInt(rand.Int63(), 36),
+	)
+
+	l, err := lcfg.Listen(ctx, "unix", temporary)
+	if err != nil {
+		return nil, BindError{Network: "unix", Address: address, Err: err}
+	}
+
+	ul, ok := l.(*net.UnixListener)
+	if !ok {
+		l.Close()
+		return nil, fmt.Errorf("Network [unix] and address [%s] does not result in a UnixListener", address)
+	}
+
+	ul.SetUnlinkOnClose(false)
+	if err := setSocketPermissions(temporary, o); err != nil {
+		ul.Close()
+		os.Remove(temporary)
+		return nil, fmt.Errorf("Unable to set permissions for unix socket [%s]: %s", address, err)
+	}
+
+	if socketInUse(address) {
+		ul.Close()
+		os.Remove(temporary)
+		return nil, BindError{Network: "unix", Address: address, Err: syscall.EADDRINUSE}
+	}
+
+	if err := os.Rename(temporary, address); err != nil {
+		ul.Close()
+		os.Remove(temporary)
+		return nil, fmt.Errorf("Unable to set permissions for unix socket [%s]: %s", address, err)
+	}
+
+	return &unixSocketListener{UnixListener: ul, path: address}, nil
+}
@@ -0,0 +1,216 @@
+package xhttpserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUnixSocketDir creates a temporary directory for unix sockets, with a short path to stay within
+// the operating system limit on socket path lengths
+func testUnixSocketDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "xhs")
+	require.NoError(t, err)
+	return dir
+}
+
+func testListenUnixNoPermissions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir     = testUnixSocketDir(t)
+		address = filepath.Join(dir, "test.sock")
+	)
+
+	defer os.RemoveAll(dir)
+	l, err := NewListener(context.Background(), Options{Network: "unix", Address: address}, net.ListenConfig{}, nil)
+	require.NoError(err)
+	require.NotNil(l)
+
+	conn, err := net.Dial("unix", address)
+	require.NoError(err)
+	conn.Close()
+
+	assert.NoError(l.Close())
+	_, err = os.Stat(address)
+	assert.True(os.IsNotExist(err))
+}
+
+func testListenUnixPermissions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir     = testUnixSocketDir(t)
+		address = filepath.Join(dir, "test.sock")
+
+		uid = os.Getuid()
+		gid = os.Getgid()
+	)
+
+	defer os.RemoveAll(dir)
+
+	// a stale socket file should be replaced
+	require.NoError(ioutil.WriteFile(address, nil, 0644))
+
+	l, err := NewListener(
+		context.Background(),
+		Options{
+			Network:    "unix",
+			Address:    address,
+			SocketMode: 0600,
+			SocketUID:  &uid,
+			SocketGID:  &gid,
+		},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+
+	info, err := os.Stat(address)
+	require.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	assert.NotZero(info.Mode() & os.ModeSocket)
+
+	// only the socket itself should remain in the directory
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(err)
+	assert.Len(entries, 1)
+
+	conn, err := net.Dial("unix", address)
+	require.NoError(err)
+	conn.Close()
+
+	assert.NoError(l.Close())
+	_, err = os.Stat(address)
+	assert.True(os.IsNotExist(err))
+	l.Close()
+}
+
+func testListenUnixInvalidAddress(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir     = testUnixSocketDir(t)
+		address = filepath.Join(dir, "missing", "test.sock")
+	)
+
+	defer os.RemoveAll(dir)
+	for _, mode := range []os.FileMode{0, 0600} {
+		l, err := NewListener(
+			context.Background(),
+			Options{Network: "unix", Address: address, SocketMode: mode},
+			net.ListenConfig{},
+			nil,
+		)
+
+		require.Error(err)
+		assert.Nil(l)
+
+		_, ok := err.(BindError)
+		assert.True(ok)
+	}
+}
+
+func TestHasSocketPermissions(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		root   = 0
+	)
+
+	assert.False(hasSocketPermissions(Options{}))
+	assert.True(hasSocketPermissions(Options{SocketMode: 0600}))
+
+	// root is a valid owner
+	assert.True(hasSocketPermissions(Options{SocketUID: &root}))
+	assert.True(hasSocketPermissions(Options{SocketGID: &root}))
+}
+
+func testListenUnixInUse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir     = testUnixSocketDir(t)
+		address = filepath.Join(dir, "test.sock")
+	)
+
+	defer os.RemoveAll(dir)
+	live, err := net.Listen("unix", address)
+	require.NoError(err)
+	defer live.Close()
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Network: "unix", Address: address, SocketMode: 0600},
+		net.ListenConfig{},
+		nil,
+	)
+
+	assert.Nil(l)
+	require.Error(err)
+	bindErr, ok := err.(BindError)
+	require.True(ok)
+	assert.True(bindErr.AddressInUse())
+
+	// the live socket must still be reachable, and no temporary file may remain
+	conn, err := net.Dial("unix", address)
+	require.NoError(err)
+	conn.Close()
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(err)
+	assert.Len(entries, 1)
+}
+
+func testListenUnixStaleSocket(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		dir     = testUnixSocketDir(t)
+		address = filepath.Join(dir, "test.sock")
+	)
+
+	defer os.RemoveAll(dir)
+
+	// a socket file left behind by a process that is no longer serving
+	stale, err := net.Listen("unix", address)
+	require.NoError(err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := NewListener(
+		context.Background(),
+		Options{Network: "unix", Address: address, SocketMode: 0600},
+		net.ListenConfig{},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	conn, err := net.Dial("unix", address)
+	require.NoError(err)
+	conn.Close()
+	assert.NoError(l.Close())
+}
+
+func TestListenUnix(t *testing.T) {
+	t.Run("NoPermissions", testListenUnixNoPermissions)
+	t.Run("Permissions", testListenUnixPermissions)
+	t.Run("InvalidAddress", testListenUnixInvalidAddress)
+	t.Run("InUse", testListenUnixInUse)
+	t.Run("StaleSocket", testListenUnixStaleSocket)
+}
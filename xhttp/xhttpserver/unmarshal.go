@@ -1,17 +1,28 @@
 package xhttpserver
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strings"
 
 	"github.com/xmidt-org/themis/config"
+	"github.com/xmidt-org/themis/xlog"
 	"github.com/xmidt-org/themis/xlog/xloghttp"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"go.uber.org/fx"
 )
 
+var (
+	ErrInvalidHTTP2BufferSize = errors.New("An HTTP/2 frame or buffer size is out of range")
+	ErrNoParameterBuilderSets = errors.New("logParameterSets requires a ParameterBuilderSets component, which was not provided")
+)
+
 // ServerNotConfiguredError is returned when a required server has no configuration key
 type ServerNotConfiguredError struct {
 	Key string
@@ -38,6 +49,21 @@ func (cff ChainFactoryFunc) New(n string, o Options) (alice.Chain, error) {
 	return cff(n, o)
 }
 
+// ConnStateFactory is a creation strategy for server-specific http.Server.ConnState callbacks.  Any callback
+// created by this factory is invoked in addition to the ones configured via Options, such as connection logging.
+//
+// The most common use of this interface is connection metrics, which need the name of the server as a label.
+// A factory may return a nil callback, in which case nothing is added to the server.
+type ConnStateFactory interface {
+	New(string, Options) (func(net.Conn, http.ConnState), error)
+}
+
+type ConnStateFactoryFunc func(string, Options) (func(net.Conn, http.ConnState), error)
+
+func (csff ConnStateFactoryFunc) New(n string, o Options) (func(net.Conn, http.ConnState), error) {
+	return csff(n, o)
+}
+
 // ServerIn holds the set of dependencies required to create an HTTP server in the context
 // of a uber/fx application.
 type ServerIn struct {
@@ -52,9 +78,47 @@ type ServerIn struct {
 	// server based on configuration.  Both this field and Chain may be used simultaneously.
 	ChainFactory ChainFactory `optional:"true"`
 
+	// ConnStateFactory is an optional component which is used to build an additional http.Server.ConnState
+	// callback for each particular server.
+	ConnStateFactory ConnStateFactory `optional:"true"`
+
+	// ShutdownSequence is an optional component which controls the order in which servers are stopped.
+	// If not supplied, servers are stopped in the reverse order of their construction.
+	ShutdownSequence *ShutdownSequence `optional:"true"`
+
+	// ConcurrencyMetricsFactory is an optional component which is used to build the metrics for each server
+	// that configures a ConcurrencyLimit.
+	ConcurrencyMetricsFactory ConcurrencyMetricsFactory `optional:"true"`
+
+	// HandshakeWaitFactory is an optional component which is used to build the handshake wait metric for
+	// each TLS server that configures MaxConcurrentHandshakes.
+	HandshakeWaitFactory HandshakeWaitFactory `optional:"true"`
+
+	// TlsPolicies is an optional component with which each TLS server registers its TlsPolicy, allowing
+	// that server's TLS configuration to be replaced at runtime.
+	TlsPolicies *TlsPolicies `optional:"true"`
+
+	// Gate is an optional component which controls whether servers configured with StartupGate accept requests.
+	Gate *Gate `optional:"true"`
+
+	// Maintenance is an optional component which controls whether servers configured with Maintenance reject requests.
+	Maintenance *Maintenance `optional:"true"`
+
+	// ShutdownSignal is an optional component which indicates when servers configured with Drain begin
+	// rejecting requests.
+	ShutdownSignal *ShutdownSignal `optional:"true"`
+
+	// AccessLogger is an optional component which, when supplied, is used in place of Logger to create the
+	// contextual request loggers for each server.  See Options.AccessLogger.
+	AccessLogger log.Logger `name:"accessLogger" optional:"true"`
+
 	// ParameterBuiders is an optional component which is used to create contextual request loggers
 	// for use by http.Handler code.
 	ParameterBuilders xloghttp.ParameterBuilders `optional:"true"`
+
+	// ParameterBuilderSets is an optional component containing named groups of builders, which servers select
+	// via Options.LogParameterSets.  Selected builders are used in addition to ParameterBuilders.
+	ParameterBuilderSets xloghttp.ParameterBuilderSets `optional:"true"`
 }
 
 // Unmarshal describes how to unmarshal an HTTP server.  This type contains all the non-component information
@@ -103,12 +167,78 @@ func (u Unmarshal) Provide(in ServerIn) (*mux.Router, error) {
 		return nil, err
 	}
 
+	if o.Favicon != nil {
+		if err := o.Favicon.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !o.validHTTP2Buffers() {
+		return nil, ErrInvalidHTTP2BufferSize
+	}
+
+	if err := o.validateNetworks(); err != nil {
+		return nil, err
+	}
+
+	if in.AccessLogger != nil {
+		o.AccessLogger = log.With(in.AccessLogger, ServerKey(), u.name())
+	}
+
+	if o.ServerInfo {
+		o.BaseContext = withServerName(o.BaseContext, u.name())
+	}
+
+	builders := in.ParameterBuilders
+	if len(o.LogParameterSets) > 0 {
+		if in.ParameterBuilderSets == nil {
+			return nil, ErrNoParameterBuilderSets
+		}
+
+		selected, err := in.ParameterBuilderSets.Select(o.LogParameterSets...)
+		if err != nil {
+			return nil, err
+		}
+
+		builders = append(builders[:len(builders):len(builders)], selected...)
+	}
+
+	o.Gate = in.Gate
+	o.ShutdownSignal = in.ShutdownSignal
+	o.MaintenanceSwitch = in.Maintenance
+
+	serverName := u.name()
+	if in.ConcurrencyMetricsFactory != nil && o.ConcurrencyLimit > 0 {
+		var err error
+		o.ConcurrencyMetrics, err = in.ConcurrencyMetricsFactory.New(serverName, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var (
-		serverName   = u.name()
 		serverLogger = log.With(in.Logger, ServerKey(), serverName)
-		serverChain  = NewServerChain(o, serverLogger, in.ParameterBuilders...)
+		serverChain  = NewServerChain(o, serverLogger, builders...)
 	)
 
+	if dependents := o.statusDependents(); o.DisableTracking && len(dependents) > 0 {
+		serverLogger.Log(
+			level.Key(), level.WarnValue(),
+			xlog.MessageKey(), fmt.Sprintf(
+				"DisableTracking is overridden, since the response status is required by %s",
+				strings.Join(dependents, ", "),
+			),
+		)
+	}
+
+	if in.HandshakeWaitFactory != nil && o.Tls != nil && o.MaxConcurrentHandshakes > 0 && o.HandshakeWait == nil {
+		var err error
+		o.HandshakeWait, err = in.HandshakeWaitFactory.New(serverName, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if in.ChainFactory != nil {
 		more, err := in.ChainFactory.New(serverName, o)
 		if err != nil {
@@ -118,19 +248,54 @@ func (u Unmarshal) Provide(in ServerIn) (*mux.Router, error) {
 		serverChain = serverChain.Extend(more)
 	}
 
+	var connStates []func(net.Conn, http.ConnState)
+	if in.ConnStateFactory != nil {
+		cs, err := in.ConnStateFactory.New(serverName, o)
+		if err != nil {
+			return nil, err
+		}
+
+		if cs != nil {
+			connStates = append(connStates, cs)
+		}
+	}
+
 	var (
 		router = mux.NewRouter()
 		server = New(
 			o,
 			serverLogger,
 			serverChain.Extend(u.Chain).Then(router),
+			connStates...,
 		)
 	)
 
-	in.Lifecycle.Append(fx.Hook{
-		OnStart: OnStart(o, server, serverLogger, func() { in.Shutdowner.Shutdown() }),
-		OnStop:  OnStop(server, serverLogger),
-	})
+	if len(o.OptionsPath) > 0 {
+		optionsHandler, err := NewOptionsHandler(o)
+		if err != nil {
+			return nil, err
+		}
+
+		router.Handle(o.OptionsPath, optionsHandler).Methods("GET")
+	}
+
+	var policy *TlsPolicy
+	if in.TlsPolicies != nil && o.Tls != nil {
+		policy = in.TlsPolicies.register(serverName, o)
+	}
+
+	if in.ShutdownSequence != nil {
+		in.Lifecycle.Append(fx.Hook{
+			OnStart: onStart(serverName, o, server, serverLogger, shutdownOnError(in.Shutdowner), policy),
+		})
+
+		in.ShutdownSequence.Add(o.ShutdownOrder, onStop(o, server, serverLogger))
+	} else {
+		in.Lifecycle.Append(fx.Hook{
+			OnStart: onStart(serverName, o, server, serverLogger, shutdownOnError(in.Shutdowner), policy),
+			OnStop:  onStop(o, server, serverLogger),
+		})
+	}
 
 	return router, nil
 }
@@ -1,12 +1,19 @@
 package xhttpserver
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/xmidt-org/themis/config"
 	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+	"github.com/xmidt-org/themis/xmetrics"
 
 	"github.com/go-kit/kit/log"
 	"github.com/gorilla/mux"
@@ -79,6 +86,41 @@ func testUnmarshalProvideFull(t *testing.T) {
 	app.RequireStop()
 }
 
+func testUnmarshalProvideOptionsPath(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		router *mux.Router
+		app    = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"optionsPath": "/debug/options"
+							}
+						}
+					`),
+				),
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Populate(&router),
+		)
+	)
+
+	require.NotNil(router)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest("GET", "/debug/options", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Contains(response.Body.String(), "/debug/options")
+
+	app.RequireStart()
+	app.RequireStop()
+}
+
 type testUnmarshalProvideOptionalIn struct {
 	fx.In
 
@@ -169,6 +211,210 @@ func testUnmarshalProvideUnmarshalError(t *testing.T) {
 	assert.Error(app.Err())
 }
 
+func testUnmarshalProvideFaviconError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"favicon": {
+									"file": "/this/file/does/not/exist.ico"
+								}
+							}
+						}
+					`),
+				),
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	assert.Error(app.Err())
+}
+
+func testUnmarshalProvideDebugTrustedError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"debugTrusted": ["10.0.0.0/99"]
+							}
+						}
+					`),
+				),
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	assert.Error(app.Err())
+	assert.Contains(app.Err().Error(), "DebugTrusted")
+}
+
+func testUnmarshalProvideMaintenanceTrustedError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"maintenance": true,
+								"maintenanceTrusted": ["not an address"]
+							}
+						}
+					`),
+				),
+				ProvideMaintenance,
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	assert.Error(app.Err())
+	assert.Contains(app.Err().Error(), "MaintenanceTrusted")
+}
+
+func testUnmarshalProvideLogParameterSets(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		router *mux.Router
+		app    = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"logParameterSets": ["minimal"]
+							}
+						}
+					`),
+				),
+				func() xloghttp.ParameterBuilderSets {
+					return xloghttp.ParameterBuilderSets{
+						"minimal": xloghttp.ParameterBuilders{xloghttp.Method("requestMethod")},
+					}
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Populate(&router),
+		)
+	)
+
+	require.NoError(app.Err())
+	assert.NotNil(router)
+	app.RequireStart()
+	app.RequireStop()
+}
+
+func testUnmarshalProvideNoParameterBuilderSets(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"logParameterSets": ["minimal"]
+							}
+						}
+					`),
+				),
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	require.Error(t, app.Err())
+	assert.Contains(app.Err().Error(), ErrNoParameterBuilderSets.Error())
+}
+
+func testUnmarshalProvideLogParameterSetsError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"logParameterSets": ["verbose"]
+							}
+						}
+					`),
+				),
+				func() xloghttp.ParameterBuilderSets {
+					return xloghttp.ParameterBuilderSets{
+						"minimal": xloghttp.ParameterBuilders{xloghttp.Method("requestMethod")},
+					}
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	require.Error(t, app.Err())
+	assert.Contains(app.Err().Error(), "verbose")
+}
+
 func testUnmarshalProvideChainFactoryError(t *testing.T) {
 	var (
 		assert      = assert.New(t)
@@ -206,6 +452,298 @@ func testUnmarshalProvideChainFactoryError(t *testing.T) {
 	assert.Error(app.Err())
 }
 
+func testUnmarshalProvideConnStateFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		factoryName string
+		router      *mux.Router
+		app         = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true
+							}
+						}
+					`),
+				),
+				func() ConnStateFactory {
+					return ConnStateFactoryFunc(func(name string, o Options) (func(net.Conn, http.ConnState), error) {
+						factoryName = name
+						return func(net.Conn, http.ConnState) {}, nil
+					})
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Populate(&router),
+		)
+	)
+
+	require.NotNil(router)
+	assert.Equal("server", factoryName)
+	app.RequireStart()
+	app.RequireStop()
+}
+
+func testUnmarshalProvideConnStateFactoryError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		expectedErr = errors.New("expected conn state factory error")
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true
+							}
+						}
+					`),
+				),
+				func() ConnStateFactory {
+					return ConnStateFactoryFunc(func(name string, o Options) (func(net.Conn, http.ConnState), error) {
+						return nil, expectedErr
+					})
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	assert.Error(app.Err())
+}
+
+func testUnmarshalProvideConcurrencyMetricsFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		factoryName string
+		router      *mux.Router
+		app         = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true,
+								"concurrencyLimit": 10
+							}
+						}
+					`),
+				),
+				func() ConcurrencyMetricsFactory {
+					return ConcurrencyMetricsFactoryFunc(func(name string, o Options) (ConcurrencyMetrics, error) {
+						factoryName = name
+						return ConcurrencyMetrics{}, nil
+					})
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Populate(&router),
+		)
+	)
+
+	require.NotNil(router)
+	assert.Equal("server", factoryName)
+	app.RequireStart()
+	app.RequireStop()
+}
+
+func testUnmarshalProvideConcurrencyMetricsFactoryError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		expectedErr = errors.New("expected concurrency metrics factory error")
+
+		app = fx.New(
+			fx.Logger(xlog.DiscardPrinter{}),
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true,
+								"concurrencyLimit": 10
+							}
+						}
+					`),
+				),
+				func() ConcurrencyMetricsFactory {
+					return ConcurrencyMetricsFactoryFunc(func(name string, o Options) (ConcurrencyMetrics, error) {
+						return ConcurrencyMetrics{}, expectedErr
+					})
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Invoke(
+				func(*mux.Router) {
+					assert.Fail("This invoke function should not have been called")
+				},
+			),
+		)
+	)
+
+	assert.Error(app.Err())
+}
+
+func testUnmarshalProvideTlsPolicies(t *testing.T) {
+	certificateFile, keyFile := createServerFiles(t)
+	defer os.Remove(certificateFile)
+	defer os.Remove(keyFile)
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		policies *TlsPolicies
+		app      = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(fmt.Sprintf(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true,
+								"tls": {
+									"certificateFile": %q,
+									"keyFile": %q
+								}
+							},
+							"plaintext": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true
+							}
+						}
+					`, certificateFile, keyFile)),
+				),
+				ProvideTlsPolicies,
+				Unmarshal{Key: "server"}.Annotated(),
+				Unmarshal{Key: "plaintext"}.Annotated(),
+			),
+			fx.Invoke(
+				func(testUnmarshalTlsPoliciesIn) {},
+			),
+			fx.Populate(&policies),
+		)
+	)
+
+	require.NotNil(policies)
+	assert.Nil(policies.Get("plaintext"))
+
+	policy := policies.Get("server")
+	require.NotNil(policy)
+	assert.Nil(policy.Current())
+
+	app.RequireStart()
+	assert.NotNil(policy.Current())
+	app.RequireStop()
+}
+
+func testUnmarshalProvideHandshakeWaitFactory(t *testing.T) {
+	certificateFile, keyFile := createServerFiles(t)
+	defer os.Remove(certificateFile)
+	defer os.Remove(keyFile)
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		factoryName string
+		router      *mux.Router
+		app         = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(fmt.Sprintf(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true,
+								"maxConcurrentHandshakes": 10,
+								"tls": {
+									"certificateFile": %q,
+									"keyFile": %q
+								}
+							}
+						}
+					`, certificateFile, keyFile)),
+				),
+				func() HandshakeWaitFactory {
+					return HandshakeWaitFactoryFunc(func(name string, o Options) (xmetrics.Observer, error) {
+						factoryName = name
+						return new(testObserver), nil
+					})
+				},
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Populate(&router),
+		)
+	)
+
+	require.NotNil(router)
+	assert.Equal("server", factoryName)
+	app.RequireStart()
+	app.RequireStop()
+}
+
+type testUnmarshalTlsPoliciesIn struct {
+	fx.In
+
+	Server    *mux.Router `name:"server"`
+	Plaintext *mux.Router `name:"plaintext"`
+}
+
+func testUnmarshalProvideShutdownSequence(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		router *mux.Router
+		app    = fxtest.New(t,
+			fx.Provide(
+				xlog.Provide(log.NewNopLogger()),
+				config.ProvideViper(
+					config.Json(`
+						{
+							"server": {
+								"address": "127.0.0.1:0",
+								"disableHTTPKeepAlives": true,
+								"shutdownOrder": 1
+							}
+						}
+					`),
+				),
+				ProvideShutdownSequence,
+				Unmarshal{Key: "server"}.Provide,
+			),
+			fx.Populate(&router),
+			fx.Invoke(StopInSequence),
+		)
+	)
+
+	require.NotNil(router)
+	app.RequireStart()
+	app.RequireStop()
+}
+
 type testUnmarshalAnnotatedFullIn struct {
 	fx.In
 
@@ -324,17 +862,176 @@ func testUnmarshalAnnotatedNamed(t *testing.T) {
 	app.RequireStop()
 }
 
+func testUnmarshalHeader(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		out, err = config.ProvideViper(
+			config.Yaml(`
+server:
+  header:
+    x-single: value
+    X-Multiple: ["value1", "value2"]
+`),
+		)(config.ViperIn{})
+	)
+
+	require.NoError(err)
+	require.NotNil(out.Unmarshaller)
+
+	var o Options
+	require.NoError(out.Unmarshaller.UnmarshalKey("server", &o))
+	assert.Equal(
+		http.Header{
+			"X-Single":   []string{"value"},
+			"X-Multiple": []string{"value1", "value2"},
+		},
+		o.Header,
+	)
+}
+
+func testUnmarshalTlsVersions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		out, err = config.ProvideViper(
+			config.Yaml(`
+server:
+  tls:
+    minVersion: "1.2"
+    maxVersion: TLS1.3
+    versionFloor: 0x0301
+`),
+		)(config.ViperIn{})
+	)
+
+	require.NoError(err)
+	require.NotNil(out.Unmarshaller)
+
+	var o Options
+	require.NoError(out.Unmarshaller.UnmarshalKey("server", &o))
+	require.NotNil(o.Tls)
+	assert.Equal(config.TlsVersion(tls.VersionTLS12), o.Tls.MinVersion)
+	assert.Equal(config.TlsVersion(tls.VersionTLS13), o.Tls.MaxVersion)
+	assert.Equal(config.TlsVersion(tls.VersionTLS10), o.Tls.VersionFloor)
+}
+
+func testUnmarshalTlsVersionsFloat(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// unquoted, YAML parses these as numbers rather than strings
+		out, err = config.ProvideViper(
+			config.Yaml(`
+server:
+  tls:
+    minVersion: 1.2
+    maxVersion: 1.3
+    versionFloor: 1.0
+`),
+		)(config.ViperIn{})
+	)
+
+	require.NoError(err)
+	require.NotNil(out.Unmarshaller)
+
+	var o Options
+	require.NoError(out.Unmarshaller.UnmarshalKey("server", &o))
+	require.NotNil(o.Tls)
+	assert.Equal(config.TlsVersion(tls.VersionTLS12), o.Tls.MinVersion)
+	assert.Equal(config.TlsVersion(tls.VersionTLS13), o.Tls.MaxVersion)
+	assert.Equal(config.TlsVersion(tls.VersionTLS10), o.Tls.VersionFloor)
+}
+
+func testUnmarshalTlsVersionsInvalid(t *testing.T) {
+	for _, value := range []string{"TLS1.4", "1.4", "1.25", "garbage"} {
+		t.Run(value, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				out, err = config.ProvideViper(
+					config.Yaml(`
+server:
+  tls:
+    minVersion: ` + value + `
+`),
+				)(config.ViperIn{})
+			)
+
+			require.NoError(err)
+			require.NotNil(out.Unmarshaller)
+
+			var o Options
+			err = out.Unmarshaller.UnmarshalKey("server", &o)
+			require.Error(err)
+			assert.Contains(err.Error(), value)
+		})
+	}
+}
+
+func testUnmarshalSocketOwnership(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		out, err = config.ProvideViper(
+			config.Yaml(`
+server:
+  network: unix
+  socketUID: 0
+  socketGID: 1000
+`),
+		)(config.ViperIn{})
+	)
+
+	require.NoError(err)
+	require.NotNil(out.Unmarshaller)
+
+	var o Options
+	require.NoError(out.Unmarshaller.UnmarshalKey("server", &o))
+
+	// root must be distinguishable from an unset owner
+	require.NotNil(o.SocketUID)
+	assert.Equal(0, *o.SocketUID)
+	require.NotNil(o.SocketGID)
+	assert.Equal(1000, *o.SocketGID)
+}
+
 func TestUnmarshal(t *testing.T) {
 	t.Run("Provide", func(t *testing.T) {
 		t.Run("Full", testUnmarshalProvideFull)
+		t.Run("OptionsPath", testUnmarshalProvideOptionsPath)
 		t.Run("Optional", testUnmarshalProvideOptional)
 		t.Run("Required", testUnmarshalProvideRequired)
 		t.Run("UnmarshalError", testUnmarshalProvideUnmarshalError)
+		t.Run("FaviconError", testUnmarshalProvideFaviconError)
+		t.Run("DebugTrustedError", testUnmarshalProvideDebugTrustedError)
+		t.Run("MaintenanceTrustedError", testUnmarshalProvideMaintenanceTrustedError)
+		t.Run("LogParameterSets", testUnmarshalProvideLogParameterSets)
+		t.Run("NoParameterBuilderSets", testUnmarshalProvideNoParameterBuilderSets)
+		t.Run("LogParameterSetsError", testUnmarshalProvideLogParameterSetsError)
 		t.Run("ChainFactoryError", testUnmarshalProvideChainFactoryError)
+		t.Run("ConnStateFactory", testUnmarshalProvideConnStateFactory)
+		t.Run("ConnStateFactoryError", testUnmarshalProvideConnStateFactoryError)
+		t.Run("ConcurrencyMetricsFactory", testUnmarshalProvideConcurrencyMetricsFactory)
+		t.Run("ConcurrencyMetricsFactoryError", testUnmarshalProvideConcurrencyMetricsFactoryError)
+		t.Run("ShutdownSequence", testUnmarshalProvideShutdownSequence)
+		t.Run("TlsPolicies", testUnmarshalProvideTlsPolicies)
+		t.Run("HandshakeWaitFactory", testUnmarshalProvideHandshakeWaitFactory)
 	})
 
 	t.Run("Annotated", func(t *testing.T) {
 		t.Run("Full", testUnmarshalAnnotatedFull)
 		t.Run("Named", testUnmarshalAnnotatedNamed)
 	})
+
+	t.Run("Header", testUnmarshalHeader)
+	t.Run("TlsVersions", testUnmarshalTlsVersions)
+	t.Run("TlsVersionsFloat", testUnmarshalTlsVersionsFloat)
+	t.Run("TlsVersionsInvalid", testUnmarshalTlsVersionsInvalid)
+	t.Run("SocketOwnership", testUnmarshalSocketOwnership)
 }
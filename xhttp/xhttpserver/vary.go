@@ -0,0 +1,61 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AddVary merges header names into the Vary header of a response.  Names already present, compared
+// case-insensitively, are not repeated, and existing values are never removed.  Once Vary is *, no other
+// names are added, since the response already varies on everything.
+//
+// Any middleware or handler that selects a response based on a request header, e.g. Accept, Accept-Encoding,
+// or Origin, should use this function rather than setting Vary directly.  Otherwise, caches may serve the
+// wrong representation.
+func AddVary(h http.Header, names ...string) {
+	present := make(map[string]bool)
+	for _, value := range h["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				present[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+
+	if present["*"] {
+		return
+	}
+
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if len(name) == 0 || present[name] {
+			continue
+		}
+
+		present[name] = true
+		h.Add("Vary", name)
+	}
+}
+
+// Vary is an Alice-style decorator that merges header names into the Vary header of every response, via AddVary.
+// This is useful when a handler's output depends on request headers but the handler itself does not declare that.
+type Vary struct {
+	// Headers are the request header names that responses vary on.  If empty, no decoration is done.
+	Headers []string
+}
+
+func (v Vary) Then(next http.Handler) http.Handler {
+	if len(v.Headers) == 0 {
+		return next
+	}
+
+	headers := append([]string{}, v.Headers...)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		AddVary(response.Header(), headers...)
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (v Vary) ThenFunc(next http.HandlerFunc) http.Handler {
+	return v.Then(next)
+}
@@ -0,0 +1,77 @@
+package xhttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddVary(t *testing.T) {
+	testData := []struct {
+		existing []string
+		names    []string
+		expected []string
+	}{
+		{nil, nil, nil},
+		{nil, []string{"Accept"}, []string{"Accept"}},
+		{nil, []string{"accept-encoding", " Origin ", ""}, []string{"Accept-Encoding", "Origin"}},
+		{nil, []string{"Accept", "accept"}, []string{"Accept"}},
+		{[]string{"Accept"}, []string{"accept", "Origin"}, []string{"Accept", "Origin"}},
+		{[]string{"Accept-Encoding, accept"}, []string{"Accept", "Origin"}, []string{"Accept-Encoding, accept", "Origin"}},
+		{[]string{"*"}, []string{"Accept"}, []string{"*"}},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+				h      = http.Header{}
+			)
+
+			if len(record.existing) > 0 {
+				h["Vary"] = append([]string{}, record.existing...)
+			}
+
+			AddVary(h, record.names...)
+			assert.Equal(record.expected, h["Vary"])
+		})
+	}
+}
+
+func testVaryNoHeaders(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		next      = Constant{}.NewHandler()
+		decorated = Vary{}.Then(next)
+	)
+
+	assert.Equal(next, decorated)
+}
+
+func testVaryHeaders(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		decorated = Vary{
+			Headers: []string{"Origin", "Accept-Encoding"},
+		}.ThenFunc(func(response http.ResponseWriter, request *http.Request) {
+			AddVary(response.Header(), "Origin")
+			response.WriteHeader(299)
+		})
+
+		response = httptest.NewRecorder()
+	)
+
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Equal([]string{"Origin", "Accept-Encoding"}, response.Header()["Vary"])
+}
+
+func TestVary(t *testing.T) {
+	t.Run("NoHeaders", testVaryNoHeaders)
+	t.Run("Headers", testVaryHeaders)
+}
@@ -0,0 +1,134 @@
+package xhttpserver
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	virtualHostKey = "vhost"
+)
+
+// VirtualHostKey is the logging key for the virtual host pattern that matched a request
+func VirtualHostKey() interface{} {
+	return virtualHostKey
+}
+
+type virtualHostContextKey struct{}
+
+// WithVirtualHost returns a new context with the given virtual host pattern
+func WithVirtualHost(ctx context.Context, vhost string) context.Context {
+	return context.WithValue(ctx, virtualHostContextKey{}, vhost)
+}
+
+// VirtualHostFromContext returns the virtual host pattern that matched the current request.  If no virtual
+// host is present, this function returns the empty string.
+func VirtualHostFromContext(ctx context.Context) string {
+	vh, _ := ctx.Value(virtualHostContextKey{}).(string)
+	return vh
+}
+
+// VirtualHostMux is an http.Handler that dispatches on the request's host, which allows several services to share
+// a single server along with its TLS, logging, and limits.  Each pattern is either an exact hostname or a wildcard,
+// e.g. *.example.com, that matches any subdomain at any depth but not example.com itself.  Patterns are matched
+// case-insensitively against the request's host without its port.  Exact hostnames take precedence over wildcards,
+// and longer wildcards take precedence over shorter ones.
+//
+// The matched pattern is stored in the request context, and is available via VirtualHostFromContext.  If the
+// request has a contextual logger, e.g. from the Logging decorator, that logger is enriched with the pattern.
+//
+// All handlers must be registered prior to serving requests.
+type VirtualHostMux struct {
+	// Default is the optional handler for requests whose host matches no pattern.  If unset, a 404 is returned.
+	Default http.Handler
+
+	// ErrorEncoder is the optional strategy for rendering 404 responses when Default is unset
+	ErrorEncoder ErrorEncoder
+
+	exact     map[string]http.Handler
+	wildcards []virtualHost
+}
+
+type virtualHost struct {
+	pattern string
+	handler http.Handler
+}
+
+// Handle registers a handler for the given hostname or wildcard pattern.  Registering the same pattern again
+// replaces its handler.
+func (vhm *VirtualHostMux) Handle(pattern string, h http.Handler) *VirtualHostMux {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	if !strings.HasPrefix(pattern, "*.") {
+		if vhm.exact == nil {
+			vhm.exact = make(map[string]http.Handler)
+		}
+
+		vhm.exact[pattern] = h
+		return vhm
+	}
+
+	for i := range vhm.wildcards {
+		if vhm.wildcards[i].pattern == pattern {
+			vhm.wildcards[i].handler = h
+			return vhm
+		}
+	}
+
+	vhm.wildcards = append(vhm.wildcards, virtualHost{pattern: pattern, handler: h})
+	sort.SliceStable(vhm.wildcards, func(i, j int) bool {
+		return len(vhm.wildcards[i].pattern) > len(vhm.wildcards[j].pattern)
+	})
+
+	return vhm
+}
+
+// HandleFunc registers a handler function for the given hostname or wildcard pattern
+func (vhm *VirtualHostMux) HandleFunc(pattern string, f func(http.ResponseWriter, *http.Request)) *VirtualHostMux {
+	return vhm.Handle(pattern, http.HandlerFunc(f))
+}
+
+// match returns the pattern and handler for a hostname
+func (vhm *VirtualHostMux) match(host string) (string, http.Handler, bool) {
+	if h, ok := vhm.exact[host]; ok {
+		return host, h, true
+	}
+
+	for _, vh := range vhm.wildcards {
+		if hostMatches(vh.pattern, host) {
+			return vh.pattern, vh.handler, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func (vhm *VirtualHostMux) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	pattern, h, ok := vhm.match(hostname(request.Host))
+	if !ok {
+		switch {
+		case vhm.Default != nil:
+			vhm.Default.ServeHTTP(response, request)
+
+		case vhm.ErrorEncoder != nil:
+			vhm.ErrorEncoder(response, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+
+		default:
+			http.NotFound(response, request)
+		}
+
+		return
+	}
+
+	ctx := WithVirtualHost(request.Context(), pattern)
+	if logger := xlog.GetDefault(ctx, nil); logger != nil {
+		ctx = xlog.With(ctx, log.With(logger, VirtualHostKey(), pattern))
+	}
+
+	h.ServeHTTP(response, request.WithContext(ctx))
+}
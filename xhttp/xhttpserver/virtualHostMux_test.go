@@ -0,0 +1,152 @@
+package xhttpserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+	"github.com/xmidt-org/themis/xlog/xloghttp"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualHostFromContext(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(VirtualHostFromContext(context.Background()))
+	assert.Equal("example.com", VirtualHostFromContext(WithVirtualHost(context.Background(), "example.com")))
+}
+
+func testVirtualHostMuxDispatch(t *testing.T) {
+	var (
+		handler = func(statusCode int) func(http.ResponseWriter, *http.Request) {
+			return func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(statusCode)
+			}
+		}
+
+		vhm = new(VirtualHostMux).
+			HandleFunc("Example.com", handler(291)).
+			HandleFunc("*.example.com", handler(292)).
+			HandleFunc("*.api.example.com", handler(293)).
+			HandleFunc("exact.api.example.com.", handler(294))
+	)
+
+	testData := []struct {
+		host               string
+		expectedStatusCode int
+	}{
+		{"example.com", 291},
+		{"EXAMPLE.COM:8080", 291},
+		{"www.example.com", 292},
+		{"a.b.example.com", 292},
+		{"v1.api.example.com", 293},
+		{"exact.api.example.com", 294},
+		{"other.com", http.StatusNotFound},
+		{"notexample.com", http.StatusNotFound},
+	}
+
+	for _, record := range testData {
+		t.Run(record.host, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", "/test", nil)
+			)
+
+			request.Host = record.host
+			vhm.ServeHTTP(response, request)
+			assert.Equal(record.expectedStatusCode, response.Code)
+		})
+	}
+
+	// replacing a handler
+	assert := assert.New(t)
+	vhm.HandleFunc("*.example.com", handler(295))
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/test", nil)
+	request.Host = "www.example.com"
+	vhm.ServeHTTP(response, request)
+	assert.Equal(295, response.Code)
+}
+
+func testVirtualHostMuxContext(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		actual string
+		vhm    = new(VirtualHostMux).
+			HandleFunc("*.example.com", func(_ http.ResponseWriter, request *http.Request) {
+				actual = VirtualHostFromContext(request.Context())
+			})
+
+		request = httptest.NewRequest("GET", "/test", nil)
+	)
+
+	request.Host = "www.example.com"
+	vhm.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Equal("*.example.com", actual)
+}
+
+func testVirtualHostMuxDefault(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		vhm = VirtualHostMux{
+			Default: Constant{StatusCode: 499}.NewHandler(),
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	vhm.Handle("example.com", Constant{}.NewHandler())
+	vhm.ServeHTTP(response, httptest.NewRequest("GET", "http://other.com/test", nil))
+	assert.Equal(499, response.Code)
+}
+
+func testVirtualHostMuxErrorEncoder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		vhm      = VirtualHostMux{ErrorEncoder: JSONErrorEncoder}
+		response = httptest.NewRecorder()
+	)
+
+	vhm.ServeHTTP(response, httptest.NewRequest("GET", "http://other.com/test", nil))
+	assert.Equal(http.StatusNotFound, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+}
+
+func testVirtualHostMuxLogging(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		vhm    = new(VirtualHostMux).
+			HandleFunc("*.example.com", func(response http.ResponseWriter, request *http.Request) {
+				xlog.Get(request.Context()).Log("msg", "test")
+				response.WriteHeader(299)
+			})
+
+		handler  = xloghttp.Logging{Base: log.NewJSONLogger(&output)}.Then(vhm)
+		response = httptest.NewRecorder()
+	)
+
+	require.NotNil(handler)
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "http://www.example.com/test", nil))
+	assert.Equal(299, response.Code)
+	assert.Contains(output.String(), `"vhost":"*.example.com"`)
+}
+
+func TestVirtualHostMux(t *testing.T) {
+	t.Run("Dispatch", testVirtualHostMuxDispatch)
+	t.Run("Context", testVirtualHostMuxContext)
+	t.Run("Default", testVirtualHostMuxDefault)
+	t.Run("ErrorEncoder", testVirtualHostMuxErrorEncoder)
+	t.Run("Logging", testVirtualHostMuxLogging)
+}
@@ -22,6 +22,10 @@ const (
 	LevelWarn  = "WARN"
 	LevelInfo  = "INFO"
 	LevelDebug = "DEBUG"
+
+	FormatNone   = ""
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
 )
 
 // MessageKey returns the logging key for an arbitrary message
@@ -57,8 +61,34 @@ type Options struct {
 	// MaxAge is the lumberjack maximum age when rolling logs
 	MaxAge int
 
-	// JSON indicates whether a go-kit JSON logger or a logfmt logger is used
+	// JSON indicates whether a go-kit JSON logger or a logfmt logger is used when logging to a file.
+	// This field is ignored if Format is set.
 	JSON bool
+
+	// Format is the output format, either FormatJSON or FormatLogfmt, and is case-insensitive.  If unset,
+	// console logs are JSON and file logs use the JSON field, which preserves the behavior of older configurations.
+	Format string
+}
+
+// format determines the effective output format for these options
+func (o Options) format(console bool) (string, error) {
+	switch strings.ToLower(o.Format) {
+	case FormatNone:
+		if console || o.JSON {
+			return FormatJSON, nil
+		}
+
+		return FormatLogfmt, nil
+
+	case FormatJSON:
+		return FormatJSON, nil
+
+	case FormatLogfmt:
+		return FormatLogfmt, nil
+
+	default:
+		return FormatNone, fmt.Errorf("Unrecognized log format: %s", o.Format)
+	}
 }
 
 // AllowLevel produces a filtered logger with the given level.AllowXXX set.
@@ -110,10 +140,24 @@ func Level(v string) (level.Value, error) {
 
 // New produces a go-kit log.Logger using the given set of configuration options
 func New(o Options) (log.Logger, error) {
+	console := len(o.File) == 0 || o.File == StdoutFile
+	format, err := o.format(console)
+	if err != nil {
+		return nil, err
+	}
+
 	var l log.Logger
-	if len(o.File) == 0 || o.File == StdoutFile {
+	switch {
+	case console && format == FormatJSON:
 		l = Default()
-	} else {
+
+	case console:
+		l = log.WithPrefix(
+			log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout)),
+			TimestampKey(), log.DefaultTimestampUTC,
+		)
+
+	default:
 		w := &lumberjack.Logger{
 			Filename:   o.File,
 			MaxSize:    o.MaxSize,
@@ -121,7 +165,7 @@ func New(o Options) (log.Logger, error) {
 			MaxAge:     o.MaxAge,
 		}
 
-		if o.JSON {
+		if format == FormatJSON {
 			l = log.NewJSONLogger(w)
 		} else {
 			l = log.NewLogfmtLogger(w)
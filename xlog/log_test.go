@@ -216,6 +216,8 @@ func TestNew(t *testing.T) {
 		testData := []Options{
 			Options{},
 			Options{File: StdoutFile},
+			Options{File: StdoutFile, Format: FormatJSON},
+			Options{Format: "JSON"},
 		}
 
 		for i, o := range testData {
@@ -229,6 +231,10 @@ func TestNew(t *testing.T) {
 			Options{File: "test.log", Level: "INFO", JSON: false},
 			Options{File: "test.log", JSON: true},
 			Options{File: "test.log", Level: "INFO", JSON: true},
+			Options{File: "test.log", Format: FormatJSON},
+			Options{File: "test.log", Format: FormatLogfmt, JSON: true},
+			Options{Format: FormatLogfmt},
+			Options{File: StdoutFile, Format: "LOGFMT", Level: "INFO"},
 		}
 
 		for i, o := range testData {
@@ -240,6 +246,8 @@ func TestNew(t *testing.T) {
 		testData := []Options{
 			Options{Level: "invalid"},
 			Options{File: "test.log", Level: "invalid"},
+			Options{Format: "invalid"},
+			Options{File: "test.log", Format: "invalid"},
 		}
 
 		for i, o := range testData {
@@ -248,6 +256,32 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestOptionsFormat(t *testing.T) {
+	testData := []struct {
+		options  Options
+		console  bool
+		expected string
+		err      bool
+	}{
+		{options: Options{}, console: true, expected: FormatJSON},
+		{options: Options{}, console: false, expected: FormatLogfmt},
+		{options: Options{JSON: true}, console: false, expected: FormatJSON},
+		{options: Options{Format: "Logfmt"}, console: true, expected: FormatLogfmt},
+		{options: Options{Format: FormatLogfmt, JSON: true}, console: false, expected: FormatLogfmt},
+		{options: Options{Format: FormatJSON}, console: false, expected: FormatJSON},
+		{options: Options{Format: "xml"}, console: true, err: true},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := record.options.format(record.console)
+			assert.Equal(record.expected, actual)
+			assert.Equal(record.err, err != nil)
+		})
+	}
+}
+
 func TestDefault(t *testing.T) {
 	assert := assert.New(t)
 	assert.Equal(defaultLogger, Default())
@@ -4,6 +4,7 @@ import (
 	"github.com/xmidt-org/themis/config"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"go.uber.org/fx"
 )
 
@@ -36,3 +37,30 @@ func Unmarshal(key string) func(LogUnmarshalIn) (log.Logger, error) {
 		return l, err
 	}
 }
+
+// UnmarshalFallback is like Unmarshal, except that a logger which cannot be unmarshalled does not prevent the
+// application from starting.  Instead, Default() is used and the error is logged to it as a warning.  This is useful
+// when starting with a default logger is preferable to not starting at all, e.g. during an incident.  Unmarshal,
+// which fails fast, remains the better choice for most applications.
+func UnmarshalFallback(key string) func(LogUnmarshalIn) log.Logger {
+	unmarshal := Unmarshal(key)
+	return func(in LogUnmarshalIn) log.Logger {
+		l, err := unmarshal(in)
+		if err == nil {
+			return l
+		}
+
+		l = Default()
+		l.Log(
+			level.Key(), level.WarnValue(),
+			MessageKey(), "unable to unmarshal logger; using the default logger",
+			ErrorKey(), err,
+		)
+
+		if in.Printer != nil {
+			in.Printer.SetLogger(l)
+		}
+
+		return l
+	}
+}
@@ -105,8 +105,139 @@ func testUnmarshalFailure(t *testing.T) {
 	assert.Nil(logger)
 }
 
+func testUnmarshalFormat(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger log.Logger
+
+		app = fxtest.New(t,
+			fx.Provide(
+				config.ProvideViper(
+					config.Json(`
+						{
+							"log": {
+								"file": "stdout",
+								"format": "logfmt"
+							}
+						}`,
+					),
+				),
+				Unmarshal("log"),
+			),
+			fx.Populate(&logger),
+		)
+	)
+
+	require.NoError(app.Err())
+	assert.NotNil(logger)
+	assert.NotEqual(Default(), logger)
+}
+
+func testUnmarshalInvalidFormat(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger log.Logger
+
+		app = fx.New(
+			fx.Logger(DiscardPrinter{}),
+			fx.Provide(
+				config.ProvideViper(
+					config.Json(`
+						{
+							"log": {
+								"file": "stdout",
+								"format": "xml"
+							}
+						}`,
+					),
+				),
+				Unmarshal("log"),
+			),
+			fx.Populate(&logger),
+		)
+	)
+
+	require.Error(app.Err())
+	assert.Nil(logger)
+}
+
 func TestUnmarshal(t *testing.T) {
 	t.Run("Success", testUnmarshalSuccess)
 	t.Run("WithBufferedPrinter", testUnmarshalWithBufferedPrinter)
 	t.Run("Failure", testUnmarshalFailure)
+	t.Run("Format", testUnmarshalFormat)
+	t.Run("InvalidFormat", testUnmarshalInvalidFormat)
+}
+
+func testUnmarshalFallbackSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger log.Logger
+
+		app = fxtest.New(t,
+			fx.Provide(
+				config.ProvideViper(
+					config.Json(`
+						{
+							"log": {
+								"file": "stdout",
+								"format": "logfmt"
+							}
+						}`,
+					),
+				),
+				UnmarshalFallback("log"),
+			),
+			fx.Populate(&logger),
+		)
+	)
+
+	require.NoError(app.Err())
+	assert.NotNil(logger)
+	assert.NotEqual(Default(), logger)
+}
+
+func testUnmarshalFallbackFailure(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger  log.Logger
+		printer *BufferedPrinter
+
+		app = fxtest.New(t,
+			Logger(),
+			fx.Provide(
+				config.ProvideViper(
+					config.Json(`
+						{
+							"log": {
+								"file": "stdout",
+								"format": "xml"
+							}
+						}`,
+					),
+				),
+				UnmarshalFallback("log"),
+			),
+			fx.Populate(&logger, &printer),
+		)
+	)
+
+	require.NoError(app.Err())
+	assert.Equal(Default(), logger)
+
+	require.NotNil(printer)
+	assert.Equal(Default(), printer.logger)
+}
+
+func TestUnmarshalFallback(t *testing.T) {
+	t.Run("Success", testUnmarshalFallbackSuccess)
+	t.Run("Failure", testUnmarshalFallbackFailure)
 }
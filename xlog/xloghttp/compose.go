@@ -0,0 +1,59 @@
+package xloghttp
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Compose returns a single ParameterBuilder that invokes each of the given builders in order
+func Compose(b ...ParameterBuilder) ParameterBuilder {
+	builders := append(ParameterBuilders{}, b...)
+	return func(original *http.Request, p *Parameters) {
+		for _, f := range builders {
+			f(original, p)
+		}
+	}
+}
+
+// When returns a ParameterBuilder that invokes the given builders, in order, only for requests that satisfy
+// the predicate.  This is useful for logging fields that only apply to some requests, e.g. to a particular path.
+func When(predicate func(*http.Request) bool, b ...ParameterBuilder) ParameterBuilder {
+	composed := Compose(b...)
+	return func(original *http.Request, p *Parameters) {
+		if predicate(original) {
+			composed(original, p)
+		}
+	}
+}
+
+// ParameterBuilderSets holds named groups of builders, e.g. a minimal set and a verbose set.  Supplying this
+// type as a component allows configuration to choose which logging fields are active, rather than
+// compiling that choice into each application.
+type ParameterBuilderSets map[string]ParameterBuilders
+
+// Select returns the builders from each of the named sets, concatenated in the order given.  An error is
+// returned if any name does not refer to a set.
+func (pbs ParameterBuilderSets) Select(names ...string) (ParameterBuilders, error) {
+	var selected ParameterBuilders
+	for _, name := range names {
+		set, ok := pbs[name]
+		if !ok {
+			return nil, fmt.Errorf("No such logging parameter set [%s].  Available sets are %v", name, pbs.names())
+		}
+
+		selected = append(selected, set...)
+	}
+
+	return selected, nil
+}
+
+func (pbs ParameterBuilderSets) names() []string {
+	names := make([]string, 0, len(pbs))
+	for name := range pbs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,94 @@
+package xloghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompose(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		request = httptest.NewRequest("GET", "/test", nil)
+		p       Parameters
+		builder = Compose(Method("requestMethod"), URI("requestURI"))
+	)
+
+	require.NotNil(builder)
+	builder(request, &p)
+	assert.Equal([]interface{}{"requestMethod", "GET", "requestURI", "/test"}, p.values)
+}
+
+func TestWhen(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		builder = When(
+			func(request *http.Request) bool {
+				return request.Method == "POST"
+			},
+			Method("requestMethod"),
+			URI("requestURI"),
+		)
+	)
+
+	require.NotNil(builder)
+
+	var p Parameters
+	builder(httptest.NewRequest("GET", "/test", nil), &p)
+	assert.Empty(p.values)
+
+	builder(httptest.NewRequest("POST", "/test", nil), &p)
+	assert.Equal([]interface{}{"requestMethod", "POST", "requestURI", "/test"}, p.values)
+}
+
+func TestParameterBuilderSets(t *testing.T) {
+	sets := ParameterBuilderSets{
+		"minimal": ParameterBuilders{Method("requestMethod")},
+		"verbose": ParameterBuilders{URI("requestURI"), RemoteAddress("remoteAddr")},
+	}
+
+	t.Run("Select", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "/test", nil)
+			p       Parameters
+		)
+
+		selected, err := sets.Select("verbose", "minimal")
+		require.NoError(err)
+		require.Len(selected, 3)
+		for _, f := range selected {
+			f(request, &p)
+		}
+
+		assert.Equal(
+			[]interface{}{"requestURI", "/test", "remoteAddr", request.RemoteAddr, "requestMethod", "GET"},
+			p.values,
+		)
+	})
+
+	t.Run("None", func(t *testing.T) {
+		assert := assert.New(t)
+		selected, err := sets.Select()
+		assert.NoError(err)
+		assert.Empty(selected)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		assert := assert.New(t)
+		selected, err := sets.Select("minimal", "nosuch")
+		assert.Error(err)
+		assert.Empty(selected)
+		assert.Contains(err.Error(), "nosuch")
+		assert.Contains(err.Error(), "[minimal verbose]")
+	})
+}
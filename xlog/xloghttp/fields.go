@@ -0,0 +1,61 @@
+package xloghttp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// fields holds the logging key/value pairs that handlers add to a request while it executes
+type fields struct {
+	lock   sync.Mutex
+	values []interface{}
+}
+
+func (f *fields) add(key, value interface{}) {
+	f.lock.Lock()
+	f.values = append(f.values, key, value)
+	f.lock.Unlock()
+}
+
+// appendTo appends the current key/value pairs to a log entry
+func (f *fields) appendTo(keyvals []interface{}) []interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if len(f.values) == 0 {
+		return keyvals
+	}
+
+	// never modify the caller's slice
+	return append(keyvals[:len(keyvals):len(keyvals)], f.values...)
+}
+
+type fieldsContextKey struct{}
+
+// fieldLogger is a go-kit Logger that appends a request's added fields to each entry
+type fieldLogger struct {
+	next   log.Logger
+	fields *fields
+}
+
+func (fl fieldLogger) Log(keyvals ...interface{}) error {
+	return fl.next.Log(fl.fields.appendTo(keyvals)...)
+}
+
+// AddLogField adds a key/value pair to the contextual logger of the request that owns the given context.
+// This allows handlers to contribute information that is only known mid-request, e.g. a tenant or user ID.
+// The pair appears in every entry subsequently logged with that request's contextual logger, including those
+// logged by decorators after the handler returns.  Entries that were already logged are unaffected.
+//
+// This function returns false, and does nothing, if the context did not come from a request decorated
+// by Logging.
+func AddLogField(ctx context.Context, key, value interface{}) bool {
+	f, ok := ctx.Value(fieldsContextKey{}).(*fields)
+	if ok {
+		f.add(key, value)
+	}
+
+	return ok
+}
@@ -0,0 +1,65 @@
+package xloghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xmidt-org/themis/xlog"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddLogField(t *testing.T) {
+	t.Run("NoLogging", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.False(AddLogField(context.Background(), "key", "value"))
+	})
+
+	t.Run("Logging", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			output bytes.Buffer
+
+			decorated = Logging{
+				Base:     log.NewJSONLogger(&output),
+				Builders: ParameterBuilders{Method("requestMethod")},
+			}.Then(
+				http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+					logger := xlog.Get(request.Context())
+					logger.Log("msg", "before")
+
+					assert.True(AddLogField(request.Context(), "tenant", "acme"))
+					assert.True(AddLogField(request.Context(), "user", "joe"))
+
+					// simulate an entry logged by a decorator with its own enriched logger
+					log.With(logger, "route", "/test").Log("msg", "after")
+				}),
+			)
+		)
+
+		decorated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+		decoder := json.NewDecoder(&output)
+		var before, after map[string]interface{}
+		require.NoError(decoder.Decode(&before))
+		require.NoError(decoder.Decode(&after))
+
+		assert.Equal(
+			map[string]interface{}{"msg": "before", "requestMethod": "GET"},
+			before,
+		)
+
+		assert.Equal(
+			map[string]interface{}{"msg": "after", "requestMethod": "GET", "route": "/test", "tenant": "acme", "user": "joe"},
+			after,
+		)
+	})
+}
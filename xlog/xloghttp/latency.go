@@ -0,0 +1,112 @@
+package xloghttp
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// DefaultLatencyOverflow is the name of the bucket for durations beyond every configured threshold
+const DefaultLatencyOverflow = "very_slow"
+
+// LatencyBucket is a named latency category.  A duration belongs to the first bucket, in order of
+// ascending Threshold, whose Threshold exceeds that duration.
+type LatencyBucket struct {
+	Name      string
+	Threshold time.Duration
+}
+
+// DefaultLatencyBuckets are the buckets used when a LatencyBuckets has none configured
+var DefaultLatencyBuckets = []LatencyBucket{
+	{Name: "fast", Threshold: 100 * time.Millisecond},
+	{Name: "normal", Threshold: 500 * time.Millisecond},
+	{Name: "slow", Threshold: 2 * time.Second},
+}
+
+// LatencyBuckets classifies durations into coarse, named categories.  This makes log-based alerting on slow
+// requests trivial, without computing percentiles in a log pipeline.
+type LatencyBuckets struct {
+	// Buckets are the named thresholds, in any order.  If unset, DefaultLatencyBuckets is used.
+	Buckets []LatencyBucket
+
+	// Overflow is the name for durations at or beyond the largest threshold.  If unset,
+	// DefaultLatencyOverflow is used.
+	Overflow string
+
+	// Now is the optional strategy for obtaining the system time when measuring latency.  If not supplied,
+	// time.Now is used.
+	Now func() time.Time
+}
+
+// sorted returns a copy of the configured buckets, sorted by ascending threshold
+func (lb LatencyBuckets) sorted() []LatencyBucket {
+	buckets := lb.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	sorted := append([]LatencyBucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Threshold < sorted[j].Threshold
+	})
+
+	return sorted
+}
+
+func (lb LatencyBuckets) now() func() time.Time {
+	if lb.Now != nil {
+		return lb.Now
+	}
+
+	return time.Now
+}
+
+func (lb LatencyBuckets) overflow() string {
+	if len(lb.Overflow) > 0 {
+		return lb.Overflow
+	}
+
+	return DefaultLatencyOverflow
+}
+
+// Classify returns the name of the bucket for the given duration
+func (lb LatencyBuckets) Classify(d time.Duration) string {
+	return classifyLatency(lb.sorted(), lb.overflow(), d)
+}
+
+func classifyLatency(sorted []LatencyBucket, overflow string, d time.Duration) string {
+	for _, b := range sorted {
+		if d < b.Threshold {
+			return b.Name
+		}
+	}
+
+	return overflow
+}
+
+// Latency returns a ParameterBuilder that adds the time elapsed since the request's contextual logger was
+// created, in milliseconds, along with the name of that duration's bucket.  Both values are computed each time
+// the contextual logger is used, so a log statement made as a request completes reports the request's duration.
+// If durationKey is empty, only the bucket is added.
+func Latency(durationKey, bucketKey string, lb LatencyBuckets) ParameterBuilder {
+	var (
+		sorted   = lb.sorted()
+		overflow = lb.overflow()
+		now      = lb.now()
+	)
+
+	return func(_ *http.Request, p *Parameters) {
+		start := now()
+		if len(durationKey) > 0 {
+			p.Add(durationKey, log.Valuer(func() interface{} {
+				return int64(now().Sub(start) / time.Millisecond)
+			}))
+		}
+
+		p.Add(bucketKey, log.Valuer(func() interface{} {
+			return classifyLatency(sorted, overflow, now().Sub(start))
+		}))
+	}
+}
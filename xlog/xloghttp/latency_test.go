@@ -0,0 +1,132 @@
+package xloghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	testData := []struct {
+		buckets  LatencyBuckets
+		duration time.Duration
+		expected string
+	}{
+		{buckets: LatencyBuckets{}, duration: 0, expected: "fast"},
+		{buckets: LatencyBuckets{}, duration: 99 * time.Millisecond, expected: "fast"},
+		{buckets: LatencyBuckets{}, duration: 100 * time.Millisecond, expected: "normal"},
+		{buckets: LatencyBuckets{}, duration: time.Second, expected: "slow"},
+		{buckets: LatencyBuckets{}, duration: 2 * time.Second, expected: DefaultLatencyOverflow},
+		{
+			buckets: LatencyBuckets{
+				Buckets: []LatencyBucket{
+					{Name: "ok", Threshold: time.Second},
+					{Name: "quick", Threshold: 10 * time.Millisecond},
+				},
+				Overflow: "terrible",
+			},
+			duration: 5 * time.Millisecond,
+			expected: "quick",
+		},
+		{
+			buckets: LatencyBuckets{
+				Buckets: []LatencyBucket{
+					{Name: "ok", Threshold: time.Second},
+					{Name: "quick", Threshold: 10 * time.Millisecond},
+				},
+				Overflow: "terrible",
+			},
+			duration: 500 * time.Millisecond,
+			expected: "ok",
+		},
+		{
+			buckets: LatencyBuckets{
+				Buckets:  []LatencyBucket{{Name: "ok", Threshold: time.Second}},
+				Overflow: "terrible",
+			},
+			duration: time.Minute,
+			expected: "terrible",
+		},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, record.expected, record.buckets.Classify(record.duration))
+		})
+	}
+}
+
+func TestLatency(t *testing.T) {
+	t.Run("WithDuration", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			output bytes.Buffer
+			p      Parameters
+		)
+
+		Latency("duration_ms", "latency", LatencyBuckets{
+			Buckets: []LatencyBucket{{Name: "instant", Threshold: time.Hour}},
+		})(httptest.NewRequest("GET", "/", nil), &p)
+
+		require.NoError(p.Use(log.NewJSONLogger(&output)).Log("message", "test"))
+
+		var record map[string]interface{}
+		require.NoError(json.Unmarshal(output.Bytes(), &record))
+		assert.Equal("instant", record["latency"])
+		assert.Contains(record, "duration_ms")
+		assert.GreaterOrEqual(record["duration_ms"], 0.0)
+	})
+
+	t.Run("Now", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			output  bytes.Buffer
+			p       Parameters
+			current = time.Date(2019, time.July, 1, 12, 0, 0, 0, time.UTC)
+		)
+
+		Latency("duration_ms", "latency", LatencyBuckets{
+			Now: func() time.Time { return current },
+		})(httptest.NewRequest("GET", "/", nil), &p)
+
+		current = current.Add(750 * time.Millisecond)
+		require.NoError(p.Use(log.NewJSONLogger(&output)).Log("message", "test"))
+
+		var record map[string]interface{}
+		require.NoError(json.Unmarshal(output.Bytes(), &record))
+		assert.Equal("slow", record["latency"])
+		assert.Equal(750.0, record["duration_ms"])
+	})
+
+	t.Run("BucketOnly", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			output bytes.Buffer
+			p      Parameters
+		)
+
+		Latency("", "latency", LatencyBuckets{
+			Buckets: []LatencyBucket{{Name: "never", Threshold: 0}},
+		})(httptest.NewRequest("GET", "/", nil), &p)
+
+		require.NoError(p.Use(log.NewJSONLogger(&output)).Log("message", "test"))
+
+		var record map[string]interface{}
+		require.NoError(json.Unmarshal(output.Bytes(), &record))
+		assert.Equal(DefaultLatencyOverflow, record["latency"])
+		assert.Len(record, 2)
+	})
+}
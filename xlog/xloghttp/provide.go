@@ -1,11 +1,17 @@
 package xloghttp
 
 // ProvideStandardBuilders provides a standard set of logging fields for contextual handler logging.
-// This function supplies the requestMethod, requestURI, and remoteAddr logging parameters.
+// This function supplies the requestMethod, requestURI, and remoteAddr logging parameters along with
+// the requestID parameter when the request carries a correlation identifier.  The clientCertFingerprint and
+// clientCertSubject parameters are supplied when the request has a TLS client certificate, and the trace_id and
+// span_id parameters are supplied when the request is being traced.
 func ProvideStandardBuilders() ParameterBuilders {
 	return ParameterBuilders{
+		RequestID("requestID"),
 		Method("requestMethod"),
 		URI("requestURI"),
 		RemoteAddress("remoteAddr"),
+		ClientCertificate("clientCertFingerprint", "clientCertSubject"),
+		TraceContext("trace_id", "span_id"),
 	}
 }
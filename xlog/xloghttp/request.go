@@ -1,15 +1,25 @@
 package xloghttp
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/xmidt-org/themis/xlog"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// RequestIDHeader is the HTTP header that carries a request's correlation identifier
+const RequestIDHeader = "X-Request-Id"
+
 // Parameters is a simple builder for logging key/value pairs
 type Parameters struct {
 	values []interface{}
@@ -57,6 +67,48 @@ func RemoteAddress(key string) ParameterBuilder {
 	}
 }
 
+// RequestID returns a ParameterBuilder that adds the request's correlation identifier, taken from the
+// RequestIDHeader, as a logging key/value pair.  If the request has no such header, nothing is added.
+func RequestID(key string) ParameterBuilder {
+	return func(original *http.Request, p *Parameters) {
+		if requestID := original.Header.Get(RequestIDHeader); len(requestID) > 0 {
+			p.Add(key, requestID)
+		}
+	}
+}
+
+// ClientCertificate returns a ParameterBuilder that adds the SHA-256 fingerprint, as lowercase hex, and the
+// subject of the client's leaf certificate as logging key/value pairs.  This is intended for audit trails
+// with mutual TLS.  For non-TLS requests or requests without a client certificate, nothing is added.
+func ClientCertificate(fingerprintKey, subjectKey string) ParameterBuilder {
+	return func(original *http.Request, p *Parameters) {
+		if original.TLS == nil || len(original.TLS.PeerCertificates) == 0 {
+			return
+		}
+
+		leaf := original.TLS.PeerCertificates[0]
+		fingerprint := sha256.Sum256(leaf.Raw)
+		p.Add(fingerprintKey, hex.EncodeToString(fingerprint[:]))
+		p.Add(subjectKey, leaf.Subject.String())
+	}
+}
+
+// TraceContext returns a ParameterBuilder that adds the trace and span identifiers of the request's current
+// OpenTelemetry span, as lowercase hex, as logging key/value pairs.  This allows log entries to be correlated
+// with traces.  The span must already be in the request context, e.g. from a tracing decorator that precedes
+// Logging.  If the request has no valid span context, nothing is added.
+func TraceContext(traceIDKey, spanIDKey string) ParameterBuilder {
+	return func(original *http.Request, p *Parameters) {
+		sc := trace.SpanContextFromContext(original.Context())
+		if !sc.IsValid() {
+			return
+		}
+
+		p.Add(traceIDKey, sc.TraceID().String())
+		p.Add(spanIDKey, sc.SpanID().String())
+	}
+}
+
 // Header returns a ParameterBuilder that appends the given HTTP header as a key/value pair
 func Header(name string) ParameterBuilder {
 	name = http.CanonicalHeaderKey(name)
@@ -98,17 +150,193 @@ func WithRequest(original *http.Request, l log.Logger, b ...ParameterBuilder) *h
 	)
 }
 
-// Logging provides an Alice-style decorator that attaches a contextual logger to requests
+var nopLogger = log.NewNopLogger()
+
+// LoggerFromContext returns the contextual request logger bound by WithRequest or Logging.  This logger is
+// enriched with the request's logging parameters.  If no logger is present in the context, a no-op logger
+// is returned.
+func LoggerFromContext(ctx context.Context) log.Logger {
+	return xlog.GetDefault(ctx, nopLogger)
+}
+
+const (
+	// DefaultMaxHeldEntries is the number of entries held for each request when Logging.MaxHeldEntries is unset
+	DefaultMaxHeldEntries = 100
+
+	droppedKey = "droppedEntries"
+)
+
+// DroppedKey returns the logging key for the number of a request's entries that Logging dropped
+func DroppedKey() interface{} {
+	return droppedKey
+}
+
+// statusLogger is a go-kit Logger that holds a request's entries until that request's response status is known
+type statusLogger struct {
+	next log.Logger
+	max  int
+
+	lock     sync.Mutex
+	entries  [][]interface{}
+	dropped  int
+	complete bool
+	emit     bool
+	emitAll  bool
+}
+
+func (sl *statusLogger) Log(keyvals ...interface{}) error {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	if sl.complete {
+		if sl.emit {
+			return sl.next.Log(keyvals...)
+		}
+
+		return nil
+	}
+
+	if len(sl.entries) >= sl.max {
+		sl.dropped++
+		return nil
+	}
+
+	// evaluate any valuers now, since they describe the state of the request when the entry was logged
+	entry := make([]interface{}, len(keyvals), len(keyvals)+2)
+	hasTimestamp := false
+	for i, v := range keyvals {
+		if valuer, ok := v.(log.Valuer); ok {
+			v = valuer()
+		}
+
+		entry[i] = v
+		hasTimestamp = hasTimestamp || (i%2 == 0 && v == xlog.TimestampKey())
+	}
+
+	// the timestamp is taken now, rather than when the entry is emitted
+	if !hasTimestamp {
+		entry = append(entry, xlog.TimestampKey(), log.DefaultTimestampUTC())
+	}
+
+	sl.entries = append(sl.entries, entry)
+	return nil
+}
+
+// setEmitAll ensures that the held entries, and any entries logged afterward, are emitted
+func (sl *statusLogger) setEmitAll() {
+	sl.lock.Lock()
+	sl.emitAll = true
+	sl.lock.Unlock()
+}
+
+// finish emits or discards the held entries.  Entries logged afterward, e.g. by goroutines that
+// outlive the request, are handled the same way.
+func (sl *statusLogger) finish(emit bool) {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	sl.complete = true
+	sl.emit = emit || sl.emitAll
+	if sl.emit {
+		for _, entry := range sl.entries {
+			sl.next.Log(entry...)
+		}
+
+		if sl.dropped > 0 {
+			sl.next.Log(
+				level.Key(), level.WarnValue(),
+				xlog.MessageKey(), "dropped held log entries",
+				DroppedKey(), sl.dropped,
+			)
+		}
+	}
+
+	sl.entries = nil
+}
+
+type statusLoggerContextKey struct{}
+
+// EmitAll ensures that every entry of the contextual logger of the request that owns the given context is emitted,
+// regardless of that request's response status and of Logging.MinStatusCode.  This is useful when a request is
+// known to be interesting before it completes, e.g. when it asks to be debugged.
+//
+// This function returns false, and does nothing, if the context did not come from a request decorated by Logging
+// with a MinStatusCode.  Entries of such requests are always emitted anyway.
+func EmitAll(ctx context.Context) bool {
+	sl, ok := ctx.Value(statusLoggerContextKey{}).(*statusLogger)
+	if ok {
+		sl.setEmitAll()
+	}
+
+	return ok
+}
+
+// Logging provides an Alice-style decorator that attaches a contextual logger to requests.  Handlers may add
+// fields to that logger while the request executes via AddLogField.
 type Logging struct {
 	Base     log.Logger
 	Builders ParameterBuilders
+
+	// MinStatusCode is the optional response status below which the contextual logger's entries are discarded,
+	// e.g. 400 to only log failed requests.  When set, entries are held until the request completes and are then
+	// either emitted or discarded.  The status is taken from the response writer, which must implement go-kit's
+	// StatusCoder, e.g. a tracking writer.  Entries are always emitted when the status is unavailable, the handler
+	// panics, or EmitAll was called for the request.
+	//
+	// Each held entry records the time it was logged under xlog.TimestampKey, unless it already has a timestamp.
+	// A JSON Base logger that prefixes its own timestamp, as xlog's loggers do, emits the held entry's timestamp
+	// instead, since later keys win.  A logfmt Base logger emits both, the held entry's timestamp last.
+	//
+	// Only logging is affected.  Metrics and any other decorators still see every request.
+	MinStatusCode int
+
+	// MaxHeldEntries limits the number of entries held for each request when MinStatusCode is set.  Further entries
+	// are dropped, and the number dropped is logged under DroppedKey if the held entries are emitted.  If unset,
+	// DefaultMaxHeldEntries is used.
+	MaxHeldEntries int
+}
+
+// withFields binds a new set of fields, and a logger that emits them, to a request
+func withFields(request *http.Request, l log.Logger, b ...ParameterBuilder) *http.Request {
+	f := new(fields)
+	request = WithRequest(request, fieldLogger{next: l, fields: f}, b...)
+	return request.WithContext(
+		context.WithValue(request.Context(), fieldsContextKey{}, f),
+	)
 }
 
 func (l Logging) Then(next http.Handler) http.Handler {
+	if l.MinStatusCode > 0 {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			max := l.MaxHeldEntries
+			if max <= 0 {
+				max = DefaultMaxHeldEntries
+			}
+
+			sl := &statusLogger{next: l.Base, max: max}
+			completed := false
+			defer func() {
+				if !completed {
+					sl.finish(true)
+				}
+			}()
+
+			request = withFields(request, sl, l.Builders...)
+			next.ServeHTTP(
+				response,
+				request.WithContext(context.WithValue(request.Context(), statusLoggerContextKey{}, sl)),
+			)
+
+			completed = true
+			sc, ok := response.(kithttp.StatusCoder)
+			sl.finish(!ok || sc.StatusCode() >= l.MinStatusCode)
+		})
+	}
+
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
 		next.ServeHTTP(
 			response,
-			WithRequest(request, l.Base, l.Builders...),
+			withFields(request, l.Base, l.Builders...),
 		)
 	})
 }
@@ -2,9 +2,16 @@ package xloghttp
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/xmidt-org/themis/xlog"
 
@@ -12,6 +19,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestParameters(t *testing.T) {
@@ -79,6 +87,152 @@ func TestURI(t *testing.T) {
 	assert.Equal([]interface{}{"requestURI", "/test"}, p.values)
 }
 
+func TestRequestID(t *testing.T) {
+	t.Run("Present", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "/", nil)
+			p       Parameters
+			builder = RequestID("requestID")
+		)
+
+		require.NotNil(builder)
+		request.Header.Set(RequestIDHeader, "abc123")
+		builder(request, &p)
+		assert.Equal([]interface{}{"requestID", "abc123"}, p.values)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "/", nil)
+			p       Parameters
+			builder = RequestID("requestID")
+		)
+
+		require.NotNil(builder)
+		builder(request, &p)
+		assert.Empty(p.values)
+	})
+}
+
+func TestClientCertificate(t *testing.T) {
+	t.Run("NoTLS", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "/", nil)
+			p       Parameters
+			builder = ClientCertificate("fingerprint", "subject")
+		)
+
+		require.NotNil(builder)
+		builder(request, &p)
+		assert.Empty(p.values)
+	})
+
+	t.Run("NoClientCertificate", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "https://localhost/", nil)
+			p       Parameters
+			builder = ClientCertificate("fingerprint", "subject")
+		)
+
+		require.NotNil(builder)
+		require.NotNil(request.TLS)
+		builder(request, &p)
+		assert.Empty(p.values)
+	})
+
+	t.Run("ClientCertificate", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "https://localhost/", nil)
+			p       Parameters
+			builder = ClientCertificate("fingerprint", "subject")
+		)
+
+		require.NotNil(builder)
+		request.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{
+				{
+					Raw:     []byte("leaf"),
+					Subject: pkix.Name{CommonName: "client.example.com", Organization: []string{"Example"}},
+				},
+				{
+					Raw:     []byte("intermediate"),
+					Subject: pkix.Name{CommonName: "intermediate"},
+				},
+			},
+		}
+
+		builder(request, &p)
+		assert.Equal(
+			[]interface{}{
+				// sha256 of "leaf"
+				"fingerprint", "9f91161f43433e49a6de6db680d79f60159f2e4ac9172621a12846428158440b",
+				"subject", "CN=client.example.com,O=Example",
+			},
+			p.values,
+		)
+	})
+}
+
+func TestTraceContext(t *testing.T) {
+	t.Run("NoSpan", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			request = httptest.NewRequest("GET", "/", nil)
+			p       Parameters
+			builder = TraceContext("trace_id", "span_id")
+		)
+
+		require.NotNil(builder)
+		builder(request, &p)
+		assert.Empty(p.values)
+	})
+
+	t.Run("Span", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			sc = trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanID:     trace.SpanID{0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8},
+				TraceFlags: trace.FlagsSampled,
+			})
+
+			request = httptest.NewRequest("GET", "/", nil)
+			p       Parameters
+			builder = TraceContext("trace_id", "span_id")
+		)
+
+		require.NotNil(builder)
+		request = request.WithContext(trace.ContextWithSpanContext(request.Context(), sc))
+		builder(request, &p)
+		assert.Equal(
+			[]interface{}{
+				"trace_id", "0102030405060708090a0b0c0d0e0f10",
+				"span_id", "a1a2a3a4a5a6a7a8",
+			},
+			p.values,
+		)
+	})
+}
+
 func TestRemoteAddress(t *testing.T) {
 	var (
 		assert  = assert.New(t)
@@ -289,6 +443,18 @@ func TestWithRequest(t *testing.T) {
 	})
 }
 
+func TestLoggerFromContext(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output   bytes.Buffer
+		original = log.NewJSONLogger(&output)
+	)
+
+	assert.Equal(nopLogger, LoggerFromContext(context.Background()))
+	assert.Equal(original, LoggerFromContext(xlog.With(context.Background(), original)))
+}
+
 func TestLogging(t *testing.T) {
 	t.Run("NoBuilders", func(t *testing.T) {
 		var (
@@ -302,7 +468,8 @@ func TestLogging(t *testing.T) {
 			delegate       = http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
 				delegateCalled = true
 				logger := xlog.Get(request.Context())
-				assert.Equal(original, logger)
+				require.NotNil(logger)
+				logger.Log("msg", "hi")
 			})
 
 			logging = Logging{Base: original}
@@ -312,6 +479,7 @@ func TestLogging(t *testing.T) {
 		require.NotNil(decorated)
 		decorated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
 		assert.True(delegateCalled)
+		assert.JSONEq(`{"msg": "hi"}`, output.String())
 	})
 
 	t.Run("WithBuilders", func(t *testing.T) {
@@ -344,4 +512,188 @@ func TestLogging(t *testing.T) {
 		assert.Contains(output.String(), "requestMethod")
 		assert.Contains(output.String(), "GET")
 	})
+	t.Run("MinStatusCode", func(t *testing.T) {
+		for _, statusCode := range []int{http.StatusOK, http.StatusBadRequest, http.StatusInternalServerError} {
+			t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
+				var (
+					assert  = assert.New(t)
+					require = require.New(t)
+
+					output   bytes.Buffer
+					original = log.NewJSONLogger(&output)
+
+					delegate = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+						logger := xlog.Get(request.Context())
+						logger.Log("msg", "first", "value", log.Valuer(func() interface{} { return "evaluated" }))
+						logger.Log("msg", "second")
+						response.WriteHeader(statusCode)
+					})
+
+					logging = Logging{
+						Base:          original,
+						Builders:      []ParameterBuilder{Method("requestMethod")},
+						MinStatusCode: http.StatusBadRequest,
+					}
+				)
+
+				decorated := logging.Then(delegate)
+				require.NotNil(decorated)
+				decorated.ServeHTTP(&statusCodeRecorder{ResponseRecorder: httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+
+				if statusCode < http.StatusBadRequest {
+					assert.Zero(output.Len())
+					return
+				}
+
+				assert.Contains(output.String(), "first")
+				assert.Contains(output.String(), "evaluated")
+				assert.Contains(output.String(), "second")
+				assert.Contains(output.String(), "requestMethod")
+			})
+		}
+	})
+
+	t.Run("MinStatusCodeTimestamp", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			output   bytes.Buffer
+			original = log.WithPrefix(log.NewJSONLogger(&output), xlog.TimestampKey(), "emitted")
+
+			delegate = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				xlog.Get(request.Context()).Log("msg", "held")
+				xlog.Get(request.Context()).Log("msg", "stamped", xlog.TimestampKey(), "explicit")
+				response.WriteHeader(http.StatusInternalServerError)
+			})
+
+			logging = Logging{Base: original, MinStatusCode: http.StatusBadRequest}
+		)
+
+		before := time.Now()
+		logging.Then(delegate).ServeHTTP(&statusCodeRecorder{ResponseRecorder: httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+
+		decoder := json.NewDecoder(&output)
+
+		var held map[string]interface{}
+		require.NoError(decoder.Decode(&held))
+		assert.Equal("held", held["msg"])
+		logged, err := time.Parse(time.RFC3339Nano, held[xlog.TimestampKey().(string)].(string))
+		require.NoError(err)
+		assert.False(logged.Before(before.Truncate(time.Second)))
+
+		var stamped map[string]interface{}
+		require.NoError(decoder.Decode(&stamped))
+		assert.Equal("explicit", stamped[xlog.TimestampKey().(string)])
+	})
+
+	t.Run("MinStatusCodeMaxHeldEntries", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			output   bytes.Buffer
+			original = log.NewJSONLogger(&output)
+
+			delegate = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				for i := 0; i < 5; i++ {
+					xlog.Get(request.Context()).Log("msg", "entry", "i", i)
+				}
+
+				response.WriteHeader(http.StatusInternalServerError)
+			})
+
+			logging = Logging{Base: original, MinStatusCode: http.StatusBadRequest, MaxHeldEntries: 3}
+		)
+
+		logging.Then(delegate).ServeHTTP(&statusCodeRecorder{ResponseRecorder: httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+
+		var records []map[string]interface{}
+		for decoder := json.NewDecoder(&output); decoder.More(); {
+			var record map[string]interface{}
+			require.NoError(decoder.Decode(&record))
+			records = append(records, record)
+		}
+
+		require.Len(records, 4)
+		for i := 0; i < 3; i++ {
+			assert.Equal(float64(i), records[i]["i"])
+		}
+
+		assert.Equal(2.0, records[3][DroppedKey().(string)])
+	})
+
+	t.Run("MinStatusCodeEmitAll", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			output   bytes.Buffer
+			original = log.NewJSONLogger(&output)
+			emitAll  bool
+
+			delegate = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				emitAll = EmitAll(request.Context())
+				xlog.Get(request.Context()).Log("msg", "debugged")
+			})
+
+			logging = Logging{Base: original, MinStatusCode: http.StatusBadRequest}
+		)
+
+		logging.Then(delegate).ServeHTTP(&statusCodeRecorder{ResponseRecorder: httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+		assert.True(emitAll)
+		assert.Contains(output.String(), "debugged")
+		assert.False(EmitAll(context.Background()))
+	})
+
+	t.Run("MinStatusCodeNoStatus", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			output   bytes.Buffer
+			original = log.NewJSONLogger(&output)
+
+			delegate = http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				xlog.Get(request.Context()).Log("msg", "hi")
+			})
+
+			logging = Logging{Base: original, MinStatusCode: http.StatusBadRequest}
+		)
+
+		logging.Then(delegate).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		assert.Contains(output.String(), "hi")
+	})
+
+	t.Run("MinStatusCodePanic", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			output   bytes.Buffer
+			original = log.NewJSONLogger(&output)
+
+			delegate = http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				xlog.Get(request.Context()).Log("msg", "hi")
+				panic("expected")
+			})
+
+			logging = Logging{Base: original, MinStatusCode: http.StatusBadRequest}
+		)
+
+		assert.Panics(func() {
+			logging.Then(delegate).ServeHTTP(
+				&statusCodeRecorder{ResponseRecorder: httptest.NewRecorder()},
+				httptest.NewRequest("GET", "/", nil),
+			)
+		})
+
+		assert.Contains(output.String(), "hi")
+	})
+}
+
+// statusCodeRecorder is an httptest.ResponseRecorder that reports its status code like a tracking writer
+type statusCodeRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (scr *statusCodeRecorder) StatusCode() int {
+	return scr.Code
 }
@@ -0,0 +1,141 @@
+package xmetricshttp
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BytesWriter is expected to be implemented by http.ResponseWriters that report the size of response bodies,
+// e.g. the tracking writer installed by xhttpserver.
+type BytesWriter interface {
+	BytesWritten() int
+}
+
+// DefaultBodySizeBuckets returns the histogram buckets used for body sizes when none are configured.  These
+// buckets range from 64 bytes to 1 MiB.
+func DefaultBodySizeBuckets() []float64 {
+	return prometheus.ExponentialBuckets(64, 4, 8)
+}
+
+// countingBody is an io.ReadCloser decorator that counts the bytes actually read from a request body
+type countingBody struct {
+	io.ReadCloser
+	bytesRead int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	atomic.AddInt64(&cb.bytesRead, int64(n))
+	return n, err
+}
+
+// BodySizes observes the sizes, in bytes, of request and response bodies in histograms labelled by the request
+// method and route template.  This supports capacity planning without logging every request.
+//
+// Only the request body bytes that a handler actually reads are observed, so handlers that ignore or partially
+// read a body are unaffected.  Response sizes are taken from the http.ResponseWriter if it implements BytesWriter,
+// as is the case with the tracking writer installed by xhttpserver.  Otherwise, a minimal decorator counts them.
+//
+// This type is a prometheus.Collector.  Since routes are only known after a gorilla/mux router has matched a request,
+// install Then as router middleware, e.g. via mux.Router.Use.  See RouteLabeller.
+type BodySizes struct {
+	request  *prometheus.HistogramVec
+	response *prometheus.HistogramVec
+	labeller *ServerLabellers
+}
+
+// bodySizeLabelNames are the label names, in order, of a BodySizes' metrics
+func bodySizeLabelNames() []string {
+	return []string{DefaultMethodLabel, DefaultRouteLabel}
+}
+
+// bodySizeOpts applies the default buckets to histogram options
+func bodySizeOpts(o prometheus.HistogramOpts) prometheus.HistogramOpts {
+	if len(o.Buckets) == 0 {
+		o.Buckets = DefaultBodySizeBuckets()
+	}
+
+	return o
+}
+
+// newBodySizes wraps histograms that have the method and route labels
+func newBodySizes(request, response *prometheus.HistogramVec) *BodySizes {
+	return &BodySizes{
+		request:  request,
+		response: response,
+		labeller: NewServerLabellers(MethodLabeller{}, RouteLabeller{}),
+	}
+}
+
+// NewBodySizes creates an unregistered BodySizes.  If either set of options has no Buckets,
+// DefaultBodySizeBuckets is used.  The returned instance must be registered, e.g. with prometheus.Register,
+// before its observations are exposed.
+func NewBodySizes(request, response prometheus.HistogramOpts) *BodySizes {
+	return newBodySizes(
+		prometheus.NewHistogramVec(bodySizeOpts(request), bodySizeLabelNames()),
+		prometheus.NewHistogramVec(bodySizeOpts(response), bodySizeLabelNames()),
+	)
+}
+
+func (bs *BodySizes) Describe(ch chan<- *prometheus.Desc) {
+	bs.request.Describe(ch)
+	bs.response.Describe(ch)
+}
+
+func (bs *BodySizes) Collect(ch chan<- prometheus.Metric) {
+	bs.request.Collect(ch)
+	bs.response.Collect(ch)
+}
+
+// Then is an Alice-style decorator that observes the body sizes of each request served by the given handler
+func (bs *BodySizes) Then(next http.Handler) http.Handler {
+	var (
+		requestMetric  = xmetrics.LabelledObserverVec{ObserverVec: bs.request}
+		responseMetric = xmetrics.LabelledObserverVec{ObserverVec: bs.response}
+	)
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var body *countingBody
+		if request.Body != nil && request.Body != http.NoBody {
+			body = &countingBody{ReadCloser: request.Body}
+			request.Body = body
+		}
+
+		response = captureStatus(response)
+		next.ServeHTTP(response, request)
+
+		var l xmetrics.Labels
+		bs.labeller.ServerLabels(response, request, &l)
+
+		var bytesRead int64
+		if body != nil {
+			bytesRead = atomic.LoadInt64(&body.bytesRead)
+		}
+
+		requestMetric.Observe(&l, float64(bytesRead))
+		responseMetric.Observe(&l, float64(response.(BytesWriter).BytesWritten()))
+	})
+}
+
+// ProvideBodySizes produces an uber/fx provider for a BodySizes, whose histograms are created and registered
+// via the xmetrics.Factory.  If either set of options has no Buckets, DefaultBodySizeBuckets is used.
+func ProvideBodySizes(request, response prometheus.HistogramOpts) func(xmetrics.Factory) (*BodySizes, error) {
+	return func(f xmetrics.Factory) (*BodySizes, error) {
+		requestHistogram, err := f.NewHistogramVec(bodySizeOpts(request), bodySizeLabelNames())
+		if err != nil {
+			return nil, err
+		}
+
+		responseHistogram, err := f.NewHistogramVec(bodySizeOpts(response), bodySizeLabelNames())
+		if err != nil {
+			return nil, err
+		}
+
+		return newBodySizes(requestHistogram, responseHistogram), nil
+	}
+}
@@ -0,0 +1,180 @@
+package xmetricshttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// histogram returns the state of one labelled histogram of a BodySizes
+func histogram(t *testing.T, vec *prometheus.HistogramVec, method, route string) *dto.Histogram {
+	var m dto.Metric
+	require.NoError(t, vec.WithLabelValues(method, route).(prometheus.Metric).Write(&m))
+	return m.GetHistogram()
+}
+
+func TestDefaultBodySizeBuckets(t *testing.T) {
+	assert := assert.New(t)
+	buckets := DefaultBodySizeBuckets()
+	assert.Len(buckets, 8)
+	assert.Equal(64.0, buckets[0])
+	assert.Equal(float64(1<<20), buckets[len(buckets)-1])
+
+	// each call returns a distinct slice
+	buckets[0] = -1.0
+	assert.Equal(64.0, DefaultBodySizeBuckets()[0])
+}
+
+func testBodySizesPartialRead(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		bodySizes = NewBodySizes(
+			prometheus.HistogramOpts{Name: "request_body_bytes"},
+			prometheus.HistogramOpts{Name: "response_body_bytes"},
+		)
+
+		handler = bodySizes.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			buffer := make([]byte, 4)
+			request.Body.Read(buffer)
+			response.Write([]byte("response body"))
+		}))
+	)
+
+	// a plain recorder does not implement BytesWriter
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("request body")))
+
+	request := histogram(t, bodySizes.request, "POST", DefaultOther)
+	assert.Equal(uint64(1), request.GetSampleCount())
+	assert.Equal(4.0, request.GetSampleSum())
+
+	response := histogram(t, bodySizes.response, "POST", DefaultOther)
+	assert.Equal(uint64(1), response.GetSampleCount())
+	assert.Equal(float64(len("response body")), response.GetSampleSum())
+}
+
+func testBodySizesUnknownLength(t *testing.T) {
+	const body = "a streamed request body"
+
+	var (
+		assert = assert.New(t)
+
+		bodySizes = NewBodySizes(
+			prometheus.HistogramOpts{Name: "request_body_bytes"},
+			prometheus.HistogramOpts{Name: "response_body_bytes"},
+		)
+
+		handler = bodySizes.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			ioutil.ReadAll(request.Body)
+		}))
+
+		request = httptest.NewRequest("PUT", "/", strings.NewReader(body))
+	)
+
+	// as with a chunked request, the size is only known by reading the body
+	request.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	observed := histogram(t, bodySizes.request, "PUT", DefaultOther)
+	assert.Equal(uint64(1), observed.GetSampleCount())
+	assert.Equal(float64(len(body)), observed.GetSampleSum())
+	assert.Zero(histogram(t, bodySizes.response, "PUT", DefaultOther).GetSampleSum())
+}
+
+func testBodySizesNoBody(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		bodySizes = NewBodySizes(
+			prometheus.HistogramOpts{Name: "request_body_bytes"},
+			prometheus.HistogramOpts{Name: "response_body_bytes"},
+		)
+
+		handler = bodySizes.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Write([]byte("response body"))
+		}))
+
+		// a trackedWriter's byte count is used as is
+		response = trackedWriter{ResponseRecorder: httptest.NewRecorder()}
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	observed := histogram(t, bodySizes.request, "GET", DefaultOther)
+	assert.Equal(uint64(1), observed.GetSampleCount())
+	assert.Zero(observed.GetSampleSum())
+	assert.Equal(float64(len("response body")), histogram(t, bodySizes.response, "GET", DefaultOther).GetSampleSum())
+}
+
+func testBodySizesRoute(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		bodySizes = NewBodySizes(
+			prometheus.HistogramOpts{Name: "request_body_bytes"},
+			prometheus.HistogramOpts{Name: "response_body_bytes", Buckets: []float64{10, 100}},
+		)
+
+		router = mux.NewRouter()
+	)
+
+	router.Use(bodySizes.Then)
+	router.HandleFunc("/devices/{id}", func(response http.ResponseWriter, request *http.Request) {
+		response.Write([]byte("device"))
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/devices/123", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/devices/456", nil))
+
+	observed := histogram(t, bodySizes.response, "GET", "/devices/{id}")
+	assert.Equal(uint64(2), observed.GetSampleCount())
+	assert.Equal(12.0, observed.GetSampleSum())
+
+	// custom buckets are retained, while the request histogram has the defaults
+	assert.Len(observed.GetBucket(), 2)
+	assert.Len(histogram(t, bodySizes.request, "GET", "/devices/{id}").GetBucket(), len(DefaultBodySizeBuckets()))
+}
+
+func testBodySizesProvide(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	r, err := xmetrics.New(xmetrics.Options{DisableGoCollector: true, DisableProcessCollector: true})
+	require.NoError(err)
+
+	provide := ProvideBodySizes(
+		prometheus.HistogramOpts{Name: "request_body_bytes", Help: "request body bytes"},
+		prometheus.HistogramOpts{Name: "response_body_bytes", Help: "response body bytes"},
+	)
+
+	bodySizes, err := provide(r)
+	require.NoError(err)
+	require.NotNil(bodySizes)
+
+	bodySizes.Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(uint64(1), histogram(t, bodySizes.response, "GET", DefaultOther).GetSampleCount())
+
+	// the same metrics cannot be registered twice
+	_, err = provide(r)
+	assert.Error(err)
+}
+
+func TestBodySizes(t *testing.T) {
+	t.Run("PartialRead", testBodySizesPartialRead)
+	t.Run("UnknownLength", testBodySizesUnknownLength)
+	t.Run("NoBody", testBodySizesNoBody)
+	t.Run("Route", testBodySizesRoute)
+	t.Run("Provide", testBodySizesProvide)
+}
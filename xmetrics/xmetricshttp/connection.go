@@ -0,0 +1,117 @@
+package xmetricshttp
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/themis/xmetrics"
+)
+
+const (
+	// DefaultTransportLabel is the label name for the transport of a server connection
+	DefaultTransportLabel = "transport"
+
+	// TransportTLS is the transport label value for connections secured with TLS
+	TransportTLS = "tls"
+
+	// TransportPlaintext is the transport label value for unsecured connections
+	TransportPlaintext = "plaintext"
+)
+
+// Transport returns the transport label value for a server connection, either TransportTLS or TransportPlaintext.
+// Any connection that exposes a tls.ConnectionState, such as *tls.Conn, is considered TLS.
+func Transport(c net.Conn) string {
+	if _, ok := c.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		return TransportTLS
+	}
+
+	return TransportPlaintext
+}
+
+// connection holds the running statistics for a single server connection
+type connection struct {
+	start    time.Time
+	requests int
+}
+
+// ConnectionMetrics produces per-connection metrics from http.Server.ConnState transitions.  Each metric
+// is observed once, when a connection is closed or hijacked, with a single label named DefaultTransportLabel.
+//
+// Requests are counted as transitions to http.StateActive.  For HTTP/1.x, this is one per request.  For HTTP/2,
+// net/http only reports the first transition to active, so each HTTP/2 connection counts as a single request.
+type ConnectionMetrics struct {
+	// Lifetime is the optional metric that records how long each connection was open
+	Lifetime xmetrics.Observer
+
+	// Requests is the optional metric that records how many requests were served over each connection
+	Requests xmetrics.Observer
+
+	// Now is the optional strategy for obtaining the system time.  If not supplied, time.Now is used.
+	Now func() time.Time
+
+	// Units is the time unit to report the Lifetime metric in.  If unset, time.Millisecond is used.
+	Units time.Duration
+}
+
+// NewConnState creates a closure suitable for use as, or invoked from, http.Server.ConnState.  If neither
+// metric is set, this method returns nil.
+func (cm ConnectionMetrics) NewConnState() func(net.Conn, http.ConnState) {
+	if cm.Lifetime == nil && cm.Requests == nil {
+		return nil
+	}
+
+	now := cm.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	units := cm.Units
+	if units <= 0 {
+		units = time.Millisecond
+	}
+
+	var (
+		lock  sync.Mutex
+		conns = make(map[net.Conn]*connection)
+	)
+
+	return func(c net.Conn, cs http.ConnState) {
+		lock.Lock()
+		switch cs {
+		case http.StateNew:
+			conns[c] = &connection{start: now()}
+			lock.Unlock()
+
+		case http.StateActive:
+			if s, ok := conns[c]; ok {
+				s.requests++
+			}
+
+			lock.Unlock()
+
+		case http.StateHijacked, http.StateClosed:
+			s, ok := conns[c]
+			delete(conns, c)
+			lock.Unlock()
+
+			if ok {
+				var l xmetrics.Labels
+				l.Add(DefaultTransportLabel, Transport(c))
+
+				if cm.Lifetime != nil {
+					cm.Lifetime.Observe(&l, float64(now().Sub(s.start)/units))
+				}
+
+				if cm.Requests != nil {
+					cm.Requests.Observe(&l, float64(s.requests))
+				}
+			}
+
+		default:
+			lock.Unlock()
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package xmetricshttp
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a settable time source for ConnectionMetrics.Now
+type testClock struct {
+	current time.Time
+}
+
+func (tc *testClock) now() time.Time {
+	return tc.current
+}
+
+func (tc *testClock) add(d time.Duration) {
+	tc.current = tc.current.Add(d)
+}
+
+func TestTransport(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		c1, c2 = net.Pipe()
+	)
+
+	defer c1.Close()
+	defer c2.Close()
+
+	assert.Equal(TransportPlaintext, Transport(c1))
+	assert.Equal(TransportTLS, Transport(tls.Server(c1, new(tls.Config))))
+}
+
+func testConnectionMetricsUnconfigured(t *testing.T) {
+	assert.Nil(t, ConnectionMetrics{}.NewConnState())
+}
+
+func testConnectionMetricsClosed(t *testing.T, final http.ConnState) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lifetime = new(testMetric)
+		requests = new(testMetric)
+		clock    = &testClock{current: time.Now()}
+
+		connState = ConnectionMetrics{
+			Lifetime: lifetime,
+			Requests: requests,
+			Now:      clock.now,
+		}.NewConnState()
+
+		c1, c2 = net.Pipe()
+	)
+
+	require.NotNil(connState)
+	defer c1.Close()
+	defer c2.Close()
+
+	connState(c1, http.StateNew)
+	for i := 0; i < 3; i++ {
+		connState(c1, http.StateActive)
+		connState(c1, http.StateIdle)
+	}
+
+	// nothing is observed until the connection is finished
+	assert.Empty(lifetime.values)
+	assert.Empty(requests.values)
+
+	clock.add(1500 * time.Millisecond)
+	connState(c1, final)
+
+	assert.Equal([]float64{1500}, lifetime.values)
+	assert.Equal([]float64{3}, requests.values)
+	assert.Equal([]map[string]string{{DefaultTransportLabel: TransportPlaintext}}, lifetime.labels)
+	assert.Equal([]map[string]string{{DefaultTransportLabel: TransportPlaintext}}, requests.labels)
+
+	// a connection is only observed once
+	connState(c1, http.StateClosed)
+	assert.Len(lifetime.values, 1)
+	assert.Len(requests.values, 1)
+}
+
+func testConnectionMetricsUnits(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lifetime = new(testMetric)
+		clock    = &testClock{current: time.Now()}
+
+		connState = ConnectionMetrics{
+			Lifetime: lifetime,
+			Now:      clock.now,
+			Units:    time.Second,
+		}.NewConnState()
+
+		c1, c2  = net.Pipe()
+		tlsConn = tls.Server(c1, new(tls.Config))
+	)
+
+	require.NotNil(connState)
+	defer c1.Close()
+	defer c2.Close()
+
+	connState(tlsConn, http.StateNew)
+	clock.add(90 * time.Second)
+	connState(tlsConn, http.StateClosed)
+
+	assert.Equal([]float64{90}, lifetime.values)
+	assert.Equal([]map[string]string{{DefaultTransportLabel: TransportTLS}}, lifetime.labels)
+}
+
+func testConnectionMetricsUnknown(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		requests  = new(testMetric)
+		connState = ConnectionMetrics{Requests: requests}.NewConnState()
+
+		c1, c2 = net.Pipe()
+	)
+
+	require.NotNil(connState)
+	defer c1.Close()
+	defer c2.Close()
+
+	// connections that were never reported as new are ignored
+	connState(c1, http.StateActive)
+	connState(c1, http.StateClosed)
+	assert.Empty(requests.values)
+}
+
+func TestConnectionMetrics(t *testing.T) {
+	t.Run("Unconfigured", testConnectionMetricsUnconfigured)
+	t.Run("Closed", func(t *testing.T) {
+		testConnectionMetricsClosed(t, http.StateClosed)
+	})
+
+	t.Run("Hijacked", func(t *testing.T) {
+		testConnectionMetricsClosed(t, http.StateHijacked)
+	})
+
+	t.Run("Units", testConnectionMetricsUnits)
+	t.Run("Unknown", testConnectionMetricsUnknown)
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/xmidt-org/themis/xmetrics"
 )
 
-// HandlerCounter provides a simple count metric of HTTP transactions
+// HandlerCounter provides a simple count metric of HTTP transactions.  If the http.ResponseWriter does not
+// implement StatusCoder, e.g. because xhttpserver's tracking is disabled, a minimal decorator captures the status.
 type HandlerCounter struct {
 	Metric   xmetrics.Adder
 	Labeller ServerLabeller
@@ -25,6 +26,7 @@ func (ihc HandlerCounter) Then(next http.Handler) http.Handler {
 	}
 
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response = captureStatus(response)
 		next.ServeHTTP(response, request)
 		var l xmetrics.Labels
 		labeller.ServerLabels(response, request, &l)
@@ -32,7 +34,8 @@ func (ihc HandlerCounter) Then(next http.Handler) http.Handler {
 	})
 }
 
-// HandlerDuration provides request duration metrics
+// HandlerDuration provides request duration metrics.  As with HandlerCounter, the response status is captured
+// even when the http.ResponseWriter does not implement StatusCoder.
 type HandlerDuration struct {
 	Metric   xmetrics.Observer
 	Labeller ServerLabeller
@@ -66,6 +69,7 @@ func (ihd HandlerDuration) Then(next http.Handler) http.Handler {
 	}
 
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response = captureStatus(response)
 		start := now()
 		next.ServeHTTP(response, request)
 		var l xmetrics.Labels
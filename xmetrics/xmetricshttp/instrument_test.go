@@ -0,0 +1,100 @@
+package xmetricshttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMetric is both an xmetrics.Adder and an xmetrics.Observer that records each value with its labels
+type testMetric struct {
+	labels []map[string]string
+	values []float64
+}
+
+func (tm *testMetric) record(l *xmetrics.Labels, v float64) {
+	tm.labels = append(tm.labels, l.Labels())
+	tm.values = append(tm.values, v)
+}
+
+func (tm *testMetric) Add(l *xmetrics.Labels, v float64) {
+	tm.record(l, v)
+}
+
+func (tm *testMetric) Observe(l *xmetrics.Labels, v float64) {
+	tm.record(l, v)
+}
+
+func TestHandlerCounter(t *testing.T) {
+	testData := []struct {
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{
+			handler: func(response http.ResponseWriter, _ *http.Request) {
+				response.WriteHeader(http.StatusNotFound)
+			},
+			expected: "404",
+		},
+		{
+			handler: func(response http.ResponseWriter, _ *http.Request) {
+				response.Write([]byte("implicit OK"))
+			},
+			expected: "200",
+		},
+		{
+			handler:  func(http.ResponseWriter, *http.Request) {},
+			expected: "200",
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.expected, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				metric  = new(testMetric)
+				counter = HandlerCounter{
+					Metric:   metric,
+					Labeller: NewServerLabellers(CodeLabeller{}, MethodLabeller{}),
+				}.Then(record.handler)
+			)
+
+			// a plain recorder implements neither StatusCoder nor BytesWriter
+			counter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			assert.Equal(
+				[]map[string]string{{DefaultCodeLabel: record.expected, DefaultMethodLabel: "GET"}},
+				metric.labels,
+			)
+
+			assert.Equal([]float64{1.0}, metric.values)
+		})
+	}
+}
+
+func TestHandlerDuration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		current = time.Now()
+		metric  = new(testMetric)
+
+		duration = HandlerDuration{
+			Metric:   metric,
+			Labeller: CodeLabeller{},
+			Now:      func() time.Time { return current },
+		}.Then(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			current = current.Add(250 * time.Millisecond)
+			response.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	)
+
+	duration.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal([]map[string]string{{DefaultCodeLabel: "503"}}, metric.labels)
+	assert.Equal([]float64{250.0}, metric.values)
+}
@@ -5,16 +5,21 @@ import (
 	"strconv"
 
 	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/gorilla/mux"
 )
 
 const (
 	DefaultCodeLabel   = "code"
 	DefaultMethodLabel = "method"
+	DefaultRouteLabel  = "route"
 	DefaultOther       = "other"
 )
 
 // StatusCoder is expected to be implemented by http.ResponseWriters that participate in metrics.
-// Decorating the http.ResponseWriter is left to other packages.
+// Decorating the http.ResponseWriter is normally left to other packages, e.g. xhttpserver's tracking writer.
+// If the writer does not implement this interface, the server decorators in this package capture the
+// status themselves.
 type StatusCoder interface {
 	StatusCode() int
 }
@@ -139,6 +144,7 @@ func (el EmptyLabeller) ClientLabels(*http.Response, *http.Request, *xmetrics.La
 
 // CodeLabeller provides both ServerLabeller and ClientLabeller functionality for HTTP response codes.
 // For servers, the http.ResponseWriter must implement the StatusCode interface, or this labeller will panic.
+// The decorators in this package, e.g. HandlerCounter, ensure that it does.
 type CodeLabeller struct {
 	// Name is the name of the label to apply.  If unset, DefaultCodeLabel is used.
 	Name string
@@ -226,3 +232,45 @@ func (ml MethodLabeller) ServerLabels(_ http.ResponseWriter, request *http.Reque
 func (ml MethodLabeller) ClientLabels(_ *http.Response, request *http.Request, l *xmetrics.Labels) {
 	ml.labels(request, l)
 }
+
+// RouteLabeller provides server labelling for the path template of the gorilla/mux route that matched a request,
+// e.g. /devices/{id}.  Templates have a far lower cardinality than raw paths.  Since the matched route is only known
+// once a router has matched the request, server decorators that use this labeller must be installed as router
+// middleware, e.g. via mux.Router.Use.  Requests without a matched route, or whose route has no path template,
+// use the Other value.
+type RouteLabeller struct {
+	// Name is the name of the label to apply.  If unset, DefaultRouteLabel is used.
+	Name string
+
+	// Other is the value used for requests without a route template.  If unset, DefaultOther is used.
+	Other string
+}
+
+func (rl RouteLabeller) name() string {
+	if len(rl.Name) > 0 {
+		return rl.Name
+	}
+
+	return DefaultRouteLabel
+}
+
+func (rl RouteLabeller) LabelNames() []string {
+	return []string{rl.name()}
+}
+
+func (rl RouteLabeller) ServerLabels(_ http.ResponseWriter, request *http.Request, l *xmetrics.Labels) {
+	var value string
+	if route := mux.CurrentRoute(request); route != nil {
+		value, _ = route.GetPathTemplate()
+	}
+
+	if len(value) == 0 {
+		value = rl.Other
+	}
+
+	if len(value) == 0 {
+		value = DefaultOther
+	}
+
+	l.Add(rl.name(), value)
+}
@@ -0,0 +1,120 @@
+package xmetricshttp
+
+import (
+	"net/http"
+
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+)
+
+const (
+	DefaultStatusClassLabel = "class"
+)
+
+var statusClasses = [...]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// StatusClass returns the class of an HTTP status code, e.g. 2xx for 204.  Codes outside of the
+// standard classes produce DefaultOther.
+func StatusClass(code int) string {
+	if code >= 100 && code < 600 {
+		return statusClasses[code/100-1]
+	}
+
+	return DefaultOther
+}
+
+// StatusClassLabeller provides both ServerLabeller and ClientLabeller functionality for the class of HTTP response
+// codes, e.g. 2xx.  This has a far lower cardinality than CodeLabeller.  For servers, the http.ResponseWriter must
+// implement the StatusCoder interface, or this labeller will panic.
+type StatusClassLabeller struct {
+	// Name is the name of the label to apply.  If unset, DefaultStatusClassLabel is used.
+	Name string
+}
+
+func (scl StatusClassLabeller) name() string {
+	if len(scl.Name) > 0 {
+		return scl.Name
+	}
+
+	return DefaultStatusClassLabel
+}
+
+func (scl StatusClassLabeller) LabelNames() []string {
+	return []string{scl.name()}
+}
+
+func (scl StatusClassLabeller) ServerLabels(response http.ResponseWriter, _ *http.Request, l *xmetrics.Labels) {
+	l.Add(scl.name(), StatusClass(response.(StatusCoder).StatusCode()))
+}
+
+func (scl StatusClassLabeller) ClientLabels(response *http.Response, _ *http.Request, l *xmetrics.Labels) {
+	l.Add(scl.name(), StatusClass(response.StatusCode))
+}
+
+// StatusClassCounter is a lightweight alternative to HandlerCounter that counts responses by only the request method
+// and the class of the response code.  This is suitable for simple SLO dashboards on high traffic servers where
+// per-code or per-path labels are too costly.
+//
+// This type is a prometheus.Collector.  Use a separate instance, with a distinguishing constant label, for
+// each server.  The status is taken from the http.ResponseWriter if it implements StatusCoder, as is the case
+// with the tracking writer installed by xhttpserver.  Otherwise, a minimal decorator captures the status.
+type StatusClassCounter struct {
+	counter  *prometheus.CounterVec
+	labeller *ServerLabellers
+}
+
+// newStatusClassCounter wraps a counter that has the method and status class labels
+func newStatusClassCounter(c *prometheus.CounterVec) *StatusClassCounter {
+	return &StatusClassCounter{
+		counter:  c,
+		labeller: NewServerLabellers(MethodLabeller{}, StatusClassLabeller{}),
+	}
+}
+
+// statusClassLabelNames are the label names, in order, of a StatusClassCounter's metric
+func statusClassLabelNames() []string {
+	return []string{DefaultMethodLabel, DefaultStatusClassLabel}
+}
+
+// NewStatusClassCounter creates an unregistered StatusClassCounter.  The returned instance must be registered,
+// e.g. with prometheus.Register, before its counts are exposed.
+func NewStatusClassCounter(o prometheus.CounterOpts) *StatusClassCounter {
+	return newStatusClassCounter(prometheus.NewCounterVec(o, statusClassLabelNames()))
+}
+
+func (scc *StatusClassCounter) Describe(ch chan<- *prometheus.Desc) {
+	scc.counter.Describe(ch)
+}
+
+func (scc *StatusClassCounter) Collect(ch chan<- prometheus.Metric) {
+	scc.counter.Collect(ch)
+}
+
+// Then is an Alice-style decorator that counts each response served by the given handler
+func (scc *StatusClassCounter) Then(next http.Handler) http.Handler {
+	metric := xmetrics.LabelledCounterVec{CounterVec: scc.counter}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response = captureStatus(response)
+		next.ServeHTTP(response, request)
+		var l xmetrics.Labels
+		scc.labeller.ServerLabels(response, request, &l)
+		metric.Add(&l, 1.0)
+	})
+}
+
+// ProvideStatusClassCounter provides a StatusClassCounter, created and registered via the xmetrics.Factory
+func ProvideStatusClassCounter(o prometheus.CounterOpts) fx.Annotated {
+	return fx.Annotated{
+		Name: o.Name,
+		Target: func(f xmetrics.Factory) (*StatusClassCounter, error) {
+			c, err := f.NewCounterVec(o, statusClassLabelNames())
+			if err != nil {
+				return nil, err
+			}
+
+			return newStatusClassCounter(c), nil
+		},
+	}
+}
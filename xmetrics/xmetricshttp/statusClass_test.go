@@ -0,0 +1,159 @@
+package xmetricshttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/xmidt-org/themis/xmetrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusClass(t *testing.T) {
+	testData := []struct {
+		code     int
+		expected string
+	}{
+		{code: 99, expected: DefaultOther},
+		{code: 100, expected: "1xx"},
+		{code: 200, expected: "2xx"},
+		{code: 204, expected: "2xx"},
+		{code: 301, expected: "3xx"},
+		{code: 404, expected: "4xx"},
+		{code: 499, expected: "4xx"},
+		{code: 500, expected: "5xx"},
+		{code: 599, expected: "5xx"},
+		{code: 600, expected: DefaultOther},
+		{code: -1, expected: DefaultOther},
+	}
+
+	for _, record := range testData {
+		t.Run(strconv.Itoa(record.code), func(t *testing.T) {
+			assert.Equal(t, record.expected, StatusClass(record.code))
+		})
+	}
+}
+
+func testStatusClassLabellerDefault(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		scl = StatusClassLabeller{}
+		l   xmetrics.Labels
+	)
+
+	assert.Equal([]string{DefaultStatusClassLabel}, scl.LabelNames())
+	scl.ServerLabels(trackedStatus(503), httptest.NewRequest("GET", "/", nil), &l)
+	assert.Equal(map[string]string{DefaultStatusClassLabel: "5xx"}, l.Labels())
+}
+
+func testStatusClassLabellerCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		scl = StatusClassLabeller{Name: "custom"}
+		l   xmetrics.Labels
+	)
+
+	assert.Equal([]string{"custom"}, scl.LabelNames())
+	scl.ClientLabels(&http.Response{StatusCode: 404}, httptest.NewRequest("GET", "/", nil), &l)
+	assert.Equal(map[string]string{"custom": "4xx"}, l.Labels())
+}
+
+func TestStatusClassLabeller(t *testing.T) {
+	t.Run("Default", testStatusClassLabellerDefault)
+	t.Run("Custom", testStatusClassLabellerCustom)
+}
+
+// trackedStatus returns a trackedWriter whose status is already set to the given code
+func trackedStatus(code int) trackedWriter {
+	recorder := httptest.NewRecorder()
+	recorder.Code = code
+	return trackedWriter{ResponseRecorder: recorder}
+}
+
+// statusClassCount returns the number of responses a StatusClassCounter has counted for a method and class
+func statusClassCount(t *testing.T, scc *StatusClassCounter, method, class string) float64 {
+	var m dto.Metric
+	require.NoError(t, scc.counter.WithLabelValues(method, class).Write(&m))
+	return m.GetCounter().GetValue()
+}
+
+func testStatusClassCounterThen(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		scc     = NewStatusClassCounter(prometheus.CounterOpts{Name: "responses"})
+		handler = scc.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if code, err := strconv.Atoi(request.URL.Query().Get("code")); err == nil {
+				response.WriteHeader(code)
+			}
+		}))
+	)
+
+	// a plain recorder does not implement StatusCoder, and an unwritten status is an implicit 200
+	for _, code := range []string{"", "204", "404", "404", "503"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?code="+code, nil))
+	}
+
+	handler.ServeHTTP(trackedStatus(500), httptest.NewRequest("POST", "/", nil))
+
+	assert.Equal(2.0, statusClassCount(t, scc, "GET", "2xx"))
+	assert.Equal(2.0, statusClassCount(t, scc, "GET", "4xx"))
+	assert.Equal(1.0, statusClassCount(t, scc, "GET", "5xx"))
+	assert.Equal(1.0, statusClassCount(t, scc, "POST", "5xx"))
+	assert.Zero(statusClassCount(t, scc, "POST", "2xx"))
+}
+
+func testStatusClassCounterCollector(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		registry = prometheus.NewPedanticRegistry()
+		scc      = NewStatusClassCounter(prometheus.CounterOpts{Name: "responses", Help: "responses"})
+	)
+
+	require.NoError(registry.Register(scc))
+	scc.Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	families, err := registry.Gather()
+	require.NoError(err)
+	require.Len(families, 1)
+	assert.Equal("responses", families[0].GetName())
+	require.Len(families[0].GetMetric(), 1)
+	assert.Equal(1.0, families[0].GetMetric()[0].GetCounter().GetValue())
+}
+
+func testStatusClassCounterProvide(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	r, err := xmetrics.New(xmetrics.Options{DisableGoCollector: true, DisableProcessCollector: true})
+	require.NoError(err)
+
+	target := ProvideStatusClassCounter(prometheus.CounterOpts{Name: "responses", Help: "responses"}).Target
+	scc, err := target.(func(xmetrics.Factory) (*StatusClassCounter, error))(r)
+	require.NoError(err)
+	require.NotNil(scc)
+
+	scc.Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(1.0, statusClassCount(t, scc, "GET", "4xx"))
+
+	// the same metric cannot be registered twice
+	_, err = target.(func(xmetrics.Factory) (*StatusClassCounter, error))(r)
+	assert.Error(err)
+}
+
+func TestStatusClassCounter(t *testing.T) {
+	t.Run("Then", testStatusClassCounterThen)
+	t.Run("Collector", testStatusClassCounterCollector)
+	t.Run("Provide", testStatusClassCounterProvide)
+}
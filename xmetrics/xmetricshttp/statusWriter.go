@@ -0,0 +1,89 @@
+package xmetricshttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// statusWriter is a minimal http.ResponseWriter decorator that captures the response status and body size.
+// It is only used when the server's chain does not already supply a writer that implements both StatusCoder
+// and BytesWriter, e.g. when xhttpserver's tracking is disabled.
+type statusWriter struct {
+	next         http.ResponseWriter
+	statusCode   int
+	bytesWritten int
+}
+
+// captureStatus returns a response writer that implements both StatusCoder and BytesWriter.  If the given
+// writer already does so, it is returned as is.  Otherwise, it is decorated with a statusWriter.  This keeps
+// metrics accurate regardless of how, or whether, other packages decorate the response.
+func captureStatus(response http.ResponseWriter) http.ResponseWriter {
+	if _, ok := response.(StatusCoder); ok {
+		if _, ok := response.(BytesWriter); ok {
+			return response
+		}
+	}
+
+	return &statusWriter{next: response}
+}
+
+func (sw *statusWriter) StatusCode() int {
+	if sw.statusCode > 0 {
+		return sw.statusCode
+	}
+
+	return http.StatusOK
+}
+
+func (sw *statusWriter) BytesWritten() int {
+	return sw.bytesWritten
+}
+
+// Unwrap returns the decorated http.ResponseWriter, for http.ResponseController
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.next
+}
+
+func (sw *statusWriter) Header() http.Header {
+	return sw.next.Header()
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	c, err := sw.next.Write(b)
+	if c > 0 {
+		sw.bytesWritten += c
+	}
+
+	return c, err
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.statusCode <= 0 {
+		sw.statusCode = statusCode
+	}
+
+	sw.next.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.next.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := sw.next.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, http.ErrNotSupported
+}
+
+func (sw *statusWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := sw.next.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
@@ -0,0 +1,130 @@
+package xmetricshttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trackedWriter is an http.ResponseWriter that already implements StatusCoder and BytesWriter
+type trackedWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (tw trackedWriter) StatusCode() int {
+	return tw.Code
+}
+
+func (tw trackedWriter) BytesWritten() int {
+	return tw.Body.Len()
+}
+
+// pusherWriter is an http.ResponseWriter that records pushed targets
+type pusherWriter struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (pw *pusherWriter) Push(target string, _ *http.PushOptions) error {
+	pw.pushed = append(pw.pushed, target)
+	return nil
+}
+
+func testCaptureStatusAlreadyTracked(t *testing.T) {
+	response := trackedWriter{ResponseRecorder: httptest.NewRecorder()}
+	assert.Equal(t, response, captureStatus(response))
+}
+
+func testCaptureStatusDefaults(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		recorder = httptest.NewRecorder()
+		response = captureStatus(recorder)
+	)
+
+	require.IsType((*statusWriter)(nil), response)
+	assert.Equal(recorder, response.(*statusWriter).Unwrap())
+	assert.Equal(http.StatusOK, response.(StatusCoder).StatusCode())
+	assert.Zero(response.(BytesWriter).BytesWritten())
+}
+
+func testCaptureStatusWrites(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		recorder = httptest.NewRecorder()
+		response = captureStatus(recorder)
+	)
+
+	response.Header().Set("Content-Type", "text/plain")
+	response.WriteHeader(http.StatusNotFound)
+	response.WriteHeader(http.StatusInternalServerError)
+	response.Write([]byte("not "))
+	response.Write([]byte("found"))
+	response.(http.Flusher).Flush()
+
+	assert.Equal(http.StatusNotFound, response.(StatusCoder).StatusCode())
+	assert.Equal(9, response.(BytesWriter).BytesWritten())
+	assert.Equal(http.StatusNotFound, recorder.Code)
+	assert.Equal("not found", recorder.Body.String())
+	assert.Equal("text/plain", recorder.Header().Get("Content-Type"))
+	assert.True(recorder.Flushed)
+}
+
+func testCaptureStatusImplicitOK(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		response = captureStatus(httptest.NewRecorder())
+	)
+
+	response.Write([]byte("ok"))
+	assert.Equal(http.StatusOK, response.(StatusCoder).StatusCode())
+	assert.Equal(2, response.(BytesWriter).BytesWritten())
+}
+
+func testCaptureStatusHijack(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		response = captureStatus(httptest.NewRecorder())
+	)
+
+	c, rw, err := response.(http.Hijacker).Hijack()
+	assert.Nil(c)
+	assert.Nil(rw)
+	assert.Equal(http.ErrNotSupported, err)
+}
+
+func testCaptureStatusPush(t *testing.T) {
+	t.Run("Supported", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			pusher   = &pusherWriter{ResponseRecorder: httptest.NewRecorder()}
+			response = captureStatus(pusher)
+		)
+
+		assert.NoError(response.(http.Pusher).Push("/resource", nil))
+		assert.Equal([]string{"/resource"}, pusher.pushed)
+	})
+
+	t.Run("NotSupported", func(t *testing.T) {
+		response := captureStatus(httptest.NewRecorder())
+		assert.Equal(t, http.ErrNotSupported, response.(http.Pusher).Push("/resource", nil))
+	})
+}
+
+func TestCaptureStatus(t *testing.T) {
+	t.Run("AlreadyTracked", testCaptureStatusAlreadyTracked)
+	t.Run("Defaults", testCaptureStatusDefaults)
+	t.Run("Writes", testCaptureStatusWrites)
+	t.Run("ImplicitOK", testCaptureStatusImplicitOK)
+	t.Run("Hijack", testCaptureStatusHijack)
+	t.Run("Push", testCaptureStatusPush)
+}